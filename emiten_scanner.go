@@ -1,47 +1,110 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"html"
+	"io"
+	"log"
 	"math"
 	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"text/template"
 	"time"
 )
 
 type Emiten struct {
-	Symbol        string
-	Name          string
-	Sector        string
-	Price         float64
-	Open          float64
-	High          float64
-	Low           float64
-	PrevClose     float64
-	Change        float64
-	Volume        int64
-	AvgVolume     int64
-	RSI           float64
-	MACD          float64
-	GapPercent    float64
-	Volatility    float64
-	MorningMoment float64
-	AfternoonDip  float64
-	ScoreBSJP     float64
-	ScoreBPJS     float64
+	Symbol        string  `json:"symbol"`
+	Name          string  `json:"name"`
+	Sector        string  `json:"sector"`
+	Price         float64 `json:"price"`
+	Open          float64 `json:"open"`
+	High          float64 `json:"high"`
+	Low           float64 `json:"low"`
+	PrevClose     float64 `json:"prev_close"`
+	Change        float64 `json:"change"`
+	Volume        int64   `json:"volume"`
+	AvgVolume     int64   `json:"avg_volume"`
+	RSI           float64 `json:"rsi"`
+	MACD          float64 `json:"macd"`
+	GapPercent    float64 `json:"gap_percent"`
+	Volatility    float64 `json:"volatility"`
+	MorningMoment float64 `json:"morning_moment"`
+	AfternoonDip  float64 `json:"afternoon_dip"`
+	Change5D      float64 `json:"change_5d"`
+	Change20D     float64 `json:"change_20d"`
+	NetForeign    float64 `json:"net_foreign"`
+	SectorBonus   float64 `json:"sector_bonus"`
+	ScoreBSJP     float64 `json:"score_bsjp"`
+	ScoreBPJS     float64 `json:"score_bpjs"`
+	History       []Bar   `json:"-"`
+
+	DividendRuns []DividendRun `json:"dividend_runs,omitempty"`
+	Fundamental  *Fundamentals `json:"fundamental,omitempty"`
+}
+
+type IndexData struct {
+	Change1D  float64 `json:"change_1d"`
+	Change5D  float64 `json:"change_5d"`
+	Change20D float64 `json:"change_20d"`
+}
+
+type SectorStat struct {
+	Sector     string  `json:"sector"`
+	Count      int     `json:"count"`
+	AvgChange  float64 `json:"avg_change"`
+	Advancers  int     `json:"advancers"`
+	Decliners  int     `json:"decliners"`
+	Breadth    float64 `json:"breadth"`
+	NetForeign float64 `json:"net_foreign"`
+	RS1D       float64 `json:"rs_1d"`
+	RS5D       float64 `json:"rs_5d"`
+	RS20D      float64 `json:"rs_20d"`
+	Rotation   float64 `json:"rotation"`
+	Status     string  `json:"status"`
 }
 
 type ScanResult struct {
-	Symbol   string
-	Name     string
-	Sector   string
-	Price    float64
-	Change   float64
-	Target   float64
-	StopLoss float64
-	Score    float64
-	Signal   string
-	Reason   string
+	Symbol   string  `json:"symbol"`
+	Name     string  `json:"name"`
+	Sector   string  `json:"sector"`
+	Price    float64 `json:"price"`
+	Change   float64 `json:"change"`
+	Target   float64 `json:"target"`
+	StopLoss float64 `json:"stop_loss"`
+	Score    float64 `json:"score"`
+	Signal   string  `json:"signal"`
+	Reason   string  `json:"reason"`
+}
+
+type ScoreComponent struct {
+	Factor string  `json:"factor"`
+	Value  float64 `json:"value"`
+	Points float64 `json:"points"`
+	Max    float64 `json:"max"`
 }
 
 var emitenList = []struct {
@@ -113,519 +176,7806 @@ var emitenList = []struct {
 	{"TKIM", "Pabrik Kertas Tjiwi", "Paper"},
 }
 
-func generateEmitenData() []Emiten {
-	var emitens []Emiten
-
-	for _, e := range emitenList {
-		price := 100 + rand.Float64()*49900
-		prevClose := price * (0.95 + rand.Float64()*0.1)
-		change := (price - prevClose) / prevClose * 100
-
-		open := prevClose * (0.99 + rand.Float64()*0.02)
-		high := math.Max(open, price) * (1 + rand.Float64()*0.02)
-		low := math.Min(open, price) * (1 - rand.Float64()*0.02)
-
-		volume := int64(500000 + rand.Intn(99500000))
-		avgVolume := int64(float64(volume) * (0.7 + rand.Float64()*0.6))
-
-		rsi := 20 + rand.Float64()*60
-		macd := -2 + rand.Float64()*4
-		gap := -2 + rand.Float64()*4
-		volatility := 1 + rand.Float64()*4
-
-		morningMom := rand.Float64() * 100
-		afternoonDip := rand.Float64() * 100
-
-		scoreBSJP := calculateBSJP(rsi, change, volatility, gap, afternoonDip, volume, avgVolume)
-		scoreBPJS := calculateBPJS(rsi, change, volatility, morningMom, volume, avgVolume)
-
-		emitens = append(emitens, Emiten{
-			Symbol:        e.symbol,
-			Name:          e.name,
-			Sector:        e.sector,
-			Price:         price,
-			Open:          open,
-			High:          high,
-			Low:           low,
-			PrevClose:     prevClose,
-			Change:        change,
-			Volume:        volume,
-			AvgVolume:     avgVolume,
-			RSI:           rsi,
-			MACD:          macd,
-			GapPercent:    gap,
-			Volatility:    volatility,
-			MorningMoment: morningMom,
-			AfternoonDip:  afternoonDip,
-			ScoreBSJP:     scoreBSJP,
-			ScoreBPJS:     scoreBPJS,
-		})
-	}
+type marketSimulator struct {
+	rng *rand.Rand
+}
 
-	return emitens
+type simSymbol struct {
+	beta     float64
+	loading  float64
+	vol      float64
+	variance float64
+	baseVol  float64
+	price    float64
 }
 
-func calculateBSJP(rsi, change, volatility, gap, afternoonDip float64, vol, avgVol int64) float64 {
-	score := 0.0
+type simSector struct {
+	drift float64
+	flow  float64
+}
 
-	if rsi < 35 {
-		score += 20
-	} else if rsi < 45 {
-		score += 12
-	}
+const (
+	garchAlpha     = 0.10
+	garchBeta      = 0.85
+	marketVol      = 0.9
+	sectorVol      = 0.6
+	overnightShare = 0.35
+)
 
-	if change > -3 && change < -0.5 {
-		score += 18
-	} else if change > -5 && change < 0 {
-		score += 10
-	}
+func newMarketSimulator(seed int64) *marketSimulator {
+	return &marketSimulator{rng: rand.New(rand.NewSource(seed))}
+}
 
-	if volatility > 2 && volatility < 4 {
-		score += 15
+func tickSize(price float64) float64 {
+	switch {
+	case price < 200:
+		return 1
+	case price < 500:
+		return 2
+	case price < 2000:
+		return 5
+	case price < 5000:
+		return 10
 	}
+	return 25
+}
 
-	if gap > 0.5 {
-		score += 15
-	} else if gap > 0 {
-		score += 8
-	}
+func roundTick(price float64) float64 {
+	tick := tickSize(price)
+	return math.Max(50, math.Round(price/tick)*tick)
+}
 
-	if afternoonDip > 60 {
-		score += 17
-	} else if afternoonDip > 40 {
-		score += 10
+func autoRejectLimit(prevClose float64) float64 {
+	switch {
+	case prevClose <= 200:
+		return 0.35
+	case prevClose <= 5000:
+		return 0.25
 	}
+	return 0.20
+}
 
-	if vol > avgVol {
-		score += 15
-	}
+func priceLimits(prevClose float64) (lower, upper float64) {
+	limit := autoRejectLimit(prevClose)
+	upTick, downTick := tickSize(prevClose*(1+limit)), tickSize(prevClose*(1-limit))
+	upper = math.Floor(prevClose*(1+limit)/upTick) * upTick
+	lower = math.Max(50, math.Ceil(prevClose*(1-limit)/downTick)*downTick)
+	return lower, upper
+}
 
-	return math.Min(100, score)
+func (s *marketSimulator) garch(variance, shock, longRun float64) float64 {
+	return longRun*(1-garchAlpha-garchBeta) + garchAlpha*shock*shock + garchBeta*variance
 }
 
-func calculateBPJS(rsi, change, volatility, morningMom float64, vol, avgVol int64) float64 {
-	score := 0.0
+func (s *marketSimulator) simulate(asOf time.Time, days int) (IndexData, []btSeries) {
+	rng := s.rng
+	dates := tradingDaysBack(asOf, days)
 
-	if rsi > 55 && rsi < 70 {
-		score += 18
-	} else if rsi > 45 {
-		score += 10
+	sectors := make(map[string]*simSector)
+	var sectorNames []string
+	symbols := make([]*simSymbol, len(emitenList))
+	series := make([]btSeries, len(emitenList))
+	for i, e := range emitenList {
+		if sectors[e.sector] == nil {
+			sectors[e.sector] = &simSector{drift: rng.NormFloat64() * 0.08}
+			sectorNames = append(sectorNames, e.sector)
+		}
+		vol := 1 + rng.Float64()*2
+		symbols[i] = &simSymbol{
+			beta:     0.6 + rng.Float64()*0.8,
+			loading:  0.5 + rng.Float64()*0.5,
+			vol:      vol,
+			variance: vol * vol,
+			baseVol:  math.Exp(math.Log(5e5) + rng.Float64()*(math.Log(1e8)-math.Log(5e5))),
+			price:    roundTick(math.Exp(math.Log(100) + rng.Float64()*(math.Log(50000)-math.Log(100)))),
+		}
+		series[i] = btSeries{Symbol: e.symbol, Name: e.name, Sector: e.sector, Bars: make([]Bar, 0, days)}
 	}
 
-	if change > 0.5 && change < 3 {
-		score += 20
-	} else if change > 0 {
-		score += 12
-	}
+	index := make([]float64, days)
+	level := 7000.0
+	mktVariance := marketVol * marketVol
 
-	if volatility > 1.5 && volatility < 3 {
-		score += 15
-	}
+	for t := 0; t < days; t++ {
+		mktShock := rng.NormFloat64() * math.Sqrt(mktVariance)
+		mktVariance = s.garch(mktVariance, mktShock, marketVol*marketVol)
 
-	if morningMom > 60 {
-		score += 20
-	} else if morningMom > 40 {
-		score += 12
-	}
+		sectorShock := make(map[string]float64)
+		for _, name := range sectorNames {
+			sec := sectors[name]
+			sectorShock[name] = sec.drift + rng.NormFloat64()*sectorVol
+			sec.flow = sec.flow*0.8 + rng.NormFloat64()*0.05
+			if rng.Float64() < 0.02 {
+				sec.drift = rng.NormFloat64() * 0.08
+			}
+		}
 
-	if vol > avgVol*12/10 {
-		score += 15
-	}
+		sum := 0.0
+		for i, e := range emitenList {
+			sym, sec := symbols[i], sectors[e.sector]
 
-	return math.Min(100, score)
-}
+			idio := rng.NormFloat64() * math.Sqrt(sym.variance)
+			sym.variance = s.garch(sym.variance, idio, sym.vol*sym.vol)
+			ret := (sym.beta*mktShock + sym.loading*sectorShock[e.sector] + idio) / 100
+			sigma := math.Sqrt(sym.variance+mktVariance) / 100
 
-func scanBSJP(emitens []Emiten) []ScanResult {
-	var results []ScanResult
+			prevClose := sym.price
+			lower, upper := priceLimits(prevClose)
+			clamp := func(p float64) float64 { return math.Max(lower, math.Min(upper, roundTick(p))) }
 
-	for _, e := range emitens {
-		if e.ScoreBSJP >= 45 {
-			target := e.Price * (1 + e.Volatility*0.3/100)
-			stopLoss := e.Low * 0.99
+			overnight := ret*overnightShare + rng.NormFloat64()*sigma*0.2
+			open := clamp(prevClose * math.Exp(overnight))
+			closePrice := clamp(open * math.Exp(ret-overnight))
+			high := clamp(math.Max(open, closePrice) * math.Exp(math.Abs(rng.NormFloat64())*sigma*0.4))
+			low := clamp(math.Min(open, closePrice) * math.Exp(-math.Abs(rng.NormFloat64())*sigma*0.4))
 
-			signal := "WATCH"
-			if e.ScoreBSJP >= 75 {
-				signal = "STRONG BUY"
-			} else if e.ScoreBSJP >= 60 {
-				signal = "BUY"
+			z := 0.0
+			if sigma > 0 {
+				z = math.Log(closePrice/prevClose) / sigma
 			}
+			volume := int64(sym.baseVol*math.Exp(0.4*math.Abs(z)-0.2+rng.NormFloat64()*0.25)) / sharesPerLot * sharesPerLot
+			flowShare := math.Max(-0.5, math.Min(0.5, 0.08*z+sec.flow+rng.NormFloat64()*0.08))
 
-			reason := fmt.Sprintf("RSI=%.0f, Gap=%.1f%%, Vol=%s", e.RSI, e.GapPercent, formatVol(e.Volume))
-
-			results = append(results, ScanResult{
-				Symbol:   e.Symbol,
-				Name:     e.Name,
-				Sector:   e.Sector,
-				Price:    e.Price,
-				Change:   e.Change,
-				Target:   target,
-				StopLoss: stopLoss,
-				Score:    e.ScoreBSJP,
-				Signal:   signal,
-				Reason:   reason,
+			series[i].Bars = append(series[i].Bars, Bar{
+				Date:       dates[t],
+				Open:       open,
+				High:       high,
+				Low:        low,
+				Close:      closePrice,
+				Volume:     volume,
+				NetForeign: float64(volume) * closePrice * flowShare,
 			})
+
+			sym.price = closePrice
+			sum += math.Log(closePrice / prevClose)
 		}
-	}
 
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score > results[j].Score
-	})
+		level *= math.Exp(sum / float64(len(emitenList)))
+		index[t] = level
+	}
 
-	return results
+	change := func(n int) float64 {
+		if days <= n {
+			return 0
+		}
+		return (index[days-1]/index[days-1-n] - 1) * 100
+	}
+	return IndexData{Change1D: change(1), Change5D: change(5), Change20D: change(20)}, series
 }
 
-func scanBPJS(emitens []Emiten) []ScanResult {
-	var results []ScanResult
+type ScanMeta struct {
+	Seed             int64             `json:"seed"`
+	AsOf             string            `json:"as_of"`
+	Days             int               `json:"days"`
+	Params           StrategyParams    `json:"params"`
+	Profile          string            `json:"profile,omitempty"`
+	Watchlist        string            `json:"watchlist,omitempty"`
+	Symbols          []string          `json:"symbols,omitempty"`
+	DataDir          string            `json:"data_dir,omitempty"`
+	Provider         string            `json:"provider,omitempty"`
+	CorpActions      string            `json:"corp_actions,omitempty"`
+	Fundamentals     string            `json:"fundamentals,omitempty"`
+	FundamentalRules *FundamentalRules `json:"fundamental_rules,omitempty"`
+	Live             bool              `json:"live,omitempty"`
+}
 
-	for _, e := range emitens {
-		if e.ScoreBPJS >= 45 {
-			target := e.Price * (1 + e.Volatility*0.4/100)
-			stopLoss := e.Price * 0.985
+type seedSource struct {
+	mu    sync.Mutex
+	first int64
+	fixed bool
+	rng   *rand.Rand
+}
 
-			signal := "WATCH"
-			if e.ScoreBPJS >= 75 {
-				signal = "STRONG BUY"
-			} else if e.ScoreBPJS >= 60 {
-				signal = "BUY"
-			}
+var (
+	lastScan    ScanMeta
+	staleBanner string
+)
 
-			reason := fmt.Sprintf("RSI=%.0f, Mom=%.0f%%, Vol=%s", e.RSI, e.MorningMoment, formatVol(e.Volume))
+func newSeedSource(seed int64, fixed bool) *seedSource {
+	src := &seedSource{first: seed, fixed: fixed}
+	if !fixed {
+		seed = time.Now().UnixNano()
+	}
+	src.rng = rand.New(rand.NewSource(seed))
+	return src
+}
 
-			results = append(results, ScanResult{
-				Symbol:   e.Symbol,
-				Name:     e.Name,
-				Sector:   e.Sector,
-				Price:    e.Price,
-				Change:   e.Change,
-				Target:   target,
-				StopLoss: stopLoss,
-				Score:    e.ScoreBPJS,
-				Signal:   signal,
-				Reason:   reason,
-			})
+func flagPassed(fs *flag.FlagSet, name string) bool {
+	passed := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
 		}
+	})
+	return passed
+}
+
+func (s *seedSource) next() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fixed {
+		s.fixed = false
+		return s.first
 	}
+	return s.rng.Int63()
+}
 
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score > results[j].Score
-	})
+func (s *seedSource) rand() (*rand.Rand, int64) {
+	seed := s.next()
+	return rand.New(rand.NewSource(seed)), seed
+}
 
-	return results
+func newScanMeta(seed int64, now time.Time) ScanMeta {
+	meta := ScanMeta{
+		Seed:    seed,
+		AsOf:    now.Format("2006-01-02"),
+		Days:    historyDays + btWarmup,
+		Params:  strategyParams,
+		DataDir: marketDataDir,
+	}
+	if marketProvider != nil {
+		meta.Provider = marketProvider.base
+	}
+	if corpActions != nil {
+		meta.CorpActions = corpActions.path
+	}
+	if fundamentalData != nil {
+		meta.Fundamentals = fundamentalData.path
+	}
+	if fundamentalRules != (FundamentalRules{}) {
+		rules := fundamentalRules
+		meta.FundamentalRules = &rules
+	}
+	return meta
 }
 
-func formatPrice(p float64) string {
-	if p >= 1000 {
-		return fmt.Sprintf("Rp%.0f", p)
+func (m ScanMeta) date() time.Time {
+	d, err := time.ParseInLocation("2006-01-02", m.AsOf, time.Local)
+	if err != nil {
+		return time.Now()
 	}
-	return fmt.Sprintf("Rp%.0f", p)
+	return d
 }
 
-func formatVol(v int64) string {
-	if v >= 1000000000 {
-		return fmt.Sprintf("%.1fB", float64(v)/1000000000)
-	} else if v >= 1000000 {
-		return fmt.Sprintf("%.1fM", float64(v)/1000000)
-	} else if v >= 1000 {
-		return fmt.Sprintf("%.1fK", float64(v)/1000)
+type barSource interface {
+	Symbols() ([]string, error)
+	Load(ctx context.Context, symbol string) (btSeries, error)
+}
+
+type seriesSource struct {
+	order  []string
+	series map[string]btSeries
+}
+
+type csvDirSource struct {
+	dir string
+}
+
+type symbolError struct {
+	Symbol string
+	Err    error
+}
+
+type scanProgress struct {
+	Stage  string
+	Done   int
+	Total  int
+	Failed int
+}
+
+type pipelineConfig struct {
+	Workers      int
+	Actions      *corpActionRegistry
+	Fundamentals *fundamentalStore
+	IHSG         *IndexData
+	Progress     func(scanProgress)
+}
+
+type pipelineResult struct {
+	IHSG    IndexData
+	Emitens []Emiten
+	Sectors []SectorStat
+	BSJP    []ScanResult
+	BPJS    []ScanResult
+	Failed  []symbolError
+	Stale   []symbolError
+}
+
+var (
+	marketDataDir string
+	scanWorkers   int
+)
+
+func (e symbolError) Error() string {
+	return e.Symbol + ": " + e.Err.Error()
+}
+
+func newSeriesSource(series []btSeries) *seriesSource {
+	src := &seriesSource{series: make(map[string]btSeries, len(series))}
+	for _, s := range series {
+		src.order = append(src.order, s.Symbol)
+		src.series[s.Symbol] = s
 	}
-	return fmt.Sprintf("%d", v)
+	return src
 }
 
-func getStars(score float64) string {
-	n := int(score / 20)
-	if n > 5 {
-		n = 5
+func (s *seriesSource) Symbols() ([]string, error) {
+	return s.order, nil
+}
+
+func (s *seriesSource) Load(ctx context.Context, symbol string) (btSeries, error) {
+	series, ok := s.series[symbol]
+	if !ok {
+		return series, errors.New("tidak ada data")
 	}
-	if n < 1 {
-		n = 1
+	return series, nil
+}
+
+func (s csvDirSource) Symbols() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.csv"))
+	if err != nil {
+		return nil, err
 	}
-	return strings.Repeat("*", n) + strings.Repeat(" ", 5-n)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("tidak ada file CSV di %s", s.dir)
+	}
+
+	symbols := make([]string, len(files))
+	for i, f := range files {
+		symbols[i] = strings.ToUpper(strings.TrimSuffix(filepath.Base(f), filepath.Ext(f)))
+	}
+	sort.Strings(symbols)
+	return symbols, nil
 }
 
-func printHeader() {
-	fmt.Print("\033[2J\033[H")
-	fmt.Println(strings.Repeat("=", 100))
-	fmt.Println("                          EMITEN SCANNER BSJP & BPJS")
-	fmt.Println("                        Indonesia Stock Exchange (IDX)")
-	fmt.Println(strings.Repeat("=", 100))
-	fmt.Printf(" Waktu: %s\n", time.Now().Format("02 Jan 2006 15:04:05 WIB"))
-	fmt.Println(strings.Repeat("-", 100))
+func (s csvDirSource) Load(ctx context.Context, symbol string) (btSeries, error) {
+	path := filepath.Join(s.dir, symbol+".csv")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		path = filepath.Join(s.dir, strings.ToLower(symbol)+".csv")
+	}
+	return loadSymbolCSV(path, symbol)
 }
 
-func printBSJP(results []ScanResult) {
-	fmt.Println()
-	fmt.Println("\033[1;33m                    BSJP - BELI SORE JUAL PAGI\033[0m")
-	fmt.Println(" Strategi: Beli 14:30-15:00 WIB, Jual 09:00-09:30 WIB besok")
-	fmt.Println(strings.Repeat("-", 100))
+func runWorkers(ctx context.Context, workers, n int, work func(i int) error, done func(i int, err error)) []error {
+	errs := make([]error, n)
+	jobs := make(chan int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
 
-	if len(results) == 0 {
-		fmt.Println(" Tidak ada emiten yang memenuhi kriteria BSJP saat ini.")
-		return
-	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				err := func() (err error) {
+					defer func() {
+						if r := recover(); r != nil {
+							err = fmt.Errorf("panic: %v", r)
+						}
+					}()
+					return work(i)
+				}()
 
-	fmt.Printf(" %-7s %-22s %-12s %-10s %-7s %-10s %-10s %-6s %-10s\n",
-		"KODE", "NAMA", "SEKTOR", "HARGA", "CHG%", "TARGET", "SL", "SCORE", "SIGNAL")
-	fmt.Println(strings.Repeat("-", 100))
+				mu.Lock()
+				errs[i] = err
+				if done != nil {
+					done(i, err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
 
-	count := 0
-	for _, r := range results {
-		if count >= 15 {
-			break
+dispatch:
+	for i := 0; i < n; i++ {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			for ; i < n; i++ {
+				errs[i] = ctx.Err()
+			}
+			break dispatch
 		}
+	}
+	close(jobs)
+	wg.Wait()
 
-		chgClr := "\033[32m"
-		if r.Change < 0 {
-			chgClr = "\033[31m"
-		}
+	return errs
+}
 
-		name := r.Name
-		if len(name) > 20 {
-			name = name[:20]
-		}
-		sector := r.Sector
-		if len(sector) > 10 {
-			sector = sector[:10]
+func emitenFromSeries(s btSeries) (Emiten, error) {
+	if len(s.Bars) < btWarmup+1 {
+		return Emiten{}, fmt.Errorf("hanya %d bar, butuh minimal %d", len(s.Bars), btWarmup+1)
+	}
+	for _, b := range s.Bars[len(s.Bars)-btWarmup-1:] {
+		if b.Close <= 0 || b.Open <= 0 || b.High < b.Low {
+			return Emiten{}, fmt.Errorf("bar %s tidak valid", b.Date.Format("2006-01-02"))
 		}
-
-		fmt.Printf(" %-7s %-22s %-12s %-10s %s%-6.1f%%\033[0m %-10s %-10s %-6.0f %-10s\n",
-			r.Symbol, name, sector, formatPrice(r.Price),
-			chgClr, r.Change, formatPrice(r.Target), formatPrice(r.StopLoss),
-			r.Score, r.Signal)
-		count++
 	}
 
-	fmt.Printf("\n Total emiten BSJP: %d\n", len(results))
+	closes := barCloses(s.Bars)
+	_, _, hist := macdSeries(closes)
+	last := len(s.Bars) - 1
+
+	e := emitenAt(s, last, rsiSeries(closes, 14), hist)
+	e.History = s.Bars
+	if len(s.Bars) > historyDays {
+		e.History = s.Bars[len(s.Bars)-historyDays:]
+	}
+	return e, nil
 }
 
-func printBPJS(results []ScanResult) {
-	fmt.Println()
-	fmt.Println("\033[1;36m                    BPJS - BELI PAGI JUAL SORE\033[0m")
-	fmt.Println(" Strategi: Beli 09:00-09:30 WIB, Jual 14:30-15:00 WIB hari yang sama")
-	fmt.Println(strings.Repeat("-", 100))
+func runScanPipeline(ctx context.Context, src barSource, cfg pipelineConfig) (pipelineResult, error) {
+	var res pipelineResult
 
-	if len(results) == 0 {
-		fmt.Println(" Tidak ada emiten yang memenuhi kriteria BPJS saat ini.")
-		return
+	symbols, err := src.Symbols()
+	if err != nil {
+		return res, err
+	}
+	workers := cfg.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
 	}
 
-	fmt.Printf(" %-7s %-22s %-12s %-10s %-7s %-10s %-10s %-6s %-10s\n",
-		"KODE", "NAMA", "SEKTOR", "HARGA", "CHG%", "TARGET", "SL", "SCORE", "SIGNAL")
-	fmt.Println(strings.Repeat("-", 100))
+	failed := 0
+	progress := func(stage string, total int) func(int, error) {
+		n := 0
+		return func(_ int, err error) {
+			n++
+			if err != nil {
+				failed++
+			}
+			if cfg.Progress != nil {
+				cfg.Progress(scanProgress{Stage: stage, Done: n, Total: total, Failed: failed})
+			}
+		}
+	}
 
-	count := 0
-	for _, r := range results {
-		if count >= 15 {
-			break
+	series := make([]btSeries, len(symbols))
+	stale := make([]error, len(symbols))
+	loadErrs := runWorkers(ctx, workers, len(symbols), func(i int) error {
+		var err error
+		series[i], err = src.Load(ctx, symbols[i])
+		var staleErr *staleDataError
+		if errors.As(err, &staleErr) {
+			stale[i] = staleErr
+			return nil
 		}
+		return err
+	}, progress("muat", len(symbols)))
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
 
-		chgClr := "\033[32m"
-		if r.Change < 0 {
-			chgClr = "\033[31m"
+	emitens := make([]Emiten, len(symbols))
+	failed = 0
+	errs := runWorkers(ctx, workers, len(symbols), func(i int) error {
+		if loadErrs[i] != nil {
+			return loadErrs[i]
 		}
+		var err error
+		emitens[i], err = emitenFromSeries(series[i])
+		emitens[i].DividendRuns = cfg.Actions.dividendRuns(series[i])
+		emitens[i].Fundamental = cfg.Fundamentals.metrics(emitens[i].Symbol, emitens[i].Price)
+		return err
+	}, progress("indikator", len(symbols)))
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
 
-		name := r.Name
-		if len(name) > 20 {
-			name = name[:20]
+	for i, err := range errs {
+		if err != nil {
+			res.Failed = append(res.Failed, symbolError{Symbol: symbols[i], Err: err})
+			continue
 		}
-		sector := r.Sector
-		if len(sector) > 10 {
-			sector = sector[:10]
+		if stale[i] != nil {
+			res.Stale = append(res.Stale, symbolError{Symbol: symbols[i], Err: stale[i]})
 		}
+		res.Emitens = append(res.Emitens, emitens[i])
+	}
+	if len(res.Emitens) == 0 {
+		return res, fmt.Errorf("tidak ada saham yang berhasil dimuat dari %d simbol", len(symbols))
+	}
 
-		fmt.Printf(" %-7s %-22s %-12s %-10s %s%-6.1f%%\033[0m %-10s %-10s %-6.0f %-10s\n",
-			r.Symbol, name, sector, formatPrice(r.Price),
-			chgClr, r.Change, formatPrice(r.Target), formatPrice(r.StopLoss),
-			r.Score, r.Signal)
-		count++
+	if cfg.IHSG != nil {
+		res.IHSG = *cfg.IHSG
+	} else {
+		for _, e := range res.Emitens {
+			n := float64(len(res.Emitens))
+			res.IHSG.Change1D += e.Change / n
+			res.IHSG.Change5D += e.Change5D / n
+			res.IHSG.Change20D += e.Change20D / n
+		}
 	}
+	res.Sectors = analyzeSectors(res.Emitens, res.IHSG)
 
-	fmt.Printf("\n Total emiten BPJS: %d\n", len(results))
+	bonus := sectorBonuses(res.Sectors)
+	failed = 0
+	runWorkers(ctx, workers, len(res.Emitens), func(i int) error {
+		scoreEmiten(&res.Emitens[i], bonus)
+		return nil
+	}, progress("score", len(res.Emitens)))
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		res.BSJP = scanBSJP(res.Emitens)
+	}()
+	go func() {
+		defer wg.Done()
+		res.BPJS = scanBPJS(res.Emitens)
+	}()
+	wg.Wait()
+	if cfg.Progress != nil {
+		cfg.Progress(scanProgress{Stage: "ranking", Done: 2, Total: 2})
+	}
+
+	return res, nil
 }
 
-func printAllEmiten(emitens []Emiten) {
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+type httpProvider struct {
+	base     string
+	client   *http.Client
+	limiter  *tokenBucket
+	cacheDir string
+	retries  int
+	backoff  time.Duration
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	downUntil time.Time
+	downErr   error
+}
+
+type staleDataError struct {
+	FetchedAt time.Time
+	Err       error
+}
+
+type eodCache struct {
+	ETag      string          `json:"etag"`
+	FetchedAt time.Time       `json:"fetched_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type eodBar struct {
+	Date       string  `json:"date"`
+	Open       float64 `json:"open"`
+	High       float64 `json:"high"`
+	Low        float64 `json:"low"`
+	Close      float64 `json:"close"`
+	Volume     int64   `json:"volume"`
+	NetForeign float64 `json:"net_foreign,omitempty"`
+}
+
+type eodResponse struct {
+	Symbol string   `json:"symbol"`
+	Name   string   `json:"name,omitempty"`
+	Sector string   `json:"sector,omitempty"`
+	Bars   []eodBar `json:"bars"`
+}
+
+type fakeEODServer struct {
+	mu       sync.Mutex
+	order    []string
+	series   map[string]btSeries
+	rng      *rand.Rand
+	failRate float64
+	failNext int
+	requests int
+}
+
+const (
+	dividendHorizon = 30
+	preCumDays      = 10
+)
+
+const (
+	providerRetries  = 3
+	providerBackoff  = 500 * time.Millisecond
+	providerCooldown = time.Minute
+	providerEODReady = 16*time.Hour + 15*time.Minute
+)
+
+var (
+	marketProvider      *httpProvider
+	errProviderRejected = errors.New("request ditolak provider")
+)
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (e *staleDataError) Error() string {
+	return fmt.Sprintf("memakai cache %s: %v", e.FetchedAt.In(jakartaLocation()).Format("02 Jan 2006 15:04"), e.Err)
+}
+
+func (e *staleDataError) Unwrap() error {
+	return e.Err
+}
+
+func newHTTPProvider(base string, rate float64, cacheDir string) (*httpProvider, error) {
+	u, err := url.Parse(base)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("alamat provider %q tidak valid", base)
+	}
+	if rate <= 0 {
+		return nil, errors.New("rate provider harus lebih dari 0 request/detik")
+	}
+
+	burst := int(math.Ceil(rate))
+	return &httpProvider{
+		base:     strings.TrimRight(u.String(), "/"),
+		client:   &http.Client{Timeout: 15 * time.Second},
+		limiter:  newTokenBucket(rate, burst),
+		cacheDir: filepath.Join(cacheDir, strings.NewReplacer(":", "_", "/", "_").Replace(u.Host+u.Path)),
+		retries:  providerRetries,
+		backoff:  providerBackoff,
+		cooldown: providerCooldown,
+		now:      time.Now,
+	}, nil
+}
+
+func (p *httpProvider) cachePath(name string) string {
+	return filepath.Join(p.cacheDir, name+".json")
+}
+
+func (c *eodCache) fresh(now time.Time) bool {
+	loc := jakartaLocation()
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	ready := today.Add(providerEODReady)
+	return !c.FetchedAt.Before(today) && (!c.FetchedAt.Before(ready) || now.Before(ready))
+}
+
+func (p *httpProvider) readCache(name string) *eodCache {
+	data, err := os.ReadFile(p.cachePath(name))
+	if err != nil {
+		return nil
+	}
+	var c eodCache
+	if json.Unmarshal(data, &c) != nil || len(c.Data) == 0 {
+		return nil
+	}
+	return &c
+}
+
+func (p *httpProvider) writeCache(name string, c *eodCache) {
+	data, err := json.Marshal(c)
+	if err == nil {
+		err = os.MkdirAll(p.cacheDir, 0755)
+	}
+	if err == nil {
+		err = writeFileAtomic(p.cachePath(name), data)
+	}
+	if err != nil {
+		log.Printf("cache provider %s: %v", name, err)
+	}
+}
+
+func (p *httpProvider) fetch(ctx context.Context, path, name string) ([]byte, error) {
+	now := p.now()
+	cached := p.readCache(name)
+	if cached != nil && cached.fresh(now) {
+		return cached.Data, nil
+	}
+
+	etag := ""
+	if cached != nil {
+		etag = cached.ETag
+	}
+
+	p.mu.Lock()
+	down, downErr := now.Before(p.downUntil), p.downErr
+	p.mu.Unlock()
+
+	var body []byte
+	var newTag string
+	err := fmt.Errorf("provider sedang gagal: %v", downErr)
+	if !down {
+		body, newTag, err = p.request(ctx, path, etag)
+		if err != nil && ctx.Err() == nil && !errors.Is(err, errProviderRejected) {
+			p.mu.Lock()
+			p.downUntil, p.downErr = now.Add(p.cooldown), err
+			p.mu.Unlock()
+		}
+	}
+	if err == nil {
+		if body == nil {
+			cached.FetchedAt = now
+			p.writeCache(name, cached)
+			return cached.Data, nil
+		}
+		p.writeCache(name, &eodCache{ETag: newTag, FetchedAt: now, Data: body})
+		return body, nil
+	}
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if cached != nil {
+		return cached.Data, &staleDataError{FetchedAt: cached.FetchedAt, Err: err}
+	}
+	return nil, err
+}
+
+func (p *httpProvider) request(ctx context.Context, path, etag string) ([]byte, string, error) {
+	var lastErr error
+	var retryAfter time.Duration
+
+	for attempt := 0; attempt <= p.retries; attempt++ {
+		if attempt > 0 {
+			delay := p.backoff << (attempt - 1)
+			delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+			if retryAfter > delay {
+				delay = retryAfter
+			}
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil, "", ctx.Err()
+			}
+		}
+		if err := p.limiter.wait(ctx); err != nil {
+			return nil, "", err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.base+path, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		req.Header.Set("Accept", "application/json")
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+
+		resp, err := p.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, "", ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusNotModified && etag != "":
+			return nil, etag, nil
+		case resp.StatusCode == http.StatusOK && err == nil:
+			return body, resp.Header.Get("ETag"), nil
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 || err != nil:
+			lastErr = fmt.Errorf("HTTP %d", resp.StatusCode)
+			if err != nil {
+				lastErr = err
+			}
+			retryAfter = 0
+			if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+				retryAfter = time.Duration(secs) * time.Second
+			}
+		default:
+			return nil, "", fmt.Errorf("%s: HTTP %d: %w", path, resp.StatusCode, errProviderRejected)
+		}
+	}
+
+	return nil, "", fmt.Errorf("%s: gagal setelah %d percobaan: %v", path, p.retries+1, lastErr)
+}
+
+func (p *httpProvider) Symbols() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	data, err := p.fetch(ctx, "/symbols", "_symbols")
+	var stale *staleDataError
+	if err != nil && !errors.As(err, &stale) {
+		return nil, fmt.Errorf("daftar saham dari provider: %v", err)
+	}
+
+	var list struct {
+		Symbols []string `json:"symbols"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("daftar saham dari provider: %v", err)
+	}
+	if len(list.Symbols) == 0 {
+		return nil, errors.New("provider tidak mengirim daftar saham")
+	}
+	return list.Symbols, nil
+}
+
+func (p *httpProvider) Load(ctx context.Context, symbol string) (btSeries, error) {
+	s := btSeries{Symbol: symbol, Name: symbol, Sector: "Lainnya"}
+	if !validSymbol(symbol) {
+		return s, fmt.Errorf("kode saham %q dari provider tidak valid", symbol)
+	}
+
+	data, err := p.fetch(ctx, "/eod/"+url.PathEscape(symbol), symbol)
+	var stale *staleDataError
+	if err != nil && !errors.As(err, &stale) {
+		return s, err
+	}
+
+	var resp eodResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return s, fmt.Errorf("respons provider: %v", err)
+	}
+	for _, e := range emitenList {
+		if e.symbol == symbol {
+			s.Name, s.Sector = e.name, e.sector
+		}
+	}
+	if resp.Name != "" {
+		s.Name = resp.Name
+	}
+	if resp.Sector != "" {
+		s.Sector = resp.Sector
+	}
+
+	for _, b := range resp.Bars {
+		date, err := time.Parse("2006-01-02", b.Date)
+		if err != nil {
+			return s, fmt.Errorf("tanggal %q harus YYYY-MM-DD", b.Date)
+		}
+		s.Bars = append(s.Bars, Bar{Date: date, Open: b.Open, High: b.High, Low: b.Low, Close: b.Close, Volume: b.Volume, NetForeign: b.NetForeign})
+	}
+	sort.Slice(s.Bars, func(i, j int) bool { return s.Bars[i].Date.Before(s.Bars[j].Date) })
+
+	if stale != nil {
+		return s, stale
+	}
+	return s, nil
+}
+
+func newFakeEODServer(series []btSeries, seed int64) *fakeEODServer {
+	f := &fakeEODServer{series: make(map[string]btSeries), rng: rand.New(rand.NewSource(seed))}
+	for _, s := range series {
+		f.order = append(f.order, s.Symbol)
+		f.series[s.Symbol] = s
+	}
+	return f
+}
+
+func (f *fakeEODServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests++
+	fail := f.failNext > 0 || (f.failRate > 0 && f.rng.Float64() < f.failRate)
+	if f.failNext > 0 {
+		f.failNext--
+	}
+	f.mu.Unlock()
+
+	if fail {
+		w.Header().Set("Retry-After", "0")
+		writeError(w, http.StatusServiceUnavailable, "server sibuk")
+		return
+	}
+
+	var body interface{}
+	switch {
+	case r.URL.Path == "/symbols":
+		body = map[string][]string{"symbols": f.order}
+	case strings.HasPrefix(r.URL.Path, "/eod/"):
+		s, ok := f.series[strings.ToUpper(strings.TrimPrefix(r.URL.Path, "/eod/"))]
+		if !ok {
+			writeError(w, http.StatusNotFound, "saham tidak dikenal")
+			return
+		}
+		resp := eodResponse{Symbol: s.Symbol, Name: s.Name, Sector: s.Sector}
+		for _, b := range s.Bars {
+			resp.Bars = append(resp.Bars, eodBar{Date: b.Date.Format("2006-01-02"), Open: b.Open, High: b.High, Low: b.Low,
+				Close: b.Close, Volume: b.Volume, NetForeign: b.NetForeign})
+		}
+		body = resp
+	default:
+		writeError(w, http.StatusNotFound, "endpoint tidak dikenal")
+		return
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sum := sha1.Sum(data)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func runFakeEODCommand(seeds *seedSource, args []string) error {
+	fs := flag.NewFlagSet("fake-eod", flag.ContinueOnError)
+	addr := fs.String("addr", ":9090", "alamat server EOD tiruan")
+	days := fs.Int("days", 250, "jumlah hari bar per saham")
+	failRate := fs.Float64("fail", 0, "peluang tiap request dijawab 503 (0-1) untuk menguji retry dan cache")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *days < btWarmup+1 || *failRate < 0 || *failRate > 1 {
+		return fmt.Errorf("-days minimal %d dan -fail di antara 0 dan 1", btWarmup+1)
+	}
+
+	seed := seeds.next()
+	_, series := newMarketSimulator(seed).simulate(time.Now(), *days)
+	fake := newFakeEODServer(series, seed)
+	fake.failRate = *failRate
+
+	log.Printf("server EOD tiruan di %s (seed %d, %d saham x %d hari): GET /symbols, GET /eod/{kode}", *addr, seed, len(series), *days)
+	return http.ListenAndServe(*addr, logRequests(fake))
+}
+
+type CorporateAction struct {
+	Symbol       string  `json:"symbol"`
+	Type         string  `json:"type"`
+	ExDate       string  `json:"ex_date"`
+	Ratio        float64 `json:"ratio,omitempty"`
+	Price        float64 `json:"price,omitempty"`
+	Amount       float64 `json:"amount,omitempty"`
+	AnnounceDate string  `json:"announce_date,omitempty"`
+	CumDate      string  `json:"cum_date,omitempty"`
+	PayDate      string  `json:"pay_date,omitempty"`
+}
+
+type DividendRun struct {
+	ExDate string  `json:"ex_date"`
+	Amount float64 `json:"amount"`
+	PreCum float64 `json:"pre_cum"`
+}
+
+type DividendPick struct {
+	Symbol   string          `json:"symbol"`
+	Name     string          `json:"name"`
+	Sector   string          `json:"sector"`
+	Price    float64         `json:"price"`
+	Dividend CorporateAction `json:"dividend"`
+	Yield    float64         `json:"yield"`
+	DaysLeft int             `json:"days_left"`
+	Runs     int             `json:"runs"`
+	RunsUp   int             `json:"runs_up"`
+	AvgRun   float64         `json:"avg_run"`
+}
+
+type corpActionRegistry struct {
+	path     string
+	bySymbol map[string][]CorporateAction
+}
+
+type adjustedSource struct {
+	barSource
+	actions *corpActionRegistry
+}
+
+var corpActions *corpActionRegistry
+
+var marketHolidays map[string]bool
+
+func loadCorporateActions(path string) (*corpActionRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var actions []CorporateAction
+	if err := json.Unmarshal(data, &actions); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	reg := &corpActionRegistry{path: path, bySymbol: make(map[string][]CorporateAction)}
+	for i, a := range actions {
+		a.Symbol = strings.ToUpper(strings.TrimSpace(a.Symbol))
+		if _, err := time.Parse("2006-01-02", a.ExDate); err != nil || a.Symbol == "" {
+			return nil, fmt.Errorf("%s: aksi #%d butuh symbol dan ex_date YYYY-MM-DD", path, i+1)
+		}
+		for _, d := range []string{a.AnnounceDate, a.CumDate, a.PayDate} {
+			if _, err := time.Parse("2006-01-02", d); d != "" && err != nil {
+				return nil, fmt.Errorf("%s: aksi #%d: tanggal %q harus YYYY-MM-DD", path, i+1, d)
+			}
+		}
+		if a.CumDate != "" && a.CumDate >= a.ExDate {
+			return nil, fmt.Errorf("%s: aksi #%d: cum_date harus sebelum ex_date", path, i+1)
+		}
+		switch {
+		case (a.Type == "split" || a.Type == "reverse_split") && a.Ratio > 0:
+		case a.Type == "rights" && a.Ratio > 0 && a.Price > 0:
+		case a.Type == "dividend" && a.Amount > 0:
+		default:
+			return nil, fmt.Errorf("%s: aksi #%d (%s %s) tidak valid: split/reverse_split butuh ratio, rights butuh ratio dan price, dividend butuh amount",
+				path, i+1, a.Symbol, a.Type)
+		}
+		reg.bySymbol[a.Symbol] = append(reg.bySymbol[a.Symbol], a)
+	}
+	for _, list := range reg.bySymbol {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].ExDate < list[j].ExDate })
+	}
+	return reg, nil
+}
+
+func (a CorporateAction) factors(cumPrice float64) (price, volume float64, err error) {
+	switch a.Type {
+	case "split":
+		return 1 / a.Ratio, a.Ratio, nil
+	case "reverse_split":
+		return a.Ratio, 1 / a.Ratio, nil
+	case "rights":
+		if a.Price >= cumPrice {
+			return 1, 1, nil
+		}
+		f := (cumPrice + a.Ratio*a.Price) / ((1 + a.Ratio) * cumPrice)
+		return f, 1 / f, nil
+	default:
+		if a.Amount >= cumPrice {
+			return 0, 0, fmt.Errorf("dividen Rp%.0f tidak lebih kecil dari harga cum Rp%.0f", a.Amount, cumPrice)
+		}
+		return (cumPrice - a.Amount) / cumPrice, 1, nil
+	}
+}
+
+func (r *corpActionRegistry) adjust(s btSeries) (btSeries, error) {
+	if r == nil || len(r.bySymbol[s.Symbol]) == 0 {
+		return s, nil
+	}
+
+	priceF := make([]float64, len(s.Bars))
+	volF := make([]float64, len(s.Bars))
+	for i := range priceF {
+		priceF[i], volF[i] = 1, 1
+	}
+	for _, a := range r.bySymbol[s.Symbol] {
+		ex := sort.Search(len(s.Bars), func(i int) bool { return s.Bars[i].Date.Format("2006-01-02") >= a.ExDate })
+		if ex == 0 || ex == len(s.Bars) {
+			continue
+		}
+		pf, vf, err := a.factors(s.Bars[ex-1].Close)
+		if err != nil {
+			return s, fmt.Errorf("%s %s: %v", a.Type, a.ExDate, err)
+		}
+		for i := 0; i < ex; i++ {
+			priceF[i] *= pf
+			volF[i] *= vf
+		}
+	}
+
+	adjusted := s
+	adjusted.Bars = make([]Bar, len(s.Bars))
+	for i, b := range s.Bars {
+		f := priceF[i]
+		b.Open, b.High, b.Low, b.Close = b.Open*f, b.High*f, b.Low*f, b.Close*f
+		b.Volume = int64(math.Round(float64(b.Volume) * volF[i]))
+		adjusted.Bars[i] = b
+	}
+	return adjusted, nil
+}
+
+func (r *corpActionRegistry) exDividendOn(symbol, date string) (CorporateAction, bool) {
+	if r == nil {
+		return CorporateAction{}, false
+	}
+	for _, a := range r.bySymbol[symbol] {
+		if a.Type == "dividend" && a.ExDate == date {
+			return a, true
+		}
+	}
+	return CorporateAction{}, false
+}
+
+func (s adjustedSource) Load(ctx context.Context, symbol string) (btSeries, error) {
+	series, err := s.barSource.Load(ctx, symbol)
+	var stale *staleDataError
+	if err != nil && !errors.As(err, &stale) {
+		return series, err
+	}
+	adjusted, adjErr := s.actions.adjust(series)
+	if adjErr != nil {
+		return series, fmt.Errorf("aksi korporasi: %v", adjErr)
+	}
+	return adjusted, err
+}
+
+func (a CorporateAction) cumDate() string {
+	if a.CumDate != "" {
+		return a.CumDate
+	}
+	ex, _ := time.Parse("2006-01-02", a.ExDate)
+	return tradingDaysBack(ex.AddDate(0, 0, -1), 1)[0].Format("2006-01-02")
+}
+
+func (r *corpActionRegistry) dividendRuns(s btSeries) []DividendRun {
+	if r == nil {
+		return nil
+	}
+	var runs []DividendRun
+	for _, a := range r.bySymbol[s.Symbol] {
+		if a.Type != "dividend" {
+			continue
+		}
+		cum := a.cumDate()
+		i := sort.Search(len(s.Bars), func(i int) bool { return s.Bars[i].Date.Format("2006-01-02") > cum }) - 1
+		if i < preCumDays || i+1 >= len(s.Bars) || s.Bars[i].Date.Format("2006-01-02") != cum {
+			continue
+		}
+		from := s.Bars[i-preCumDays].Close
+		runs = append(runs, DividendRun{ExDate: a.ExDate, Amount: a.Amount, PreCum: (s.Bars[i].Close - from) / from * 100})
+	}
+	return runs
+}
+
+func scanDividends(emitens []Emiten, r *corpActionRegistry, today time.Time) []DividendPick {
+	if r == nil {
+		return nil
+	}
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	horizon := today.AddDate(0, 0, dividendHorizon).Format("2006-01-02")
+
+	var picks []DividendPick
+	for _, e := range emitens {
+		if !fundamentalRules.passes(e.Fundamental) {
+			continue
+		}
+		for _, a := range r.bySymbol[e.Symbol] {
+			cum := a.cumDate()
+			if a.Type != "dividend" || cum < today.Format("2006-01-02") || cum > horizon {
+				continue
+			}
+			cumDay, _ := time.Parse("2006-01-02", cum)
+			p := DividendPick{
+				Symbol:   e.Symbol,
+				Name:     e.Name,
+				Sector:   e.Sector,
+				Price:    e.Price,
+				Dividend: a,
+				Yield:    a.Amount / e.Price * 100,
+				DaysLeft: int(cumDay.Sub(today).Hours() / 24),
+			}
+			for _, run := range e.DividendRuns {
+				p.Runs++
+				p.AvgRun += run.PreCum
+				if run.PreCum > 0 {
+					p.RunsUp++
+				}
+			}
+			if p.Runs > 0 {
+				p.AvgRun /= float64(p.Runs)
+			}
+			picks = append(picks, p)
+		}
+	}
+
+	sort.Slice(picks, func(i, j int) bool {
+		if picks[i].DaysLeft != picks[j].DaysLeft {
+			return picks[i].DaysLeft < picks[j].DaysLeft
+		}
+		return picks[i].Yield > picks[j].Yield
+	})
+	return picks
+}
+
+func shortDate(date string) string {
+	d, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return "-"
+	}
+	return d.Format("02 Jan")
+}
+
+func printDividendCalendar(picks []DividendPick) {
+	fmt.Println()
+	fmt.Println("\033[1;35m                    KALENDER DIVIDEN - CUM DATE TERDEKAT\033[0m")
+	fmt.Printf(" Cum date dalam %d hari ke depan. Beli paling lambat di cum date untuk mendapat dividen.\n", dividendHorizon)
+	fmt.Println(strings.Repeat("-", 100))
+
+	if corpActions == nil {
+		fmt.Println(" Kalender dividen kosong. Jalankan dengan -corp-actions FILE berisi aksi bertipe dividend.")
+		return
+	}
+	if len(picks) == 0 {
+		fmt.Printf(" Tidak ada cum date dalam %d hari ke depan.\n", dividendHorizon)
+		return
+	}
+
+	fmt.Printf(" %-7s %-20s %-10s %-9s %-8s %-7s %-9s %-7s %-7s %-7s %s\n",
+		"KODE", "NAMA", "HARGA", "DIVIDEN", "YIELD", "CUM", "SISA", "EX", "BAYAR", "UMUM", "RUN PRE-CUM")
+	fmt.Println(strings.Repeat("-", 100))
+
+	for _, p := range picks {
+		name := p.Name
+		if len(name) > 18 {
+			name = name[:18]
+		}
+		yieldClr := "\033[0m"
+		if p.Yield >= 5 {
+			yieldClr = "\033[1;32m"
+		} else if p.Yield >= 3 {
+			yieldClr = "\033[32m"
+		}
+		run := "-"
+		if p.Runs > 0 {
+			run = fmt.Sprintf("%s%+.1f%%\033[0m (naik %d/%d)", changeColor(p.AvgRun), p.AvgRun, p.RunsUp, p.Runs)
+		}
+		days := fmt.Sprintf("%d hari", p.DaysLeft)
+		if p.DaysLeft == 0 {
+			days = "HARI INI"
+		}
+
+		fmt.Printf("%s%-7s %-20s %-10s %-9s %s%-8s\033[0m %-7s %-9s %-7s %-7s %-7s %s\n",
+			watchMark(p.Symbol), p.Symbol, name, formatPrice(p.Price), formatPrice(p.Dividend.Amount),
+			yieldClr, fmt.Sprintf("%.2f%%", p.Yield), shortDate(p.Dividend.cumDate()), days, shortDate(p.Dividend.ExDate),
+			shortDate(p.Dividend.PayDate), shortDate(p.Dividend.AnnounceDate), run)
+	}
+
+	fmt.Printf("\n Run pre-cum: rata-rata perubahan harga %d hari bursa sebelum cum date pada dividen-dividen sebelumnya.\n", preCumDays)
+	fmt.Println(" Harga turun sebesar dividen pada ex date. Pajak dividen belum diperhitungkan dalam yield.")
+}
+
+func nextTradingDay(t time.Time) time.Time {
+	t = t.AddDate(0, 0, 1)
+	for t.Weekday() == time.Saturday || t.Weekday() == time.Sunday || marketHolidays[t.Format("2006-01-02")] {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+func exDividendNote(symbol string, price float64) string {
+	a, ok := corpActions.exDividendOn(symbol, nextTradingDay(lastScan.date()).Format("2006-01-02"))
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("XD %s (%.1f%%)", formatPrice(a.Amount), a.Amount/price*100)
+}
+
+func exDividendMark(symbol string, price float64) string {
+	if note := exDividendNote(symbol, price); note != "" {
+		return " \033[1;35m" + note + "\033[0m"
+	}
+	return ""
+}
+
+type FinancialQuarter struct {
+	Period      string  `json:"period"`
+	Revenue     float64 `json:"revenue"`
+	NetIncome   float64 `json:"net_income"`
+	Equity      float64 `json:"equity"`
+	Liabilities float64 `json:"liabilities"`
+	Shares      float64 `json:"shares"`
+}
+
+type Fundamentals struct {
+	Period         string  `json:"period"`
+	EPS            float64 `json:"eps"`
+	BVPS           float64 `json:"bvps"`
+	PER            float64 `json:"per"`
+	PBV            float64 `json:"pbv"`
+	ROE            float64 `json:"roe"`
+	DER            float64 `json:"der"`
+	EPSGrowth      float64 `json:"eps_growth"`
+	HasGrowth      bool    `json:"has_growth"`
+	MarketCap      float64 `json:"market_cap"`
+	Loss           bool    `json:"loss"`
+	NegativeEquity bool    `json:"negative_equity"`
+}
+
+type FundamentalCriteria struct {
+	MaxPER       float64 `json:"max_per,omitempty"`
+	MaxPBV       float64 `json:"max_pbv,omitempty"`
+	MinROE       float64 `json:"min_roe,omitempty"`
+	MaxDER       float64 `json:"max_der,omitempty"`
+	MinEPSGrowth float64 `json:"min_eps_growth,omitempty"`
+	MinMarketCap float64 `json:"min_market_cap,omitempty"`
+	ProfitOnly   bool    `json:"profit_only,omitempty"`
+}
+
+type FundamentalRules struct {
+	Filter      FundamentalCriteria `json:"filter"`
+	Bonus       FundamentalCriteria `json:"bonus"`
+	BonusPoints float64             `json:"bonus_points,omitempty"`
+	RequireData bool                `json:"require_data,omitempty"`
+}
+
+type fundamentalStore struct {
+	path     string
+	bySymbol map[string][]FinancialQuarter
+}
+
+var (
+	fundamentalData  *fundamentalStore
+	fundamentalRules FundamentalRules
+)
+
+func loadFinancials(path string) (*fundamentalStore, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("file %s kosong", path)
+	}
+
+	col := make(map[string]int)
+	for i, h := range rows[0] {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	numeric := []string{"revenue", "net_income", "equity", "liabilities", "shares"}
+	for _, name := range append([]string{"symbol", "period"}, numeric...) {
+		if _, ok := col[name]; !ok {
+			return nil, fmt.Errorf("kolom %q tidak ditemukan di %s", name, path)
+		}
+	}
+
+	store := &fundamentalStore{path: path, bySymbol: make(map[string][]FinancialQuarter)}
+	for n, row := range rows[1:] {
+		if len(row) < len(rows[0]) {
+			return nil, fmt.Errorf("baris %d: jumlah kolom tidak sesuai", n+2)
+		}
+
+		values := make(map[string]float64)
+		for _, name := range numeric {
+			v, err := strconv.ParseFloat(strings.TrimSpace(row[col[name]]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("baris %d: %s: %v", n+2, name, err)
+			}
+			values[name] = v
+		}
+		period := strings.ToUpper(strings.TrimSpace(row[col["period"]]))
+		if len(period) != 6 || period[4] != 'Q' || period[5] < '1' || period[5] > '4' {
+			return nil, fmt.Errorf("baris %d: periode %q harus berformat YYYYQn, contoh 2024Q1", n+2, period)
+		}
+		if values["shares"] <= 0 {
+			return nil, fmt.Errorf("baris %d: jumlah saham harus lebih dari 0", n+2)
+		}
+
+		symbol := strings.ToUpper(strings.TrimSpace(row[col["symbol"]]))
+		store.bySymbol[symbol] = append(store.bySymbol[symbol], FinancialQuarter{
+			Period:      period,
+			Revenue:     values["revenue"],
+			NetIncome:   values["net_income"],
+			Equity:      values["equity"],
+			Liabilities: values["liabilities"],
+			Shares:      values["shares"],
+		})
+	}
+	for _, list := range store.bySymbol {
+		sort.Slice(list, func(i, j int) bool { return list[i].Period < list[j].Period })
+	}
+
+	return store, nil
+}
+
+func loadFundamentalRules(path string) (FundamentalRules, error) {
+	var rules FundamentalRules
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return rules, err
+	}
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return rules, fmt.Errorf("%s: %v", path, err)
+	}
+	if rules.BonusPoints < 0 {
+		return rules, fmt.Errorf("%s: bonus_points tidak boleh negatif", path)
+	}
+
+	return rules, nil
+}
+
+func trailingIncome(quarters []FinancialQuarter) float64 {
+	if len(quarters) > 4 {
+		quarters = quarters[len(quarters)-4:]
+	}
+	sum := 0.0
+	for _, q := range quarters {
+		sum += q.NetIncome
+	}
+	return sum * 4 / float64(len(quarters))
+}
+
+func computeFundamentals(quarters []FinancialQuarter, price float64) *Fundamentals {
+	if len(quarters) == 0 || price <= 0 {
+		return nil
+	}
+	last := quarters[len(quarters)-1]
+	income := trailingIncome(quarters)
+
+	f := &Fundamentals{
+		Period:    last.Period,
+		EPS:       income / last.Shares,
+		BVPS:      last.Equity / last.Shares,
+		MarketCap: price * last.Shares,
+		Loss:      income <= 0,
+	}
+	if !f.Loss {
+		f.PER = price / f.EPS
+	}
+	if last.Equity > 0 {
+		f.PBV = price / f.BVPS
+		f.ROE = income / last.Equity * 100
+		f.DER = last.Liabilities / last.Equity
+	} else {
+		f.NegativeEquity = true
+	}
+
+	var now, prev float64
+	switch n := len(quarters); {
+	case n >= 8:
+		now, prev = trailingIncome(quarters)/last.Shares, trailingIncome(quarters[:n-4])/quarters[n-5].Shares
+	case n >= 5:
+		now, prev = last.NetIncome/last.Shares, quarters[n-5].NetIncome/quarters[n-5].Shares
+	}
+	if prev != 0 {
+		f.EPSGrowth = (now - prev) / math.Abs(prev) * 100
+		f.HasGrowth = true
+	}
+
+	return f
+}
+
+func (s *fundamentalStore) metrics(symbol string, price float64) *Fundamentals {
+	if s == nil {
+		return nil
+	}
+	return computeFundamentals(s.bySymbol[symbol], price)
+}
+
+func (c FundamentalCriteria) evaluate(f *Fundamentals) (met, active int) {
+	check := func(enabled, ok bool) {
+		if enabled {
+			active++
+			if ok {
+				met++
+			}
+		}
+	}
+
+	check(c.MaxPER > 0, !f.Loss && f.PER <= c.MaxPER)
+	check(c.MaxPBV > 0, !f.NegativeEquity && f.PBV <= c.MaxPBV)
+	check(c.MinROE != 0, !f.NegativeEquity && f.ROE >= c.MinROE)
+	check(c.MaxDER > 0, !f.NegativeEquity && f.DER <= c.MaxDER)
+	check(c.MinEPSGrowth != 0 && f.HasGrowth, f.EPSGrowth >= c.MinEPSGrowth)
+	check(c.MinMarketCap > 0, f.MarketCap >= c.MinMarketCap)
+	check(c.ProfitOnly, !f.Loss)
+	return met, active
+}
+
+func (r FundamentalRules) passes(f *Fundamentals) bool {
+	if f == nil {
+		return !r.RequireData
+	}
+	met, active := r.Filter.evaluate(f)
+	return met == active
+}
+
+func (r FundamentalRules) component(f *Fundamentals) (ScoreComponent, bool) {
+	if r.BonusPoints == 0 || r.Bonus == (FundamentalCriteria{}) {
+		return ScoreComponent{}, false
+	}
+	c := ScoreComponent{Factor: "fundamental"}
+	if f == nil {
+		return c, true
+	}
+	met, active := r.Bonus.evaluate(f)
+	c.Value = float64(met)
+	c.Points = float64(met) * r.BonusPoints
+	c.Max = float64(active) * r.BonusPoints
+	return c, true
+}
+
+func fundamentalLines(f *Fundamentals) []string {
+	if f == nil {
+		return []string{"Fundamental: tidak ada data laporan keuangan"}
+	}
+
+	per := fmt.Sprintf("%.1fx", f.PER)
+	if f.Loss {
+		per = "\033[31mrugi\033[0m"
+	}
+	pbv, roe, der := fmt.Sprintf("%.2fx", f.PBV), fmt.Sprintf("%.1f%%", f.ROE), fmt.Sprintf("%.2fx", f.DER)
+	if f.NegativeEquity {
+		pbv, roe, der = "\033[31mekuitas negatif\033[0m", "-", "-"
+	}
+	growth := "-"
+	if f.HasGrowth {
+		growth = fmt.Sprintf("%s%+.1f%%\033[0m", changeColor(f.EPSGrowth), f.EPSGrowth)
+	}
+
+	status := ""
+	if fundamentalRules.Filter != (FundamentalCriteria{}) {
+		status = "  \033[32mlolos filter\033[0m"
+		if !fundamentalRules.passes(f) {
+			status = "  \033[31mtidak lolos filter\033[0m"
+		}
+	}
+	return []string{
+		fmt.Sprintf("Fundamental %s  PER %s  PBV %s  ROE %s  DER %s%s", f.Period, per, pbv, roe, der, status),
+		fmt.Sprintf("EPS Rp%.1f  BVPS Rp%.0f  Pertumbuhan EPS %s  Kapitalisasi %s", f.EPS, f.BVPS, growth, formatMoney(f.MarketCap)),
+	}
+}
+
+func loadMarket(ctx context.Context, meta ScanMeta, progress func(scanProgress)) (pipelineResult, error) {
+	actions := corpActions
+	if meta.CorpActions != "" && (actions == nil || actions.path != meta.CorpActions) {
+		var err error
+		if actions, err = loadCorporateActions(meta.CorpActions); err != nil {
+			return pipelineResult{}, err
+		}
+	}
+	fundamentals := fundamentalData
+	if meta.Fundamentals != "" && (fundamentals == nil || fundamentals.path != meta.Fundamentals) {
+		var err error
+		if fundamentals, err = loadFinancials(meta.Fundamentals); err != nil {
+			return pipelineResult{}, err
+		}
+	}
+
+	if meta.DataDir != "" {
+		src := adjustedSource{barSource: csvDirSource{dir: meta.DataDir}, actions: actions}
+		return runScanPipeline(ctx, src, pipelineConfig{Workers: scanWorkers, Actions: actions, Fundamentals: fundamentals, Progress: progress})
+	}
+	if meta.Provider != "" {
+		provider := marketProvider
+		if provider == nil || provider.base != meta.Provider {
+			var err error
+			if provider, err = newHTTPProvider(meta.Provider, 5, filepath.Join(".cache", "eod")); err != nil {
+				return pipelineResult{}, err
+			}
+		}
+		src := adjustedSource{barSource: provider, actions: actions}
+		return runScanPipeline(ctx, src, pipelineConfig{Workers: scanWorkers, Actions: actions, Fundamentals: fundamentals, Progress: progress})
+	}
+
+	days := meta.Days
+	if days < historyDays+btWarmup {
+		days = historyDays + btWarmup
+	}
+	ihsg, series := newMarketSimulator(meta.Seed).simulate(meta.date(), days)
+	return runScanPipeline(ctx, newSeriesSource(series), pipelineConfig{Workers: scanWorkers, IHSG: &ihsg, Fundamentals: fundamentals, Progress: progress})
+}
+
+func printProgress(p scanProgress) {
+	fmt.Fprintf(os.Stderr, "\r Memuat data: %-9s %d/%d", p.Stage, p.Done, p.Total)
+	if p.Failed > 0 {
+		fmt.Fprintf(os.Stderr, " (%d gagal)", p.Failed)
+	}
+	fmt.Fprint(os.Stderr, "\033[K")
+	if p.Stage == "ranking" {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+func failedNote(failed []symbolError) string {
+	if len(failed) == 0 {
+		return ""
+	}
+	names := make([]string, 0, 3)
+	for i, f := range failed {
+		if i == 3 {
+			names = append(names, "...")
+			break
+		}
+		names = append(names, f.Error())
+	}
+	return fmt.Sprintf("%d saham gagal dimuat dan dilewati: %s", len(failed), strings.Join(names, "; "))
+}
+
+func staleNote(stale []symbolError) string {
+	if len(stale) == 0 {
+		return ""
+	}
+	oldest := time.Now()
+	for _, s := range stale {
+		var err *staleDataError
+		if errors.As(s.Err, &err) && err.FetchedAt.Before(oldest) {
+			oldest = err.FetchedAt
+		}
+	}
+	return fmt.Sprintf("provider gagal untuk %d saham, memakai cache terakhir (tertua %s)",
+		len(stale), oldest.In(jakartaLocation()).Format("02 Jan 2006 15:04"))
+}
+
+func sectorBonuses(sectors []SectorStat) map[string]float64 {
+	bonus := make(map[string]float64)
+	for _, s := range sectors {
+		bonus[s.Sector] = sectorBonus(s)
+	}
+	return bonus
+}
+
+func scoreEmiten(e *Emiten, bonus map[string]float64) {
+	e.SectorBonus = bonus[e.Sector]
+	e.ScoreBSJP = totalScore(bsjpBreakdown(*e))
+	e.ScoreBPJS = totalScore(bpjsBreakdown(*e))
+}
+
+func scoreEmitens(emitens []Emiten, sectors []SectorStat) {
+	bonus := sectorBonuses(sectors)
+	for i := range emitens {
+		scoreEmiten(&emitens[i], bonus)
+	}
+}
+
+func analyzeSectors(emitens []Emiten, ihsg IndexData) []SectorStat {
+	stats := make(map[string]*SectorStat)
+	var order []string
+
+	for _, e := range emitens {
+		s, ok := stats[e.Sector]
+		if !ok {
+			s = &SectorStat{Sector: e.Sector}
+			stats[e.Sector] = s
+			order = append(order, e.Sector)
+		}
+
+		s.Count++
+		s.AvgChange += e.Change
+		s.RS5D += e.Change5D
+		s.RS20D += e.Change20D
+		s.NetForeign += e.NetForeign
+
+		if e.Change > 0 {
+			s.Advancers++
+		} else if e.Change < 0 {
+			s.Decliners++
+		}
+	}
+
+	var results []SectorStat
+	for _, name := range order {
+		s := stats[name]
+		n := float64(s.Count)
+
+		s.AvgChange /= n
+		s.RS1D = s.AvgChange - ihsg.Change1D
+		s.RS5D = s.RS5D/n - ihsg.Change5D
+		s.RS20D = s.RS20D/n - ihsg.Change20D
+		s.Breadth = float64(s.Advancers) / n * 100
+
+		s.Rotation = s.RS1D*0.5 + s.RS5D*0.3 - s.RS20D*0.1 + (s.Breadth-50)/25
+
+		s.Status = "NETRAL"
+		if s.Rotation >= 1 && s.RS5D > 0 {
+			s.Status = "ROTASI MASUK"
+		} else if s.Rotation <= -1 && s.RS5D < 0 {
+			s.Status = "ROTASI KELUAR"
+		}
+
+		results = append(results, *s)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Rotation > results[j].Rotation
+	})
+
+	return results
+}
+
+func sectorBonus(s SectorStat) float64 {
+	bonus := 0.0
+
+	if s.Status == "ROTASI MASUK" {
+		bonus += 6
+	} else if s.Status == "ROTASI KELUAR" {
+		bonus -= 6
+	}
+
+	if s.NetForeign > 0 {
+		bonus += 2
+	}
+
+	if s.RS20D > 0 {
+		bonus += 2
+	}
+
+	return bonus
+}
+
+func calculateBSJP(rsi, change, volatility, gap, afternoonDip, sectorBonus float64, vol, avgVol int64) float64 {
+	return totalScore(bsjpComponents(rsi, change, volatility, gap, afternoonDip, sectorBonus, vol, avgVol))
+}
+
+func calculateBPJS(rsi, change, volatility, morningMom, sectorBonus float64, vol, avgVol int64) float64 {
+	return totalScore(bpjsComponents(rsi, change, volatility, morningMom, sectorBonus, vol, avgVol))
+}
+
+func totalScore(components []ScoreComponent) float64 {
+	score := 0.0
+	for _, c := range components {
+		score += c.Points
+	}
+	return math.Max(0, math.Min(100, score))
+}
+
+type StrategyParams struct {
+	BSJP BSJPParams `json:"bsjp"`
+	BPJS BPJSParams `json:"bpjs"`
+}
+
+type BSJPParams struct {
+	RSIStrong     float64 `json:"rsi_strong"`
+	RSIWeak       float64 `json:"rsi_weak"`
+	ChangeMin     float64 `json:"change_min"`
+	ChangeMax     float64 `json:"change_max"`
+	VolatilityMin float64 `json:"volatility_min"`
+	VolatilityMax float64 `json:"volatility_max"`
+	MinScore      float64 `json:"min_score"`
+}
+
+type BPJSParams struct {
+	RSIMin        float64 `json:"rsi_min"`
+	RSIMax        float64 `json:"rsi_max"`
+	RSIWeak       float64 `json:"rsi_weak"`
+	ChangeMin     float64 `json:"change_min"`
+	ChangeMax     float64 `json:"change_max"`
+	VolatilityMin float64 `json:"volatility_min"`
+	VolatilityMax float64 `json:"volatility_max"`
+	MinScore      float64 `json:"min_score"`
+}
+
+var strategyParams = defaultStrategyParams()
+
+func defaultStrategyParams() StrategyParams {
+	return StrategyParams{
+		BSJP: BSJPParams{RSIStrong: 35, RSIWeak: 45, ChangeMin: -3, ChangeMax: -0.5, VolatilityMin: 2, VolatilityMax: 4, MinScore: 45},
+		BPJS: BPJSParams{RSIMin: 55, RSIMax: 70, RSIWeak: 45, ChangeMin: 0.5, ChangeMax: 3, VolatilityMin: 1.5, VolatilityMax: 3, MinScore: 45},
+	}
+}
+
+func loadStrategyParams(path string) (StrategyParams, error) {
+	params := defaultStrategyParams()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return params, err
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return params, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := params.validate(); err != nil {
+		return params, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return params, nil
+}
+
+func (params StrategyParams) validate() error {
+	b, p := params.BSJP, params.BPJS
+	if b.RSIStrong > b.RSIWeak || b.ChangeMin >= b.ChangeMax || b.VolatilityMin >= b.VolatilityMax ||
+		p.RSIMin >= p.RSIMax || p.RSIWeak > p.RSIMin || p.ChangeMin >= p.ChangeMax || p.VolatilityMin >= p.VolatilityMax {
+		return errors.New("batas bawah parameter harus lebih kecil dari batas atas")
+	}
+	return nil
+}
+
+func bsjpComponents(rsi, change, volatility, gap, afternoonDip, sectorBonus float64, vol, avgVol int64) []ScoreComponent {
+	return strategyParams.BSJP.components(rsi, change, volatility, gap, afternoonDip, sectorBonus, vol, avgVol)
+}
+
+func (p BSJPParams) components(rsi, change, volatility, gap, afternoonDip, sectorBonus float64, vol, avgVol int64) []ScoreComponent {
+	var rsiPts, changePts, volatPts, gapPts, dipPts, volPts float64
+
+	if rsi < p.RSIStrong {
+		rsiPts = 20
+	} else if rsi < p.RSIWeak {
+		rsiPts = 12
+	}
+
+	if change > p.ChangeMin && change < p.ChangeMax {
+		changePts = 18
+	} else if change > -5 && change < 0 {
+		changePts = 10
+	}
+
+	if volatility > p.VolatilityMin && volatility < p.VolatilityMax {
+		volatPts = 15
+	}
+
+	if gap > 0.5 {
+		gapPts = 15
+	} else if gap > 0 {
+		gapPts = 8
+	}
+
+	if afternoonDip > 60 {
+		dipPts = 17
+	} else if afternoonDip > 40 {
+		dipPts = 10
+	}
+
+	if vol > avgVol {
+		volPts = 15
+	}
+
+	return []ScoreComponent{
+		{Factor: "rsi", Value: rsi, Points: rsiPts, Max: 20},
+		{Factor: "change", Value: change, Points: changePts, Max: 18},
+		{Factor: "volatility", Value: volatility, Points: volatPts, Max: 15},
+		{Factor: "gap", Value: gap, Points: gapPts, Max: 15},
+		{Factor: "afternoon_dip", Value: afternoonDip, Points: dipPts, Max: 17},
+		{Factor: "volume", Value: float64(vol) / float64(avgVol), Points: volPts, Max: 15},
+		{Factor: "sector_bonus", Value: sectorBonus, Points: sectorBonus, Max: 10},
+	}
+}
+
+func bpjsComponents(rsi, change, volatility, morningMom, sectorBonus float64, vol, avgVol int64) []ScoreComponent {
+	return strategyParams.BPJS.components(rsi, change, volatility, morningMom, sectorBonus, vol, avgVol)
+}
+
+func (p BPJSParams) components(rsi, change, volatility, morningMom, sectorBonus float64, vol, avgVol int64) []ScoreComponent {
+	var rsiPts, changePts, volatPts, momPts, volPts float64
+
+	if rsi > p.RSIMin && rsi < p.RSIMax {
+		rsiPts = 18
+	} else if rsi > p.RSIWeak {
+		rsiPts = 10
+	}
+
+	if change > p.ChangeMin && change < p.ChangeMax {
+		changePts = 20
+	} else if change > 0 {
+		changePts = 12
+	}
+
+	if volatility > p.VolatilityMin && volatility < p.VolatilityMax {
+		volatPts = 15
+	}
+
+	if morningMom > 60 {
+		momPts = 20
+	} else if morningMom > 40 {
+		momPts = 12
+	}
+
+	if vol > avgVol*12/10 {
+		volPts = 15
+	}
+
+	return []ScoreComponent{
+		{Factor: "rsi", Value: rsi, Points: rsiPts, Max: 18},
+		{Factor: "change", Value: change, Points: changePts, Max: 20},
+		{Factor: "volatility", Value: volatility, Points: volatPts, Max: 15},
+		{Factor: "morning_momentum", Value: morningMom, Points: momPts, Max: 20},
+		{Factor: "volume", Value: float64(vol) / float64(avgVol), Points: volPts, Max: 15},
+		{Factor: "sector_bonus", Value: sectorBonus, Points: sectorBonus, Max: 10},
+	}
+}
+
+func bsjpBreakdown(e Emiten) []ScoreComponent {
+	components := bsjpComponents(e.RSI, e.Change, e.Volatility, e.GapPercent, e.AfternoonDip, e.SectorBonus, e.Volume, e.AvgVolume)
+	if c, ok := fundamentalRules.component(e.Fundamental); ok {
+		components = append(components, c)
+	}
+	return components
+}
+
+func bpjsBreakdown(e Emiten) []ScoreComponent {
+	components := bpjsComponents(e.RSI, e.Change, e.Volatility, e.MorningMoment, e.SectorBonus, e.Volume, e.AvgVolume)
+	if c, ok := fundamentalRules.component(e.Fundamental); ok {
+		components = append(components, c)
+	}
+	return components
+}
+
+func scanBSJP(emitens []Emiten) []ScanResult {
+	var results []ScanResult
+
+	for _, e := range emitens {
+		if e.ScoreBSJP >= strategyParams.BSJP.MinScore && fundamentalRules.passes(e.Fundamental) {
+			target := e.Price * (1 + e.Volatility*0.3/100)
+			stopLoss := e.Low * 0.99
+
+			signal := "WATCH"
+			if e.ScoreBSJP >= 75 {
+				signal = "STRONG BUY"
+			} else if e.ScoreBSJP >= 60 {
+				signal = "BUY"
+			}
+
+			reason := fmt.Sprintf("RSI=%.0f, Gap=%.1f%%, Vol=%s", e.RSI, e.GapPercent, formatVol(e.Volume))
+
+			results = append(results, ScanResult{
+				Symbol:   e.Symbol,
+				Name:     e.Name,
+				Sector:   e.Sector,
+				Price:    e.Price,
+				Change:   e.Change,
+				Target:   target,
+				StopLoss: stopLoss,
+				Score:    e.ScoreBSJP,
+				Signal:   signal,
+				Reason:   reason,
+			})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	return results
+}
+
+func scanBPJS(emitens []Emiten) []ScanResult {
+	var results []ScanResult
+
+	for _, e := range emitens {
+		if e.ScoreBPJS >= strategyParams.BPJS.MinScore && fundamentalRules.passes(e.Fundamental) {
+			target := e.Price * (1 + e.Volatility*0.4/100)
+			stopLoss := e.Price * 0.985
+
+			signal := "WATCH"
+			if e.ScoreBPJS >= 75 {
+				signal = "STRONG BUY"
+			} else if e.ScoreBPJS >= 60 {
+				signal = "BUY"
+			}
+
+			reason := fmt.Sprintf("RSI=%.0f, Mom=%.0f%%, Vol=%s", e.RSI, e.MorningMoment, formatVol(e.Volume))
+
+			results = append(results, ScanResult{
+				Symbol:   e.Symbol,
+				Name:     e.Name,
+				Sector:   e.Sector,
+				Price:    e.Price,
+				Change:   e.Change,
+				Target:   target,
+				StopLoss: stopLoss,
+				Score:    e.ScoreBPJS,
+				Signal:   signal,
+				Reason:   reason,
+			})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	return results
+}
+
+func formatPrice(p float64) string {
+	if p >= 1000 {
+		return fmt.Sprintf("Rp%.0f", p)
+	}
+	return fmt.Sprintf("Rp%.0f", p)
+}
+
+func formatVol(v int64) string {
+	if v >= 1000000000 {
+		return fmt.Sprintf("%.1fB", float64(v)/1000000000)
+	} else if v >= 1000000 {
+		return fmt.Sprintf("%.1fM", float64(v)/1000000)
+	} else if v >= 1000 {
+		return fmt.Sprintf("%.1fK", float64(v)/1000)
+	}
+	return fmt.Sprintf("%d", v)
+}
+
+func formatMoney(val float64) string {
+	absVal := math.Abs(val)
+	sign := ""
+	if val < 0 {
+		sign = "-"
+	}
+
+	if absVal >= 1000000000000 {
+		return fmt.Sprintf("%sRp %.1fT", sign, absVal/1000000000000)
+	} else if absVal >= 1000000000 {
+		return fmt.Sprintf("%sRp %.1fB", sign, absVal/1000000000)
+	} else if absVal >= 1000000 {
+		return fmt.Sprintf("%sRp %.1fM", sign, absVal/1000000)
+	}
+	return fmt.Sprintf("%sRp %.0f", sign, absVal)
+}
+
+func getStars(score float64) string {
+	n := int(score / 20)
+	if n > 5 {
+		n = 5
+	}
+	if n < 1 {
+		n = 1
+	}
+	return strings.Repeat("*", n) + strings.Repeat(" ", 5-n)
+}
+
+func printHeader() {
+	fmt.Print("\033[2J\033[H")
+	fmt.Println(strings.Repeat("=", 100))
+	fmt.Println("                          EMITEN SCANNER BSJP & BPJS")
+	fmt.Println("                        Indonesia Stock Exchange (IDX)")
+	fmt.Println(strings.Repeat("=", 100))
+	fmt.Printf(" Waktu: %s", time.Now().Format("02 Jan 2006 15:04:05 WIB"))
+	if lastScan.DataDir != "" {
+		fmt.Printf("   Data: %s", lastScan.DataDir)
+	} else if lastScan.Provider != "" {
+		fmt.Printf("   Provider: %s", lastScan.Provider)
+	} else if lastScan.AsOf != "" {
+		fmt.Printf("   Seed: %d (replay: -seed %d)", lastScan.Seed, lastScan.Seed)
+	}
+	fmt.Println()
+	if staleBanner != "" {
+		fmt.Printf("\033[1;37;41m DATA BASI \033[0m \033[31m%s\033[0m\n", staleBanner)
+	}
+	fmt.Println(strings.Repeat("-", 100))
+}
+
+func printBSJP(results []ScanResult) {
+	fmt.Println()
+	fmt.Println("\033[1;33m                    BSJP - BELI SORE JUAL PAGI\033[0m")
+	fmt.Println(" Strategi: Beli 14:30-15:00 WIB, Jual 09:00-09:30 WIB besok")
+	fmt.Println(strings.Repeat("-", 100))
+
+	if len(results) == 0 {
+		fmt.Println(" Tidak ada emiten yang memenuhi kriteria BSJP saat ini.")
+		return
+	}
+
+	fmt.Printf(" %-7s %-22s %-12s %-10s %-7s %-10s %-10s %-6s %-10s\n",
+		"KODE", "NAMA", "SEKTOR", "HARGA", "CHG%", "TARGET", "SL", "SCORE", "SIGNAL")
+	fmt.Println(strings.Repeat("-", 100))
+
+	count, exDiv := 0, 0
+	for _, r := range results {
+		if count >= 15 {
+			break
+		}
+
+		chgClr := "\033[32m"
+		if r.Change < 0 {
+			chgClr = "\033[31m"
+		}
+
+		name := r.Name
+		if len(name) > 20 {
+			name = name[:20]
+		}
+		sector := r.Sector
+		if len(sector) > 10 {
+			sector = sector[:10]
+		}
+
+		mark := exDividendMark(r.Symbol, r.Price)
+		if mark != "" {
+			exDiv++
+		}
+
+		fmt.Printf("%s%-7s %-22s %-12s %-10s %s%-6.1f%%\033[0m %-10s %-10s %-6.0f %-10s%s\n",
+			watchMark(r.Symbol), r.Symbol, name, sector, formatPrice(r.Price),
+			chgClr, r.Change, formatPrice(r.Target), formatPrice(r.StopLoss),
+			r.Score, r.Signal, mark)
+		count++
+	}
+
+	fmt.Printf("\n Total emiten BSJP: %d\n", len(results))
+	if exDiv > 0 {
+		fmt.Println(" \033[35mXD = ex-dividen besok: harga pembukaan turun sebesar dividen, gap pagi negatif secara mekanis.\033[0m")
+	}
+}
+
+func printBPJS(results []ScanResult) {
+	fmt.Println()
+	fmt.Println("\033[1;36m                    BPJS - BELI PAGI JUAL SORE\033[0m")
+	fmt.Println(" Strategi: Beli 09:00-09:30 WIB, Jual 14:30-15:00 WIB hari yang sama")
+	fmt.Println(strings.Repeat("-", 100))
+
+	if len(results) == 0 {
+		fmt.Println(" Tidak ada emiten yang memenuhi kriteria BPJS saat ini.")
+		return
+	}
+
+	fmt.Printf(" %-7s %-22s %-12s %-10s %-7s %-10s %-10s %-6s %-10s\n",
+		"KODE", "NAMA", "SEKTOR", "HARGA", "CHG%", "TARGET", "SL", "SCORE", "SIGNAL")
+	fmt.Println(strings.Repeat("-", 100))
+
+	count := 0
+	for _, r := range results {
+		if count >= 15 {
+			break
+		}
+
+		chgClr := "\033[32m"
+		if r.Change < 0 {
+			chgClr = "\033[31m"
+		}
+
+		name := r.Name
+		if len(name) > 20 {
+			name = name[:20]
+		}
+		sector := r.Sector
+		if len(sector) > 10 {
+			sector = sector[:10]
+		}
+
+		fmt.Printf("%s%-7s %-22s %-12s %-10s %s%-6.1f%%\033[0m %-10s %-10s %-6.0f %-10s\n",
+			watchMark(r.Symbol), r.Symbol, name, sector, formatPrice(r.Price),
+			chgClr, r.Change, formatPrice(r.Target), formatPrice(r.StopLoss),
+			r.Score, r.Signal)
+		count++
+	}
+
+	fmt.Printf("\n Total emiten BPJS: %d\n", len(results))
+}
+
+func printAllEmiten(emitens []Emiten) {
+	printHeader()
+	fmt.Println()
+	fmt.Println("                           DAFTAR SEMUA EMITEN")
+	fmt.Println(strings.Repeat("-", 100))
+
+	fmt.Printf(" %-7s %-20s %-10s %-10s %-7s %-6s %-8s %-8s %-8s\n",
+		"KODE", "NAMA", "SEKTOR", "HARGA", "CHG%", "RSI", "VOL", "BSJP", "BPJS")
+	fmt.Println(strings.Repeat("-", 100))
+
+	for _, e := range emitens {
+		chgClr := "\033[32m"
+		if e.Change < 0 {
+			chgClr = "\033[31m"
+		}
+
+		name := e.Name
+		if len(name) > 18 {
+			name = name[:18]
+		}
+		sector := e.Sector
+		if len(sector) > 8 {
+			sector = sector[:8]
+		}
+
+		fmt.Printf("%s%-7s %-20s %-10s %-10s %s%-6.1f%%\033[0m %-6.0f %-8s %-8.0f %-8.0f\n",
+			watchMark(e.Symbol), e.Symbol, name, sector, formatPrice(e.Price),
+			chgClr, e.Change, e.RSI, formatVol(e.Volume),
+			e.ScoreBSJP, e.ScoreBPJS)
+	}
+
+	fmt.Printf("\n Total emiten: %d\n", len(emitens))
+	if len(watchedSymbols) > 0 {
+		fmt.Println(" \033[1;36m*\033[0m = ada di watchlist")
+	}
+}
+
+func printBySector(emitens []Emiten) {
+	printHeader()
+	fmt.Println()
+	fmt.Println("                         EMITEN PER SEKTOR")
+	fmt.Println(strings.Repeat("-", 100))
+
+	sectors := make(map[string][]Emiten)
+	for _, e := range emitens {
+		sectors[e.Sector] = append(sectors[e.Sector], e)
+	}
+
+	var sectorNames []string
+	for s := range sectors {
+		sectorNames = append(sectorNames, s)
+	}
+	sort.Strings(sectorNames)
+
+	for _, sector := range sectorNames {
+		list := sectors[sector]
+		avgChange := 0.0
+		netForeign := 0.0
+		for _, e := range list {
+			avgChange += e.Change
+			netForeign += e.NetForeign
+		}
+		avgChange /= float64(len(list))
+
+		fmt.Printf("\n \033[1;34m%s (%d emiten)\033[0m  Avg %+.1f%%  Net Asing %s\n", sector, len(list), avgChange, formatMoney(netForeign))
+		fmt.Println(strings.Repeat("-", 60))
+
+		for _, e := range list {
+			chgClr := "\033[32m"
+			if e.Change < 0 {
+				chgClr = "\033[31m"
+			}
+
+			fmt.Printf("%s%-7s %-25s %-10s %s%-6.1f%%\033[0m BSJP=%-3.0f BPJS=%-3.0f\n",
+				watchMark(e.Symbol), e.Symbol, e.Name, formatPrice(e.Price),
+				chgClr, e.Change, e.ScoreBSJP, e.ScoreBPJS)
+		}
+	}
+}
+
+func printSectorRotation(sectors []SectorStat, ihsg IndexData) {
+	fmt.Println()
+	fmt.Println("                       ROTASI SEKTOR & ALIRAN ASING")
+	fmt.Println(strings.Repeat("-", 100))
+	fmt.Printf(" IHSG: 1H %+.1f%%   5H %+.1f%%   20H %+.1f%%\n", ihsg.Change1D, ihsg.Change5D, ihsg.Change20D)
+	fmt.Println(strings.Repeat("-", 100))
+
+	fmt.Printf(" %-4s %-15s %-4s %-7s %-9s %-8s %-13s %-7s %-7s %-7s %-14s\n",
+		"RANK", "SEKTOR", "JML", "AVG%", "ADV/DEC", "BREADTH", "NET ASING", "RS 1H", "RS 5H", "RS 20H", "STATUS")
+	fmt.Println(strings.Repeat("-", 100))
+
+	for i, s := range sectors {
+		statusClr := "\033[0m"
+		if s.Status == "ROTASI MASUK" {
+			statusClr = "\033[32m"
+		} else if s.Status == "ROTASI KELUAR" {
+			statusClr = "\033[31m"
+		}
+
+		sector := s.Sector
+		if len(sector) > 14 {
+			sector = sector[:14]
+		}
+
+		fmt.Printf(" %-4d %-15s %-4d %-+7.1f %-9s %-8s %-13s %-+7.1f %-+7.1f %-+7.1f %s%-14s\033[0m\n",
+			i+1, sector, s.Count, s.AvgChange,
+			fmt.Sprintf("%d/%d", s.Advancers, s.Decliners), fmt.Sprintf("%.0f%%", s.Breadth),
+			formatMoney(s.NetForeign), s.RS1D, s.RS5D, s.RS20D,
+			statusClr, s.Status)
+	}
+
+	fmt.Println()
+	fmt.Println(" RS = return rata-rata sektor dikurangi return IHSG pada periode yang sama.")
+	fmt.Println(" Sektor ROTASI MASUK mendapat bonus score BSJP/BPJS, ROTASI KELUAR mendapat penalti.")
+}
+
+func printGuide() {
+	printHeader()
+	fmt.Println()
+	fmt.Println("                    PANDUAN STRATEGI BSJP & BPJS")
+	fmt.Println(strings.Repeat("-", 100))
+	fmt.Println()
+	fmt.Println(" BSJP - BELI SORE JUAL PAGI")
+	fmt.Println(" ==========================")
+	fmt.Println(" Waktu Beli  : 14:30 - 15:00 WIB (menjelang closing)")
+	fmt.Println(" Waktu Jual  : 09:00 - 09:30 WIB (keesokan hari)")
+	fmt.Println(" Konsep      : Memanfaatkan gap overnight dan momentum pembukaan")
+	fmt.Println()
+	fmt.Println(" Kriteria:")
+	fmt.Println(" - RSI < 45 (kondisi oversold)")
+	fmt.Println(" - Harga turun -0.5% s/d -3% (koreksi sehat)")
+	fmt.Println(" - Volatilitas 2-4% (potensi gap)")
+	fmt.Println(" - Volume di atas rata-rata")
+	fmt.Println(" - Ada pattern afternoon dip")
+	fmt.Println()
+	fmt.Println(" BPJS - BELI PAGI JUAL SORE")
+	fmt.Println(" ==========================")
+	fmt.Println(" Waktu Beli  : 09:00 - 09:30 WIB (setelah opening)")
+	fmt.Println(" Waktu Jual  : 14:30 - 15:00 WIB (hari yang sama)")
+	fmt.Println(" Konsep      : Intraday momentum trading")
+	fmt.Println()
+	fmt.Println(" Kriteria:")
+	fmt.Println(" - RSI 55-70 (momentum naik)")
+	fmt.Println(" - Harga naik +0.5% s/d +3% saat opening")
+	fmt.Println(" - Morning momentum kuat")
+	fmt.Println(" - Volume tinggi di awal sesi")
+	fmt.Println()
+	fmt.Println(" MANAJEMEN RISIKO")
+	fmt.Println(" ================")
+	fmt.Println(" - Maksimal 3-5 saham per hari")
+	fmt.Println(" - Stop loss -1.5% untuk BPJS, -2% untuk BSJP")
+	fmt.Println(" - Take profit +1% untuk BPJS, +1.5% untuk BSJP")
+	fmt.Println(" - Jangan all-in, diversifikasi")
+	fmt.Println()
+}
+
+func printStatistics(emitens []Emiten, bsjpResults, bpjsResults []ScanResult) {
+	printHeader()
+	fmt.Println()
+	fmt.Println("                           STATISTIK SCAN")
+	fmt.Println(strings.Repeat("-", 100))
+	fmt.Println()
+	fmt.Printf(" Total Emiten Terscan    : %d\n", len(emitens))
+	fmt.Printf(" Emiten Lolos BSJP       : %d\n", len(bsjpResults))
+	fmt.Printf(" Emiten Lolos BPJS       : %d\n", len(bpjsResults))
+	fmt.Println()
+
+	strongBuyBSJP, buyBSJP, watchBSJP := signalStats(bsjpResults)
+	strongBuyBPJS, buyBPJS, watchBPJS := signalStats(bpjsResults)
+
+	fmt.Println(" BSJP Signals:")
+	fmt.Printf("   STRONG BUY : %d\n", strongBuyBSJP)
+	fmt.Printf("   BUY        : %d\n", buyBSJP)
+	fmt.Printf("   WATCH      : %d\n", watchBSJP)
+	fmt.Println()
+	fmt.Println(" BPJS Signals:")
+	fmt.Printf("   STRONG BUY : %d\n", strongBuyBPJS)
+	fmt.Printf("   BUY        : %d\n", buyBPJS)
+	fmt.Printf("   WATCH      : %d\n", watchBPJS)
+	fmt.Println()
+}
+
+func printMenu() {
+	fmt.Println()
+	fmt.Println(strings.Repeat("-", 100))
+	fmt.Println(" MENU:")
+	fmt.Println(" [1] Scan Ulang")
+	fmt.Println(" [2] Lihat Semua Emiten")
+	fmt.Println(" [3] Lihat Per Sektor")
+	fmt.Println(" [4] Statistik")
+	fmt.Println(" [5] Panduan Strategi")
+	fmt.Println(" [6] Rotasi Sektor")
+	fmt.Println(" [7] Jurnal Trading")
+	fmt.Println(" [8] Grafik Saham")
+	fmt.Println(" [9] Kalender Dividen")
+	fmt.Println(" [10] Keluar")
+	fmt.Println()
+	fmt.Print(" Pilihan: ")
+}
+
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+type scanSnapshot struct {
+	IHSG        IndexData
+	Emitens     []Emiten
+	Sectors     []SectorStat
+	GeneratedAt time.Time
+	Meta        ScanMeta
+}
+
+type apiServer struct {
+	mu         sync.RWMutex
+	snap       scanSnapshot
+	seeds      *seedSource
+	foreignAPI *url.URL
+	live       *liveState
+	hub        *streamHub
+	alerts     *alertDispatcher
+	watchlists *watchlistStore
+	profile    string
+}
+
+type serverConfig struct {
+	Addr       string
+	ForeignAPI string
+	Refresh    time.Duration
+	Live       bool
+	Feed       string
+	Speed      float64
+	Tick       time.Duration
+	Alerts     *alertDispatcher
+	Watchlists *watchlistStore
+	Profile    string
+	Seeds      *seedSource
+}
+
+type apiResponse struct {
+	GeneratedAt time.Time   `json:"generated_at"`
+	Date        string      `json:"date"`
+	Meta        ScanMeta    `json:"meta"`
+	Count       int         `json:"count"`
+	Results     interface{} `json:"results"`
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("server tidak mendukung websocket")
+	}
+	r.status = http.StatusSwitchingProtocols
+	return hj.Hijack()
+}
+
+func newAPIServer(foreignAPI string, alerts *alertDispatcher, seeds *seedSource) (*apiServer, error) {
+	srv := &apiServer{hub: newStreamHub(), alerts: alerts, seeds: seeds}
+	if foreignAPI != "" {
+		u, err := url.Parse(foreignAPI)
+		if err != nil {
+			return nil, fmt.Errorf("alamat -foreign-api tidak valid: %v", err)
+		}
+		srv.foreignAPI = u
+	}
+	if err := srv.refresh(); err != nil {
+		return nil, err
+	}
+	return srv, nil
+}
+
+func (s *apiServer) refresh() error {
+	meta := newScanMeta(s.seeds.next(), time.Now())
+	market, err := loadMarket(context.Background(), meta, nil)
+	if err != nil {
+		return err
+	}
+	if note := failedNote(market.Failed); note != "" {
+		log.Print(note)
+	}
+	if note := staleNote(market.Stale); note != "" {
+		log.Print("data basi: " + note)
+	}
+
+	snap := scanSnapshot{
+		IHSG:        market.IHSG,
+		Emitens:     market.Emitens,
+		Sectors:     market.Sectors,
+		GeneratedAt: time.Now(),
+		Meta:        meta,
+	}
+
+	s.mu.Lock()
+	s.snap = snap
+	s.mu.Unlock()
+
+	s.notifyAlerts(snap.Emitens)
+	return nil
+}
+
+func (s *apiServer) notifyAlerts(emitens []Emiten) {
+	if s.alerts == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	n, err := notifyScans(ctx, s.alerts, emitens)
+	if err != nil {
+		log.Printf("alert: %v", err)
+	}
+	if n > 0 {
+		log.Printf("alert: %d sinyal baru dikirim", n)
+	}
+}
+
+func (s *apiServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/scan/bsjp", s.handleScan(scanBSJP))
+	mux.HandleFunc("/scan/bpjs", s.handleScan(scanBPJS))
+	mux.HandleFunc("/emiten/", s.handleEmiten)
+	mux.HandleFunc("/sectors", s.handleSectors)
+	mux.HandleFunc("/ws", s.handleStream)
+	mux.HandleFunc("/", s.handleDashboard)
+
+	if s.foreignAPI != nil {
+		proxy := httputil.NewSingleHostReverseProxy(s.foreignAPI)
+		mux.Handle("/scan/foreign/", proxy)
+		mux.Handle("/foreign/", proxy)
+	}
+
+	return logRequests(mux)
+}
+
+func (s *apiServer) snapshot(w http.ResponseWriter, r *http.Request) (scanSnapshot, bool) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "hanya mendukung GET")
+		return scanSnapshot{}, false
+	}
+
+	s.mu.RLock()
+	snap := s.snap
+	s.mu.RUnlock()
+
+	if date := r.URL.Query().Get("date"); date != "" {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			writeError(w, http.StatusBadRequest, "format date harus YYYY-MM-DD")
+			return scanSnapshot{}, false
+		}
+		if latest := snap.GeneratedAt.Format("2006-01-02"); date != latest {
+			writeError(w, http.StatusNotFound, "data untuk tanggal "+date+" tidak tersedia, server hanya menyimpan scan terakhir ("+latest+"); ulang scan lama dengan perintah replay")
+			return scanSnapshot{}, false
+		}
+	}
+
+	return snap, true
+}
+
+func newAPIResponse(snap scanSnapshot, count int, results interface{}) apiResponse {
+	return apiResponse{
+		GeneratedAt: snap.GeneratedAt,
+		Date:        snap.GeneratedAt.Format("2006-01-02"),
+		Meta:        snap.Meta,
+		Count:       count,
+		Results:     results,
+	}
+}
+
+func (s *apiServer) handleScan(scan func([]Emiten) []ScanResult) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snap, ok := s.snapshot(w, r)
+		if !ok {
+			return
+		}
+
+		emitens, err := s.scope(snap.Emitens, r.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		results, err := filterResults(scan(emitens), r.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newAPIResponse(snap, len(results), results))
+	}
+}
+
+func (s *apiServer) handleEmiten(w http.ResponseWriter, r *http.Request) {
+	snap, ok := s.snapshot(w, r)
+	if !ok {
+		return
+	}
+
+	symbol := strings.ToUpper(strings.Trim(strings.TrimPrefix(r.URL.Path, "/emiten/"), "/"))
+	for _, e := range snap.Emitens {
+		if e.Symbol != symbol {
+			continue
+		}
+
+		detail := map[string]interface{}{
+			"emiten": e,
+		}
+		for _, res := range scanBSJP([]Emiten{e}) {
+			detail["bsjp"] = res
+		}
+		for _, res := range scanBPJS([]Emiten{e}) {
+			detail["bpjs"] = res
+		}
+		detail["bsjp_breakdown"] = bsjpBreakdown(e)
+		detail["bpjs_breakdown"] = bpjsBreakdown(e)
+
+		writeJSON(w, http.StatusOK, newAPIResponse(snap, 1, detail))
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "emiten "+symbol+" tidak ditemukan")
+}
+
+func (s *apiServer) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		writeError(w, http.StatusNotFound, "endpoint "+r.URL.Path+" tidak ditemukan")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHTML)
+}
+
+func (s *apiServer) handleSectors(w http.ResponseWriter, r *http.Request) {
+	snap, ok := s.snapshot(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	results := []SectorStat{}
+	for _, sec := range snap.Sectors {
+		if name := q.Get("sector"); name != "" && !strings.EqualFold(name, sec.Sector) {
+			continue
+		}
+		results = append(results, sec)
+	}
+
+	if top := q.Get("top"); top != "" {
+		n, err := strconv.Atoi(top)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "top harus bilangan bulat tidak negatif")
+			return
+		}
+		if n < len(results) {
+			results = results[:n]
+		}
+	}
+
+	writeJSON(w, http.StatusOK, newAPIResponse(snap, len(results), map[string]interface{}{
+		"ihsg":    snap.IHSG,
+		"sectors": results,
+	}))
+}
+
+func (s *apiServer) scope(emitens []Emiten, q url.Values) ([]Emiten, error) {
+	name := q.Get("watchlist")
+	if name == "" || s.watchlists == nil {
+		return emitens, nil
+	}
+
+	profile := q.Get("profile")
+	if profile == "" {
+		profile = s.profile
+	}
+
+	list, err := s.watchlists.get(profile, name)
+	if err != nil {
+		return nil, err
+	}
+	return scopeEmitens(emitens, list), nil
+}
+
+func filterResults(results []ScanResult, q url.Values) ([]ScanResult, error) {
+	minScore := 0.0
+	if v := q.Get("min_score"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("min_score harus angka")
+		}
+		minScore = f
+	}
+
+	top := -1
+	if v := q.Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("top harus bilangan bulat tidak negatif")
+		}
+		top = n
+	}
+
+	sector := q.Get("sector")
+
+	filtered := []ScanResult{}
+	for _, r := range results {
+		if r.Score < minScore {
+			continue
+		}
+		if sector != "" && !strings.EqualFold(sector, r.Sector) {
+			continue
+		}
+		filtered = append(filtered, r)
+	}
+
+	if top >= 0 && top < len(filtered) {
+		filtered = filtered[:top]
+	}
+
+	return filtered, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		log.Printf("%s %s %d %s", r.Method, r.URL.RequestURI(), rec.status, time.Since(start).Round(time.Microsecond))
+	})
+}
+
+func runServer(cfg serverConfig) error {
+	api, err := newAPIServer(cfg.ForeignAPI, cfg.Alerts, cfg.Seeds)
+	if err != nil {
+		return err
+	}
+	api.watchlists = cfg.Watchlists
+	api.profile = cfg.Profile
+
+	var feed quoteFeed
+	if cfg.Feed != "" {
+		feed, err = loadReplayFeed(cfg.Feed, cfg.Speed)
+		if err != nil {
+			return err
+		}
+	} else if cfg.Live {
+		api.mu.RLock()
+		feed = newSimulatedFeed(api.snap.Emitens, cfg.Tick, cfg.Seeds.next())
+		api.mu.RUnlock()
+	}
+
+	srv := &http.Server{
+		Addr:    cfg.Addr,
+		Handler: api.routes(),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if feed != nil {
+		go api.runLive(ctx, feed)
+	} else if cfg.Refresh > 0 {
+		go func() {
+			ticker := time.NewTicker(cfg.Refresh)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					if err := api.refresh(); err != nil {
+						log.Printf("refresh gagal, data lama tetap dipakai: %v", err)
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("server berjalan di %s", cfg.Addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("menghentikan server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = srv.Shutdown(shutdownCtx)
+	api.hub.closeAll()
+	return err
+}
+
+type Quote struct {
+	Time   time.Time `json:"time"`
+	Symbol string    `json:"symbol"`
+	Price  float64   `json:"price"`
+	Volume int64     `json:"volume"`
+}
+
+type ScoreEvent struct {
+	Type       string      `json:"type"`
+	Strategy   string      `json:"strategy"`
+	Symbol     string      `json:"symbol"`
+	Time       time.Time   `json:"time"`
+	Result     *ScanResult `json:"result,omitempty"`
+	PrevScore  float64     `json:"prev_score,omitempty"`
+	PrevSignal string      `json:"prev_signal,omitempty"`
+}
+
+type quoteFeed interface {
+	Next(ctx context.Context) (Quote, error)
+}
+
+type replayFeed struct {
+	quotes []Quote
+	pos    int
+	speed  float64
+}
+
+type simulatedFeed struct {
+	rng      *rand.Rand
+	symbols  []string
+	prices   map[string]float64
+	interval time.Duration
+}
+
+type rsiState struct {
+	avgGain float64
+	avgLoss float64
+	last    float64
+}
+
+type liveState struct {
+	rsi     map[string]*rsiState
+	results map[string][]ScanResult
+}
+
+func loadReplayFeed(path string, speed float64) (*replayFeed, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("file replay %s kosong", path)
+	}
+
+	var quotes []Quote
+	for n, row := range rows[1:] {
+		if len(row) < 4 {
+			return nil, fmt.Errorf("%s baris %d: butuh kolom time,symbol,price,volume", path, n+2)
+		}
+
+		t, err := parseQuoteTime(strings.TrimSpace(row[0]))
+		if err != nil {
+			return nil, fmt.Errorf("%s baris %d: %v", path, n+2, err)
+		}
+		price, err := strconv.ParseFloat(strings.TrimSpace(row[2]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s baris %d: %v", path, n+2, err)
+		}
+		volume, err := strconv.ParseInt(strings.TrimSpace(row[3]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s baris %d: %v", path, n+2, err)
+		}
+
+		quotes = append(quotes, Quote{
+			Time:   t,
+			Symbol: strings.ToUpper(strings.TrimSpace(row[1])),
+			Price:  price,
+			Volume: volume,
+		})
+	}
+
+	sort.SliceStable(quotes, func(i, j int) bool {
+		return quotes[i].Time.Before(quotes[j].Time)
+	})
+
+	return &replayFeed{quotes: quotes, speed: speed}, nil
+}
+
+func parseQuoteTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("15:04:05", v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("format waktu %q tidak dikenal (RFC3339 atau HH:MM:SS)", v)
+	}
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, now.Location()), nil
+}
+
+func (f *replayFeed) Next(ctx context.Context) (Quote, error) {
+	if f.pos >= len(f.quotes) {
+		return Quote{}, io.EOF
+	}
+
+	q := f.quotes[f.pos]
+	if f.pos > 0 && f.speed > 0 {
+		gap := q.Time.Sub(f.quotes[f.pos-1].Time)
+		wait := time.Duration(float64(gap) / f.speed)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return Quote{}, ctx.Err()
+		}
+	}
+
+	f.pos++
+	return q, nil
+}
+
+func newSimulatedFeed(emitens []Emiten, interval time.Duration, seed int64) *simulatedFeed {
+	f := &simulatedFeed{
+		rng:      rand.New(rand.NewSource(seed)),
+		prices:   make(map[string]float64),
+		interval: interval,
+	}
+	for _, e := range emitens {
+		f.symbols = append(f.symbols, e.Symbol)
+		f.prices[e.Symbol] = e.Price
+	}
+	return f
+}
+
+func (f *simulatedFeed) Next(ctx context.Context) (Quote, error) {
+	select {
+	case <-time.After(f.interval):
+	case <-ctx.Done():
+		return Quote{}, ctx.Err()
+	}
+
+	symbol := f.symbols[f.rng.Intn(len(f.symbols))]
+	price := f.prices[symbol] * (1 + f.rng.NormFloat64()*0.004)
+	f.prices[symbol] = price
+
+	return Quote{
+		Time:   time.Now(),
+		Symbol: symbol,
+		Price:  price,
+		Volume: int64(10000 + f.rng.Intn(2000000)),
+	}, nil
+}
+
+func newRSIState(e Emiten) *rsiState {
+	move := e.Price * e.Volatility / 100 / 4
+	rsi := math.Max(1, math.Min(99, e.RSI))
+	return &rsiState{
+		avgGain: move * rsi / (100 - rsi),
+		avgLoss: move,
+		last:    e.Price,
+	}
+}
+
+func applyQuote(e *Emiten, st *rsiState, q Quote) {
+	delta := q.Price - st.last
+	st.avgGain = (st.avgGain*13 + math.Max(delta, 0)) / 14
+	st.avgLoss = (st.avgLoss*13 + math.Max(-delta, 0)) / 14
+	st.last = q.Price
+
+	if st.avgLoss == 0 {
+		e.RSI = 100
+	} else {
+		e.RSI = 100 - 100/(1+st.avgGain/st.avgLoss)
+	}
+
+	e.Price = q.Price
+	e.High = math.Max(e.High, q.Price)
+	e.Low = math.Min(e.Low, q.Price)
+	e.Change = (e.Price - e.PrevClose) / e.PrevClose * 100
+	e.Volume += q.Volume
+
+	e.MorningMoment = math.Max(0, math.Min(100, 50+(e.Price-e.Open)/e.Open*100*20))
+	if e.High > e.Low {
+		e.AfternoonDip = (e.High - e.Price) / (e.High - e.Low) * 100
+	}
+}
+
+func diffResults(strategy string, prev, next []ScanResult, at time.Time) []ScoreEvent {
+	before := make(map[string]ScanResult)
+	for _, r := range prev {
+		before[r.Symbol] = r
+	}
+
+	var events []ScoreEvent
+	seen := make(map[string]bool)
+	for _, r := range next {
+		r := r
+		seen[r.Symbol] = true
+
+		old, ok := before[r.Symbol]
+		switch {
+		case !ok:
+			events = append(events, ScoreEvent{Type: "entered", Strategy: strategy, Symbol: r.Symbol, Time: at, Result: &r})
+		case old.Signal != r.Signal:
+			events = append(events, ScoreEvent{Type: "signal_changed", Strategy: strategy, Symbol: r.Symbol, Time: at, Result: &r, PrevScore: old.Score, PrevSignal: old.Signal})
+		case old.Score != r.Score:
+			events = append(events, ScoreEvent{Type: "score_changed", Strategy: strategy, Symbol: r.Symbol, Time: at, Result: &r, PrevScore: old.Score, PrevSignal: old.Signal})
+		}
+	}
+
+	for _, r := range prev {
+		if !seen[r.Symbol] {
+			events = append(events, ScoreEvent{Type: "left", Strategy: strategy, Symbol: r.Symbol, Time: at, PrevScore: r.Score, PrevSignal: r.Signal})
+		}
+	}
+
+	return events
+}
+
+func (s *apiServer) runLive(ctx context.Context, feed quoteFeed) {
+	s.mu.Lock()
+	s.live = &liveState{
+		rsi: make(map[string]*rsiState),
+		results: map[string][]ScanResult{
+			"bsjp": scanBSJP(s.snap.Emitens),
+			"bpjs": scanBPJS(s.snap.Emitens),
+		},
+	}
+	for _, e := range s.snap.Emitens {
+		s.live.rsi[e.Symbol] = newRSIState(e)
+	}
+	s.mu.Unlock()
+
+	for {
+		q, err := feed.Next(ctx)
+		if err != nil {
+			if err == io.EOF {
+				log.Println("feed selesai diputar ulang")
+			}
+			return
+		}
+
+		events := s.applyLiveQuote(q)
+		if len(events) == 0 {
+			continue
+		}
+		s.hub.broadcast(events)
+
+		if s.alerts != nil && hasSignalChange(events) {
+			s.mu.RLock()
+			emitens := s.snap.Emitens
+			s.mu.RUnlock()
+			go s.notifyAlerts(emitens)
+		}
+	}
+}
+
+func (s *apiServer) applyLiveQuote(q Quote) []ScoreEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.live.rsi[q.Symbol]
+	if !ok || q.Price <= 0 {
+		return nil
+	}
+
+	emitens := make([]Emiten, len(s.snap.Emitens))
+	copy(emitens, s.snap.Emitens)
+	for i := range emitens {
+		if emitens[i].Symbol == q.Symbol {
+			applyQuote(&emitens[i], st, q)
+		}
+	}
+
+	sectors := analyzeSectors(emitens, s.snap.IHSG)
+	scoreEmitens(emitens, sectors)
+
+	s.snap = scanSnapshot{
+		IHSG:        s.snap.IHSG,
+		Emitens:     emitens,
+		Sectors:     sectors,
+		GeneratedAt: q.Time,
+		Meta:        s.snap.Meta,
+	}
+	s.snap.Meta.Live = true
+
+	var events []ScoreEvent
+	for strategy, scan := range map[string]func([]Emiten) []ScanResult{"bsjp": scanBSJP, "bpjs": scanBPJS} {
+		next := scan(emitens)
+		events = append(events, diffResults(strategy, s.live.results[strategy], next, q.Time)...)
+		s.live.results[strategy] = next
+	}
+
+	return events
+}
+
+func hasSignalChange(events []ScoreEvent) bool {
+	for _, ev := range events {
+		if ev.Type == "entered" || ev.Type == "signal_changed" {
+			return true
+		}
+	}
+	return false
+}
+
+type wsClient struct {
+	conn     net.Conn
+	reader   *bufio.Reader
+	writeMu  sync.Mutex
+	send     chan []byte
+	strategy string
+	symbols  map[string]bool
+}
+
+type streamHub struct {
+	mu      sync.Mutex
+	clients map[*wsClient]bool
+}
+
+func newStreamHub() *streamHub {
+	return &streamHub{clients: make(map[*wsClient]bool)}
+}
+
+func (h *streamHub) add(c *wsClient) {
+	h.mu.Lock()
+	h.clients[c] = true
+	h.mu.Unlock()
+}
+
+func (h *streamHub) remove(c *wsClient) {
+	h.mu.Lock()
+	if h.clients[c] {
+		delete(h.clients, c)
+		close(c.send)
+	}
+	h.mu.Unlock()
+}
+
+func (h *streamHub) closeAll() {
+	h.mu.Lock()
+	clients := h.clients
+	h.clients = make(map[*wsClient]bool)
+	h.mu.Unlock()
+
+	for c := range clients {
+		close(c.send)
+		c.conn.Close()
+	}
+}
+
+func (h *streamHub) broadcast(events []ScoreEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.clients {
+		for _, ev := range events {
+			if !c.wants(ev) {
+				continue
+			}
+			msg, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			select {
+			case c.send <- msg:
+			default:
+				log.Printf("klien %s terlalu lambat, koneksi ditutup", c.conn.RemoteAddr())
+				delete(h.clients, c)
+				close(c.send)
+			}
+		}
+	}
+}
+
+func (c *wsClient) wants(ev ScoreEvent) bool {
+	if c.strategy != "" && c.strategy != "all" && c.strategy != ev.Strategy {
+		return false
+	}
+	if len(c.symbols) > 0 && !c.symbols[ev.Symbol] {
+		return false
+	}
+	return true
+}
+
+func (s *apiServer) handleStream(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	strategy := strings.ToLower(q.Get("strategy"))
+	if strategy != "" && strategy != "all" && strategy != "bsjp" && strategy != "bpjs" {
+		writeError(w, http.StatusBadRequest, "strategy harus bsjp, bpjs, atau all")
+		return
+	}
+
+	symbols := make(map[string]bool)
+	if v := q.Get("symbols"); v != "" {
+		for _, sym := range strings.Split(v, ",") {
+			if sym = strings.ToUpper(strings.TrimSpace(sym)); sym != "" {
+				symbols[sym] = true
+			}
+		}
+	}
+
+	conn, reader, err := upgradeWebSocket(w, r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c := &wsClient{
+		conn:     conn,
+		reader:   reader,
+		send:     make(chan []byte, 256),
+		strategy: strategy,
+		symbols:  symbols,
+	}
+
+	s.mu.RLock()
+	var initial []ScoreEvent
+	for strat, scan := range map[string]func([]Emiten) []ScanResult{"bsjp": scanBSJP, "bpjs": scanBPJS} {
+		for _, res := range scan(s.snap.Emitens) {
+			res := res
+			initial = append(initial, ScoreEvent{Type: "snapshot", Strategy: strat, Symbol: res.Symbol, Time: s.snap.GeneratedAt, Result: &res})
+		}
+	}
+	s.mu.RUnlock()
+
+	for _, ev := range initial {
+		if !c.wants(ev) {
+			continue
+		}
+		msg, _ := json.Marshal(ev)
+		if err := c.writeFrame(0x1, msg); err != nil {
+			log.Printf("snapshot ke klien %s gagal: %v", conn.RemoteAddr(), err)
+			conn.Close()
+			return
+		}
+	}
+
+	s.hub.add(c)
+	go c.writeLoop()
+	c.readLoop()
+	s.hub.remove(c)
+}
+
+func (c *wsClient) writeLoop() {
+	for msg := range c.send {
+		if err := c.writeFrame(0x1, msg); err != nil {
+			break
+		}
+	}
+	c.writeFrame(0x8, nil)
+	c.conn.Close()
+}
+
+func (c *wsClient) readLoop() {
+	for {
+		opcode, payload, err := readFrame(c.reader)
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case 0x8:
+			return
+		case 0x9:
+			c.writeFrame(0xA, payload)
+		}
+	}
+}
+
+func (c *wsClient) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, byte(n>>8), byte(n))
+	default:
+		header = append(header, 127)
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		header = append(header, ext[:]...)
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(payload)
+	return err
+}
+
+func readFrame(r *bufio.Reader) (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+
+	opcode := head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+	length := uint64(head[1] & 0x7F)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > 1<<16 {
+		return 0, nil, errors.New("frame websocket terlalu besar")
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return opcode, payload, nil
+}
+
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.Reader, error) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return nil, nil, errors.New("endpoint ini membutuhkan koneksi websocket")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, nil, errors.New("header Sec-WebSocket-Key tidak ada")
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("server tidak mendukung websocket")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	accept := base64.StdEncoding.EncodeToString(sum[:])
+
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	rw.WriteString("Upgrade: websocket\r\n")
+	rw.WriteString("Connection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + accept + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return conn, rw.Reader, nil
+}
+
+type AlertConfig struct {
+	Strategies []string          `json:"strategies"`
+	Tiers      []string          `json:"tiers"`
+	Timezone   string            `json:"timezone"`
+	QuietHours *QuietHours       `json:"quiet_hours"`
+	Template   string            `json:"template"`
+	Retries    int               `json:"retries"`
+	Backoff    string            `json:"backoff"`
+	StateFile  string            `json:"state_file"`
+	Sinks      []AlertSinkConfig `json:"sinks"`
+}
+
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+type AlertSinkConfig struct {
+	Type    string `json:"type"`
+	URL     string `json:"url"`
+	BaseURL string `json:"base_url"`
+	Token   string `json:"token"`
+	ChatID  string `json:"chat_id"`
+}
+
+type Alert struct {
+	Strategy string    `json:"strategy"`
+	Symbol   string    `json:"symbol"`
+	Name     string    `json:"name"`
+	Sector   string    `json:"sector"`
+	Signal   string    `json:"signal"`
+	Score    float64   `json:"score"`
+	Price    float64   `json:"price"`
+	Target   float64   `json:"target"`
+	StopLoss float64   `json:"stop_loss"`
+	Reason   string    `json:"reason"`
+	Time     time.Time `json:"time"`
+}
+
+type alertSink interface {
+	Name() string
+	Send(ctx context.Context, text string, alerts []Alert) error
+}
+
+type webhookSink struct {
+	client *http.Client
+	url    string
+}
+
+type telegramSink struct {
+	client  *http.Client
+	baseURL string
+	token   string
+	chatID  string
+}
+
+type chatWebhookSink struct {
+	client *http.Client
+	kind   string
+	url    string
+}
+
+type alertDispatcher struct {
+	mu         sync.Mutex
+	strategies map[string]bool
+	tiers      map[string]bool
+	loc        *time.Location
+	quietStart int
+	quietEnd   int
+	quiet      bool
+	tmpl       *template.Template
+	retries    int
+	backoff    time.Duration
+	stateFile  string
+	sent       map[string]bool
+	pending    map[string]bool
+	sinks      []alertSink
+	now        func() time.Time
+}
+
+const defaultAlertTemplate = `{{.Signal}} {{.Strategy}}: {{.Symbol}} ({{.Name}}) @ {{printf "%.0f" .Price}} | score {{printf "%.0f" .Score}} | TP {{printf "%.0f" .Target}} | SL {{printf "%.0f" .StopLoss}}`
+
+func jakartaLocation() *time.Location {
+	if loc, err := time.LoadLocation("Asia/Jakarta"); err == nil {
+		return loc
+	}
+	return time.FixedZone("WIB", 7*3600)
+}
+
+func parseClock(v string) (int, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, fmt.Errorf("jam %q harus berformat HH:MM", v)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func loadAlertConfig(path string) (AlertConfig, error) {
+	var cfg AlertConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return cfg, nil
+}
+
+func newAlertDispatcher(cfg AlertConfig) (*alertDispatcher, error) {
+	d := &alertDispatcher{
+		strategies: make(map[string]bool),
+		tiers:      make(map[string]bool),
+		loc:        jakartaLocation(),
+		retries:    cfg.Retries,
+		backoff:    2 * time.Second,
+		stateFile:  cfg.StateFile,
+		sent:       make(map[string]bool),
+		pending:    make(map[string]bool),
+		now:        time.Now,
+	}
+
+	if len(cfg.Strategies) == 0 {
+		cfg.Strategies = []string{"bsjp", "bpjs"}
+	}
+	for _, s := range cfg.Strategies {
+		d.strategies[strings.ToLower(s)] = true
+	}
+
+	if len(cfg.Tiers) == 0 {
+		cfg.Tiers = []string{"STRONG BUY"}
+	}
+	for _, t := range cfg.Tiers {
+		d.tiers[strings.ToUpper(t)] = true
+	}
+
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("timezone %q tidak dikenal: %v", cfg.Timezone, err)
+		}
+		d.loc = loc
+	}
+
+	if cfg.QuietHours != nil {
+		var err error
+		if d.quietStart, err = parseClock(cfg.QuietHours.Start); err != nil {
+			return nil, err
+		}
+		if d.quietEnd, err = parseClock(cfg.QuietHours.End); err != nil {
+			return nil, err
+		}
+		d.quiet = d.quietStart != d.quietEnd
+	}
+
+	if cfg.Backoff != "" {
+		backoff, err := time.ParseDuration(cfg.Backoff)
+		if err != nil {
+			return nil, fmt.Errorf("backoff %q tidak valid: %v", cfg.Backoff, err)
+		}
+		d.backoff = backoff
+	}
+
+	text := cfg.Template
+	if text == "" {
+		text = defaultAlertTemplate
+	}
+	tmpl, err := template.New("alert").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("template alert tidak valid: %v", err)
+	}
+	d.tmpl = tmpl
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	for i, sc := range cfg.Sinks {
+		sink, err := newAlertSink(client, sc)
+		if err != nil {
+			return nil, fmt.Errorf("sink #%d: %v", i+1, err)
+		}
+		d.sinks = append(d.sinks, sink)
+	}
+	if len(d.sinks) == 0 {
+		return nil, errors.New("belum ada sink alert yang dikonfigurasi")
+	}
+
+	if d.stateFile != "" {
+		if data, err := os.ReadFile(d.stateFile); err == nil {
+			json.Unmarshal(data, &d.sent)
+		}
+	}
+
+	return d, nil
+}
+
+func newAlertSink(client *http.Client, sc AlertSinkConfig) (alertSink, error) {
+	switch strings.ToLower(sc.Type) {
+	case "webhook":
+		if sc.URL == "" {
+			return nil, errors.New("webhook membutuhkan url")
+		}
+		return &webhookSink{client: client, url: sc.URL}, nil
+	case "telegram":
+		token := sc.Token
+		if token == "" {
+			token = os.Getenv("TELEGRAM_BOT_TOKEN")
+		}
+		if token == "" || sc.ChatID == "" {
+			return nil, errors.New("telegram membutuhkan token (atau TELEGRAM_BOT_TOKEN) dan chat_id")
+		}
+		baseURL := sc.BaseURL
+		if baseURL == "" {
+			baseURL = "https://api.telegram.org"
+		}
+		return &telegramSink{client: client, baseURL: strings.TrimRight(baseURL, "/"), token: token, chatID: sc.ChatID}, nil
+	case "discord", "slack":
+		if sc.URL == "" {
+			return nil, fmt.Errorf("%s membutuhkan url webhook", sc.Type)
+		}
+		return &chatWebhookSink{client: client, kind: strings.ToLower(sc.Type), url: sc.URL}, nil
+	}
+	return nil, fmt.Errorf("tipe sink %q tidak dikenal (webhook, telegram, discord, slack)", sc.Type)
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(string(data)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *webhookSink) Name() string { return "webhook" }
+
+func (s *webhookSink) Send(ctx context.Context, text string, alerts []Alert) error {
+	return postJSON(ctx, s.client, s.url, map[string]interface{}{
+		"text":   text,
+		"alerts": alerts,
+	})
+}
+
+func (s *telegramSink) Name() string { return "telegram" }
+
+func (s *telegramSink) Send(ctx context.Context, text string, alerts []Alert) error {
+	err := postJSON(ctx, s.client, s.baseURL+"/bot"+s.token+"/sendMessage", map[string]string{
+		"chat_id": s.chatID,
+		"text":    text,
+	})
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		uerr.URL = s.baseURL + "/bot<token>/sendMessage"
+	}
+	return err
+}
+
+func (s *chatWebhookSink) Name() string { return s.kind }
+
+func (s *chatWebhookSink) Send(ctx context.Context, text string, alerts []Alert) error {
+	key := "text"
+	if s.kind == "discord" {
+		key = "content"
+	}
+	return postJSON(ctx, s.client, s.url, map[string]string{key: text})
+}
+
+func (d *alertDispatcher) inQuietHours(t time.Time) bool {
+	if !d.quiet {
+		return false
+	}
+	t = t.In(d.loc)
+	m := t.Hour()*60 + t.Minute()
+	if d.quietStart < d.quietEnd {
+		return m >= d.quietStart && m < d.quietEnd
+	}
+	return m >= d.quietStart || m < d.quietEnd
+}
+
+func (d *alertDispatcher) Notify(ctx context.Context, strategy string, results []ScanResult) (int, error) {
+	d.mu.Lock()
+	if !d.strategies[strategy] {
+		d.mu.Unlock()
+		return 0, nil
+	}
+
+	now := d.now().In(d.loc)
+	if d.inQuietHours(now) {
+		d.mu.Unlock()
+		return 0, nil
+	}
+
+	today := now.Format("2006-01-02")
+	batches := make([][]Alert, len(d.sinks))
+	keys := make([][]string, len(d.sinks))
+	queued := make(map[string]bool)
+	for _, r := range results {
+		if !d.tiers[r.Signal] {
+			continue
+		}
+		alert := Alert{
+			Strategy: strings.ToUpper(strategy),
+			Symbol:   r.Symbol,
+			Name:     r.Name,
+			Sector:   r.Sector,
+			Signal:   r.Signal,
+			Score:    r.Score,
+			Price:    r.Price,
+			Target:   r.Target,
+			StopLoss: r.StopLoss,
+			Reason:   r.Reason,
+			Time:     now,
+		}
+		for i, sink := range d.sinks {
+			key := today + "|" + sink.Name() + "#" + strconv.Itoa(i+1) + "|" + strategy + "|" + r.Symbol + "|" + r.Signal
+			if d.sent[key] || d.pending[key] {
+				continue
+			}
+			d.pending[key] = true
+			keys[i] = append(keys[i], key)
+			batches[i] = append(batches[i], alert)
+			queued[r.Symbol+"|"+r.Signal] = true
+		}
+	}
+	d.mu.Unlock()
+
+	if len(queued) == 0 {
+		return 0, nil
+	}
+
+	delivered := make([]bool, len(d.sinks))
+	var failed []string
+	for i, sink := range d.sinks {
+		if len(batches[i]) == 0 {
+			continue
+		}
+		text, err := d.render(batches[i])
+		if err == nil {
+			err = d.sendWithRetry(ctx, sink, text, batches[i])
+		}
+		if err != nil {
+			failed = append(failed, sink.Name()+": "+err.Error())
+			continue
+		}
+		delivered[i] = true
+	}
+
+	d.mu.Lock()
+	for i := range d.sinks {
+		for _, key := range keys[i] {
+			delete(d.pending, key)
+			if delivered[i] {
+				d.sent[key] = true
+			}
+		}
+	}
+	d.pruneSent(today)
+	d.saveState()
+	d.mu.Unlock()
+
+	if len(failed) > 0 {
+		return len(queued), fmt.Errorf("gagal kirim alert: %s", strings.Join(failed, "; "))
+	}
+	return len(queued), nil
+}
+
+func (d *alertDispatcher) render(alerts []Alert) (string, error) {
+	var lines []string
+	for _, a := range alerts {
+		var b strings.Builder
+		if err := d.tmpl.Execute(&b, a); err != nil {
+			return "", fmt.Errorf("template alert: %v", err)
+		}
+		lines = append(lines, b.String())
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (d *alertDispatcher) sendWithRetry(ctx context.Context, sink alertSink, text string, alerts []Alert) error {
+	delay := d.backoff
+	var err error
+	for attempt := 0; attempt <= d.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			delay *= 2
+		}
+		if err = sink.Send(ctx, text, alerts); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (d *alertDispatcher) pruneSent(today string) {
+	for key := range d.sent {
+		if !strings.HasPrefix(key, today+"|") {
+			delete(d.sent, key)
+		}
+	}
+}
+
+func (d *alertDispatcher) saveState() {
+	if d.stateFile == "" {
+		return
+	}
+	data, err := json.MarshalIndent(d.sent, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(d.stateFile, data, 0644); err != nil {
+		log.Printf("gagal menyimpan state alert: %v", err)
+	}
+}
+
+func notifyScans(ctx context.Context, alerts *alertDispatcher, emitens []Emiten) (int, error) {
+	total := 0
+	var errs []string
+	for _, strategy := range []string{"bsjp", "bpjs"} {
+		results := scanBSJP(emitens)
+		if strategy == "bpjs" {
+			results = scanBPJS(emitens)
+		}
+		n, err := alerts.Notify(ctx, strategy, results)
+		total += n
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return total, errors.New(strings.Join(errs, "; "))
+	}
+	return total, nil
+}
+
+type ScheduleConfig struct {
+	Timezone     string        `json:"timezone"`
+	Holidays     []string      `json:"holidays"`
+	HolidaysFile string        `json:"holidays_file"`
+	ResultsDir   string        `json:"results_dir"`
+	StateFile    string        `json:"state_file"`
+	Export       []string      `json:"export"`
+	ForeignAPI   string        `json:"foreign_api"`
+	CatchUp      string        `json:"catch_up"`
+	Jobs         []ScheduleJob `json:"jobs"`
+}
+
+type ScheduleJob struct {
+	Name      string `json:"name"`
+	Strategy  string `json:"strategy"`
+	Cron      string `json:"cron"`
+	Watchlist string `json:"watchlist"`
+}
+
+type cronField map[int]bool
+
+type cronSpec struct {
+	minute  cronField
+	hour    cronField
+	dom     cronField
+	month   cronField
+	dow     cronField
+	domStar bool
+	dowStar bool
+}
+
+type scheduledJob struct {
+	ScheduleJob
+	spec    cronSpec
+	symbols []string
+}
+
+type scheduler struct {
+	loc        *time.Location
+	holidays   map[string]bool
+	resultsDir string
+	stateFile  string
+	export     []string
+	foreignAPI string
+	catchUp    time.Duration
+	jobs       []scheduledJob
+	lastRun    map[string]time.Time
+	alerts     *alertDispatcher
+	seeds      *seedSource
+	now        func() time.Time
+}
+
+type scheduledRun struct {
+	Job      string       `json:"job"`
+	Strategy string       `json:"strategy"`
+	Slot     time.Time    `json:"slot"`
+	RunAt    time.Time    `json:"run_at"`
+	IHSG     IndexData    `json:"ihsg"`
+	Meta     ScanMeta     `json:"meta"`
+	Failed   []string     `json:"failed,omitempty"`
+	BSJP     []ScanResult `json:"bsjp,omitempty"`
+	BPJS     []ScanResult `json:"bpjs,omitempty"`
+}
+
+func parseCronField(expr string, min, max int) (cronField, error) {
+	field := make(cronField)
+
+	for _, part := range strings.Split(expr, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("step %q tidak valid", part)
+			}
+			step = n
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("nilai %q tidak valid", part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("nilai %q tidak valid", part)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("nilai %q di luar rentang %d-%d", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			field[v] = true
+		}
+	}
+
+	return field, nil
+}
+
+func parseCron(expr string) (cronSpec, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return cronSpec{}, fmt.Errorf("cron %q harus 5 kolom: menit jam tanggal bulan hari", expr)
+	}
+
+	limits := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	fields := make([]cronField, 5)
+	for i, p := range parts {
+		f, err := parseCronField(p, limits[i][0], limits[i][1])
+		if err != nil {
+			return cronSpec{}, fmt.Errorf("cron %q: %v", expr, err)
+		}
+		fields[i] = f
+	}
+	if fields[4][7] {
+		fields[4][0] = true
+	}
+
+	return cronSpec{
+		minute:  fields[0],
+		hour:    fields[1],
+		dom:     fields[2],
+		month:   fields[3],
+		dow:     fields[4],
+		domStar: strings.HasPrefix(parts[2], "*"),
+		dowStar: strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+func (c cronSpec) matches(t time.Time) bool {
+	if !c.minute[t.Minute()] || !c.hour[t.Hour()] || !c.month[int(t.Month())] {
+		return false
+	}
+	if c.domStar || c.dowStar {
+		return c.dom[t.Day()] && c.dow[int(t.Weekday())]
+	}
+	return c.dom[t.Day()] || c.dow[int(t.Weekday())]
+}
+
+func loadHolidays(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var days []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			days = append(days, line)
+		}
+	}
+	return days, nil
+}
+
+func parseHolidays(days []string) (map[string]bool, error) {
+	holidays := make(map[string]bool)
+	for _, day := range days {
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			return nil, fmt.Errorf("tanggal libur %q harus berformat YYYY-MM-DD", day)
+		}
+		holidays[day] = true
+	}
+	return holidays, nil
+}
+
+func loadScheduleConfig(path string) (ScheduleConfig, error) {
+	var cfg ScheduleConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return cfg, nil
+}
+
+func newScheduler(cfg ScheduleConfig, alerts *alertDispatcher, watchlists map[string][]string, seeds *seedSource) (*scheduler, error) {
+	s := &scheduler{
+		loc:        jakartaLocation(),
+		resultsDir: cfg.ResultsDir,
+		stateFile:  cfg.StateFile,
+		export:     cfg.Export,
+		foreignAPI: cfg.ForeignAPI,
+		catchUp:    10 * time.Minute,
+		lastRun:    make(map[string]time.Time),
+		alerts:     alerts,
+		seeds:      seeds,
+		now:        time.Now,
+	}
+
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("timezone %q tidak dikenal: %v", cfg.Timezone, err)
+		}
+		s.loc = loc
+	}
+
+	if s.resultsDir == "" {
+		s.resultsDir = "results"
+	}
+	if s.stateFile == "" {
+		s.stateFile = filepath.Join(s.resultsDir, "scheduler_state.json")
+	}
+	if len(s.export) == 0 {
+		s.export = []string{"json"}
+	}
+	for _, f := range s.export {
+		if f != "json" && f != "csv" && f != "report" {
+			return nil, fmt.Errorf("format export %q tidak dikenal (json, csv, report)", f)
+		}
+	}
+
+	if cfg.CatchUp != "" {
+		d, err := time.ParseDuration(cfg.CatchUp)
+		if err != nil {
+			return nil, fmt.Errorf("catch_up %q tidak valid: %v", cfg.CatchUp, err)
+		}
+		s.catchUp = d
+	}
+
+	holidays := cfg.Holidays
+	if cfg.HolidaysFile != "" {
+		days, err := loadHolidays(cfg.HolidaysFile)
+		if err != nil {
+			return nil, err
+		}
+		holidays = append(holidays, days...)
+	}
+	parsed, err := parseHolidays(holidays)
+	if err != nil {
+		return nil, err
+	}
+	s.holidays = parsed
+
+	if len(cfg.Jobs) == 0 {
+		cfg.Jobs = []ScheduleJob{
+			{Name: "bpjs-pagi", Strategy: "bpjs", Cron: "5 9 * * 1-5"},
+			{Name: "bsjp-sore", Strategy: "bsjp", Cron: "45 14 * * 1-5"},
+		}
+	}
+	seen := make(map[string]bool)
+	for _, job := range cfg.Jobs {
+		if job.Name == "" || seen[job.Name] {
+			return nil, fmt.Errorf("setiap job harus punya nama unik (%q)", job.Name)
+		}
+		seen[job.Name] = true
+
+		job.Strategy = strings.ToLower(job.Strategy)
+		if job.Strategy != "bsjp" && job.Strategy != "bpjs" && job.Strategy != "all" {
+			return nil, fmt.Errorf("job %s: strategy harus bsjp, bpjs, atau all", job.Name)
+		}
+		spec, err := parseCron(job.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("job %s: %v", job.Name, err)
+		}
+		var symbols []string
+		if job.Watchlist != "" {
+			list, ok := watchlists[job.Watchlist]
+			if !ok {
+				return nil, fmt.Errorf("job %s: watchlist %q tidak ditemukan", job.Name, job.Watchlist)
+			}
+			symbols = list
+		}
+		s.jobs = append(s.jobs, scheduledJob{ScheduleJob: job, spec: spec, symbols: symbols})
+	}
+
+	if err := os.MkdirAll(s.resultsDir, 0755); err != nil {
+		return nil, err
+	}
+	if data, err := os.ReadFile(s.stateFile); err == nil {
+		if err := json.Unmarshal(data, &s.lastRun); err != nil {
+			return nil, fmt.Errorf("state scheduler %s rusak: %v", s.stateFile, err)
+		}
+	}
+
+	return s, nil
+}
+
+func (s *scheduler) isTradingDay(t time.Time) bool {
+	t = t.In(s.loc)
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	return !s.holidays[t.Format("2006-01-02")]
+}
+
+func (s *scheduler) dueSlot(job scheduledJob, now time.Time) (time.Time, bool) {
+	now = now.In(s.loc).Truncate(time.Minute)
+	for t := now; !t.Before(now.Add(-s.catchUp)); t = t.Add(-time.Minute) {
+		if !job.spec.matches(t) {
+			continue
+		}
+		if !s.isTradingDay(t) || !t.After(s.lastRun[job.Name]) {
+			return time.Time{}, false
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+func (s *scheduler) nextSlot(job scheduledJob, from time.Time) time.Time {
+	t := from.In(s.loc).Truncate(time.Minute).Add(time.Minute)
+	for i := 0; i < 366*24*60; i++ {
+		if job.spec.matches(t) && s.isTradingDay(t) {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+	return time.Time{}
+}
+
+func (s *scheduler) tick(ctx context.Context) {
+	now := s.now()
+	for _, job := range s.jobs {
+		slot, ok := s.dueSlot(job, now)
+		if !ok {
+			continue
+		}
+
+		if err := s.runJob(ctx, job, slot); err != nil {
+			log.Printf("job %s slot %s gagal: %v", job.Name, slot.Format("2006-01-02 15:04"), err)
+			continue
+		}
+		log.Printf("job %s slot %s selesai, berikutnya %s", job.Name, slot.Format("2006-01-02 15:04"),
+			s.nextSlot(job, slot).Format("2006-01-02 15:04"))
+	}
+}
+
+func (s *scheduler) runJob(ctx context.Context, job scheduledJob, slot time.Time) error {
+	meta := newScanMeta(s.seeds.next(), s.now().In(s.loc))
+	meta.Watchlist, meta.Symbols = job.Watchlist, job.symbols
+	market, err := loadMarket(ctx, meta, nil)
+	if err != nil {
+		return err
+	}
+	ihsg, all := market.IHSG, market.Emitens
+	emitens := scopeEmitens(all, job.symbols)
+
+	run := scheduledRun{
+		Job:      job.Name,
+		Strategy: job.Strategy,
+		Slot:     slot,
+		RunAt:    s.now().In(s.loc),
+		IHSG:     ihsg,
+		Meta:     meta,
+	}
+	for _, f := range market.Failed {
+		run.Failed = append(run.Failed, f.Error())
+	}
+	if note := failedNote(market.Failed); note != "" {
+		log.Printf("job %s: %s", job.Name, note)
+	}
+	if note := staleNote(market.Stale); note != "" {
+		log.Printf("job %s: data basi, %s", job.Name, note)
+	}
+	if job.Strategy == "bsjp" || job.Strategy == "all" {
+		run.BSJP = scanBSJP(emitens)
+	}
+	if job.Strategy == "bpjs" || job.Strategy == "all" {
+		run.BPJS = scanBPJS(emitens)
+	}
+
+	if err := s.saveRun(run); err != nil {
+		return err
+	}
+	for _, format := range s.export {
+		if format == "report" {
+			rep := newDailyReport(ctx, ihsg, emitens, market.Sectors, s.foreignAPI)
+			rep.GeneratedAt, rep.Meta = run.RunAt, meta
+			if _, err := writeReport(s.resultsDir, run.Slot.Format("1504")+"_"+job.Name, rep); err != nil {
+				return err
+			}
+		}
+	}
+
+	s.lastRun[job.Name] = slot
+	if err := s.saveState(); err != nil {
+		return err
+	}
+
+	if s.alerts != nil {
+		if _, err := s.alerts.Notify(ctx, "bsjp", run.BSJP); err != nil {
+			log.Printf("alert job %s: %v", job.Name, err)
+		}
+		if _, err := s.alerts.Notify(ctx, "bpjs", run.BPJS); err != nil {
+			log.Printf("alert job %s: %v", job.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *scheduler) saveRun(run scheduledRun) error {
+	base := filepath.Join(s.resultsDir, run.Slot.Format("2006-01-02_1504")+"_"+run.Job)
+
+	for _, format := range s.export {
+		switch format {
+		case "json":
+			data, err := json.MarshalIndent(run, "", "  ")
+			if err != nil {
+				return err
+			}
+			if err := writeFileAtomic(base+".json", data); err != nil {
+				return err
+			}
+		case "csv":
+			var b strings.Builder
+			w := csv.NewWriter(&b)
+			w.Write([]string{"strategy", "symbol", "name", "sector", "price", "change", "target", "stop_loss", "score", "signal"})
+			rows := append(append([]ScanResult{}, run.BSJP...), run.BPJS...)
+			for i, r := range rows {
+				strategy := "BSJP"
+				if i >= len(run.BSJP) {
+					strategy = "BPJS"
+				}
+				w.Write([]string{
+					strategy, r.Symbol, r.Name, r.Sector,
+					strconv.FormatFloat(r.Price, 'f', 0, 64),
+					strconv.FormatFloat(r.Change, 'f', 2, 64),
+					strconv.FormatFloat(r.Target, 'f', 0, 64),
+					strconv.FormatFloat(r.StopLoss, 'f', 0, 64),
+					strconv.FormatFloat(r.Score, 'f', 0, 64),
+					r.Signal,
+				})
+			}
+			w.Flush()
+			if err := writeFileAtomic(base+".csv", []byte(b.String())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *scheduler) saveState() error {
+	data, err := json.MarshalIndent(s.lastRun, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.stateFile, data)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func runScheduler(s *scheduler) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for _, job := range s.jobs {
+		log.Printf("job %s (%s, cron %q) berikutnya %s", job.Name, job.Strategy, job.Cron,
+			s.nextSlot(job, s.now()).Format("2006-01-02 15:04 MST"))
+	}
+
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	s.tick(ctx)
+	for {
+		select {
+		case <-ticker.C:
+			s.tick(ctx)
+		case <-ctx.Done():
+			log.Println("scheduler dihentikan")
+			return nil
+		}
+	}
+}
+
+type Trade struct {
+	ID         int        `json:"id"`
+	Symbol     string     `json:"symbol"`
+	Name       string     `json:"name"`
+	Strategy   string     `json:"strategy"`
+	Signal     string     `json:"signal"`
+	Score      float64    `json:"score"`
+	Lots       int64      `json:"lots"`
+	EntryPrice float64    `json:"entry_price"`
+	Target     float64    `json:"target"`
+	StopLoss   float64    `json:"stop_loss"`
+	EntryTime  time.Time  `json:"entry_time"`
+	LastPrice  float64    `json:"last_price"`
+	Status     string     `json:"status"`
+	ExitPrice  float64    `json:"exit_price,omitempty"`
+	ExitTime   *time.Time `json:"exit_time,omitempty"`
+}
+
+type tradeStat struct {
+	Trades int
+	Wins   int
+	PnL    float64
+}
+
+type journal struct {
+	path   string
+	NextID int     `json:"next_id"`
+	Trades []Trade `json:"trades"`
+}
+
+const sharesPerLot = 100
+
+func loadJournal(path string) (*journal, error) {
+	j := &journal{path: path, NextID: 1}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, fmt.Errorf("jurnal %s rusak: %v", path, err)
+	}
+
+	return j, nil
+}
+
+func (j *journal) save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(j.path, data)
+}
+
+func (j *journal) add(t Trade) Trade {
+	t.ID = j.NextID
+	t.Status = "OPEN"
+	t.LastPrice = t.EntryPrice
+	j.NextID++
+	j.Trades = append(j.Trades, t)
+	return t
+}
+
+func (j *journal) addFromResult(r ScanResult, strategy string, lots int64, at time.Time) Trade {
+	return j.add(Trade{
+		Symbol:     r.Symbol,
+		Name:       r.Name,
+		Strategy:   strings.ToUpper(strategy),
+		Signal:     r.Signal,
+		Score:      r.Score,
+		Lots:       lots,
+		EntryPrice: r.Price,
+		Target:     r.Target,
+		StopLoss:   r.StopLoss,
+		EntryTime:  at,
+	})
+}
+
+func (j *journal) find(id int) *Trade {
+	for i := range j.Trades {
+		if j.Trades[i].ID == id {
+			return &j.Trades[i]
+		}
+	}
+	return nil
+}
+
+func (t *Trade) close(status string, price float64, at time.Time) {
+	t.Status = status
+	t.ExitPrice = price
+	t.LastPrice = price
+	t.ExitTime = &at
+}
+
+func (t Trade) pnl() float64 {
+	price := t.LastPrice
+	if t.Status != "OPEN" {
+		price = t.ExitPrice
+	}
+	return (price - t.EntryPrice) * float64(t.Lots*sharesPerLot)
+}
+
+func (t Trade) pnlPercent() float64 {
+	price := t.LastPrice
+	if t.Status != "OPEN" {
+		price = t.ExitPrice
+	}
+	return (price - t.EntryPrice) / t.EntryPrice * 100
+}
+
+func (j *journal) updatePrices(emitens []Emiten, at time.Time) ([]Trade, bool) {
+	bySymbol := make(map[string]Emiten)
+	for _, e := range emitens {
+		bySymbol[e.Symbol] = e
+	}
+
+	var exited []Trade
+	changed := false
+	for i := range j.Trades {
+		t := &j.Trades[i]
+		e, ok := bySymbol[t.Symbol]
+		if t.Status != "OPEN" || !ok {
+			continue
+		}
+
+		if t.LastPrice != e.Price {
+			t.LastPrice = e.Price
+			changed = true
+		}
+		switch {
+		case t.StopLoss > 0 && e.Low <= t.StopLoss:
+			t.close("SL", t.StopLoss, at)
+		case t.Target > 0 && e.High >= t.Target:
+			t.close("TP", t.Target, at)
+		default:
+			continue
+		}
+		exited = append(exited, *t)
+		changed = true
+	}
+
+	return exited, changed
+}
+
+func (j *journal) summary() (realized, unrealized float64, byStrategy, bySignal map[string]*tradeStat) {
+	byStrategy = make(map[string]*tradeStat)
+	bySignal = make(map[string]*tradeStat)
+
+	for _, t := range j.Trades {
+		if t.Status == "OPEN" {
+			unrealized += t.pnl()
+			continue
+		}
+
+		realized += t.pnl()
+		for _, m := range []struct {
+			stats map[string]*tradeStat
+			key   string
+		}{{byStrategy, t.Strategy}, {bySignal, t.Signal}} {
+			st, ok := m.stats[m.key]
+			if !ok {
+				st = &tradeStat{}
+				m.stats[m.key] = st
+			}
+			st.Trades++
+			st.PnL += t.pnl()
+			if t.pnl() > 0 {
+				st.Wins++
+			}
+		}
+	}
+
+	return realized, unrealized, byStrategy, bySignal
+}
+
+func pnlColor(v float64) string {
+	if v < 0 {
+		return "\033[31m"
+	}
+	return "\033[32m"
+}
+
+func printJournal(j *journal) {
+	printHeader()
+	fmt.Println()
+	fmt.Println("\033[1;35m                           JURNAL TRADING\033[0m")
+	fmt.Println(strings.Repeat("-", 100))
+
+	fmt.Println("\n POSISI TERBUKA")
+	fmt.Printf(" %-4s %-7s %-6s %-11s %-5s %-10s %-10s %-10s %-10s %-14s %s\n",
+		"ID", "KODE", "STRAT", "SINYAL", "LOT", "ENTRY", "TERAKHIR", "TARGET", "STOP", "UNREALIZED", "%")
+	open := 0
+	for _, t := range j.Trades {
+		if t.Status != "OPEN" {
+			continue
+		}
+		open++
+		fmt.Printf(" %-4d %-7s %-6s %-11s %-5d %-10s %-10s %-10s %-10s %s%-14s %+.2f%%\033[0m\n",
+			t.ID, t.Symbol, t.Strategy, t.Signal, t.Lots, formatPrice(t.EntryPrice), formatPrice(t.LastPrice),
+			formatPrice(t.Target), formatPrice(t.StopLoss), pnlColor(t.pnl()), formatMoney(t.pnl()), t.pnlPercent())
+	}
+	if open == 0 {
+		fmt.Println(" (tidak ada posisi terbuka)")
+	}
+
+	fmt.Println("\n POSISI TERTUTUP (10 terakhir)")
+	fmt.Printf(" %-4s %-7s %-6s %-11s %-6s %-10s %-10s %-16s %-14s %s\n",
+		"ID", "KODE", "STRAT", "SINYAL", "EXIT", "ENTRY", "KELUAR", "TANGGAL", "REALIZED", "%")
+	var closed []Trade
+	for _, t := range j.Trades {
+		if t.Status != "OPEN" {
+			closed = append(closed, t)
+		}
+	}
+	if len(closed) > 10 {
+		closed = closed[len(closed)-10:]
+	}
+	for _, t := range closed {
+		fmt.Printf(" %-4d %-7s %-6s %-11s %-6s %-10s %-10s %-16s %s%-14s %+.2f%%\033[0m\n",
+			t.ID, t.Symbol, t.Strategy, t.Signal, t.Status, formatPrice(t.EntryPrice), formatPrice(t.ExitPrice),
+			t.ExitTime.Format("2006-01-02 15:04"), pnlColor(t.pnl()), formatMoney(t.pnl()), t.pnlPercent())
+	}
+	if len(closed) == 0 {
+		fmt.Println(" (belum ada posisi tertutup)")
+	}
+
+	realized, unrealized, byStrategy, bySignal := j.summary()
+	fmt.Println()
+	fmt.Println(strings.Repeat("-", 100))
+	fmt.Printf(" Realized P&L   : %s%s\033[0m\n", pnlColor(realized), formatMoney(realized))
+	fmt.Printf(" Unrealized P&L : %s%s\033[0m\n", pnlColor(unrealized), formatMoney(unrealized))
+
+	for _, group := range []struct {
+		title string
+		stats map[string]*tradeStat
+	}{{"Per Strategi", byStrategy}, {"Per Sinyal", bySignal}} {
+		if len(group.stats) == 0 {
+			continue
+		}
+		var keys []string
+		for k := range group.stats {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fmt.Printf("\n %s:\n", group.title)
+		for _, k := range keys {
+			st := group.stats[k]
+			fmt.Printf("   %-11s %3d trade  hit rate %5.1f%%  P&L %s%s\033[0m\n",
+				k, st.Trades, float64(st.Wins)/float64(st.Trades)*100, pnlColor(st.PnL), formatMoney(st.PnL))
+		}
+	}
+}
+
+func readInput(prompt string) string {
+	fmt.Print(prompt)
+	return readLine()
+}
+
+func runJournalMenu(j *journal, bsjpResults, bpjsResults []ScanResult) {
+	for {
+		printJournal(j)
+
+		fmt.Println()
+		fmt.Println(strings.Repeat("-", 100))
+		fmt.Println(" [a] Catat dari hasil scan  [m] Catat manual  [c] Tutup posisi  [Enter] Kembali")
+		choice := strings.ToLower(readInput(" Pilihan: "))
+
+		var err error
+		switch choice {
+		case "a":
+			err = journalAddFromScan(j, bsjpResults, bpjsResults)
+		case "m":
+			err = journalAddManual(j)
+		case "c":
+			err = journalClose(j)
+		default:
+			return
+		}
+
+		if err == nil {
+			err = j.save()
+		}
+		if err != nil {
+			fmt.Printf("\n \033[31m%v\033[0m\n Tekan Enter...", err)
+			readLine()
+		}
+	}
+}
+
+func journalAddFromScan(j *journal, bsjpResults, bpjsResults []ScanResult) error {
+	strategy := strings.ToLower(readInput(" Strategi (bsjp/bpjs): "))
+	results := bsjpResults
+	if strategy == "bpjs" {
+		results = bpjsResults
+	} else if strategy != "bsjp" {
+		return fmt.Errorf("strategi %q tidak dikenal", strategy)
+	}
+
+	symbol := strings.ToUpper(readInput(" Kode saham: "))
+	lots, err := strconv.ParseInt(readInput(" Jumlah lot: "), 10, 64)
+	if err != nil || lots <= 0 {
+		return errors.New("jumlah lot harus angka positif")
+	}
+
+	for _, r := range results {
+		if r.Symbol == symbol {
+			j.addFromResult(r, strategy, lots, time.Now())
+			return nil
+		}
+	}
+	return fmt.Errorf("%s tidak ada di hasil scan %s", symbol, strings.ToUpper(strategy))
+}
+
+func journalAddManual(j *journal) error {
+	symbol := strings.ToUpper(readInput(" Kode saham: "))
+	if symbol == "" {
+		return errors.New("kode saham wajib diisi")
+	}
+
+	var values [4]float64
+	for i, prompt := range []string{" Harga entry: ", " Target (0 = tanpa): ", " Stop loss (0 = tanpa): ", " Jumlah lot: "} {
+		v, err := strconv.ParseFloat(readInput(prompt), 64)
+		if err != nil || v < 0 {
+			return fmt.Errorf("nilai %q tidak valid", strings.TrimSpace(prompt))
+		}
+		values[i] = v
+	}
+	if values[0] <= 0 || values[3] < 1 {
+		return errors.New("harga entry dan jumlah lot harus lebih dari 0")
+	}
+
+	name := symbol
+	for _, e := range emitenList {
+		if e.symbol == symbol {
+			name = e.name
+		}
+	}
+
+	j.add(Trade{
+		Symbol:     symbol,
+		Name:       name,
+		Strategy:   "MANUAL",
+		Signal:     "-",
+		Lots:       int64(values[3]),
+		EntryPrice: values[0],
+		Target:     values[1],
+		StopLoss:   values[2],
+		EntryTime:  time.Now(),
+	})
+	return nil
+}
+
+func journalClose(j *journal) error {
+	id, err := strconv.Atoi(readInput(" ID posisi: "))
+	if err != nil {
+		return errors.New("ID harus angka")
+	}
+	t := j.find(id)
+	if t == nil || t.Status != "OPEN" {
+		return fmt.Errorf("posisi terbuka #%d tidak ditemukan", id)
+	}
+
+	price := t.LastPrice
+	if v := readInput(fmt.Sprintf(" Harga keluar [%s]: ", formatPrice(price))); v != "" {
+		if price, err = strconv.ParseFloat(v, 64); err != nil || price <= 0 {
+			return errors.New("harga keluar tidak valid")
+		}
+	}
+
+	t.close("CLOSED", price, time.Now())
+	return nil
+}
+
+type watchlistStore struct {
+	path     string
+	Profiles map[string]map[string][]string `json:"profiles"`
+}
+
+var watchedSymbols = map[string]bool{}
+
+func defaultProfile() string {
+	if p := os.Getenv("IDX_PROFILE"); p != "" {
+		return p
+	}
+	return "default"
+}
+
+func loadWatchlists(path string) (*watchlistStore, error) {
+	store := &watchlistStore{path: path, Profiles: make(map[string]map[string][]string)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("watchlist %s rusak: %v", path, err)
+	}
+	if store.Profiles == nil {
+		store.Profiles = make(map[string]map[string][]string)
+	}
+	for _, lists := range store.Profiles {
+		for name, list := range lists {
+			if list == nil {
+				lists[name] = []string{}
+			}
+		}
+	}
+
+	return store, nil
+}
+
+func (w *watchlistStore) save() error {
+	data, err := json.MarshalIndent(w, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(w.path, data)
+}
+
+func (w *watchlistStore) get(profile, name string) ([]string, error) {
+	list, ok := w.Profiles[profile][name]
+	if !ok {
+		return nil, fmt.Errorf("watchlist %q tidak ada di profil %q", name, profile)
+	}
+	if list == nil {
+		list = []string{}
+	}
+	return list, nil
+}
+
+func (w *watchlistStore) members(profile string) map[string]bool {
+	members := make(map[string]bool)
+	for _, list := range w.Profiles[profile] {
+		for _, sym := range list {
+			members[sym] = true
+		}
+	}
+	return members
+}
+
+func validSymbol(symbol string) bool {
+	if symbol == "" || len(symbol) > 12 {
+		return false
+	}
+	for _, c := range symbol {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *watchlistStore) add(profile, name string, symbols []string) (int, error) {
+	var clean []string
+	for _, sym := range symbols {
+		sym = strings.ToUpper(strings.TrimSpace(sym))
+		if sym == "" {
+			continue
+		}
+		if !validSymbol(sym) {
+			return 0, fmt.Errorf("kode %q tidak valid", sym)
+		}
+		clean = append(clean, sym)
+	}
+	if len(clean) == 0 {
+		return 0, fmt.Errorf("tidak ada kode saham untuk ditambahkan ke %s", name)
+	}
+
+	if w.Profiles[profile] == nil {
+		w.Profiles[profile] = make(map[string][]string)
+	}
+	list := w.Profiles[profile][name]
+
+	existing := make(map[string]bool)
+	for _, sym := range list {
+		existing[sym] = true
+	}
+
+	added := 0
+	for _, sym := range clean {
+		if existing[sym] {
+			continue
+		}
+		existing[sym] = true
+		list = append(list, sym)
+		added++
+	}
+
+	sort.Strings(list)
+	w.Profiles[profile][name] = list
+	return added, nil
+}
+
+func (w *watchlistStore) remove(profile, name string, symbols []string) (int, error) {
+	list, err := w.get(profile, name)
+	if err != nil {
+		return 0, err
+	}
+
+	drop := make(map[string]bool)
+	for _, sym := range symbols {
+		drop[strings.ToUpper(strings.TrimSpace(sym))] = true
+	}
+
+	kept := []string{}
+	for _, sym := range list {
+		if !drop[sym] {
+			kept = append(kept, sym)
+		}
+	}
+
+	w.Profiles[profile][name] = kept
+	return len(list) - len(kept), nil
+}
+
+func (w *watchlistStore) deleteList(profile, name string) error {
+	if _, err := w.get(profile, name); err != nil {
+		return err
+	}
+	delete(w.Profiles[profile], name)
+	if len(w.Profiles[profile]) == 0 {
+		delete(w.Profiles, profile)
+	}
+	return nil
+}
+
+func readSymbolFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.Comment = '#'
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	var symbols []string
+	for _, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+		sym := strings.ToUpper(strings.TrimSpace(row[0]))
+		if sym == "" || sym == "SYMBOL" || sym == "KODE" {
+			continue
+		}
+		symbols = append(symbols, sym)
+	}
+	return symbols, nil
+}
+
+func (m ScanMeta) continuous() bool {
+	return m.DataDir != "" || m.Provider != ""
+}
+
+func (m ScanMeta) scope() []string {
+	if m.Watchlist != "" && m.Symbols == nil {
+		return []string{}
+	}
+	return m.Symbols
+}
+
+func scopeEmitens(emitens []Emiten, symbols []string) []Emiten {
+	if symbols == nil {
+		return emitens
+	}
+
+	keep := make(map[string]bool)
+	for _, sym := range symbols {
+		keep[sym] = true
+	}
+
+	scoped := []Emiten{}
+	for _, e := range emitens {
+		if keep[e.Symbol] {
+			scoped = append(scoped, e)
+		}
+	}
+	return scoped
+}
+
+func watchMark(symbol string) string {
+	if watchedSymbols[symbol] {
+		return "\033[1;36m*\033[0m"
+	}
+	return " "
+}
+
+func runWatchlistCommand(store *watchlistStore, profile string, args []string) error {
+	usage := errors.New("pemakaian: watchlist list | show <nama> | add <nama> KODE... | remove <nama> KODE... | delete <nama> | import <nama> <file> | export <nama> [file]")
+	if len(args) == 0 {
+		return usage
+	}
+
+	cmd, args := args[0], args[1:]
+	if cmd != "list" && len(args) == 0 {
+		return usage
+	}
+
+	switch cmd {
+	case "list":
+		var names []string
+		for name := range store.Profiles[profile] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			fmt.Printf("Profil %s belum punya watchlist.\n", profile)
+		}
+		for _, name := range names {
+			fmt.Printf("%-15s %2d saham  %s\n", name, len(store.Profiles[profile][name]), strings.Join(store.Profiles[profile][name], " "))
+		}
+		return nil
+
+	case "show":
+		list, err := store.get(profile, args[0])
+		if err != nil {
+			return err
+		}
+		for _, sym := range list {
+			fmt.Println(sym)
+		}
+		return nil
+
+	case "add":
+		n, err := store.add(profile, args[0], args[1:])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d saham ditambahkan ke %s (profil %s)\n", n, args[0], profile)
+
+	case "remove":
+		n, err := store.remove(profile, args[0], args[1:])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d saham dihapus dari %s (profil %s)\n", n, args[0], profile)
+
+	case "delete":
+		if err := store.deleteList(profile, args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Watchlist %s dihapus (profil %s)\n", args[0], profile)
+
+	case "import":
+		if len(args) < 2 {
+			return usage
+		}
+		symbols, err := readSymbolFile(args[1])
+		if err != nil {
+			return err
+		}
+		n, err := store.add(profile, args[0], symbols)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d saham diimpor ke %s (profil %s)\n", n, args[0], profile)
+
+	case "export":
+		list, err := store.get(profile, args[0])
+		if err != nil {
+			return err
+		}
+		out := os.Stdout
+		if len(args) > 1 {
+			f, err := os.Create(args[1])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		w := csv.NewWriter(out)
+		w.Write([]string{"symbol"})
+		for _, sym := range list {
+			w.Write([]string{sym})
+		}
+		w.Flush()
+		return w.Error()
+
+	default:
+		return usage
+	}
+
+	return store.save()
+}
+
+type tuiColumn struct {
+	Title string
+	Width int
+	Text  func(i int) string
+	Num   func(i int) float64
+	Color func(i int) string
+}
+
+type tuiView struct {
+	Name    string
+	Len     int
+	Columns []tuiColumn
+	Symbol  func(i int) string
+	Sector  func(i int) string
+	Detail  func(i int) []string
+	Chart   func(i int) []string
+}
+
+type tuiState struct {
+	sortCol int
+	desc    bool
+	cursor  int
+	offset  int
+}
+
+type tui struct {
+	title     string
+	width     int
+	views     []tuiView
+	states    []tuiState
+	active    int
+	search    string
+	searching bool
+	sectors   []string
+	sector    int
+	detail    bool
+	rows      []int
+	notes     []string
+	in        *bufio.Reader
+	restore   string
+}
+
+const (
+	keyUp = iota + 256
+	keyDown
+	keyLeft
+	keyRight
+	keyPgUp
+	keyPgDn
+	keyHome
+	keyEnd
+)
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+var stdin = bufio.NewReader(os.Stdin)
+
+func readLine() string {
+	line, _ := stdin.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+func rawModeAvailable() bool {
+	_, err := stty("-g")
+	return err == nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+func terminalHeight() int {
+	if out, err := stty("size"); err == nil {
+		var rows, cols int
+		if _, err := fmt.Sscan(out, &rows, &cols); err == nil && rows > 10 {
+			return rows
+		}
+	}
+	if n, err := strconv.Atoi(os.Getenv("LINES")); err == nil && n > 10 {
+		return n
+	}
+	return 24
+}
+
+func newTUI(title string, width int, views []tuiView, in *bufio.Reader) *tui {
+	t := &tui{title: title, width: width, views: views, in: in}
+
+	seen := make(map[string]bool)
+	for _, v := range views {
+		t.states = append(t.states, tuiState{sortCol: -1})
+		for i := 0; i < v.Len; i++ {
+			if sec := v.Sector(i); !seen[sec] {
+				seen[sec] = true
+				t.sectors = append(t.sectors, sec)
+			}
+		}
+	}
+	sort.Strings(t.sectors)
+
+	return t
+}
+
+func (t *tui) enterRaw() {
+	if saved, err := stty("-g"); err == nil {
+		t.restore = saved
+		stty("-icanon", "-echo", "min", "1")
+	}
+	fmt.Print("\033[?25l")
+}
+
+func (t *tui) leaveRaw() {
+	if t.restore != "" {
+		stty(t.restore)
+		t.restore = ""
+	}
+	fmt.Print("\033[?25h")
+}
+
+func (t *tui) readKey() (int, error) {
+	b, err := t.in.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != 27 || t.in.Buffered() == 0 {
+		return int(b), nil
+	}
+
+	next, _ := t.in.ReadByte()
+	if next != '[' && next != 'O' {
+		return 27, nil
+	}
+	code, _ := t.in.ReadByte()
+	switch code {
+	case 'A':
+		return keyUp, nil
+	case 'B':
+		return keyDown, nil
+	case 'C':
+		return keyRight, nil
+	case 'D':
+		return keyLeft, nil
+	case 'H':
+		return keyHome, nil
+	case 'F':
+		return keyEnd, nil
+	case '5', '6':
+		t.in.ReadByte()
+		if code == '5' {
+			return keyPgUp, nil
+		}
+		return keyPgDn, nil
+	}
+	return 27, nil
+}
+
+func (t *tui) filter() {
+	v := t.views[t.active]
+	st := &t.states[t.active]
+	query := strings.ToUpper(t.search)
+
+	t.rows = t.rows[:0]
+	for i := 0; i < v.Len; i++ {
+		if query != "" && !strings.Contains(v.Symbol(i), query) {
+			continue
+		}
+		if t.sector > 0 && v.Sector(i) != t.sectors[t.sector-1] {
+			continue
+		}
+		t.rows = append(t.rows, i)
+	}
+
+	if st.sortCol >= 0 {
+		col := v.Columns[st.sortCol]
+		sort.SliceStable(t.rows, func(a, b int) bool {
+			x, y := t.rows[a], t.rows[b]
+			if st.desc {
+				x, y = y, x
+			}
+			if col.Num != nil {
+				return col.Num(x) < col.Num(y)
+			}
+			return col.Text(x) < col.Text(y)
+		})
+	}
+
+	if st.cursor >= len(t.rows) {
+		st.cursor = len(t.rows) - 1
+	}
+	if st.cursor < 0 {
+		st.cursor = 0
+	}
+}
+
+func padCell(s string, width int) string {
+	r := []rune(s)
+	if len(r) > width-1 {
+		r = r[:width-1]
+	}
+	return string(r) + strings.Repeat(" ", width-len(r))
+}
+
+func (t *tui) render(height int) {
+	v := t.views[t.active]
+	st := &t.states[t.active]
+	var b strings.Builder
+
+	b.WriteString("\033[H\033[2J")
+	b.WriteString(strings.Repeat("=", t.width) + "\n")
+	b.WriteString(" \033[1m" + t.title + "\033[0m  ")
+	for i, view := range t.views {
+		if i == t.active {
+			b.WriteString("\033[7m " + view.Name + " \033[0m ")
+		} else {
+			b.WriteString(" " + view.Name + "  ")
+		}
+	}
+	b.WriteString("\n")
+
+	sortLabel := "-"
+	if st.sortCol >= 0 {
+		dir := "naik"
+		if st.desc {
+			dir = "turun"
+		}
+		sortLabel = v.Columns[st.sortCol].Title + " " + dir
+	}
+	sectorLabel := "Semua"
+	if t.sector > 0 {
+		sectorLabel = t.sectors[t.sector-1]
+	}
+	cursorMark := ""
+	if t.searching {
+		cursorMark = "_"
+	}
+	fmt.Fprintf(&b, " Urut: %-16s Sektor: %-14s Cari: %-10s %d/%d baris\n",
+		sortLabel, sectorLabel, t.search+cursorMark, len(t.rows), v.Len)
+	b.WriteString(strings.Repeat("-", t.width) + "\n")
+
+	b.WriteString(" ")
+	for i, col := range v.Columns {
+		title := col.Title
+		if i == st.sortCol {
+			if st.desc {
+				title += "v"
+			} else {
+				title += "^"
+			}
+		}
+		b.WriteString(padCell(title, col.Width))
+	}
+	b.WriteString("\n")
+
+	var detail []string
+	if t.detail && len(t.rows) > 0 {
+		detail = v.Detail(t.rows[st.cursor])
+	}
+
+	visible := height - 7 - len(t.notes)
+	if len(detail) > 0 {
+		visible -= len(detail) + 1
+	}
+	if visible < 3 {
+		visible = 3
+	}
+	if st.cursor < st.offset {
+		st.offset = st.cursor
+	}
+	if st.cursor >= st.offset+visible {
+		st.offset = st.cursor - visible + 1
+	}
+
+	for n := st.offset; n < st.offset+visible; n++ {
+		if n >= len(t.rows) {
+			b.WriteString("\n")
+			continue
+		}
+		i := t.rows[n]
+		selected := n == st.cursor
+
+		if selected {
+			b.WriteString("\033[7m>")
+		} else {
+			b.WriteString(watchMark(v.Symbol(i)))
+		}
+		for _, col := range v.Columns {
+			cell := padCell(col.Text(i), col.Width)
+			if col.Color != nil && !selected {
+				cell = col.Color(i) + cell + "\033[0m"
+			}
+			b.WriteString(cell)
+		}
+		if selected {
+			b.WriteString("\033[0m")
+		}
+		b.WriteString("\n")
+	}
+
+	if len(detail) > 0 {
+		b.WriteString(strings.Repeat("-", t.width) + "\n")
+		for _, line := range detail {
+			b.WriteString(" " + line + "\n")
+		}
+	}
+
+	for _, note := range t.notes {
+		b.WriteString(" " + note + "\n")
+	}
+	b.WriteString(strings.Repeat("-", t.width) + "\n")
+	if t.searching {
+		b.WriteString(" Ketik kode saham, [Enter] selesai, [Esc] hapus")
+	} else {
+		help := " Tab tabel  j/k gerak  </> urut  s balik  / cari  f sektor  d detail  c grafik  r scan  m menu  q keluar"
+		if t.views[t.active].Chart == nil {
+			help = strings.Replace(help, "  c grafik", "", 1)
+		}
+		b.WriteString(help)
+	}
+
+	fmt.Print(b.String())
+}
+
+func (t *tui) run() (string, error) {
+	t.enterRaw()
+	defer t.leaveRaw()
+
+	for {
+		t.filter()
+		height := terminalHeight()
+		t.render(height)
+
+		key, err := t.readKey()
+		if err != nil {
+			return "q", err
+		}
+
+		st := &t.states[t.active]
+		page := height - 10
+
+		if t.searching {
+			switch {
+			case key == '\n' || key == '\r':
+				t.searching = false
+			case key == 27:
+				t.search = ""
+				t.searching = false
+			case key == 127 || key == 8:
+				if len(t.search) > 0 {
+					t.search = t.search[:len(t.search)-1]
+				}
+			case key >= '0' && key <= '9' || key >= 'a' && key <= 'z' || key >= 'A' && key <= 'Z':
+				t.search += strings.ToUpper(string(rune(key)))
+				st.cursor = 0
+			}
+			continue
+		}
+
+		switch key {
+		case 'q', 'Q':
+			return "q", nil
+		case 'r', 'R':
+			return "r", nil
+		case 'm', 'M':
+			return "m", nil
+		case '\t':
+			t.active = (t.active + 1) % len(t.views)
+		case keyDown, 'j':
+			st.cursor++
+		case keyUp, 'k':
+			st.cursor--
+		case keyPgDn, ' ':
+			st.cursor += page
+		case keyPgUp, 'b':
+			st.cursor -= page
+		case keyHome, 'g':
+			st.cursor = 0
+		case keyEnd, 'G':
+			st.cursor = len(t.rows) - 1
+		case keyRight, '>', '.':
+			st.sortCol = (st.sortCol + 1) % len(t.views[t.active].Columns)
+			st.desc = t.views[t.active].Columns[st.sortCol].Num != nil
+		case keyLeft, '<', ',':
+			if st.sortCol <= 0 {
+				st.sortCol = len(t.views[t.active].Columns)
+			}
+			st.sortCol--
+			st.desc = t.views[t.active].Columns[st.sortCol].Num != nil
+		case 's':
+			st.desc = !st.desc
+		case '/':
+			t.searching = true
+		case 'f':
+			t.sector = (t.sector + 1) % (len(t.sectors) + 1)
+			st.cursor = 0
+		case 'd', '\r':
+			t.detail = !t.detail
+		case 'c', 'C':
+			if v := t.views[t.active]; v.Chart != nil && len(t.rows) > 0 {
+				fmt.Print("\033[H\033[2J" + strings.Join(v.Chart(t.rows[st.cursor]), "\n") + "\n\n Tekan tombol apa saja...")
+				if _, err := t.readKey(); err != nil {
+					return "q", err
+				}
+			}
+		case '\n':
+			if t.restore != "" {
+				t.detail = !t.detail
+			}
+		}
+	}
+}
+
+func changeColor(v float64) string {
+	if v < 0 {
+		return "\033[31m"
+	}
+	return "\033[32m"
+}
+
+func breakdownLine(components []ScoreComponent) string {
+	var parts []string
+	for _, c := range components {
+		parts = append(parts, fmt.Sprintf("%s %.0f/%.0f", c.Factor, c.Points, c.Max))
+	}
+	return strings.Join(parts, "  ")
+}
+
+func emitenDetail(e Emiten) []string {
+	lines := []string{
+		fmt.Sprintf("\033[1m%s\033[0m %s (%s)  Harga %s  Chg %+.2f%%  RSI %.1f  Vol %s / avg %s",
+			e.Symbol, e.Name, e.Sector, formatPrice(e.Price), e.Change, e.RSI, formatVol(e.Volume), formatVol(e.AvgVolume)),
+		fmt.Sprintf("Open %s  High %s  Low %s  Gap %+.2f%%  Volatilitas %.2f%%  Net asing %s",
+			formatPrice(e.Open), formatPrice(e.High), formatPrice(e.Low), e.GapPercent, e.Volatility, formatMoney(e.NetForeign)),
+		fmt.Sprintf("BSJP %.0f: %s", e.ScoreBSJP, breakdownLine(bsjpBreakdown(e))),
+		fmt.Sprintf("BPJS %.0f: %s", e.ScoreBPJS, breakdownLine(bpjsBreakdown(e))),
+	}
+	if fundamentalData != nil {
+		lines = append(lines, fundamentalLines(e.Fundamental)...)
+	}
+	return lines
+}
+
+func trendSpark(e Emiten, st chartStyle) string {
+	closes := barCloses(e.History)
+	if len(closes) > 12 {
+		closes = closes[len(closes)-12:]
+	}
+	return sparkline(closes, st)
+}
+
+func resultView(name string, results []ScanResult, bySymbol map[string]Emiten, exDiv bool, st chartStyle) tuiView {
+	view := tuiView{
+		Name: name,
+		Len:  len(results),
+		Columns: []tuiColumn{
+			{Title: "KODE", Width: 7, Text: func(i int) string { return results[i].Symbol }},
+			{Title: "NAMA", Width: 22, Text: func(i int) string { return results[i].Name }},
+			{Title: "SEKTOR", Width: 12, Text: func(i int) string { return results[i].Sector }},
+			{Title: "HARGA", Width: 10, Text: func(i int) string { return formatPrice(results[i].Price) },
+				Num: func(i int) float64 { return results[i].Price }},
+			{Title: "CHG%", Width: 8, Text: func(i int) string { return fmt.Sprintf("%.1f%%", results[i].Change) },
+				Num: func(i int) float64 { return results[i].Change }, Color: func(i int) string { return changeColor(results[i].Change) }},
+			{Title: "TARGET", Width: 10, Text: func(i int) string { return formatPrice(results[i].Target) },
+				Num: func(i int) float64 { return results[i].Target }},
+			{Title: "SL", Width: 10, Text: func(i int) string { return formatPrice(results[i].StopLoss) },
+				Num: func(i int) float64 { return results[i].StopLoss }},
+			{Title: "SCORE", Width: 7, Text: func(i int) string { return fmt.Sprintf("%.0f", results[i].Score) },
+				Num: func(i int) float64 { return results[i].Score }},
+			{Title: "SIGNAL", Width: 11, Text: func(i int) string { return results[i].Signal }},
+			{Title: "TREN", Width: 14, Text: func(i int) string { return trendSpark(bySymbol[results[i].Symbol], st) }},
+		},
+		Symbol: func(i int) string { return results[i].Symbol },
+		Sector: func(i int) string { return results[i].Sector },
+		Detail: func(i int) []string {
+			lines := emitenDetail(bySymbol[results[i].Symbol])
+			if note := exDividendNote(results[i].Symbol, results[i].Price); exDiv && note != "" {
+				lines = append(lines, note+" besok: harga pembukaan turun sebesar dividen")
+			}
+			return append(lines, "Alasan: "+results[i].Reason)
+		},
+		Chart: func(i int) []string { return renderChart(bySymbol[results[i].Symbol], st) },
+	}
+	if exDiv {
+		view.Columns = append(view.Columns, tuiColumn{Title: "XD", Width: 4,
+			Text: func(i int) string {
+				if exDividendNote(results[i].Symbol, results[i].Price) != "" {
+					return "XD"
+				}
+				return ""
+			},
+			Color: func(i int) string { return "\033[1;35m" }})
+	}
+	return view
+}
+
+func emitenViews(emitens []Emiten, bsjpResults, bpjsResults []ScanResult, st chartStyle) []tuiView {
+	bySymbol := make(map[string]Emiten)
+	for _, e := range emitens {
+		bySymbol[e.Symbol] = e
+	}
+
+	all := tuiView{
+		Name: "SEMUA",
+		Len:  len(emitens),
+		Columns: []tuiColumn{
+			{Title: "KODE", Width: 7, Text: func(i int) string { return emitens[i].Symbol }},
+			{Title: "NAMA", Width: 22, Text: func(i int) string { return emitens[i].Name }},
+			{Title: "SEKTOR", Width: 12, Text: func(i int) string { return emitens[i].Sector }},
+			{Title: "HARGA", Width: 10, Text: func(i int) string { return formatPrice(emitens[i].Price) },
+				Num: func(i int) float64 { return emitens[i].Price }},
+			{Title: "CHG%", Width: 8, Text: func(i int) string { return fmt.Sprintf("%.1f%%", emitens[i].Change) },
+				Num: func(i int) float64 { return emitens[i].Change }, Color: func(i int) string { return changeColor(emitens[i].Change) }},
+			{Title: "RSI", Width: 6, Text: func(i int) string { return fmt.Sprintf("%.0f", emitens[i].RSI) },
+				Num: func(i int) float64 { return emitens[i].RSI }},
+			{Title: "VOL", Width: 9, Text: func(i int) string { return formatVol(emitens[i].Volume) },
+				Num: func(i int) float64 { return float64(emitens[i].Volume) }},
+			{Title: "ASING", Width: 11, Text: func(i int) string { return formatMoney(emitens[i].NetForeign) },
+				Num: func(i int) float64 { return emitens[i].NetForeign }},
+			{Title: "BSJP", Width: 6, Text: func(i int) string { return fmt.Sprintf("%.0f", emitens[i].ScoreBSJP) },
+				Num: func(i int) float64 { return emitens[i].ScoreBSJP }},
+			{Title: "BPJS", Width: 6, Text: func(i int) string { return fmt.Sprintf("%.0f", emitens[i].ScoreBPJS) },
+				Num: func(i int) float64 { return emitens[i].ScoreBPJS }},
+			{Title: "TREN", Width: 14, Text: func(i int) string { return trendSpark(emitens[i], st) }},
+		},
+		Symbol: func(i int) string { return emitens[i].Symbol },
+		Sector: func(i int) string { return emitens[i].Sector },
+		Detail: func(i int) []string { return emitenDetail(emitens[i]) },
+		Chart:  func(i int) []string { return renderChart(emitens[i], st) },
+	}
+
+	return []tuiView{
+		resultView("BSJP", bsjpResults, bySymbol, true, st),
+		resultView("BPJS", bpjsResults, bySymbol, false, st),
+		all,
+	}
+}
+
+type Bar struct {
+	Date       time.Time `json:"date"`
+	Open       float64   `json:"open"`
+	High       float64   `json:"high"`
+	Low        float64   `json:"low"`
+	Close      float64   `json:"close"`
+	Volume     int64     `json:"volume"`
+	NetForeign float64   `json:"net_foreign"`
+}
+
+type chartStyle struct {
+	unicode bool
+	color   bool
+}
+
+const historyDays = 60
+
+func tradingDaysBack(end time.Time, n int) []time.Time {
+	days := make([]time.Time, n)
+	d := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, end.Location())
+	for i := n - 1; i >= 0; i-- {
+		for d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+			d = d.AddDate(0, 0, -1)
+		}
+		days[i] = d
+		d = d.AddDate(0, 0, -1)
+	}
+	return days
+}
+
+func barCloses(bars []Bar) []float64 {
+	closes := make([]float64, len(bars))
+	for i, b := range bars {
+		closes[i] = b.Close
+	}
+	return closes
+}
+
+func sma(values []float64, n int) []float64 {
+	out := make([]float64, len(values))
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= n {
+			sum -= values[i-n]
+		}
+		if i >= n-1 {
+			out[i] = sum / float64(n)
+		} else {
+			out[i] = math.NaN()
+		}
+	}
+	return out
+}
+
+func ema(values []float64, n int) []float64 {
+	out := make([]float64, len(values))
+	k := 2 / float64(n+1)
+	for i, v := range values {
+		if i == 0 {
+			out[i] = v
+			continue
+		}
+		out[i] = v*k + out[i-1]*(1-k)
+	}
+	return out
+}
+
+func rsiSeries(closes []float64, period int) []float64 {
+	out := make([]float64, len(closes))
+	var avgGain, avgLoss float64
+	for i := range closes {
+		out[i] = math.NaN()
+		if i == 0 {
+			continue
+		}
+
+		delta := closes[i] - closes[i-1]
+		gain, loss := math.Max(delta, 0), math.Max(-delta, 0)
+		if i <= period {
+			avgGain += gain / float64(period)
+			avgLoss += loss / float64(period)
+			if i < period {
+				continue
+			}
+		} else {
+			avgGain = (avgGain*float64(period-1) + gain) / float64(period)
+			avgLoss = (avgLoss*float64(period-1) + loss) / float64(period)
+		}
+
+		if avgLoss == 0 {
+			out[i] = 100
+		} else {
+			out[i] = 100 - 100/(1+avgGain/avgLoss)
+		}
+	}
+	return out
+}
+
+func macdSeries(closes []float64) (line, signal, hist []float64) {
+	fast, slow := ema(closes, 12), ema(closes, 26)
+	line = make([]float64, len(closes))
+	for i := range closes {
+		line[i] = fast[i] - slow[i]
+	}
+	signal = ema(line, 9)
+	hist = make([]float64, len(closes))
+	for i := range closes {
+		hist[i] = line[i] - signal[i]
+	}
+	return line, signal, hist
+}
+
+func detectChartStyle(forceASCII bool) chartStyle {
+	if forceASCII {
+		return chartStyle{}
+	}
+
+	locale := strings.ToUpper(os.Getenv("LC_ALL") + os.Getenv("LC_CTYPE") + os.Getenv("LANG"))
+	return chartStyle{
+		unicode: strings.Contains(locale, "UTF-8") || strings.Contains(locale, "UTF8") || os.Getenv("WT_SESSION") != "",
+		color:   os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb",
+	}
+}
+
+func (s chartStyle) paint(code, text string) string {
+	if !s.color || text == " " {
+		return text
+	}
+	return code + text + "\033[0m"
+}
+
+func (s chartStyle) pick(unicode, ascii string) string {
+	if s.unicode {
+		return unicode
+	}
+	return ascii
+}
+
+func sparkline(values []float64, st chartStyle) string {
+	ramp := []rune("_.-~=^*#")
+	if st.unicode {
+		ramp = []rune("▁▂▃▄▅▆▇█")
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+
+	out := make([]rune, len(values))
+	for i, v := range values {
+		idx := 0
+		if hi > lo {
+			idx = int((v - lo) / (hi - lo) * float64(len(ramp)-1))
+		}
+		out[i] = ramp[idx]
+	}
+	return string(out)
+}
+
+func newGrid(height, width int) [][]string {
+	grid := make([][]string, height)
+	for y := range grid {
+		grid[y] = make([]string, width)
+		for x := range grid[y] {
+			grid[y][x] = " "
+		}
+	}
+	return grid
+}
+
+func gridLines(grid [][]string, label func(row int) string, st chartStyle) []string {
+	axis := st.pick("│", "|")
+	lines := make([]string, len(grid))
+	for i := range grid {
+		row := len(grid) - 1 - i
+		lines[i] = fmt.Sprintf("%10s %s%s", label(row), axis, strings.Join(grid[row], ""))
+	}
+	return lines
+}
+
+func renderCandles(bars []Bar, height int, st chartStyle) []string {
+	closes := barCloses(bars)
+	sma5, sma20 := sma(closes, 5), sma(closes, 20)
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, b := range bars {
+		lo, hi = math.Min(lo, b.Low), math.Max(hi, b.High)
+	}
+	row := func(p float64) int {
+		if hi == lo {
+			return 0
+		}
+		return int(math.Round((p - lo) / (hi - lo) * float64(height-1)))
+	}
+
+	grid := newGrid(height, len(bars))
+	for x, b := range bars {
+		color, body := "\033[32m", st.pick("┃", "#")
+		if b.Close < b.Open {
+			color, body = "\033[31m", st.pick("┃", "=")
+		}
+		for y := row(b.Low); y <= row(b.High); y++ {
+			grid[y][x] = st.paint(color, st.pick("│", "|"))
+		}
+		for y := row(math.Min(b.Open, b.Close)); y <= row(math.Max(b.Open, b.Close)); y++ {
+			grid[y][x] = st.paint(color, body)
+		}
+	}
+
+	for _, overlay := range []struct {
+		values []float64
+		mark   string
+		color  string
+	}{{sma5, st.pick("·", "."), "\033[33m"}, {sma20, st.pick("•", "*"), "\033[36m"}} {
+		for x, v := range overlay.values {
+			if math.IsNaN(v) || v < lo || v > hi {
+				continue
+			}
+			if y := row(v); grid[y][x] == " " {
+				grid[y][x] = st.paint(overlay.color, overlay.mark)
+			}
+		}
+	}
+
+	return gridLines(grid, func(r int) string {
+		if r%3 != 0 && r != height-1 {
+			return ""
+		}
+		return fmt.Sprintf("%.0f", lo+(hi-lo)*float64(r)/float64(height-1))
+	}, st)
+}
+
+func renderVolume(bars []Bar, height int, st chartStyle) []string {
+	blocks := []string{" ", "▁", "▂", "▃", "▄", "▅", "▆", "▇", "█"}
+
+	var maxVol int64
+	for _, b := range bars {
+		if b.Volume > maxVol {
+			maxVol = b.Volume
+		}
+	}
+
+	grid := newGrid(height, len(bars))
+	for x, b := range bars {
+		color := "\033[32m"
+		if b.Close < b.Open {
+			color = "\033[31m"
+		}
+		level := float64(b.Volume) / float64(maxVol) * float64(height)
+		for y := 0; y < height; y++ {
+			fill := level - float64(y)
+			switch {
+			case fill >= 1:
+				grid[y][x] = st.paint(color, st.pick("█", "#"))
+			case fill > 0 && st.unicode:
+				grid[y][x] = st.paint(color, blocks[int(fill*8)])
+			case fill >= 0.5:
+				grid[y][x] = st.paint(color, "#")
+			}
+		}
+	}
+
+	return gridLines(grid, func(r int) string {
+		if r != height-1 {
+			return ""
+		}
+		return formatVol(maxVol)
+	}, st)
+}
+
+func renderLine(values []float64, lo, hi float64, levels []float64, height int, st chartStyle) []string {
+	row := func(v float64) int {
+		return int(math.Round((math.Max(lo, math.Min(hi, v)) - lo) / (hi - lo) * float64(height-1)))
+	}
+
+	grid := newGrid(height, len(values))
+	labels := make(map[int]string)
+	for _, level := range levels {
+		y := row(level)
+		labels[y] = fmt.Sprintf("%.0f", level)
+		for x := range values {
+			grid[y][x] = st.paint("\033[2m", st.pick("┄", "-"))
+		}
+	}
+	for x, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		color := "\033[37m"
+		if v >= levels[len(levels)-1] {
+			color = "\033[31m"
+		} else if v <= levels[0] {
+			color = "\033[32m"
+		}
+		grid[row(v)][x] = st.paint(color, st.pick("•", "*"))
+	}
+
+	return gridLines(grid, func(r int) string { return labels[r] }, st)
+}
+
+func renderSignedBars(values []float64, height int, format func(float64) string, st chartStyle) []string {
+	maxAbs := 0.0
+	for _, v := range values {
+		maxAbs = math.Max(maxAbs, math.Abs(v))
+	}
+	half := height / 2
+
+	grid := newGrid(height, len(values))
+	for x, v := range values {
+		if maxAbs == 0 || math.IsNaN(v) {
+			continue
+		}
+		n := int(math.Round(math.Abs(v) / maxAbs * float64(half)))
+		for i := 0; i < n; i++ {
+			if v > 0 {
+				grid[half+i][x] = st.paint("\033[32m", st.pick("█", "+"))
+			} else {
+				grid[half-1-i][x] = st.paint("\033[31m", st.pick("█", "-"))
+			}
+		}
+	}
+
+	return gridLines(grid, func(r int) string {
+		switch r {
+		case height - 1:
+			return format(maxAbs)
+		case 0:
+			return format(-maxAbs)
+		}
+		return ""
+	}, st)
+}
+
+func renderChart(e Emiten, st chartStyle) []string {
+	bars := e.History
+	if len(bars) == 0 {
+		return []string{" Data historis " + e.Symbol + " tidak tersedia."}
+	}
+
+	closes := barCloses(bars)
+	rsi := rsiSeries(closes, 14)
+	_, _, hist := macdSeries(closes)
+	flows := make([]float64, len(bars))
+	for i, b := range bars {
+		flows[i] = b.NetForeign
+	}
+
+	section := func(title string) string {
+		return st.paint("\033[1m", " "+title)
+	}
+
+	lines := []string{
+		fmt.Sprintf(" %s %s (%s)  Close %s  %s  RSI %.1f  MACD hist %.2f",
+			st.paint("\033[1;36m", e.Symbol), e.Name, e.Sector, formatPrice(e.Price),
+			st.paint(changeColor(e.Change), fmt.Sprintf("%+.2f%%", e.Change)), e.RSI, e.MACD),
+		section(fmt.Sprintf("Harga %d hari  %s naik  %s turun  %s SMA5  %s SMA20", len(bars),
+			st.paint("\033[32m", st.pick("┃", "#")), st.paint("\033[31m", st.pick("┃", "=")),
+			st.paint("\033[33m", st.pick("·", ".")), st.paint("\033[36m", st.pick("•", "*")))),
+	}
+	lines = append(lines, renderCandles(bars, 14, st)...)
+	lines = append(lines, section("Volume"))
+	lines = append(lines, renderVolume(bars, 3, st)...)
+	lines = append(lines, section("RSI(14)"))
+	lines = append(lines, renderLine(rsi, 0, 100, []float64{30, 70}, 5, st)...)
+	lines = append(lines, section("MACD(12,26,9) histogram"))
+	lines = append(lines, renderSignedBars(hist, 4, func(v float64) string { return fmt.Sprintf("%.1f", v) }, st)...)
+	lines = append(lines, section("Net Asing"))
+	lines = append(lines, renderSignedBars(flows, 4, formatMoney, st)...)
+
+	axis := fmt.Sprintf("%-30s%30s", bars[0].Date.Format("02 Jan"), bars[len(bars)-1].Date.Format("02 Jan"))
+	lines = append(lines, strings.Repeat(" ", 12)+axis)
+
+	return lines
+}
+
+func printChart(e Emiten, st chartStyle) {
 	printHeader()
+	for _, line := range renderChart(e, st) {
+		fmt.Println(line)
+	}
+}
+
+func printChartPrompt(emitens []Emiten, st chartStyle) {
+	symbol := strings.ToUpper(readInput(" Kode saham: "))
+	for _, e := range emitens {
+		if e.Symbol == symbol {
+			printChart(e, st)
+			return
+		}
+	}
+	fmt.Printf(" Saham %s tidak ditemukan.\n", symbol)
+}
+
+type dailyReport struct {
+	GeneratedAt time.Time
+	IHSG        IndexData
+	Emitens     []Emiten
+	Sectors     []SectorStat
+	BSJP        []ScanResult
+	BPJS        []ScanResult
+	ForeignBuy  []foreignResult
+	ForeignSell []foreignResult
+	ForeignNote string
+	Meta        ScanMeta
+}
+
+type foreignResult struct {
+	Symbol          string  `json:"symbol"`
+	Name            string  `json:"name"`
+	Sector          string  `json:"sector"`
+	Price           float64 `json:"price"`
+	Change          float64 `json:"change"`
+	NetForeignValue float64 `json:"net_foreign_value"`
+	ForeignPercent  float64 `json:"foreign_percent"`
+	Score           float64 `json:"score"`
+	Strength        int     `json:"strength"`
+	Signal          string  `json:"signal"`
+}
+
+func (r foreignResult) rank() string {
+	if r.Score == 0 {
+		return strings.Repeat("*", r.Strength)
+	}
+	return fmt.Sprintf("%.0f", r.Score)
+}
+
+type reportPick struct {
+	Strategy   string
+	Result     ScanResult
+	Components []ScoreComponent
+}
+
+const (
+	reportRows  = 15
+	reportPicks = 3
+)
+
+const reportCSS = `body{font-family:Segoe UI,Helvetica,Arial,sans-serif;color:#222;margin:24px auto;max-width:980px}
+h1{margin-bottom:0}h2{border-bottom:2px solid #1d4e89;padding-bottom:4px;margin-top:28px}
+.sub{color:#666;margin-top:4px}table{border-collapse:collapse;width:100%;font-size:13px;margin:8px 0}
+th,td{border:1px solid #ddd;padding:4px 6px;text-align:left}th{background:#1d4e89;color:#fff}
+td.num{text-align:right}.up{color:#1a7f37}.down{color:#c0392b}.note{color:#666;font-style:italic}
+.pick{border:1px solid #ddd;border-radius:6px;padding:10px;margin:10px 0;page-break-inside:avoid}
+.pick h3{margin:0 0 6px}.row{display:flex;gap:16px;flex-wrap:wrap}
+@media print{body{margin:0}h2{page-break-after:avoid}table{page-break-inside:auto}tr{page-break-inside:avoid}}`
+
+func newDailyReport(ctx context.Context, ihsg IndexData, emitens []Emiten, sectors []SectorStat, foreignAPI string) dailyReport {
+	rep := dailyReport{
+		GeneratedAt: time.Now().In(jakartaLocation()),
+		IHSG:        ihsg,
+		Emitens:     emitens,
+		Sectors:     sectors,
+		BSJP:        scanBSJP(emitens),
+		BPJS:        scanBPJS(emitens),
+	}
+
+	if foreignAPI == "" {
+		rep.ForeignNote = "Tabel net foreign tidak disertakan (jalankan dengan -foreign-api)."
+		return rep
+	}
+
+	var err error
+	if rep.ForeignBuy, err = fetchForeign(ctx, foreignAPI, "/scan/foreign/buy"); err == nil {
+		rep.ForeignSell, err = fetchForeign(ctx, foreignAPI, "/scan/foreign/sell")
+	}
+	if err != nil {
+		rep.ForeignNote = "Data net foreign gagal diambil: " + err.Error()
+	}
+
+	return rep
+}
+
+func fetchForeign(ctx context.Context, base, path string) ([]foreignResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(base, "/")+path+"?top="+strconv.Itoa(reportRows), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: status %d", path, resp.StatusCode)
+	}
+
+	var body struct {
+		Results []foreignResult `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return body.Results, nil
+}
+
+func signalStats(results []ScanResult) (strongBuy, buy, watch int) {
+	for _, r := range results {
+		switch r.Signal {
+		case "STRONG BUY":
+			strongBuy++
+		case "BUY":
+			buy++
+		}
+	}
+	return strongBuy, buy, len(results) - strongBuy - buy
+}
+
+func (rep dailyReport) emiten(symbol string) Emiten {
+	for _, e := range rep.Emitens {
+		if e.Symbol == symbol {
+			return e
+		}
+	}
+	return Emiten{Symbol: symbol}
+}
+
+func (rep dailyReport) picks() []reportPick {
+	var picks []reportPick
+	for _, scan := range []struct {
+		name      string
+		results   []ScanResult
+		breakdown func(Emiten) []ScoreComponent
+	}{{"BSJP", rep.BSJP, bsjpBreakdown}, {"BPJS", rep.BPJS, bpjsBreakdown}} {
+		for i, r := range scan.results {
+			if i >= reportPicks {
+				break
+			}
+			picks = append(picks, reportPick{scan.name, r, scan.breakdown(rep.emiten(r.Symbol))})
+		}
+	}
+	return picks
+}
+
+func writeReport(dir, name string, rep dailyReport) (string, error) {
+	day := filepath.Join(dir, rep.GeneratedAt.Format("2006-01-02"))
+	if err := os.MkdirAll(day, 0755); err != nil {
+		return "", err
+	}
+
+	if err := writeFileAtomic(filepath.Join(day, name+".html"), renderReportHTML(rep)); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(filepath.Join(day, name+".md"), renderReportMarkdown(rep)); err != nil {
+		return "", err
+	}
+
+	return day, nil
+}
+
+func changeClass(v float64) string {
+	if v < 0 {
+		return "down"
+	}
+	return "up"
+}
+
+func svgSectorChart(sectors []SectorStat) string {
+	maxAbs := 0.1
+	for _, s := range sectors {
+		maxAbs = math.Max(maxAbs, math.Abs(s.AvgChange))
+	}
+
+	const rowH, labelW, barW = 20, 130, 420
+	zero := labelW + barW/2
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-size="12">`, labelW+barW+60, len(sectors)*rowH+10)
+	fmt.Fprintf(&b, `<line x1="%d" y1="0" x2="%d" y2="%d" stroke="#999"/>`, zero, zero, len(sectors)*rowH+10)
+	for i, s := range sectors {
+		y := i*rowH + 5
+		w := math.Abs(s.AvgChange) / maxAbs * barW / 2
+		x, color := float64(zero), "#1a7f37"
+		if s.AvgChange < 0 {
+			x, color = float64(zero)-w, "#c0392b"
+		}
+		fmt.Fprintf(&b, `<text x="0" y="%d">%s</text>`, y+13, html.EscapeString(s.Sector))
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%d" width="%.1f" height="%d" fill="%s"/>`, x, y+2, w, rowH-6, color)
+		fmt.Fprintf(&b, `<text x="%d" y="%d">%+.2f%%</text>`, labelW+barW+8, y+13, s.AvgChange)
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+func svgPriceLine(bars []Bar) string {
+	if len(bars) < 2 {
+		return ""
+	}
+
+	const w, h = 320, 110
+	closes := barCloses(bars)
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, c := range closes {
+		lo, hi = math.Min(lo, c), math.Max(hi, c)
+	}
+	if hi == lo {
+		hi = lo + 1
+	}
+
+	points := func(values []float64) string {
+		var pts []string
+		for i, v := range values {
+			if math.IsNaN(v) {
+				continue
+			}
+			x := float64(i) / float64(len(values)-1) * w
+			y := h - 5 - (v-lo)/(hi-lo)*(h-10)
+			pts = append(pts, fmt.Sprintf("%.1f,%.1f", x, y))
+		}
+		return strings.Join(pts, " ")
+	}
+
+	color := "#1a7f37"
+	if closes[len(closes)-1] < closes[0] {
+		color = "#c0392b"
+	}
+
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`+
+		`<rect width="%d" height="%d" fill="#fafafa" stroke="#ddd"/>`+
+		`<polyline points="%s" fill="none" stroke="#1d4e89" stroke-dasharray="4 3"/>`+
+		`<polyline points="%s" fill="none" stroke="%s" stroke-width="1.5"/></svg>`,
+		w, h, w, h, points(sma(closes, 20)), points(closes), color)
+}
+
+func svgBreakdown(components []ScoreComponent) string {
+	const rowH, labelW, barW = 18, 110, 200
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-size="12">`, labelW+barW+60, len(components)*rowH+4)
+	for i, c := range components {
+		y := i*rowH + 2
+		fill := 0.0
+		if c.Max > 0 {
+			fill = c.Points / c.Max * barW
+		}
+		fmt.Fprintf(&b, `<text x="0" y="%d">%s</text>`, y+12, html.EscapeString(c.Factor))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="#eee"/>`, labelW, y+2, barW, rowH-6)
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="#1d4e89"/>`, labelW, y+2, fill, rowH-6)
+		fmt.Fprintf(&b, `<text x="%d" y="%d">%.0f/%.0f</text>`, labelW+barW+8, y+12, c.Points, c.Max)
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+func htmlScanTable(b *strings.Builder, results []ScanResult) {
+	if len(results) == 0 {
+		b.WriteString(`<p class="note">Tidak ada emiten yang memenuhi kriteria.</p>`)
+		return
+	}
+
+	b.WriteString(`<table><tr><th>Kode</th><th>Nama</th><th>Sektor</th><th>Harga</th><th>Chg%</th><th>Target</th><th>SL</th><th>Score</th><th>Signal</th></tr>`)
+	for i, r := range results {
+		if i >= reportRows {
+			break
+		}
+		fmt.Fprintf(b, `<tr><td><b>%s</b></td><td>%s</td><td>%s</td><td class="num">%s</td><td class="num %s">%+.2f%%</td><td class="num">%s</td><td class="num">%s</td><td class="num">%.0f</td><td>%s</td></tr>`,
+			html.EscapeString(r.Symbol), html.EscapeString(r.Name), html.EscapeString(r.Sector), formatPrice(r.Price),
+			changeClass(r.Change), r.Change, formatPrice(r.Target), formatPrice(r.StopLoss), r.Score, r.Signal)
+	}
+	b.WriteString(`</table>`)
+}
+
+func htmlForeignTable(b *strings.Builder, results []foreignResult) {
+	if len(results) == 0 {
+		b.WriteString(`<p class="note">Tidak ada saham yang memenuhi kriteria.</p>`)
+		return
+	}
+
+	b.WriteString(`<table><tr><th>Kode</th><th>Nama</th><th>Sektor</th><th>Harga</th><th>Chg%</th><th>Net Asing</th><th>Asing%</th><th>Score</th><th>Signal</th></tr>`)
+	for _, r := range results {
+		fmt.Fprintf(b, `<tr><td><b>%s</b></td><td>%s</td><td>%s</td><td class="num">%s</td><td class="num %s">%+.2f%%</td><td class="num %s">%s</td><td class="num">%.0f%%</td><td class="num">%s</td><td>%s</td></tr>`,
+			html.EscapeString(r.Symbol), html.EscapeString(r.Name), html.EscapeString(r.Sector), formatPrice(r.Price),
+			changeClass(r.Change), r.Change, changeClass(r.NetForeignValue), formatMoney(r.NetForeignValue),
+			r.ForeignPercent, r.rank(), html.EscapeString(r.Signal))
+	}
+	b.WriteString(`</table>`)
+}
+
+func renderReportHTML(rep dailyReport) []byte {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html lang=\"id\"><head><meta charset=\"utf-8\"><title>Laporan Scanner %s</title><style>%s</style></head><body>\n",
+		rep.GeneratedAt.Format("2006-01-02"), reportCSS)
+	b.WriteString(`<h1>Laporan Harian Scanner IDX</h1>`)
+	fmt.Fprintf(&b, `<p class="sub">%s WIB &middot; IHSG 1H <span class="%s">%+.2f%%</span> &middot; 5H %+.2f%% &middot; 20H %+.2f%%</p>`,
+		rep.GeneratedAt.Format("02 Jan 2006 15:04"), changeClass(rep.IHSG.Change1D), rep.IHSG.Change1D, rep.IHSG.Change5D, rep.IHSG.Change20D)
+	if rep.Meta.AsOf != "" {
+		fmt.Fprintf(&b, `<p class="note">Seed %d &middot; data per %s &middot; replay: <code>emiten_scanner replay -seed %d -as-of %s</code></p>`,
+			rep.Meta.Seed, rep.Meta.AsOf, rep.Meta.Seed, rep.Meta.AsOf)
+	}
+
+	b.WriteString("\n<h2>Statistik Sinyal</h2>")
+	fmt.Fprintf(&b, `<p>Total emiten terscan: <b>%d</b></p>`, len(rep.Emitens))
+	b.WriteString(`<table><tr><th>Strategi</th><th>Lolos</th><th>STRONG BUY</th><th>BUY</th><th>WATCH</th></tr>`)
+	for _, s := range []struct {
+		name    string
+		results []ScanResult
+	}{{"BSJP", rep.BSJP}, {"BPJS", rep.BPJS}} {
+		strong, buy, watch := signalStats(s.results)
+		fmt.Fprintf(&b, `<tr><td>%s</td><td class="num">%d</td><td class="num">%d</td><td class="num">%d</td><td class="num">%d</td></tr>`,
+			s.name, len(s.results), strong, buy, watch)
+	}
+	b.WriteString(`</table>`)
+
+	b.WriteString("\n<h2>BSJP - Beli Sore Jual Pagi</h2>")
+	htmlScanTable(&b, rep.BSJP)
+	b.WriteString("\n<h2>BPJS - Beli Pagi Jual Sore</h2>")
+	htmlScanTable(&b, rep.BPJS)
+
+	b.WriteString("\n<h2>Net Foreign</h2>")
+	if rep.ForeignNote != "" {
+		fmt.Fprintf(&b, `<p class="note">%s</p>`, html.EscapeString(rep.ForeignNote))
+	} else {
+		b.WriteString(`<h3>Net Foreign Buy</h3>`)
+		htmlForeignTable(&b, rep.ForeignBuy)
+		b.WriteString(`<h3>Net Foreign Sell</h3>`)
+		htmlForeignTable(&b, rep.ForeignSell)
+	}
+
+	b.WriteString("\n<h2>Ringkasan Sektor</h2>")
+	b.WriteString(svgSectorChart(rep.Sectors))
+	b.WriteString(`<table><tr><th>Sektor</th><th>Jml</th><th>Avg%</th><th>Adv/Dec</th><th>Net Asing</th><th>RS 1H</th><th>RS 5H</th><th>RS 20H</th><th>Status</th></tr>`)
+	for _, s := range rep.Sectors {
+		fmt.Fprintf(&b, `<tr><td>%s</td><td class="num">%d</td><td class="num %s">%+.2f%%</td><td class="num">%d/%d</td><td class="num %s">%s</td><td class="num">%+.1f</td><td class="num">%+.1f</td><td class="num">%+.1f</td><td>%s</td></tr>`,
+			html.EscapeString(s.Sector), s.Count, changeClass(s.AvgChange), s.AvgChange, s.Advancers, s.Decliners,
+			changeClass(s.NetForeign), formatMoney(s.NetForeign), s.RS1D, s.RS5D, s.RS20D, s.Status)
+	}
+	b.WriteString(`</table>`)
+
+	b.WriteString("\n<h2>Top Pick &amp; Rincian Score</h2>")
+	for _, p := range rep.picks() {
+		e := rep.emiten(p.Result.Symbol)
+		fmt.Fprintf(&b, `<div class="pick"><h3>%s &middot; %s <small>%s</small></h3>`, p.Strategy, html.EscapeString(p.Result.Symbol), html.EscapeString(p.Result.Name))
+		fmt.Fprintf(&b, `<p>Harga %s (<span class="%s">%+.2f%%</span>) &middot; Target %s &middot; SL %s &middot; Score <b>%.0f</b> %s</p>`,
+			formatPrice(p.Result.Price), changeClass(p.Result.Change), p.Result.Change, formatPrice(p.Result.Target),
+			formatPrice(p.Result.StopLoss), p.Result.Score, p.Result.Signal)
+		fmt.Fprintf(&b, `<div class="row">%s%s</div>`, svgPriceLine(e.History), svgBreakdown(p.Components))
+		fmt.Fprintf(&b, `<p class="note">%s</p></div>`, html.EscapeString(p.Result.Reason))
+	}
+
+	b.WriteString("\n<p class=\"note\">Data simulasi untuk edukasi, bukan rekomendasi investasi.</p>\n</body></html>\n")
+	return []byte(b.String())
+}
+
+func renderReportMarkdown(rep dailyReport) []byte {
+	var b strings.Builder
+	st := chartStyle{unicode: true}
+
+	fmt.Fprintf(&b, "# Laporan Harian Scanner IDX\n\n%s WIB  \nIHSG 1H %+.2f%% | 5H %+.2f%% | 20H %+.2f%%\n\n",
+		rep.GeneratedAt.Format("02 Jan 2006 15:04"), rep.IHSG.Change1D, rep.IHSG.Change5D, rep.IHSG.Change20D)
+	if rep.Meta.AsOf != "" {
+		fmt.Fprintf(&b, "_Seed %d, data per %s. Replay: `emiten_scanner replay -seed %d -as-of %s`_\n\n",
+			rep.Meta.Seed, rep.Meta.AsOf, rep.Meta.Seed, rep.Meta.AsOf)
+	}
+
+	fmt.Fprintf(&b, "## Statistik Sinyal\n\nTotal emiten terscan: **%d**\n\n", len(rep.Emitens))
+	b.WriteString("| Strategi | Lolos | STRONG BUY | BUY | WATCH |\n|---|--:|--:|--:|--:|\n")
+	for _, s := range []struct {
+		name    string
+		results []ScanResult
+	}{{"BSJP", rep.BSJP}, {"BPJS", rep.BPJS}} {
+		strong, buy, watch := signalStats(s.results)
+		fmt.Fprintf(&b, "| %s | %d | %d | %d | %d |\n", s.name, len(s.results), strong, buy, watch)
+	}
+
+	for _, s := range []struct {
+		title   string
+		results []ScanResult
+	}{{"BSJP - Beli Sore Jual Pagi", rep.BSJP}, {"BPJS - Beli Pagi Jual Sore", rep.BPJS}} {
+		fmt.Fprintf(&b, "\n## %s\n\n", s.title)
+		if len(s.results) == 0 {
+			b.WriteString("_Tidak ada emiten yang memenuhi kriteria._\n")
+			continue
+		}
+		b.WriteString("| Kode | Nama | Sektor | Harga | Chg% | Target | SL | Score | Signal | Tren |\n|---|---|---|--:|--:|--:|--:|--:|---|---|\n")
+		for i, r := range s.results {
+			if i >= reportRows {
+				break
+			}
+			fmt.Fprintf(&b, "| **%s** | %s | %s | %s | %+.2f%% | %s | %s | %.0f | %s | %s |\n",
+				r.Symbol, r.Name, r.Sector, formatPrice(r.Price), r.Change, formatPrice(r.Target),
+				formatPrice(r.StopLoss), r.Score, r.Signal, trendSpark(rep.emiten(r.Symbol), st))
+		}
+	}
+
+	b.WriteString("\n## Net Foreign\n\n")
+	if rep.ForeignNote != "" {
+		fmt.Fprintf(&b, "_%s_\n", rep.ForeignNote)
+	} else {
+		for _, s := range []struct {
+			title   string
+			results []foreignResult
+		}{{"Net Foreign Buy", rep.ForeignBuy}, {"Net Foreign Sell", rep.ForeignSell}} {
+			fmt.Fprintf(&b, "### %s\n\n| Kode | Nama | Harga | Chg%% | Net Asing | Asing%% | Score | Signal |\n|---|---|--:|--:|--:|--:|--:|---|\n", s.title)
+			for _, r := range s.results {
+				fmt.Fprintf(&b, "| **%s** | %s | %s | %+.2f%% | %s | %.0f%% | %s | %s |\n",
+					r.Symbol, r.Name, formatPrice(r.Price), r.Change, formatMoney(r.NetForeignValue), r.ForeignPercent, r.rank(), r.Signal)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString("\n## Ringkasan Sektor\n\n| Sektor | Jml | Avg% | Adv/Dec | Net Asing | RS 1H | RS 5H | RS 20H | Status |\n|---|--:|--:|--:|--:|--:|--:|--:|---|\n")
+	for _, s := range rep.Sectors {
+		fmt.Fprintf(&b, "| %s | %d | %+.2f%% | %d/%d | %s | %+.1f | %+.1f | %+.1f | %s |\n",
+			s.Sector, s.Count, s.AvgChange, s.Advancers, s.Decliners, formatMoney(s.NetForeign), s.RS1D, s.RS5D, s.RS20D, s.Status)
+	}
+
+	b.WriteString("\n## Top Pick & Rincian Score\n")
+	for _, p := range rep.picks() {
+		fmt.Fprintf(&b, "\n### %s · %s %s\n\nHarga %s (%+.2f%%) · Target %s · SL %s · Score **%.0f** %s  \n60 hari: `%s`\n\n",
+			p.Strategy, p.Result.Symbol, p.Result.Name, formatPrice(p.Result.Price), p.Result.Change,
+			formatPrice(p.Result.Target), formatPrice(p.Result.StopLoss), p.Result.Score, p.Result.Signal,
+			sparkline(barCloses(rep.emiten(p.Result.Symbol).History), st))
+		b.WriteString("| Faktor | Nilai | Poin |\n|---|--:|--:|\n")
+		for _, c := range p.Components {
+			fmt.Fprintf(&b, "| %s | %.2f | %.0f/%.0f |\n", c.Factor, c.Value, c.Points, c.Max)
+		}
+		fmt.Fprintf(&b, "\n%s\n", p.Result.Reason)
+	}
+
+	b.WriteString("\n---\n_Data simulasi untuk edukasi, bukan rekomendasi investasi._\n")
+	return []byte(b.String())
+}
+
+type btSeries struct {
+	Symbol string
+	Name   string
+	Sector string
+	Bars   []Bar
+}
+
+type btEntry struct {
+	Emiten Emiten
+	Next   Bar
+}
+
+type btMarket struct {
+	Dates []time.Time
+	Days  [][]btEntry
+}
+
+type btTrade struct {
+	Strategy   string    `json:"strategy"`
+	Symbol     string    `json:"symbol"`
+	EntryDate  time.Time `json:"entry_date"`
+	ExitDate   time.Time `json:"exit_date"`
+	EntryPrice float64   `json:"entry_price"`
+	ExitPrice  float64   `json:"exit_price"`
+	Score      float64   `json:"score"`
+	Return     float64   `json:"return"`
+}
+
+type btStats struct {
+	Trades       int     `json:"trades"`
+	WinRate      float64 `json:"win_rate"`
+	Expectancy   float64 `json:"expectancy"`
+	ProfitFactor float64 `json:"profit_factor"`
+	Sharpe       float64 `json:"sharpe"`
+	TotalReturn  float64 `json:"total_return"`
+	MaxDrawdown  float64 `json:"max_drawdown"`
+}
+
+type btResult struct {
+	Trades []btTrade
+	Daily  []float64
+}
+
+const (
+	btWarmup       = 26
+	btMaxPositions = 3
+	btMinTrades    = 20
+	btForeignMin   = 10
+	feeBuy         = 0.0015
+	feeSell        = 0.0025
+)
+
+func syntheticSeries(seed int64, days int) []btSeries {
+	_, series := newMarketSimulator(seed).simulate(time.Now(), days)
+	return series
+}
+
+func loadHistoryCSV(path string) ([]btSeries, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	info := make(map[string]struct{ name, sector string })
+	for _, e := range emitenList {
+		info[e.symbol] = struct{ name, sector string }{e.name, e.sector}
+	}
+
+	bySymbol := make(map[string]*btSeries)
+	var order []string
+	for n, row := range rows {
+		if n == 0 && strings.EqualFold(strings.TrimSpace(row[0]), "date") {
+			continue
+		}
+		if len(row) < 7 {
+			return nil, fmt.Errorf("%s baris %d: butuh kolom date,symbol,open,high,low,close,volume[,net_foreign]", path, n+1)
+		}
+
+		bar, err := parseBar(append([]string{row[0]}, row[2:]...))
+		if err != nil {
+			return nil, fmt.Errorf("%s baris %d: %v", path, n+1, err)
+		}
+
+		symbol := strings.ToUpper(strings.TrimSpace(row[1]))
+		s, ok := bySymbol[symbol]
+		if !ok {
+			s = &btSeries{Symbol: symbol, Name: info[symbol].name, Sector: info[symbol].sector}
+			if s.Sector == "" {
+				s.Name, s.Sector = symbol, "Lainnya"
+			}
+			bySymbol[symbol] = s
+			order = append(order, symbol)
+		}
+		s.Bars = append(s.Bars, bar)
+	}
+
+	series := make([]btSeries, 0, len(order))
+	for _, symbol := range order {
+		s := bySymbol[symbol]
+		sort.Slice(s.Bars, func(i, j int) bool { return s.Bars[i].Date.Before(s.Bars[j].Date) })
+		adjusted, err := corpActions.adjust(*s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: aksi korporasi: %v", symbol, err)
+		}
+		series = append(series, adjusted)
+	}
+	return series, nil
+}
+
+func parseBar(row []string) (Bar, error) {
+	date, err := time.Parse("2006-01-02", strings.TrimSpace(row[0]))
+	if err != nil {
+		return Bar{}, fmt.Errorf("tanggal %q harus YYYY-MM-DD", row[0])
+	}
+	var values [5]float64
+	for i := range values {
+		if values[i], err = strconv.ParseFloat(strings.TrimSpace(row[i+1]), 64); err != nil {
+			return Bar{}, err
+		}
+	}
+	bar := Bar{Date: date, Open: values[0], High: values[1], Low: values[2], Close: values[3], Volume: int64(values[4])}
+	if len(row) > 6 {
+		bar.NetForeign, _ = strconv.ParseFloat(strings.TrimSpace(row[6]), 64)
+	}
+	return bar, nil
+}
+
+func loadSymbolCSV(path, symbol string) (btSeries, error) {
+	s := btSeries{Symbol: symbol, Name: symbol, Sector: "Lainnya"}
+	for _, e := range emitenList {
+		if e.symbol == symbol {
+			s.Name, s.Sector = e.name, e.sector
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return s, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return s, fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+
+	for n, row := range rows {
+		if n == 0 && strings.EqualFold(strings.TrimSpace(row[0]), "date") {
+			continue
+		}
+		if len(row) < 6 {
+			return s, fmt.Errorf("%s baris %d: butuh kolom date,open,high,low,close,volume[,net_foreign]", filepath.Base(path), n+1)
+		}
+		bar, err := parseBar(row)
+		if err != nil {
+			return s, fmt.Errorf("%s baris %d: %v", filepath.Base(path), n+1, err)
+		}
+		s.Bars = append(s.Bars, bar)
+	}
+
+	sort.Slice(s.Bars, func(i, j int) bool { return s.Bars[i].Date.Before(s.Bars[j].Date) })
+	return s, nil
+}
+
+func emitenAt(s btSeries, i int, rsi, hist []float64) Emiten {
+	bars := s.Bars
+	b, prev := bars[i], bars[i-1]
+
+	var avgVol int64
+	var atr float64
+	for k := i - 20; k < i; k++ {
+		avgVol += bars[k].Volume / 20
+	}
+	for k := i - 13; k <= i; k++ {
+		tr := math.Max(bars[k].High, bars[k-1].Close) - math.Min(bars[k].Low, bars[k-1].Close)
+		atr += tr / bars[k-1].Close * 100 / 14
+	}
+
+	e := Emiten{
+		Symbol:        s.Symbol,
+		Name:          s.Name,
+		Sector:        s.Sector,
+		Price:         b.Close,
+		Open:          b.Open,
+		High:          b.High,
+		Low:           b.Low,
+		PrevClose:     prev.Close,
+		Change:        (b.Close - prev.Close) / prev.Close * 100,
+		Volume:        b.Volume,
+		AvgVolume:     avgVol,
+		RSI:           rsi[i],
+		MACD:          hist[i],
+		GapPercent:    (b.Open - prev.Close) / prev.Close * 100,
+		Volatility:    atr,
+		MorningMoment: math.Max(0, math.Min(100, 50+(b.Close-b.Open)/b.Open*100*20)),
+		Change5D:      (b.Close - bars[i-5].Close) / bars[i-5].Close * 100,
+		Change20D:     (b.Close - bars[i-20].Close) / bars[i-20].Close * 100,
+		NetForeign:    b.NetForeign,
+	}
+	if b.High > b.Low {
+		e.AfternoonDip = (b.High - b.Close) / (b.High - b.Low) * 100
+	}
+	if avgVol == 0 {
+		e.AvgVolume = 1
+	}
+	return e
+}
+
+func buildMarket(series []btSeries) btMarket {
+	type point struct {
+		series int
+		idx    int
+	}
+	byDate := make(map[time.Time][]point)
+	indicators := make([][2][]float64, len(series))
+
+	for n, s := range series {
+		closes := barCloses(s.Bars)
+		_, _, hist := macdSeries(closes)
+		indicators[n] = [2][]float64{rsiSeries(closes, 14), hist}
+		for i := btWarmup; i < len(s.Bars)-1; i++ {
+			byDate[s.Bars[i].Date] = append(byDate[s.Bars[i].Date], point{n, i})
+		}
+	}
+
+	var m btMarket
+	for date := range byDate {
+		m.Dates = append(m.Dates, date)
+	}
+	sort.Slice(m.Dates, func(i, j int) bool { return m.Dates[i].Before(m.Dates[j]) })
+
+	for _, date := range m.Dates {
+		var day []btEntry
+		var emitens []Emiten
+		for _, p := range byDate[date] {
+			s := series[p.series]
+			emitens = append(emitens, emitenAt(s, p.idx, indicators[p.series][0], indicators[p.series][1]))
+			day = append(day, btEntry{Next: s.Bars[p.idx+1]})
+		}
+
+		var ihsg IndexData
+		for _, e := range emitens {
+			n := float64(len(emitens))
+			ihsg.Change1D += e.Change / n
+			ihsg.Change5D += e.Change5D / n
+			ihsg.Change20D += e.Change20D / n
+		}
+		bonus := make(map[string]float64)
+		for _, s := range analyzeSectors(emitens, ihsg) {
+			bonus[s.Sector] = sectorBonus(s)
+		}
+
+		for i := range day {
+			emitens[i].SectorBonus = bonus[emitens[i].Sector]
+			day[i].Emiten = emitens[i]
+		}
+		m.Days = append(m.Days, day)
+	}
+
+	return m
+}
+
+func (p StrategyParams) score(strategy string, e Emiten) float64 {
+	if strategy == "foreign" {
+		if e.Volume == 0 {
+			return 0
+		}
+		return e.NetForeign / (e.Price * float64(e.Volume)) * 100
+	}
+	if strategy == "bpjs" {
+		return totalScore(p.BPJS.components(e.RSI, e.Change, e.Volatility, e.MorningMoment, e.SectorBonus, e.Volume, e.AvgVolume))
+	}
+	return totalScore(p.BSJP.components(e.RSI, e.Change, e.Volatility, e.GapPercent, e.AfternoonDip, e.SectorBonus, e.Volume, e.AvgVolume))
+}
+
+func (p StrategyParams) minScore(strategy string) float64 {
+	if strategy == "foreign" {
+		return btForeignMin
+	}
+	if strategy == "bpjs" {
+		return p.BPJS.MinScore
+	}
+	return p.BSJP.MinScore
+}
+
+func simulateTrade(strategy string, e Emiten, next Bar) (entry, exit float64) {
+	if strategy == "bpjs" {
+		entry = next.Open
+		target, stopLoss := entry*(1+e.Volatility*0.4/100), entry*0.985
+		switch {
+		case next.Low <= stopLoss:
+			return entry, stopLoss
+		case next.High >= target:
+			return entry, target
+		}
+		return entry, next.Close
+	}
+	return e.Price, next.Open
+}
+
+func runBacktest(m btMarket, p StrategyParams, strategy string, from, to int) btResult {
+	var res btResult
+	cutoff := p.minScore(strategy)
+
+	type pick struct {
+		entry btEntry
+		score float64
+	}
+	for d := from; d < to; d++ {
+		var picks []pick
+		for _, entry := range m.Days[d] {
+			if score := p.score(strategy, entry.Emiten); score >= cutoff {
+				picks = append(picks, pick{entry, score})
+			}
+		}
+		sort.Slice(picks, func(i, j int) bool { return picks[i].score > picks[j].score })
+		if len(picks) > btMaxPositions {
+			picks = picks[:btMaxPositions]
+		}
+
+		daily := 0.0
+		for _, pk := range picks {
+			entry, exit := simulateTrade(strategy, pk.entry.Emiten, pk.entry.Next)
+			ret := exit*(1-feeSell)/(entry*(1+feeBuy)) - 1
+			res.Trades = append(res.Trades, btTrade{
+				Strategy:   strings.ToUpper(strategy),
+				Symbol:     pk.entry.Emiten.Symbol,
+				EntryDate:  m.Dates[d],
+				ExitDate:   pk.entry.Next.Date,
+				EntryPrice: entry,
+				ExitPrice:  exit,
+				Score:      pk.score,
+				Return:     ret * 100,
+			})
+			daily += ret / float64(btMaxPositions)
+		}
+		res.Daily = append(res.Daily, daily)
+	}
+
+	return res
+}
+
+func (r btResult) stats() btStats {
+	var st btStats
+	st.Trades = len(r.Trades)
+
+	var wins int
+	var gross, loss float64
+	for _, t := range r.Trades {
+		st.Expectancy += t.Return
+		if t.Return > 0 {
+			wins++
+			gross += t.Return
+		} else {
+			loss -= t.Return
+		}
+	}
+	if st.Trades > 0 {
+		st.Expectancy /= float64(st.Trades)
+		st.WinRate = float64(wins) / float64(st.Trades) * 100
+	}
+	if loss > 0 {
+		st.ProfitFactor = gross / loss
+	}
+
+	equity, peak := 1.0, 1.0
+	var mean, sq float64
+	for _, d := range r.Daily {
+		equity *= 1 + d
+		peak = math.Max(peak, equity)
+		st.MaxDrawdown = math.Max(st.MaxDrawdown, (peak-equity)/peak*100)
+		mean += d
+	}
+	st.TotalReturn = (equity - 1) * 100
+
+	if n := float64(len(r.Daily)); n > 1 {
+		mean /= n
+		for _, d := range r.Daily {
+			sq += (d - mean) * (d - mean)
+		}
+		if std := math.Sqrt(sq / (n - 1)); std > 0 {
+			st.Sharpe = mean / std * math.Sqrt(252)
+		}
+	}
+
+	return st
+}
+
+func objectiveValue(objective string, st btStats) float64 {
+	if st.Trades < btMinTrades {
+		return math.Inf(-1)
+	}
+	if objective == "sharpe" {
+		return st.Sharpe
+	}
+	return st.Expectancy
+}
+
+type optimizeFold struct {
+	TrainFrom string         `json:"train_from"`
+	TrainTo   string         `json:"train_to"`
+	TestFrom  string         `json:"test_from"`
+	TestTo    string         `json:"test_to"`
+	Params    StrategyParams `json:"params"`
+	InSample  btStats        `json:"in_sample"`
+	OutSample btStats        `json:"out_of_sample"`
+	ISScore   float64        `json:"is_objective"`
+	OOSScore  float64        `json:"oos_objective"`
+}
+
+type paramStability struct {
+	Name  string  `json:"name"`
+	Mean  float64 `json:"mean"`
+	Std   float64 `json:"std"`
+	CV    float64 `json:"cv"`
+	Best  float64 `json:"best"`
+	Value float64 `json:"default"`
+}
+
+type optimizeReport struct {
+	Strategy      string           `json:"strategy"`
+	Objective     string           `json:"objective"`
+	Seed          int64            `json:"seed"`
+	Trials        int              `json:"trials"`
+	Symbols       int              `json:"symbols"`
+	Days          int              `json:"days"`
+	Folds         []optimizeFold   `json:"folds"`
+	OutOfSample   btStats          `json:"out_of_sample"`
+	Efficiency    float64          `json:"walk_forward_efficiency"`
+	Stability     []paramStability `json:"stability"`
+	Best          StrategyParams   `json:"best"`
+	BestStats     btStats          `json:"best_in_sample"`
+	DefaultStats  btStats          `json:"default_in_sample"`
+	OverfitRisk   string           `json:"overfit_risk"`
+	OverfitReason []string         `json:"overfit_reasons"`
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+func randomParams(rng *rand.Rand, base StrategyParams, strategy string) StrategyParams {
+	uniform := func(lo, hi float64) float64 {
+		return round1(lo + rng.Float64()*(hi-lo))
+	}
+
+	p := base
+	if strategy == "bpjs" {
+		rsiMin := uniform(45, 65)
+		changeMin := uniform(0, 1.5)
+		volMin := uniform(0.5, 2.5)
+		p.BPJS = BPJSParams{
+			RSIMin:        rsiMin,
+			RSIMax:        uniform(rsiMin+5, 85),
+			RSIWeak:       uniform(35, rsiMin),
+			ChangeMin:     changeMin,
+			ChangeMax:     uniform(changeMin+1, changeMin+5),
+			VolatilityMin: volMin,
+			VolatilityMax: uniform(volMin+0.5, volMin+3),
+			MinScore:      uniform(35, 75),
+		}
+		return p
+	}
+
+	rsiStrong := uniform(25, 45)
+	changeMin := uniform(-6, -1)
+	volMin := uniform(1, 3)
+	p.BSJP = BSJPParams{
+		RSIStrong:     rsiStrong,
+		RSIWeak:       uniform(rsiStrong+5, rsiStrong+20),
+		ChangeMin:     changeMin,
+		ChangeMax:     uniform(changeMin+0.5, 0),
+		VolatilityMin: volMin,
+		VolatilityMax: uniform(volMin+1, volMin+4),
+		MinScore:      uniform(35, 75),
+	}
+	return p
+}
+
+func paramValues(p StrategyParams, strategy string) ([]string, []float64) {
+	if strategy == "bpjs" {
+		b := p.BPJS
+		return []string{"rsi_min", "rsi_max", "rsi_weak", "change_min", "change_max", "volatility_min", "volatility_max", "min_score"},
+			[]float64{b.RSIMin, b.RSIMax, b.RSIWeak, b.ChangeMin, b.ChangeMax, b.VolatilityMin, b.VolatilityMax, b.MinScore}
+	}
+	b := p.BSJP
+	return []string{"rsi_strong", "rsi_weak", "change_min", "change_max", "volatility_min", "volatility_max", "min_score"},
+		[]float64{b.RSIStrong, b.RSIWeak, b.ChangeMin, b.ChangeMax, b.VolatilityMin, b.VolatilityMax, b.MinScore}
+}
+
+func searchParams(m btMarket, candidates []StrategyParams, strategy, objective string, from, to int) (StrategyParams, btStats, float64) {
+	best, bestScore := candidates[0], math.Inf(-1)
+	var bestStats btStats
+	for _, p := range candidates {
+		st := runBacktest(m, p, strategy, from, to).stats()
+		if score := objectiveValue(objective, st); score > bestScore {
+			best, bestStats, bestScore = p, st, score
+		}
+	}
+	return best, bestStats, bestScore
+}
+
+func optimize(rng *rand.Rand, m btMarket, base StrategyParams, strategy, objective string, trials, train, test int) (optimizeReport, error) {
+	rep := optimizeReport{Strategy: strings.ToUpper(strategy), Objective: objective, Trials: trials, Days: len(m.Dates)}
+	if len(m.Days) > 0 {
+		rep.Symbols = len(m.Days[len(m.Days)-1])
+	}
+	if train+test > len(m.Dates) {
+		return rep, fmt.Errorf("data %d hari terlalu pendek untuk train %d + test %d hari", len(m.Dates), train, test)
+	}
+
+	candidates := []StrategyParams{base}
+	for len(candidates) < trials {
+		candidates = append(candidates, randomParams(rng, base, strategy))
+	}
+
+	day := func(i int) string { return m.Dates[i].Format("2006-01-02") }
+	var oos btResult
+	var isSum, oosSum float64
+	for start := 0; start+train+test <= len(m.Dates); start += test {
+		best, isStats, isScore := searchParams(m, candidates, strategy, objective, start, start+train)
+		res := runBacktest(m, best, strategy, start+train, start+train+test)
+		oos.Trades = append(oos.Trades, res.Trades...)
+		oos.Daily = append(oos.Daily, res.Daily...)
+
+		oosStats := res.stats()
+		oosScore := oosStats.Expectancy
+		if objective == "sharpe" {
+			oosScore = oosStats.Sharpe
+		}
+		fold := optimizeFold{
+			TrainFrom: day(start), TrainTo: day(start + train - 1),
+			TestFrom: day(start + train), TestTo: day(start + train + test - 1),
+			Params: best, InSample: isStats, OutSample: oosStats, OOSScore: oosScore,
+		}
+		if !math.IsInf(isScore, -1) {
+			fold.ISScore = isScore
+			isSum += isScore
+			oosSum += oosScore
+		}
+		rep.Folds = append(rep.Folds, fold)
+	}
+	rep.OutOfSample = oos.stats()
+	if isSum > 0 {
+		rep.Efficiency = oosSum / isSum
+	}
+
+	rep.Best, rep.BestStats, _ = searchParams(m, candidates, strategy, objective, 0, len(m.Dates))
+	rep.DefaultStats = runBacktest(m, base, strategy, 0, len(m.Dates)).stats()
+
+	names, defaults := paramValues(base, strategy)
+	_, best := paramValues(rep.Best, strategy)
+	for i, name := range names {
+		var mean, sq float64
+		for _, f := range rep.Folds {
+			_, v := paramValues(f.Params, strategy)
+			mean += v[i] / float64(len(rep.Folds))
+		}
+		for _, f := range rep.Folds {
+			_, v := paramValues(f.Params, strategy)
+			sq += (v[i] - mean) * (v[i] - mean)
+		}
+		ps := paramStability{Name: name, Mean: mean, Std: math.Sqrt(sq / float64(len(rep.Folds))), Best: best[i], Value: defaults[i]}
+		if mean != 0 {
+			ps.CV = math.Abs(ps.Std / mean)
+		}
+		rep.Stability = append(rep.Stability, ps)
+	}
+
+	risk := 0
+	if rep.OutOfSample.Expectancy <= 0 {
+		risk += 2
+		rep.OverfitReason = append(rep.OverfitReason, "expectancy out-of-sample tidak positif")
+	}
+	if isSum <= 0 {
+		risk++
+		rep.OverfitReason = append(rep.OverfitReason, "objective in-sample tidak positif, walk-forward efficiency tidak bisa dihitung")
+	} else if rep.Efficiency < 0.5 {
+		risk++
+		rep.OverfitReason = append(rep.OverfitReason, fmt.Sprintf("walk-forward efficiency %.2f di bawah 0.5", rep.Efficiency))
+	}
+	unstable := 0
+	for _, ps := range rep.Stability {
+		if ps.CV > 0.25 {
+			unstable++
+		}
+	}
+	if len(rep.Folds) < 2 {
+		risk++
+		rep.OverfitReason = append(rep.OverfitReason, "hanya 1 fold, stabilitas parameter tidak bisa dinilai")
+	} else if unstable*2 > len(rep.Stability) {
+		risk++
+		rep.OverfitReason = append(rep.OverfitReason, fmt.Sprintf("%d dari %d parameter berubah jauh antar fold (CV > 0.25)", unstable, len(rep.Stability)))
+	}
+	switch {
+	case risk >= 2:
+		rep.OverfitRisk = "TINGGI"
+	case risk == 1:
+		rep.OverfitRisk = "SEDANG"
+	default:
+		rep.OverfitRisk = "RENDAH"
+	}
+
+	return rep, nil
+}
+
+func printStatsRow(label string, st btStats) {
+	fmt.Printf(" %-22s %6d  %6.1f%%  %+8.3f%%  %6.2f  %6.2f  %+8.1f%%  %6.1f%%\n",
+		label, st.Trades, st.WinRate, st.Expectancy, st.ProfitFactor, st.Sharpe, st.TotalReturn, st.MaxDrawdown)
+}
+
+func printOptimizeReport(rep optimizeReport) {
 	fmt.Println()
-	fmt.Println("                           DAFTAR SEMUA EMITEN")
+	fmt.Printf("\033[1;33m OPTIMASI PARAMETER %s\033[0m  (random search %d kombinasi, objective %s, seed %d)\n", rep.Strategy, rep.Trials, rep.Objective, rep.Seed)
+	fmt.Printf(" Data: %d saham, %d hari bursa\n", rep.Symbols, rep.Days)
 	fmt.Println(strings.Repeat("-", 100))
 
-	fmt.Printf(" %-7s %-20s %-10s %-10s %-7s %-6s %-8s %-8s %-8s\n",
-		"KODE", "NAMA", "SEKTOR", "HARGA", "CHG%", "RSI", "VOL", "BSJP", "BPJS")
-	fmt.Println(strings.Repeat("-", 100))
+	fmt.Println(" WALK-FORWARD")
+	fmt.Printf(" %-4s %-23s %-23s %9s %9s %7s %7s\n", "FOLD", "TRAIN", "TEST", "IS", "OOS", "TRADES", "WIN%")
+	for i, f := range rep.Folds {
+		is := "-"
+		if f.InSample.Trades >= btMinTrades {
+			is = fmt.Sprintf("%.3f", f.ISScore)
+		}
+		fmt.Printf(" %-4d %-23s %-23s %9s %s%9.3f\033[0m %7d %6.1f%%\n", i+1, f.TrainFrom+" "+f.TrainTo, f.TestFrom+" "+f.TestTo,
+			is, pnlColor(f.OOSScore), f.OOSScore, f.OutSample.Trades, f.OutSample.WinRate)
+	}
+	fmt.Println(strings.Repeat("-", 100))
+
+	fmt.Printf(" %-22s %6s  %7s  %9s  %6s  %6s  %9s  %7s\n", "", "TRADES", "WIN%", "EXPECT", "PF", "SHARPE", "TOTAL", "MAX DD")
+	printStatsRow("Out-of-sample", rep.OutOfSample)
+	printStatsRow("Default (semua data)", rep.DefaultStats)
+	printStatsRow("Terbaik (semua data)", rep.BestStats)
+	fmt.Printf("\n Walk-forward efficiency (OOS/IS): %.2f\n", rep.Efficiency)
+	fmt.Println(strings.Repeat("-", 100))
+
+	fmt.Println(" STABILITAS PARAMETER ANTAR FOLD")
+	fmt.Printf(" %-16s %9s %9s %9s %6s %9s\n", "PARAMETER", "DEFAULT", "TERBAIK", "RATA2", "CV", "STD")
+	for _, ps := range rep.Stability {
+		clr := "\033[0m"
+		if ps.CV > 0.25 {
+			clr = "\033[33m"
+		}
+		fmt.Printf(" %-16s %9.1f %9.1f %9.1f %s%6.2f\033[0m %9.2f\n", ps.Name, ps.Value, ps.Best, ps.Mean, clr, ps.CV, ps.Std)
+	}
+	fmt.Println(strings.Repeat("-", 100))
+
+	clr := "\033[32m"
+	if rep.OverfitRisk == "TINGGI" {
+		clr = "\033[31m"
+	} else if rep.OverfitRisk == "SEDANG" {
+		clr = "\033[33m"
+	}
+	fmt.Printf(" Risiko overfitting: %s%s\033[0m\n", clr, rep.OverfitRisk)
+	for _, reason := range rep.OverfitReason {
+		fmt.Printf("  - %s\n", reason)
+	}
+}
+
+func loadBacktestMarket(historyFile string, seed int64, days int) (btMarket, error) {
+	var series []btSeries
+	if historyFile != "" {
+		var err error
+		if series, err = loadHistoryCSV(historyFile); err != nil {
+			return btMarket{}, err
+		}
+	} else {
+		series = syntheticSeries(seed, days)
+	}
+
+	m := buildMarket(series)
+	if len(m.Dates) == 0 {
+		return m, fmt.Errorf("data historis kurang dari %d hari", btWarmup+2)
+	}
+	return m, nil
+}
+
+func runOptimizeCommand(seeds *seedSource, args []string) error {
+	fs := flag.NewFlagSet("optimize", flag.ContinueOnError)
+	strategy := fs.String("strategy", "bsjp", "strategi yang dioptimasi: bsjp atau bpjs")
+	objective := fs.String("objective", "expectancy", "fungsi tujuan: expectancy (rata-rata return per trade) atau sharpe")
+	trials := fs.Int("trials", 200, "jumlah kombinasi parameter acak (termasuk default)")
+	train := fs.Int("train", 120, "panjang jendela train walk-forward (hari bursa)")
+	test := fs.Int("test", 40, "panjang jendela test walk-forward (hari bursa)")
+	days := fs.Int("days", 500, "jumlah hari data simulasi jika -history tidak diisi")
+	historyFile := fs.String("history", "", "file CSV historis (date,symbol,open,high,low,close,volume[,net_foreign])")
+	out := fs.String("out", "params.json", "file parameter terbaik (laporan stabilitas ditulis ke <nama>_report.json)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *strategy != "bsjp" && *strategy != "bpjs" {
+		return fmt.Errorf("strategi %q tidak dikenal (bsjp, bpjs)", *strategy)
+	}
+	if *objective != "expectancy" && *objective != "sharpe" {
+		return fmt.Errorf("objective %q tidak dikenal (expectancy, sharpe)", *objective)
+	}
+	if *trials < 1 || *train < 1 || *test < 1 {
+		return errors.New("-trials, -train dan -test harus lebih dari 0")
+	}
+
+	rng, seed := seeds.rand()
+	m, err := loadBacktestMarket(*historyFile, rng.Int63(), *days+btWarmup+1)
+	if err != nil {
+		return err
+	}
+
+	rep, err := optimize(rng, m, strategyParams, *strategy, *objective, *trials, *train, *test)
+	if err != nil {
+		return err
+	}
+	rep.Seed = seed
+	printOptimizeReport(rep)
+
+	data, err := json.MarshalIndent(rep.Best, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(*out, data); err != nil {
+		return err
+	}
+	reportFile := strings.TrimSuffix(*out, filepath.Ext(*out)) + "_report.json"
+	if data, err = json.MarshalIndent(rep, "", "  "); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(reportFile, data); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n Parameter terbaik: %s (pakai dengan -params %s)\n Laporan: %s\n", *out, *out, reportFile)
+	return nil
+}
+
+type monteCarloRun struct {
+	FinalReturn  float64
+	MaxDrawdown  float64
+	LosingStreak int
+}
+
+type mcPercentiles struct {
+	Mean float64 `json:"mean"`
+	P5   float64 `json:"p5"`
+	P25  float64 `json:"p25"`
+	P50  float64 `json:"p50"`
+	P75  float64 `json:"p75"`
+	P95  float64 `json:"p95"`
+}
+
+type monteCarloReport struct {
+	Source       string        `json:"source"`
+	Seed         int64         `json:"seed"`
+	Mode         string        `json:"mode"`
+	Runs         int           `json:"runs"`
+	Trades       int           `json:"trades"`
+	Slippage     float64       `json:"slippage"`
+	Fraction     float64       `json:"fraction"`
+	Original     btStats       `json:"original"`
+	FinalReturn  mcPercentiles `json:"final_return"`
+	MaxDrawdown  mcPercentiles `json:"max_drawdown"`
+	LosingStreak mcPercentiles `json:"losing_streak"`
+	ProbLoss     float64       `json:"prob_loss"`
+	finals       []float64
+}
+
+func simulateEquity(returns []float64, fraction float64) monteCarloRun {
+	var run monteCarloRun
+	equity, peak := 1.0, 1.0
+	streak := 0
+	for _, r := range returns {
+		equity *= 1 + fraction*r/100
+		peak = math.Max(peak, equity)
+		run.MaxDrawdown = math.Max(run.MaxDrawdown, (peak-equity)/peak*100)
+		if r <= 0 {
+			streak++
+			if streak > run.LosingStreak {
+				run.LosingStreak = streak
+			}
+		} else {
+			streak = 0
+		}
+	}
+	run.FinalReturn = (equity - 1) * 100
+	return run
+}
+
+func percentiles(values []float64) mcPercentiles {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
 
-	for _, e := range emitens {
-		chgClr := "\033[32m"
-		if e.Change < 0 {
-			chgClr = "\033[31m"
+	at := func(q float64) float64 {
+		if len(sorted) == 0 {
+			return 0
 		}
+		return sorted[int(q*float64(len(sorted)-1))]
+	}
 
-		name := e.Name
-		if len(name) > 18 {
-			name = name[:18]
+	var mean float64
+	for _, v := range sorted {
+		mean += v / float64(len(sorted))
+	}
+	return mcPercentiles{Mean: mean, P5: at(0.05), P25: at(0.25), P50: at(0.5), P75: at(0.75), P95: at(0.95)}
+}
+
+func monteCarlo(rng *rand.Rand, trades []btTrade, runs int, mode string, slippage, fraction float64) monteCarloReport {
+	rep := monteCarloReport{Mode: mode, Runs: runs, Trades: len(trades), Slippage: slippage, Fraction: fraction}
+	rep.Original = btResult{Trades: trades}.stats()
+	original := make([]float64, len(trades))
+	for i, t := range trades {
+		original[i] = t.Return
+	}
+	base := simulateEquity(original, fraction)
+	rep.Original.TotalReturn, rep.Original.MaxDrawdown = base.FinalReturn, base.MaxDrawdown
+
+	finals := make([]float64, runs)
+	drawdowns := make([]float64, runs)
+	streaks := make([]float64, runs)
+	returns := make([]float64, len(trades))
+	losses := 0
+
+	for n := 0; n < runs; n++ {
+		if mode == "shuffle" {
+			for i, j := range rng.Perm(len(trades)) {
+				returns[i] = trades[j].Return
+			}
+		} else {
+			for i := range returns {
+				returns[i] = trades[rng.Intn(len(trades))].Return
+			}
 		}
-		sector := e.Sector
-		if len(sector) > 8 {
-			sector = sector[:8]
+
+		for i, r := range returns {
+			entrySlip := rng.Float64() * slippage / 100
+			exitSlip := rng.Float64() * slippage / 100
+			returns[i] = ((1+r/100)*(1-exitSlip)/(1+entrySlip) - 1) * 100
 		}
 
-		fmt.Printf(" %-7s %-20s %-10s %-10s %s%-6.1f%%\033[0m %-6.0f %-8s %-8.0f %-8.0f\n",
-			e.Symbol, name, sector, formatPrice(e.Price),
-			chgClr, e.Change, e.RSI, formatVol(e.Volume),
-			e.ScoreBSJP, e.ScoreBPJS)
+		run := simulateEquity(returns, fraction)
+		finals[n], drawdowns[n], streaks[n] = run.FinalReturn, run.MaxDrawdown, float64(run.LosingStreak)
+		if run.FinalReturn < 0 {
+			losses++
+		}
 	}
 
-	fmt.Printf("\n Total emiten: %d\n", len(emitens))
+	rep.FinalReturn = percentiles(finals)
+	rep.MaxDrawdown = percentiles(drawdowns)
+	rep.LosingStreak = percentiles(streaks)
+	rep.ProbLoss = float64(losses) / float64(runs) * 100
+	rep.finals = finals
+	return rep
 }
 
-func printBySector(emitens []Emiten) {
-	printHeader()
+func asciiHistogram(values []float64, bins, width int) []string {
+	if len(values) == 0 {
+		return nil
+	}
+
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	if hi == lo {
+		hi = lo + 1
+	}
+
+	counts := make([]int, bins)
+	maxCount := 0
+	for _, v := range values {
+		b := int((v - lo) / (hi - lo) * float64(bins))
+		if b >= bins {
+			b = bins - 1
+		}
+		counts[b]++
+		if counts[b] > maxCount {
+			maxCount = counts[b]
+		}
+	}
+
+	lines := make([]string, bins)
+	step := (hi - lo) / float64(bins)
+	for i, c := range counts {
+		bar := strings.Repeat("#", int(math.Round(float64(c)/float64(maxCount)*float64(width))))
+		lines[i] = fmt.Sprintf(" %+9.1f%% .. %+9.1f%% |%-*s %d", lo+step*float64(i), lo+step*float64(i+1), width, bar, c)
+	}
+	return lines
+}
+
+func printMonteCarlo(rep monteCarloReport) {
 	fmt.Println()
-	fmt.Println("                         EMITEN PER SEKTOR")
+	fmt.Printf("\033[1;33m MONTE CARLO %s\033[0m  (%d simulasi, mode %s, slippage s/d %.2f%% per sisi, %.0f%% modal per trade, seed %d)\n",
+		rep.Source, rep.Runs, rep.Mode, rep.Slippage, rep.Fraction*100, rep.Seed)
+	fmt.Printf(" Urutan asli: %d trade, win rate %.1f%%, expectancy %+.3f%%, return %+.1f%%, max DD %.1f%%\n",
+		rep.Trades, rep.Original.WinRate, rep.Original.Expectancy, rep.Original.TotalReturn, rep.Original.MaxDrawdown)
 	fmt.Println(strings.Repeat("-", 100))
 
-	sectors := make(map[string][]Emiten)
-	for _, e := range emitens {
-		sectors[e.Sector] = append(sectors[e.Sector], e)
+	fmt.Printf(" %-22s %10s %10s %10s %10s %10s %10s\n", "METRIK", "RATA2", "P5", "P25", "P50", "P75", "P95")
+	for _, row := range []struct {
+		label  string
+		p      mcPercentiles
+		format string
+	}{
+		{"Return akhir (%)", rep.FinalReturn, "%+10.1f"},
+		{"Max drawdown (%)", rep.MaxDrawdown, "%10.1f"},
+		{"Losing streak (trade)", rep.LosingStreak, "%10.0f"},
+	} {
+		fmt.Printf(" %-22s", row.label)
+		for _, v := range []float64{row.p.Mean, row.p.P5, row.p.P25, row.p.P50, row.p.P75, row.p.P95} {
+			fmt.Printf(" "+row.format, v)
+		}
+		fmt.Println()
 	}
+	fmt.Printf("\n Peluang rugi di akhir periode: %s%.1f%%\033[0m\n", pnlColor(50-rep.ProbLoss), rep.ProbLoss)
+	fmt.Println(strings.Repeat("-", 100))
 
-	var sectorNames []string
-	for s := range sectors {
-		sectorNames = append(sectorNames, s)
+	fmt.Println(" DISTRIBUSI RETURN AKHIR")
+	for _, line := range asciiHistogram(rep.finals, 15, 50) {
+		fmt.Println(line)
 	}
-	sort.Strings(sectorNames)
-
-	for _, sector := range sectorNames {
-		list := sectors[sector]
-		fmt.Printf("\n \033[1;34m%s (%d emiten)\033[0m\n", sector, len(list))
-		fmt.Println(strings.Repeat("-", 60))
+}
 
-		for _, e := range list {
-			chgClr := "\033[32m"
-			if e.Change < 0 {
-				chgClr = "\033[31m"
-			}
+func journalReturns(path, strategy string) ([]btTrade, error) {
+	j, err := loadJournal(path)
+	if err != nil {
+		return nil, err
+	}
 
-			fmt.Printf(" %-7s %-25s %-10s %s%-6.1f%%\033[0m BSJP=%-3.0f BPJS=%-3.0f\n",
-				e.Symbol, e.Name, formatPrice(e.Price),
-				chgClr, e.Change, e.ScoreBSJP, e.ScoreBPJS)
+	var trades []btTrade
+	for _, t := range j.Trades {
+		if t.Status == "OPEN" || (strategy != "all" && !strings.EqualFold(t.Strategy, strategy)) {
+			continue
 		}
+		trade := btTrade{
+			Strategy:   t.Strategy,
+			Symbol:     t.Symbol,
+			EntryDate:  t.EntryTime,
+			EntryPrice: t.EntryPrice,
+			ExitPrice:  t.ExitPrice,
+			Score:      t.Score,
+			Return:     t.pnlPercent(),
+		}
+		if t.ExitTime != nil {
+			trade.ExitDate = *t.ExitTime
+		}
+		trades = append(trades, trade)
 	}
+	return trades, nil
 }
 
-func printGuide() {
-	printHeader()
-	fmt.Println()
-	fmt.Println("                    PANDUAN STRATEGI BSJP & BPJS")
-	fmt.Println(strings.Repeat("-", 100))
-	fmt.Println()
-	fmt.Println(" BSJP - BELI SORE JUAL PAGI")
-	fmt.Println(" ==========================")
-	fmt.Println(" Waktu Beli  : 14:30 - 15:00 WIB (menjelang closing)")
-	fmt.Println(" Waktu Jual  : 09:00 - 09:30 WIB (keesokan hari)")
-	fmt.Println(" Konsep      : Memanfaatkan gap overnight dan momentum pembukaan")
-	fmt.Println()
-	fmt.Println(" Kriteria:")
-	fmt.Println(" - RSI < 45 (kondisi oversold)")
-	fmt.Println(" - Harga turun -0.5% s/d -3% (koreksi sehat)")
-	fmt.Println(" - Volatilitas 2-4% (potensi gap)")
-	fmt.Println(" - Volume di atas rata-rata")
-	fmt.Println(" - Ada pattern afternoon dip")
-	fmt.Println()
-	fmt.Println(" BPJS - BELI PAGI JUAL SORE")
-	fmt.Println(" ==========================")
-	fmt.Println(" Waktu Beli  : 09:00 - 09:30 WIB (setelah opening)")
-	fmt.Println(" Waktu Jual  : 14:30 - 15:00 WIB (hari yang sama)")
-	fmt.Println(" Konsep      : Intraday momentum trading")
-	fmt.Println()
-	fmt.Println(" Kriteria:")
-	fmt.Println(" - RSI 55-70 (momentum naik)")
-	fmt.Println(" - Harga naik +0.5% s/d +3% saat opening")
-	fmt.Println(" - Morning momentum kuat")
-	fmt.Println(" - Volume tinggi di awal sesi")
-	fmt.Println()
-	fmt.Println(" MANAJEMEN RISIKO")
-	fmt.Println(" ================")
-	fmt.Println(" - Maksimal 3-5 saham per hari")
-	fmt.Println(" - Stop loss -1.5% untuk BPJS, -2% untuk BSJP")
-	fmt.Println(" - Take profit +1% untuk BPJS, +1.5% untuk BSJP")
-	fmt.Println(" - Jangan all-in, diversifikasi")
-	fmt.Println()
+func runMonteCarloCommand(seeds *seedSource, args []string) error {
+	fs := flag.NewFlagSet("montecarlo", flag.ContinueOnError)
+	strategy := fs.String("strategy", "bsjp", "strategi backtest: bsjp, bpjs, atau foreign (dengan -journal: nama strategi di jurnal atau all)")
+	runs := fs.Int("runs", 5000, "jumlah simulasi")
+	mode := fs.String("mode", "bootstrap", "bootstrap (ambil ulang trade dengan pengembalian) atau shuffle (acak urutan)")
+	slippage := fs.Float64("slippage", 0.1, "slippage maksimum per sisi dalam persen, diambil acak 0..nilai ini")
+	fraction := fs.Float64("fraction", 0.2, "porsi modal per trade (0.2 = 20%)")
+	days := fs.Int("days", 500, "jumlah hari data simulasi jika -history tidak diisi")
+	historyFile := fs.String("history", "", "file CSV historis (date,symbol,open,high,low,close,volume[,net_foreign])")
+	journalFile := fs.String("journal", "", "pakai trade tertutup dari file jurnal ini alih-alih backtest")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *mode != "bootstrap" && *mode != "shuffle" {
+		return fmt.Errorf("mode %q tidak dikenal (bootstrap, shuffle)", *mode)
+	}
+	if *runs < 1 || *fraction <= 0 || *fraction > 1 || *slippage < 0 {
+		return errors.New("-runs harus lebih dari 0, -fraction di antara 0 dan 1, -slippage tidak boleh negatif")
+	}
+
+	rng, seed := seeds.rand()
+	var trades []btTrade
+	var source string
+	if *journalFile != "" {
+		var err error
+		if trades, err = journalReturns(*journalFile, *strategy); err != nil {
+			return err
+		}
+		source = "JURNAL " + strings.ToUpper(*strategy)
+	} else {
+		if *strategy != "bsjp" && *strategy != "bpjs" && *strategy != "foreign" {
+			return fmt.Errorf("strategi %q tidak dikenal (bsjp, bpjs, foreign)", *strategy)
+		}
+		m, err := loadBacktestMarket(*historyFile, rng.Int63(), *days+btWarmup+1)
+		if err != nil {
+			return err
+		}
+		trades = runBacktest(m, strategyParams, *strategy, 0, len(m.Dates)).Trades
+		source = "BACKTEST " + strings.ToUpper(*strategy)
+	}
+	if len(trades) < 2 {
+		return fmt.Errorf("butuh minimal 2 trade, hanya ada %d", len(trades))
+	}
+
+	rep := monteCarlo(rng, trades, *runs, *mode, *slippage, *fraction)
+	rep.Source, rep.Seed = source, seed
+	printMonteCarlo(rep)
+	return nil
 }
 
-func printStatistics(emitens []Emiten, bsjpResults, bpjsResults []ScanResult) {
-	printHeader()
-	fmt.Println()
-	fmt.Println("                           STATISTIK SCAN")
-	fmt.Println(strings.Repeat("-", 100))
-	fmt.Println()
-	fmt.Printf(" Total Emiten Terscan    : %d\n", len(emitens))
-	fmt.Printf(" Emiten Lolos BSJP       : %d\n", len(bsjpResults))
-	fmt.Printf(" Emiten Lolos BPJS       : %d\n", len(bpjsResults))
-	fmt.Println()
+type replayFile struct {
+	Meta *ScanMeta    `json:"meta"`
+	BSJP []ScanResult `json:"bsjp"`
+	BPJS []ScanResult `json:"bpjs"`
+}
 
-	strongBuyBSJP := 0
-	buyBSJP := 0
-	for _, r := range bsjpResults {
-		if r.Signal == "STRONG BUY" {
-			strongBuyBSJP++
-		} else if r.Signal == "BUY" {
-			buyBSJP++
+func compareResults(strategy string, want, got []ScanResult) []string {
+	var diffs []string
+	for i := 0; i < len(want) || i < len(got); i++ {
+		switch {
+		case i >= len(got):
+			diffs = append(diffs, fmt.Sprintf("%s #%d: %s tidak muncul lagi", strategy, i+1, want[i].Symbol))
+		case i >= len(want):
+			diffs = append(diffs, fmt.Sprintf("%s #%d: %s tambahan", strategy, i+1, got[i].Symbol))
+		case want[i] != got[i]:
+			diffs = append(diffs, fmt.Sprintf("%s #%d: %s score %.0f %s, replay %s score %.0f %s", strategy, i+1,
+				want[i].Symbol, want[i].Score, want[i].Signal, got[i].Symbol, got[i].Score, got[i].Signal))
 		}
 	}
+	return diffs
+}
+
+func runReplayCommand(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	seed := fs.Int64("seed", 0, "seed scan yang diulang (lihat header scan, laporan, atau field meta di JSON)")
+	asOf := fs.String("as-of", time.Now().Format("2006-01-02"), "tanggal data scan yang diulang (YYYY-MM-DD)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
 
-	strongBuyBPJS := 0
-	buyBPJS := 0
-	for _, r := range bpjsResults {
-		if r.Signal == "STRONG BUY" {
-			strongBuyBPJS++
-		} else if r.Signal == "BUY" {
-			buyBPJS++
+	meta := newScanMeta(*seed, time.Now())
+	meta.AsOf = *asOf
+	var saved *replayFile
+	if fs.NArg() > 0 {
+		data, err := os.ReadFile(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		saved = &replayFile{}
+		if err := json.Unmarshal(data, saved); err != nil {
+			return fmt.Errorf("%s: %v", fs.Arg(0), err)
 		}
+		if saved.Meta == nil {
+			return fmt.Errorf("%s tidak berisi metadata seed", fs.Arg(0))
+		}
+		meta = *saved.Meta
+	} else if !flagPassed(fs, "seed") {
+		return errors.New("isi -seed atau berikan file JSON hasil scan (scheduler/API) yang berisi meta")
+	}
+	if _, err := time.Parse("2006-01-02", meta.AsOf); err != nil {
+		return fmt.Errorf("tanggal %q tidak valid, format YYYY-MM-DD", meta.AsOf)
 	}
+	if err := meta.Params.validate(); err != nil {
+		return fmt.Errorf("parameter strategi di metadata tidak valid: %v", err)
+	}
+	strategyParams = meta.Params
+	fundamentalRules = FundamentalRules{}
+	if meta.FundamentalRules != nil {
+		fundamentalRules = *meta.FundamentalRules
+	}
+	market, err := loadMarket(context.Background(), meta, nil)
+	if err != nil {
+		return err
+	}
+	emitens := scopeEmitens(market.Emitens, meta.scope())
+	bsjp, bpjs := scanBSJP(emitens), scanBPJS(emitens)
 
-	fmt.Println(" BSJP Signals:")
-	fmt.Printf("   STRONG BUY : %d\n", strongBuyBSJP)
-	fmt.Printf("   BUY        : %d\n", buyBSJP)
-	fmt.Printf("   WATCH      : %d\n", len(bsjpResults)-strongBuyBSJP-buyBSJP)
+	fmt.Printf("\033[1;33m REPLAY SCAN\033[0m  seed %d, data per %s, %d saham", meta.Seed, meta.AsOf, len(emitens))
+	if meta.Watchlist != "" {
+		fmt.Printf(", watchlist %s", meta.Watchlist)
+	}
+	if meta.DataDir != "" {
+		fmt.Printf(", data dari %s", meta.DataDir)
+	}
+	if meta.Provider != "" {
+		fmt.Printf(", provider %s", meta.Provider)
+	}
+	if meta.CorpActions != "" {
+		fmt.Printf(", aksi korporasi %s", meta.CorpActions)
+	}
+	if meta.Fundamentals != "" {
+		fmt.Printf(", fundamental %s", meta.Fundamentals)
+	}
 	fmt.Println()
-	fmt.Println(" BPJS Signals:")
-	fmt.Printf("   STRONG BUY : %d\n", strongBuyBPJS)
-	fmt.Printf("   BUY        : %d\n", buyBPJS)
-	fmt.Printf("   WATCH      : %d\n", len(bpjsResults)-strongBuyBPJS-buyBPJS)
+	if note := failedNote(market.Failed); note != "" {
+		fmt.Printf(" \033[33m%s\033[0m\n", note)
+	}
+	printBSJP(bsjp)
+	printBPJS(bpjs)
 	fmt.Println()
-}
 
-func printMenu() {
-	fmt.Println()
-	fmt.Println(strings.Repeat("-", 100))
-	fmt.Println(" MENU:")
-	fmt.Println(" [1] Scan Ulang")
-	fmt.Println(" [2] Lihat Semua Emiten")
-	fmt.Println(" [3] Lihat Per Sektor")
-	fmt.Println(" [4] Statistik")
-	fmt.Println(" [5] Panduan Strategi")
-	fmt.Println(" [6] Keluar")
-	fmt.Println()
-	fmt.Print(" Pilihan: ")
+	if saved == nil {
+		return nil
+	}
+	if meta.Live {
+		fmt.Println(" \033[33mScan asli berjalan di mode live; quote yang masuk setelahnya tidak ikut diulang.\033[0m")
+	}
+	if saved.BSJP == nil && saved.BPJS == nil {
+		fmt.Println(" File tidak berisi hasil bsjp/bpjs, perbandingan dilewati.")
+		return nil
+	}
+
+	var diffs []string
+	if saved.BSJP != nil {
+		diffs = append(diffs, compareResults("BSJP", saved.BSJP, bsjp)...)
+	}
+	if saved.BPJS != nil {
+		diffs = append(diffs, compareResults("BPJS", saved.BPJS, bpjs)...)
+	}
+	if len(diffs) == 0 {
+		fmt.Printf(" \033[32mHasil identik dengan %s.\033[0m\n", fs.Arg(0))
+		return nil
+	}
+	fmt.Printf(" \033[31mHasil berbeda dengan %s:\033[0m\n", fs.Arg(0))
+	for _, d := range diffs {
+		fmt.Printf("  - %s\n", d)
+	}
+	return fmt.Errorf("%d perbedaan", len(diffs))
 }
 
 func main() {
-	rand.Seed(time.Now().UnixNano())
+	serveAddr := flag.String("serve", "", "jalankan REST API di alamat ini (contoh :8080) alih-alih menu interaktif")
+	foreignAPI := flag.String("foreign-api", "", "URL server net_foreign_scanner untuk diteruskan di /scan/foreign/ (contoh http://localhost:8081)")
+	refresh := flag.Duration("refresh", 5*time.Minute, "interval scan ulang data pada mode server (0 = tidak pernah)")
+	live := flag.Bool("live", false, "mode server: hitung ulang score setiap ada quote baru dan kirim ke /ws")
+	feed := flag.String("feed", "", "file CSV replay quote (time,symbol,price,volume) sebagai sumber mode live")
+	speed := flag.Float64("speed", 1, "kecepatan replay feed (2 = dua kali lebih cepat, 0 = tanpa jeda)")
+	tick := flag.Duration("tick", time.Second, "interval quote simulasi pada mode live tanpa -feed")
+	alertFile := flag.String("alerts", "", "file konfigurasi alert JSON (webhook/Telegram/Discord/Slack)")
+	daemon := flag.String("daemon", "", "jalankan scheduler scan terjadwal dengan file konfigurasi JSON ini")
+	journalFile := flag.String("journal", "journal.json", "file jurnal trading")
+	watchlistFile := flag.String("watchlists", "watchlists.json", "file penyimpanan watchlist")
+	profile := flag.String("profile", defaultProfile(), "profil watchlist (default env IDX_PROFILE atau \"default\")")
+	watchlist := flag.String("watchlist", "", "batasi scan hanya ke saham di watchlist ini")
+	classic := flag.Bool("classic", false, "pakai tampilan lama (cetak tabel + menu angka) alih-alih TUI")
+	ascii := flag.Bool("ascii", false, "gambar grafik dengan ASCII polos tanpa warna/Unicode")
+	paramsFile := flag.String("params", "", "file parameter strategi JSON hasil perintah optimize")
+	reportDir := flag.String("report", "", "tulis laporan harian HTML dan Markdown ke folder ini (subfolder YYYY-MM-DD) lalu keluar")
+	seed := flag.Int64("seed", 0, "seed data simulasi untuk scan pertama; scan berikutnya diturunkan dari seed ini (tanpa flag ini = acak)")
+	flag.StringVar(&marketDataDir, "data", "", "folder CSV harian per saham (SYMBOL.csv: date,open,high,low,close,volume[,net_foreign]) alih-alih data simulasi")
+	flag.IntVar(&scanWorkers, "workers", 0, "jumlah worker paralel untuk memuat dan menghitung indikator (0 = jumlah CPU)")
+	providerURL := flag.String("provider", "", "URL provider data EOD HTTP JSON (GET /symbols, GET /eod/{kode}) alih-alih data simulasi")
+	providerRate := flag.Float64("provider-rate", 5, "batas request ke provider per detik")
+	providerCache := flag.String("provider-cache", filepath.Join(".cache", "eod"), "folder cache respons provider")
+	fundamentalFile := flag.String("fundamentals", "", "file CSV ringkasan laporan keuangan kuartalan (symbol,period,revenue,net_income,equity,liabilities,shares)")
+	fundamentalRuleFile := flag.String("fundamental-rules", "", "file JSON filter dan bonus score fundamental (PER, PBV, ROE, DER, pertumbuhan EPS, kapitalisasi)")
+	corpActionFile := flag.String("corp-actions", "", "file JSON aksi korporasi (split, reverse_split, rights, dividend) untuk menyesuaikan harga historis")
+	holidayFile := flag.String("holidays", "", "file tanggal libur bursa (satu YYYY-MM-DD per baris) untuk menentukan hari bursa berikutnya")
+	flag.Parse()
+
+	if *holidayFile != "" {
+		days, err := loadHolidays(*holidayFile)
+		if err == nil {
+			marketHolidays, err = parseHolidays(days)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *corpActionFile != "" {
+		reg, err := loadCorporateActions(*corpActionFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		corpActions = reg
+	}
+	if *fundamentalFile != "" {
+		store, err := loadFinancials(*fundamentalFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fundamentalData = store
+	}
+	if *fundamentalRuleFile != "" {
+		rules, err := loadFundamentalRules(*fundamentalRuleFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fundamentalRules = rules
+	}
+
+	if *providerURL != "" {
+		if marketDataDir != "" {
+			log.Fatal("pilih salah satu: -data atau -provider")
+		}
+		p, err := newHTTPProvider(*providerURL, *providerRate, *providerCache)
+		if err != nil {
+			log.Fatal(err)
+		}
+		marketProvider = p
+	}
+
+	seeds := newSeedSource(*seed, flagPassed(flag.CommandLine, "seed"))
+	scanMarket := func(meta ScanMeta) (pipelineResult, error) {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if meta.DataDir == "" && meta.Provider == "" {
+			return loadMarket(ctx, meta, nil)
+		}
+		return loadMarket(ctx, meta, printProgress)
+	}
+
+	if *paramsFile != "" {
+		params, err := loadStrategyParams(*paramsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		strategyParams = params
+	}
+
+	if flag.Arg(0) == "fake-eod" {
+		if err := runFakeEODCommand(seeds, flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "replay" {
+		if err := runReplayCommand(flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "montecarlo" {
+		if err := runMonteCarloCommand(seeds, flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "optimize" {
+		if err := runOptimizeCommand(seeds, flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	watchlists, err := loadWatchlists(*watchlistFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	watchedSymbols = watchlists.members(*profile)
+
+	if flag.Arg(0) == "watchlist" {
+		if err := runWatchlistCommand(watchlists, *profile, flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var scope []string
+	if *watchlist != "" {
+		if scope, err = watchlists.get(*profile, *watchlist); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var alerts *alertDispatcher
+	if *alertFile != "" {
+		cfg, err := loadAlertConfig(*alertFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if alerts, err = newAlertDispatcher(cfg); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *daemon != "" {
+		cfg, err := loadScheduleConfig(*daemon)
+		if err != nil {
+			log.Fatal(err)
+		}
+		sched, err := newScheduler(cfg, alerts, watchlists.Profiles[*profile], seeds)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for day := range marketHolidays {
+			sched.holidays[day] = true
+		}
+		marketHolidays = sched.holidays
+		if err := runScheduler(sched); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *reportDir != "" {
+		meta := newScanMeta(seeds.next(), time.Now())
+		meta.Profile, meta.Watchlist, meta.Symbols = *profile, *watchlist, scope
+		market, err := scanMarket(meta)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if note := failedNote(market.Failed); note != "" {
+			log.Print(note)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		rep := newDailyReport(ctx, market.IHSG, scopeEmitens(market.Emitens, scope), market.Sectors, *foreignAPI)
+		rep.Meta = meta
+		cancel()
+		day, err := writeReport(*reportDir, "report", rep)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Laporan ditulis ke %s\n", filepath.Join(day, "report.html"))
+		if rep.ForeignNote != "" {
+			fmt.Println(rep.ForeignNote)
+		}
+		return
+	}
+
+	if *serveAddr != "" {
+		cfg := serverConfig{
+			Addr:       *serveAddr,
+			ForeignAPI: *foreignAPI,
+			Refresh:    *refresh,
+			Live:       *live,
+			Feed:       *feed,
+			Speed:      *speed,
+			Tick:       *tick,
+			Alerts:     alerts,
+			Watchlists: watchlists,
+			Profile:    *profile,
+			Seeds:      seeds,
+		}
+		if err := runServer(cfg); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	useTUI := !*classic && isTerminal(os.Stdin) && isTerminal(os.Stdout) && rawModeAvailable()
+	style := detectChartStyle(*ascii)
+
+	trades, err := loadJournal(*journalFile)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	for {
-		emitens := generateEmitenData()
+		lastScan = newScanMeta(seeds.next(), time.Now())
+		lastScan.Profile, lastScan.Watchlist, lastScan.Symbols = *profile, *watchlist, scope
+		market, err := scanMarket(lastScan)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ihsg, all, sectors := market.IHSG, market.Emitens, market.Sectors
+		emitens := scopeEmitens(all, scope)
 
-		printHeader()
+		var exited []Trade
+		if lastScan.continuous() {
+			var changed bool
+			if exited, changed = trades.updatePrices(all, time.Now()); changed {
+				if err := trades.save(); err != nil {
+					log.Printf("gagal menyimpan jurnal: %v", err)
+				}
+			}
+		}
 
 		bsjpResults := scanBSJP(emitens)
 		bpjsResults := scanBPJS(emitens)
 
-		printBSJP(bsjpResults)
-		printBPJS(bpjsResults)
-
-		printMenu()
+		var notes []string
+		staleBanner = staleNote(market.Stale)
+		if staleBanner != "" {
+			notes = append(notes, "\033[31mDATA BASI: "+staleBanner+"\033[0m")
+		}
+		if note := failedNote(market.Failed); note != "" {
+			notes = append(notes, "\033[33mData: "+note+"\033[0m")
+		}
+		if alerts != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			n, err := notifyScans(ctx, alerts, emitens)
+			cancel()
+			if err != nil {
+				notes = append(notes, fmt.Sprintf("\033[33mAlert: %v\033[0m", err))
+			} else if n > 0 {
+				notes = append(notes, fmt.Sprintf("\033[32mAlert: %d sinyal baru terkirim\033[0m", n))
+			}
+		}
+		for _, t := range exited {
+			notes = append(notes, fmt.Sprintf("%sJurnal: #%d %s kena %s di %s (%s)\033[0m", pnlColor(t.pnl()), t.ID, t.Symbol, t.Status, formatPrice(t.ExitPrice), formatMoney(t.pnl())))
+		}
 
 		var choice string
-		fmt.Scanln(&choice)
+		if useTUI {
+			title := fmt.Sprintf("EMITEN SCANNER BSJP & BPJS  seed %d", lastScan.Seed)
+			if lastScan.DataDir != "" {
+				title = "EMITEN SCANNER BSJP & BPJS  data " + lastScan.DataDir
+			} else if lastScan.Provider != "" {
+				title = "EMITEN SCANNER BSJP & BPJS  provider " + lastScan.Provider
+			}
+			ui := newTUI(title, 116, emitenViews(emitens, bsjpResults, bpjsResults, style), stdin)
+			ui.notes = notes
+			action, _ := ui.run()
+			switch action {
+			case "r":
+				choice = "1"
+			case "q":
+				choice = "10"
+			default:
+				printHeader()
+				printMenu()
+				choice = readLine()
+			}
+		} else {
+			printHeader()
+			printBSJP(bsjpResults)
+			printBPJS(bpjsResults)
+			for _, note := range notes {
+				fmt.Printf("\n %s", note)
+			}
+			if len(notes) > 0 {
+				fmt.Println()
+			}
+			printMenu()
+			choice = readLine()
+		}
 
 		switch choice {
 		case "1":
@@ -633,20 +7983,35 @@ func main() {
 		case "2":
 			printAllEmiten(emitens)
 			fmt.Println("\n Tekan Enter...")
-			fmt.Scanln()
+			readLine()
 		case "3":
 			printBySector(emitens)
 			fmt.Println("\n Tekan Enter...")
-			fmt.Scanln()
+			readLine()
 		case "4":
 			printStatistics(emitens, bsjpResults, bpjsResults)
 			fmt.Println("\n Tekan Enter...")
-			fmt.Scanln()
+			readLine()
 		case "5":
 			printGuide()
 			fmt.Println("\n Tekan Enter...")
-			fmt.Scanln()
-		case "6", "q", "Q":
+			readLine()
+		case "6":
+			printHeader()
+			printSectorRotation(sectors, ihsg)
+			fmt.Println("\n Tekan Enter...")
+			readLine()
+		case "7":
+			runJournalMenu(trades, bsjpResults, bpjsResults)
+		case "8":
+			printChartPrompt(all, style)
+			fmt.Println("\n Tekan Enter...")
+			readLine()
+		case "9":
+			printDividendCalendar(scanDividends(emitens, corpActions, lastScan.date()))
+			fmt.Println("\n Tekan Enter...")
+			readLine()
+		case "10", "q", "Q":
 			fmt.Println()
 			fmt.Println(" Terima kasih!")
 			fmt.Println(" Selamat berinvestasi dengan bijak.")
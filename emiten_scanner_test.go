@@ -0,0 +1,2421 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+var update = flag.Bool("update", false, "tulis ulang file golden di testdata")
+
+func captureStdout(t *testing.T, fn func()) []byte {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+
+	defer func() { os.Stdout = stdout }()
+	fn()
+	w.Close()
+	return <-done
+}
+
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", "emiten", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (jalankan dengan -update untuk membuat file golden)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output %s berbeda dari %s\n--- got ---\n%s\n--- want ---\n%s", name, path, got, want)
+	}
+}
+
+func TestCalculateBSJPThresholds(t *testing.T) {
+	type input struct {
+		rsi, change, volatility, gap, dip, bonus float64
+		vol, avgVol                              int64
+	}
+	base := input{rsi: 50, change: 5, volatility: 1, vol: 1000, avgVol: 1000}
+
+	tests := []struct {
+		name string
+		edit func(*input)
+		want float64
+	}{
+		{"netral", func(in *input) {}, 0},
+		{"rsi di bawah batas kuat", func(in *input) { in.rsi = 34.9 }, 20},
+		{"rsi tepat batas kuat", func(in *input) { in.rsi = 35 }, 12},
+		{"rsi di bawah batas lemah", func(in *input) { in.rsi = 44.9 }, 12},
+		{"rsi tepat batas lemah", func(in *input) { in.rsi = 45 }, 0},
+		{"change tepat batas bawah", func(in *input) { in.change = -3 }, 10},
+		{"change di atas batas bawah", func(in *input) { in.change = -2.9 }, 18},
+		{"change di bawah batas atas", func(in *input) { in.change = -0.6 }, 18},
+		{"change tepat batas atas", func(in *input) { in.change = -0.5 }, 10},
+		{"change tepat -5", func(in *input) { in.change = -5 }, 0},
+		{"change di atas -5", func(in *input) { in.change = -4.9 }, 10},
+		{"change nol", func(in *input) { in.change = 0 }, 0},
+		{"volatilitas tepat batas bawah", func(in *input) { in.volatility = 2 }, 0},
+		{"volatilitas di atas batas bawah", func(in *input) { in.volatility = 2.1 }, 15},
+		{"volatilitas di bawah batas atas", func(in *input) { in.volatility = 3.9 }, 15},
+		{"volatilitas tepat batas atas", func(in *input) { in.volatility = 4 }, 0},
+		{"gap nol", func(in *input) { in.gap = 0 }, 0},
+		{"gap kecil", func(in *input) { in.gap = 0.1 }, 8},
+		{"gap tepat 0.5", func(in *input) { in.gap = 0.5 }, 8},
+		{"gap besar", func(in *input) { in.gap = 0.6 }, 15},
+		{"dip tepat 40", func(in *input) { in.dip = 40 }, 0},
+		{"dip di atas 40", func(in *input) { in.dip = 41 }, 10},
+		{"dip tepat 60", func(in *input) { in.dip = 60 }, 10},
+		{"dip di atas 60", func(in *input) { in.dip = 61 }, 17},
+		{"volume sama rata-rata", func(in *input) { in.vol = 1000 }, 0},
+		{"volume di atas rata-rata", func(in *input) { in.vol = 1001 }, 15},
+		{"bonus sektor", func(in *input) { in.bonus = 5 }, 5},
+		{"bonus negatif dipotong ke 0", func(in *input) { in.bonus = -5 }, 0},
+		{"semua maksimal dipotong ke 100", func(in *input) {
+			*in = input{rsi: 20, change: -1, volatility: 3, gap: 1, dip: 80, bonus: 10, vol: 2000, avgVol: 1000}
+		}, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := base
+			tt.edit(&in)
+			got := calculateBSJP(in.rsi, in.change, in.volatility, in.gap, in.dip, in.bonus, in.vol, in.avgVol)
+			if got != tt.want {
+				t.Errorf("calculateBSJP = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateBPJSThresholds(t *testing.T) {
+	type input struct {
+		rsi, change, volatility, mom, bonus float64
+		vol, avgVol                         int64
+	}
+	base := input{rsi: 40, change: -1, volatility: 1, vol: 1000, avgVol: 1000}
+
+	tests := []struct {
+		name string
+		edit func(*input)
+		want float64
+	}{
+		{"netral", func(in *input) {}, 0},
+		{"rsi tepat batas lemah", func(in *input) { in.rsi = 45 }, 0},
+		{"rsi di atas batas lemah", func(in *input) { in.rsi = 45.1 }, 10},
+		{"rsi tepat batas bawah", func(in *input) { in.rsi = 55 }, 10},
+		{"rsi di atas batas bawah", func(in *input) { in.rsi = 55.1 }, 18},
+		{"rsi di bawah batas atas", func(in *input) { in.rsi = 69.9 }, 18},
+		{"rsi tepat batas atas", func(in *input) { in.rsi = 70 }, 10},
+		{"change nol", func(in *input) { in.change = 0 }, 0},
+		{"change positif kecil", func(in *input) { in.change = 0.1 }, 12},
+		{"change tepat batas bawah", func(in *input) { in.change = 0.5 }, 12},
+		{"change di atas batas bawah", func(in *input) { in.change = 0.6 }, 20},
+		{"change di bawah batas atas", func(in *input) { in.change = 2.9 }, 20},
+		{"change tepat batas atas", func(in *input) { in.change = 3 }, 12},
+		{"volatilitas tepat batas bawah", func(in *input) { in.volatility = 1.5 }, 0},
+		{"volatilitas di atas batas bawah", func(in *input) { in.volatility = 1.6 }, 15},
+		{"volatilitas di bawah batas atas", func(in *input) { in.volatility = 2.9 }, 15},
+		{"volatilitas tepat batas atas", func(in *input) { in.volatility = 3 }, 0},
+		{"momentum tepat 40", func(in *input) { in.mom = 40 }, 0},
+		{"momentum di atas 40", func(in *input) { in.mom = 41 }, 12},
+		{"momentum tepat 60", func(in *input) { in.mom = 60 }, 12},
+		{"momentum di atas 60", func(in *input) { in.mom = 61 }, 20},
+		{"volume tepat 1.2x", func(in *input) { in.vol = 1200 }, 0},
+		{"volume di atas 1.2x", func(in *input) { in.vol = 1201 }, 15},
+		{"bonus sektor", func(in *input) { in.bonus = 7 }, 7},
+		{"bonus negatif dipotong ke 0", func(in *input) { in.bonus = -5 }, 0},
+		{"semua maksimal", func(in *input) {
+			*in = input{rsi: 60, change: 1, volatility: 2, mom: 80, bonus: 10, vol: 2000, avgVol: 1000}
+		}, 98},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := base
+			tt.edit(&in)
+			got := calculateBPJS(in.rsi, in.change, in.volatility, in.mom, in.bonus, in.vol, in.avgVol)
+			if got != tt.want {
+				t.Errorf("calculateBPJS = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreRangeProperty(t *testing.T) {
+	cfg := &quick.Config{MaxCount: 2000, Rand: rand.New(rand.NewSource(1))}
+
+	bsjp := func(rsi, change, volatility, gap, dip, bonus float64, vol, avgVol int64) bool {
+		s := calculateBSJP(rsi, change, volatility, gap, dip, bonus, vol, avgVol)
+		return s >= 0 && s <= 100
+	}
+	if err := quick.Check(bsjp, cfg); err != nil {
+		t.Errorf("score BSJP di luar 0-100: %v", err)
+	}
+
+	bpjs := func(rsi, change, volatility, mom, bonus float64, vol, avgVol int64) bool {
+		s := calculateBPJS(rsi, change, volatility, mom, bonus, vol, avgVol)
+		return s >= 0 && s <= 100
+	}
+	if err := quick.Check(bpjs, cfg); err != nil {
+		t.Errorf("score BPJS di luar 0-100: %v", err)
+	}
+}
+
+func randomEmitens(rng *rand.Rand, n int) []Emiten {
+	emitens := make([]Emiten, n)
+	for i := range emitens {
+		avgVol := int64(1000000 + rng.Intn(50000000))
+		emitens[i] = Emiten{
+			Symbol:        emitenList[i%len(emitenList)].symbol,
+			Name:          emitenList[i%len(emitenList)].name,
+			Sector:        emitenList[i%len(emitenList)].sector,
+			Price:         float64(50 + rng.Intn(20000)),
+			Change:        -8 + rng.Float64()*16,
+			Volume:        avgVol/2 + rng.Int63n(avgVol*2),
+			AvgVolume:     avgVol,
+			RSI:           rng.Float64() * 100,
+			Volatility:    rng.Float64() * 6,
+			GapPercent:    -2 + rng.Float64()*4,
+			AfternoonDip:  rng.Float64() * 100,
+			MorningMoment: rng.Float64() * 100,
+		}
+		emitens[i].Low = emitens[i].Price * 0.98
+	}
+	scoreEmitens(emitens, nil)
+	return emitens
+}
+
+func TestScanResultsProperty(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+
+	for round := 0; round < 50; round++ {
+		emitens := randomEmitens(rng, 40)
+
+		for _, tc := range []struct {
+			name     string
+			results  []ScanResult
+			minScore float64
+		}{
+			{"BSJP", scanBSJP(emitens), strategyParams.BSJP.MinScore},
+			{"BPJS", scanBPJS(emitens), strategyParams.BPJS.MinScore},
+		} {
+			if !sort.SliceIsSorted(tc.results, func(i, j int) bool { return tc.results[i].Score > tc.results[j].Score }) {
+				t.Fatalf("putaran %d: hasil %s tidak urut menurun", round, tc.name)
+			}
+			for _, r := range tc.results {
+				if r.Score < tc.minScore || r.Score > 100 {
+					t.Fatalf("putaran %d: %s %s score %v di luar %v-100", round, tc.name, r.Symbol, r.Score, tc.minScore)
+				}
+				want := "WATCH"
+				if r.Score >= 75 {
+					want = "STRONG BUY"
+				} else if r.Score >= 60 {
+					want = "BUY"
+				}
+				if r.Signal != want {
+					t.Fatalf("putaran %d: %s %s score %v signal %q, want %q", round, tc.name, r.Symbol, r.Score, r.Signal, want)
+				}
+			}
+		}
+	}
+}
+
+func TestFormatVol(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1.0K"},
+		{1500, "1.5K"},
+		{999900, "999.9K"},
+		{1000000, "1.0M"},
+		{25400000, "25.4M"},
+		{999900000, "999.9M"},
+		{1000000000, "1.0B"},
+		{3250000000, "3.2B"},
+	}
+
+	for _, tt := range tests {
+		if got := formatVol(tt.in); got != tt.want {
+			t.Errorf("formatVol(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatMoney(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want string
+	}{
+		{0, "Rp 0"},
+		{999999, "Rp 999999"},
+		{-999999, "-Rp 999999"},
+		{1000000, "Rp 1.0M"},
+		{-1000000, "-Rp 1.0M"},
+		{999900000, "Rp 999.9M"},
+		{1000000000, "Rp 1.0B"},
+		{-2500000000, "-Rp 2.5B"},
+		{999900000000, "Rp 999.9B"},
+		{1000000000000, "Rp 1.0T"},
+		{-1500000000000, "-Rp 1.5T"},
+	}
+
+	for _, tt := range tests {
+		if got := formatMoney(tt.in); got != tt.want {
+			t.Errorf("formatMoney(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func fixedEmitens() []Emiten {
+	emitens := []Emiten{
+		{Symbol: "BBCA", Name: "Bank Central Asia", Sector: "Banking", Price: 9850, Low: 9700, Change: -1.2, Volume: 42000000, AvgVolume: 30000000,
+			RSI: 32, Volatility: 2.6, GapPercent: 0.8, AfternoonDip: 65, MorningMoment: 30},
+		{Symbol: "TLKM", Name: "Telkom Indonesia", Sector: "Telco", Price: 3870, Low: 3820, Change: 1.4, Volume: 95000000, AvgVolume: 60000000,
+			RSI: 62, Volatility: 2.2, GapPercent: 0.2, AfternoonDip: 20, MorningMoment: 72},
+		{Symbol: "ANTM", Name: "Aneka Tambang", Sector: "Mining", Price: 1525, Low: 1490, Change: -2.1, Volume: 120000000, AvgVolume: 140000000,
+			RSI: 41, Volatility: 3.4, GapPercent: 0.3, AfternoonDip: 50, MorningMoment: 45},
+		{Symbol: "GOTO", Name: "GoTo Gojek Tokopedia", Sector: "Technology", Price: 68, Low: 66, Change: 2.5, Volume: 2100000000, AvgVolume: 1500000000,
+			RSI: 58, Volatility: 2.8, GapPercent: -0.4, AfternoonDip: 10, MorningMoment: 64},
+		{Symbol: "UNVR", Name: "Unilever Indonesia", Sector: "Consumer", Price: 2650, Low: 2630, Change: 0.2, Volume: 8000000, AvgVolume: 12000000,
+			RSI: 50, Volatility: 0.9, GapPercent: 0, AfternoonDip: 30, MorningMoment: 35},
+	}
+	scoreEmitens(emitens, nil)
+	return emitens
+}
+
+func TestGoldenTables(t *testing.T) {
+	emitens := fixedEmitens()
+
+	checkGolden(t, "bsjp", captureStdout(t, func() { printBSJP(scanBSJP(emitens)) }))
+	checkGolden(t, "bpjs", captureStdout(t, func() { printBPJS(scanBPJS(emitens)) }))
+	checkGolden(t, "empty", captureStdout(t, func() {
+		printBSJP(nil)
+		printBPJS(nil)
+	}))
+}
+
+func newTestAPIServer(t *testing.T) http.Handler {
+	t.Helper()
+
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	store, err := loadWatchlists(filepath.Join(t.TempDir(), "wl.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Profiles["default"] = map[string][]string{"bank": {"BBCA"}, "kosong": {}}
+	store.Profiles["andi"] = map[string][]string{"bank": {"ANTM"}}
+
+	s := &apiServer{
+		hub:        newStreamHub(),
+		watchlists: store,
+		profile:    "default",
+		snap: scanSnapshot{
+			Emitens:     fixedEmitens(),
+			GeneratedAt: time.Date(2024, 6, 3, 16, 0, 0, 0, time.UTC),
+			Meta:        ScanMeta{Seed: 42, AsOf: "2024-06-03"},
+		},
+	}
+	return s.routes()
+}
+
+func TestAPIHandlers(t *testing.T) {
+	h := newTestAPIServer(t)
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		status  int
+		symbols []string
+	}{
+		{"bsjp", "GET", "/scan/bsjp", 200, []string{"BBCA", "ANTM"}},
+		{"min_score", "GET", "/scan/bsjp?min_score=70", 200, []string{"BBCA"}},
+		{"min_score bukan angka", "GET", "/scan/bsjp?min_score=tinggi", 400, nil},
+		{"top", "GET", "/scan/bsjp?top=1", 200, []string{"BBCA"}},
+		{"top nol", "GET", "/scan/bsjp?top=0", 200, []string{}},
+		{"top negatif", "GET", "/scan/bsjp?top=-1", 400, nil},
+		{"sector", "GET", "/scan/bsjp?sector=mining", 200, []string{"ANTM"}},
+		{"date sama", "GET", "/scan/bsjp?date=2024-06-03", 200, []string{"BBCA", "ANTM"}},
+		{"date lain", "GET", "/scan/bsjp?date=2024-06-04", 404, nil},
+		{"date salah format", "GET", "/scan/bsjp?date=03-06-2024", 400, nil},
+		{"watchlist", "GET", "/scan/bsjp?watchlist=bank", 200, []string{"BBCA"}},
+		{"watchlist profil lain", "GET", "/scan/bsjp?watchlist=bank&profile=andi", 200, []string{"ANTM"}},
+		{"watchlist kosong", "GET", "/scan/bsjp?watchlist=kosong", 200, []string{}},
+		{"watchlist tidak ada", "GET", "/scan/bsjp?watchlist=tambang", 404, nil},
+		{"bukan GET", "POST", "/scan/bsjp", 405, nil},
+		{"emiten", "GET", "/emiten/bbca", 200, nil},
+		{"emiten tidak ada", "GET", "/emiten/XXXX", 404, nil},
+		{"endpoint tidak ada", "GET", "/scan/lain", 404, nil},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, rec.Code, tt.status, rec.Body)
+			continue
+		}
+		if tt.status != 200 {
+			var body struct{ Error string }
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error == "" {
+				t.Errorf("%s: respons error tanpa pesan: %s", tt.name, rec.Body)
+			}
+			continue
+		}
+		if tt.symbols == nil {
+			continue
+		}
+
+		var resp struct {
+			Date    string
+			Meta    ScanMeta
+			Count   int
+			Results []ScanResult
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var got []string
+		for _, r := range resp.Results {
+			got = append(got, r.Symbol)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.symbols) || resp.Count != len(tt.symbols) {
+			t.Errorf("%s: hasil %v (count %d), want %v", tt.name, got, resp.Count, tt.symbols)
+		}
+		if resp.Date != "2024-06-03" || resp.Meta.Seed != 42 {
+			t.Errorf("%s: date %q seed %d, want 2024-06-03 seed 42", tt.name, resp.Date, resp.Meta.Seed)
+		}
+	}
+}
+
+func TestReplayFeed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feed.csv")
+	body := "time,symbol,price,volume\n09:00:02,bbca,9900,100\n09:00:01,TLKM,3880,200\n09:00:02,ANTM,1530,300\n"
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	feed, err := loadReplayFeed(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for {
+		q, err := feed.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%s@%.0f", q.Symbol, q.Price))
+	}
+	if want := "[TLKM@3880 BBCA@9900 ANTM@1530]"; fmt.Sprint(got) != want {
+		t.Errorf("urutan replay %v, want %s", got, want)
+	}
+
+	feed, _ = loadReplayFeed(path, 0.001)
+	feed.Next(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := feed.Next(ctx); err != context.Canceled {
+		t.Errorf("Next setelah cancel = %v, want context.Canceled", err)
+	}
+
+	for name, body := range map[string]string{
+		"kosong":       "time,symbol,price,volume\n",
+		"kolom kurang": "time,symbol,price,volume\n09:00:00,BBCA,9900\n",
+		"waktu salah":  "time,symbol,price,volume\n9 pagi,BBCA,9900,1\n",
+		"harga salah":  "time,symbol,price,volume\n09:00:00,BBCA,x,1\n",
+	} {
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadReplayFeed(path, 0); err == nil {
+			t.Errorf("%s: seharusnya error", name)
+		}
+	}
+}
+
+func wsClientFrame(opcode byte, payload []byte) []byte {
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+func TestWebSocketStream(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	base := fixedEmitens()[0]
+	emitens := make([]Emiten, 300)
+	for i := range emitens {
+		emitens[i] = base
+		emitens[i].Symbol = fmt.Sprintf("S%03d", i)
+	}
+	scoreEmitens(emitens, nil)
+	want := len(scanBSJP(emitens))
+	if want <= 256 {
+		t.Fatalf("snapshot hanya %d event, butuh lebih dari buffer kirim", want)
+	}
+
+	s := &apiServer{hub: newStreamHub(), snap: scanSnapshot{Emitens: emitens, GeneratedAt: time.Now()}}
+	srv := httptest.NewServer(s.routes())
+	defer srv.Close()
+	defer s.hub.closeAll()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	handshake := "GET /ws?strategy=bsjp HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := conn.Write(append([]byte(handshake), wsClientFrame(0x9, []byte("halo"))...)); err != nil {
+		t.Fatal(err)
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake: %s accept %q", resp.Status, resp.Header.Get("Sec-WebSocket-Accept"))
+	}
+
+	snapshots := 0
+	for {
+		opcode, payload, err := readFrame(r)
+		if err != nil {
+			t.Fatalf("setelah %d snapshot: %v", snapshots, err)
+		}
+		if opcode == 0xA {
+			if string(payload) != "halo" {
+				t.Errorf("pong %q, want halo", payload)
+			}
+			break
+		}
+		var ev ScoreEvent
+		if err := json.Unmarshal(payload, &ev); err != nil || ev.Type != "snapshot" || ev.Strategy != "bsjp" {
+			t.Fatalf("event %s tidak valid: %v", payload, err)
+		}
+		snapshots++
+	}
+	if snapshots != want {
+		t.Errorf("%d snapshot diterima, want %d", snapshots, want)
+	}
+
+	s.hub.broadcast([]ScoreEvent{
+		{Type: "score_changed", Strategy: "bpjs", Symbol: "S001"},
+		{Type: "entered", Strategy: "bsjp", Symbol: "S002"},
+	})
+	opcode, payload, err := readFrame(r)
+	if err != nil || opcode != 0x1 {
+		t.Fatalf("broadcast: opcode %d err %v", opcode, err)
+	}
+	var ev ScoreEvent
+	if err := json.Unmarshal(payload, &ev); err != nil || ev.Symbol != "S002" {
+		t.Errorf("broadcast %s, want hanya event bsjp S002", payload)
+	}
+
+	if _, err := conn.Write(wsClientFrame(0x8, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if opcode, _, err := readFrame(r); err != nil || opcode != 0x8 {
+		t.Errorf("penutupan: opcode %d err %v, want frame close", opcode, err)
+	}
+}
+
+func TestDashboardAndBreakdown(t *testing.T) {
+	h := newTestAPIServer(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != 200 || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") || !bytes.Contains(rec.Body.Bytes(), []byte("/scan/bsjp")) {
+		t.Errorf("dashboard: status %d type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	for _, e := range fixedEmitens() {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/emiten/"+e.Symbol, nil))
+		var resp struct {
+			Results struct {
+				Emiten        Emiten
+				BSJPBreakdown []ScoreComponent `json:"bsjp_breakdown"`
+				BPJSBreakdown []ScoreComponent `json:"bpjs_breakdown"`
+			}
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: %v", e.Symbol, err)
+		}
+
+		for _, c := range []struct {
+			name       string
+			score      float64
+			components []ScoreComponent
+		}{{"bsjp", resp.Results.Emiten.ScoreBSJP, resp.Results.BSJPBreakdown}, {"bpjs", resp.Results.Emiten.ScoreBPJS, resp.Results.BPJSBreakdown}} {
+			if len(c.components) == 0 {
+				t.Errorf("%s %s: rincian score kosong", e.Symbol, c.name)
+			}
+			sum := 0.0
+			for _, comp := range c.components {
+				sum += comp.Points
+			}
+			if got := math.Max(0, math.Min(100, sum)); math.Abs(got-c.score) > 1e-9 {
+				t.Errorf("%s %s: jumlah komponen %.2f, score %.2f", e.Symbol, c.name, got, c.score)
+			}
+		}
+	}
+}
+
+type sinkRecorder struct {
+	mu       sync.Mutex
+	fail     int
+	paths    []string
+	requests []map[string]interface{}
+}
+
+func (s *sinkRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+	s.paths = append(s.paths, r.URL.Path)
+	s.requests = append(s.requests, body)
+	if s.fail > 0 {
+		s.fail--
+		w.WriteHeader(http.StatusBadGateway)
+	}
+}
+
+func (s *sinkRecorder) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+func alertResults() []ScanResult {
+	return []ScanResult{
+		{Symbol: "BBCA", Name: "Bank Central Asia", Signal: "STRONG BUY", Score: 88, Price: 9850, Target: 9927, StopLoss: 9603},
+		{Symbol: "ANTM", Name: "Aneka Tambang", Signal: "BUY", Score: 63, Price: 1525},
+	}
+}
+
+func TestAlertSinks(t *testing.T) {
+	sinks := map[string]*sinkRecorder{"webhook": {}, "telegram": {}, "discord": {}, "slack": {}}
+	var cfg AlertConfig
+	for kind, rec := range sinks {
+		srv := httptest.NewServer(rec)
+		defer srv.Close()
+		sc := AlertSinkConfig{Type: kind, URL: srv.URL + "/hook"}
+		if kind == "telegram" {
+			sc = AlertSinkConfig{Type: kind, BaseURL: srv.URL, Token: "123:rahasia", ChatID: "-100"}
+		}
+		cfg.Sinks = append(cfg.Sinks, sc)
+	}
+	cfg.Strategies = []string{"bsjp"}
+
+	d, err := newAlertDispatcher(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.now = func() time.Time { return time.Date(2024, 6, 3, 14, 45, 0, 0, d.loc) }
+
+	n, err := d.Notify(context.Background(), "bsjp", alertResults())
+	if err != nil || n != 1 {
+		t.Fatalf("Notify = %d, %v, want 1 alert STRONG BUY", n, err)
+	}
+	if n, _ := d.Notify(context.Background(), "bpjs", alertResults()); n != 0 {
+		t.Errorf("strategi bpjs tidak dipilih tapi %d alert terkirim", n)
+	}
+
+	want := "STRONG BUY BSJP: BBCA (Bank Central Asia) @ 9850 | score 88 | TP 9927 | SL 9603"
+	for kind, rec := range sinks {
+		if rec.count() != 1 {
+			t.Errorf("%s menerima %d request, want 1", kind, rec.count())
+			continue
+		}
+		body := rec.requests[0]
+		switch kind {
+		case "webhook":
+			alerts, _ := body["alerts"].([]interface{})
+			if body["text"] != want || len(alerts) != 1 {
+				t.Errorf("webhook body %v", body)
+			}
+		case "telegram":
+			if rec.paths[0] != "/bot123:rahasia/sendMessage" || body["chat_id"] != "-100" || body["text"] != want {
+				t.Errorf("telegram %s %v", rec.paths[0], body)
+			}
+		case "discord":
+			if body["content"] != want {
+				t.Errorf("discord body %v", body)
+			}
+		case "slack":
+			if body["text"] != want {
+				t.Errorf("slack body %v", body)
+			}
+		}
+	}
+
+	if n, err := d.Notify(context.Background(), "bsjp", alertResults()); n != 0 || err != nil {
+		t.Errorf("alert yang sama terkirim ulang: %d, %v", n, err)
+	}
+	for kind, rec := range sinks {
+		if rec.count() != 1 {
+			t.Errorf("%s menerima %d request setelah dedup, want 1", kind, rec.count())
+		}
+	}
+}
+
+func TestAlertRetryAndPerSinkState(t *testing.T) {
+	good, flaky := &sinkRecorder{}, &sinkRecorder{fail: 5}
+	goodSrv, flakySrv := httptest.NewServer(good), httptest.NewServer(flaky)
+	defer goodSrv.Close()
+	defer flakySrv.Close()
+
+	d, err := newAlertDispatcher(AlertConfig{
+		Retries: 2,
+		Backoff: "1ms",
+		Sinks:   []AlertSinkConfig{{Type: "webhook", URL: goodSrv.URL}, {Type: "webhook", URL: flakySrv.URL}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.Notify(context.Background(), "bsjp", alertResults()); err == nil {
+		t.Fatal("sink yang terus gagal seharusnya menghasilkan error")
+	}
+	if good.count() != 1 || flaky.count() != 3 {
+		t.Fatalf("request = %d dan %d, want 1 dan 3 (1 + 2 retry)", good.count(), flaky.count())
+	}
+
+	if n, err := d.Notify(context.Background(), "bsjp", alertResults()); err != nil || n != 1 {
+		t.Fatalf("Notify ulang = %d, %v, want 1 alert untuk sink yang tadi gagal", n, err)
+	}
+	if good.count() != 1 || flaky.count() != 6 {
+		t.Errorf("request = %d dan %d, want 1 dan 6 (sink sehat tidak dikirim ulang)", good.count(), flaky.count())
+	}
+}
+
+func TestAlertQuietHours(t *testing.T) {
+	rec := &sinkRecorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	d, err := newAlertDispatcher(AlertConfig{
+		QuietHours: &QuietHours{Start: "22:00", End: "06:00"},
+		Sinks:      []AlertSinkConfig{{Type: "slack", URL: srv.URL}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		clock string
+		sent  int
+	}{{"23:30", 0}, {"05:59", 0}, {"06:00", 1}, {"21:59", 0}} {
+		c, _ := time.Parse("15:04", tt.clock)
+		d.now = func() time.Time { return time.Date(2024, 6, 3, c.Hour(), c.Minute(), 0, 0, d.loc) }
+		if n, err := d.Notify(context.Background(), "bsjp", alertResults()); n != tt.sent || err != nil {
+			t.Errorf("jam %s: %d alert (%v), want %d", tt.clock, n, err, tt.sent)
+		}
+	}
+	if rec.count() != 1 {
+		t.Errorf("%d request, want 1", rec.count())
+	}
+}
+
+func TestTelegramErrorHidesToken(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	base := srv.URL
+	srv.Close()
+
+	sink, err := newAlertSink(&http.Client{Timeout: time.Second}, AlertSinkConfig{Type: "telegram", BaseURL: base, Token: "123:rahasia", ChatID: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sink.Send(context.Background(), "tes", nil)
+	if err == nil {
+		t.Fatal("server mati seharusnya error")
+	}
+	if strings.Contains(err.Error(), "rahasia") {
+		t.Errorf("error membocorkan token: %v", err)
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		ok      bool
+		minutes []int
+		dows    []int
+	}{
+		{"5 9 * * 1-5", true, []int{5}, []int{1, 2, 3, 4, 5}},
+		{"*/15 9 * * *", true, []int{0, 15, 30, 45}, []int{0, 1, 2, 3, 4, 5, 6, 7}},
+		{"10-20/5 9 * * 7", true, []int{10, 15, 20}, []int{0, 7}},
+		{"0,30 9 * * 1,3", true, []int{0, 30}, []int{1, 3}},
+		{"50/5 9 * * 0", true, []int{50, 55}, []int{0}},
+		{"5 9 * *", false, nil, nil},
+		{"60 9 * * *", false, nil, nil},
+		{"5 24 * * *", false, nil, nil},
+		{"5 9 0 * *", false, nil, nil},
+		{"5 9 * 13 *", false, nil, nil},
+		{"5 9 * * 8", false, nil, nil},
+		{"*/0 9 * * *", false, nil, nil},
+		{"20-10 9 * * *", false, nil, nil},
+		{"a 9 * * *", false, nil, nil},
+	}
+
+	keys := func(f cronField) []int {
+		var out []int
+		for v := range f {
+			out = append(out, v)
+		}
+		sort.Ints(out)
+		return out
+	}
+	for _, tt := range tests {
+		spec, err := parseCron(tt.expr)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseCron(%q) err = %v", tt.expr, err)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if fmt.Sprint(keys(spec.minute)) != fmt.Sprint(tt.minutes) || fmt.Sprint(keys(spec.dow)) != fmt.Sprint(tt.dows) {
+			t.Errorf("parseCron(%q) menit %v hari %v, want %v %v", tt.expr, keys(spec.minute), keys(spec.dow), tt.minutes, tt.dows)
+		}
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	at := func(day, hour, min int) time.Time { return time.Date(2024, 6, day, hour, min, 0, 0, time.UTC) }
+
+	tests := []struct {
+		expr string
+		t    time.Time
+		want bool
+	}{
+		{"45 14 * * 1-5", at(3, 14, 45), true},
+		{"45 14 * * 1-5", at(3, 14, 46), false},
+		{"45 14 * * 1-5", at(1, 14, 45), false},
+		{"45 14 * * 0", at(2, 14, 45), true},
+		{"45 14 * * 7", at(2, 14, 45), true},
+		{"0 9 3 * *", at(3, 9, 0), true},
+		{"0 9 3 7 *", at(3, 9, 0), false},
+		{"0 9 1 * 1", at(1, 9, 0), true},
+		{"0 9 1 * 1", at(3, 9, 0), true},
+		{"0 9 1 * 1", at(4, 9, 0), false},
+		{"0 9 */2 * 1", at(4, 9, 0), false},
+		{"0 9 */2 * 1", at(5, 9, 0), false},
+		{"0 9 */2 * 1", at(3, 9, 0), true},
+		{"0 9 */2 * 1", at(10, 9, 0), false},
+		{"0 9 1-7 * *", at(8, 9, 0), false},
+		{"0 9 * * */2", at(4, 9, 0), true},
+	}
+
+	for _, tt := range tests {
+		spec, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := spec.matches(tt.t); got != tt.want {
+			t.Errorf("%q pada %s = %v, want %v", tt.expr, tt.t.Format("Mon 02 Jan 15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestSchedulerSlots(t *testing.T) {
+	loc := jakartaLocation()
+	at := func(day, hour, min int) time.Time { return time.Date(2024, 6, day, hour, min, 0, 0, loc) }
+	spec, _ := parseCron("45 14 * * 1-5")
+	job := scheduledJob{ScheduleJob: ScheduleJob{Name: "bsjp-sore"}, spec: spec}
+
+	s := &scheduler{loc: loc, catchUp: 10 * time.Minute, holidays: map[string]bool{"2024-06-17": true}, lastRun: map[string]time.Time{}}
+
+	due := []struct {
+		name    string
+		now     time.Time
+		lastRun time.Time
+		slot    time.Time
+		ok      bool
+	}{
+		{"tepat waktu", at(3, 14, 45), time.Time{}, at(3, 14, 45), true},
+		{"terlambat masih dalam catch-up", at(3, 14, 55), time.Time{}, at(3, 14, 45), true},
+		{"lewat catch-up", at(3, 14, 56), time.Time{}, time.Time{}, false},
+		{"belum waktunya", at(3, 14, 44), time.Time{}, time.Time{}, false},
+		{"slot sudah jalan", at(3, 14, 50), at(3, 14, 45), time.Time{}, false},
+		{"slot kemarin sudah jalan", at(4, 14, 47), at(3, 14, 45), at(4, 14, 45), true},
+		{"hari libur", at(17, 14, 45), time.Time{}, time.Time{}, false},
+		{"akhir pekan", at(8, 14, 45), time.Time{}, time.Time{}, false},
+		{"detik diabaikan", at(3, 14, 45).Add(59 * time.Second), time.Time{}, at(3, 14, 45), true},
+	}
+	for _, tt := range due {
+		s.lastRun[job.Name] = tt.lastRun
+		slot, ok := s.dueSlot(job, tt.now)
+		if ok != tt.ok || !slot.Equal(tt.slot) {
+			t.Errorf("%s: dueSlot = %s %v, want %s %v", tt.name, slot, ok, tt.slot, tt.ok)
+		}
+	}
+
+	next := []struct {
+		from time.Time
+		want time.Time
+	}{
+		{at(3, 9, 0), at(3, 14, 45)},
+		{at(3, 14, 45), at(4, 14, 45)},
+		{at(7, 14, 45), at(10, 14, 45)},
+		{at(14, 15, 0), at(18, 14, 45)},
+	}
+	for _, tt := range next {
+		if got := s.nextSlot(job, tt.from); !got.Equal(tt.want) {
+			t.Errorf("nextSlot(%s) = %s, want %s", tt.from.Format("Mon 02 15:04"), got.Format("Mon 02 15:04"), tt.want.Format("Mon 02 15:04"))
+		}
+	}
+}
+
+func TestJournalTrades(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.json")
+	j, err := loadJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2024, 6, 3, 14, 50, 0, 0, time.UTC)
+
+	tp := j.addFromResult(ScanResult{Symbol: "BBCA", Signal: "STRONG BUY", Score: 88, Price: 9850, Target: 9950, StopLoss: 9600}, "bsjp", 2, at)
+	sl := j.addFromResult(ScanResult{Symbol: "ANTM", Signal: "BUY", Score: 63, Price: 1525, Target: 1600, StopLoss: 1490}, "bpjs", 10, at)
+	open := j.add(Trade{Symbol: "TLKM", Strategy: "MANUAL", Signal: "BUY", Lots: 5, EntryPrice: 3870, Target: 4000, StopLoss: 3700})
+	if tp.ID != 1 || sl.ID != 2 || open.ID != 3 || open.Status != "OPEN" || open.LastPrice != 3870 {
+		t.Fatalf("id/status trade baru salah: %+v %+v %+v", tp, sl, open)
+	}
+
+	if _, changed := j.updatePrices([]Emiten{{Symbol: "TLKM", Price: 3870, High: 3900, Low: 3850}}, at); changed {
+		t.Error("harga sama seharusnya tidak mengubah jurnal")
+	}
+
+	exited, changed := j.updatePrices([]Emiten{
+		{Symbol: "BBCA", Price: 9900, High: 9975, Low: 9800},
+		{Symbol: "ANTM", Price: 1500, High: 1530, Low: 1480},
+		{Symbol: "TLKM", Price: 3950, High: 3960, Low: 3880},
+	}, at.Add(24*time.Hour))
+	if !changed || len(exited) != 2 {
+		t.Fatalf("exited = %+v changed %v, want BBCA TP dan ANTM SL", exited, changed)
+	}
+
+	bbca, antm, tlkm := j.find(1), j.find(2), j.find(3)
+	if bbca.Status != "TP" || bbca.ExitPrice != 9950 || bbca.pnl() != 100*200 {
+		t.Errorf("BBCA: %s @%.0f pnl %.0f, want TP @9950 pnl 20000", bbca.Status, bbca.ExitPrice, bbca.pnl())
+	}
+	if antm.Status != "SL" || antm.ExitPrice != 1490 || antm.pnl() != -35*1000 {
+		t.Errorf("ANTM: %s @%.0f pnl %.0f, want SL @1490 pnl -35000", antm.Status, antm.ExitPrice, antm.pnl())
+	}
+	if tlkm.Status != "OPEN" || tlkm.LastPrice != 3950 || tlkm.pnl() != 80*500 {
+		t.Errorf("TLKM: %s last %.0f pnl %.0f, want OPEN 3950 pnl 40000", tlkm.Status, tlkm.LastPrice, tlkm.pnl())
+	}
+	if got := math.Round(tlkm.pnlPercent()*100) / 100; got != 2.07 {
+		t.Errorf("TLKM pnl%% = %.2f, want 2.07", got)
+	}
+
+	tlkm.close("MANUAL", 3800, at.Add(48*time.Hour))
+	if _, changed := j.updatePrices([]Emiten{{Symbol: "TLKM", Price: 3500, High: 4100, Low: 3400}}, at); changed {
+		t.Error("posisi tertutup tidak boleh ikut diperbarui")
+	}
+
+	realized, unrealized, byStrategy, bySignal := j.summary()
+	if realized != 20000-35000-35000 || unrealized != 0 {
+		t.Errorf("realized %.0f unrealized %.0f, want -50000 dan 0", realized, unrealized)
+	}
+	if st := byStrategy["BSJP"]; st == nil || st.Trades != 1 || st.Wins != 1 {
+		t.Errorf("statistik BSJP %+v", st)
+	}
+	if st := bySignal["BUY"]; st == nil || st.Trades != 2 || st.Wins != 0 || st.PnL != -70000 {
+		t.Errorf("statistik BUY %+v", st)
+	}
+
+	if err := j.save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.NextID != 4 || len(loaded.Trades) != 3 || loaded.find(3).ExitTime == nil {
+		t.Errorf("jurnal dimuat ulang: next %d, %d trade", loaded.NextID, len(loaded.Trades))
+	}
+}
+
+func TestWatchlistStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wl.json")
+	store, err := loadWatchlists(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.add("andi", "bank", nil); err == nil {
+		t.Error("add tanpa kode seharusnya ditolak")
+	}
+	if _, err := store.add("andi", "bank", []string{" ", ""}); err == nil {
+		t.Error("add dengan kode kosong seharusnya ditolak")
+	}
+	if _, err := store.add("andi", "bank", []string{"BBCA", "../x"}); err == nil {
+		t.Error("kode tidak valid seharusnya ditolak")
+	}
+	if _, err := store.get("andi", "bank"); err == nil {
+		t.Error("add yang gagal tidak boleh membuat watchlist")
+	}
+
+	n, err := store.add("andi", "bank", []string{"bbri", "BBCA", "MAPA", "BBCA"})
+	if err != nil || n != 3 {
+		t.Fatalf("add = %d, %v, want 3 saham", n, err)
+	}
+	if n, _ := store.add("andi", "bank", []string{"BBRI"}); n != 0 {
+		t.Errorf("add duplikat = %d, want 0", n)
+	}
+	if n, _ := store.remove("andi", "bank", []string{"BBCA", "BBRI", "MAPA"}); n != 3 {
+		t.Errorf("remove = %d, want 3", n)
+	}
+	if err := store.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = loadWatchlists(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := store.get("andi", "bank")
+	if err != nil || list == nil || len(list) != 0 {
+		t.Fatalf("watchlist kosong setelah dimuat = %#v, %v", list, err)
+	}
+	if got := scopeEmitens(fixedEmitens(), list); len(got) != 0 {
+		t.Errorf("watchlist kosong menghasilkan %d emiten, want 0", len(got))
+	}
+	if err := store.deleteList("andi", "bank"); err != nil {
+		t.Error(err)
+	}
+	if _, ok := store.Profiles["andi"]; ok {
+		t.Error("profil tanpa watchlist seharusnya ikut terhapus")
+	}
+}
+
+func TestWatchlistNullList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wl.json")
+	if err := os.WriteFile(path, []byte(`{"profiles":{"default":{"foo":null}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := loadWatchlists(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := store.get("default", "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := scopeEmitens(fixedEmitens(), list); len(got) != 0 {
+		t.Errorf("watchlist null menghasilkan %d emiten, want 0", len(got))
+	}
+	if got := scopeEmitens(fixedEmitens(), ScanMeta{Watchlist: "foo"}.scope()); len(got) != 0 {
+		t.Errorf("replay watchlist kosong menghasilkan %d emiten, want 0", len(got))
+	}
+	if got := scopeEmitens(fixedEmitens(), ScanMeta{}.scope()); len(got) != 5 {
+		t.Errorf("scan tanpa watchlist menghasilkan %d emiten, want 5", len(got))
+	}
+}
+
+func runTUIKeys(t *testing.T, keys string) (*tui, string) {
+	t.Helper()
+
+	emitens := fixedEmitens()
+	ui := newTUI("TES", 112, emitenViews(emitens, scanBSJP(emitens), scanBPJS(emitens), chartStyle{}), bufio.NewReader(strings.NewReader(keys)))
+	var action string
+	captureStdout(t, func() { action, _ = ui.run() })
+	return ui, action
+}
+
+func tuiSymbols(ui *tui) []string {
+	var out []string
+	for _, i := range ui.rows {
+		out = append(out, ui.views[ui.active].Symbol(i))
+	}
+	return out
+}
+
+func TestTUIKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    string
+		action  string
+		view    string
+		symbols []string
+	}{
+		{"awal", "q", "q", "BSJP", []string{"BBCA", "ANTM"}},
+		{"pindah tab", "\t\tq", "q", "SEMUA", []string{"BBCA", "TLKM", "ANTM", "GOTO", "UNVR"}},
+		{"urut kode", "\t\t>q", "q", "SEMUA", []string{"ANTM", "BBCA", "GOTO", "TLKM", "UNVR"}},
+		{"urut kode terbalik", "\t\t>sq", "q", "SEMUA", []string{"UNVR", "TLKM", "GOTO", "BBCA", "ANTM"}},
+		{"cari", "\t\t/bb\rq", "q", "SEMUA", []string{"BBCA"}},
+		{"cari lalu batal", "\t\t/bb\x1bq", "q", "SEMUA", []string{"BBCA", "TLKM", "ANTM", "GOTO", "UNVR"}},
+		{"cari dengan hapus", "\t\t/bbx\x7f\rq", "q", "SEMUA", []string{"BBCA"}},
+		{"filter sektor", "\t\tfq", "q", "SEMUA", []string{"BBCA"}},
+		{"scan ulang", "r", "r", "BSJP", []string{"BBCA", "ANTM"}},
+		{"menu", "\tm", "m", "BPJS", nil},
+		{"input habis", "\t", "q", "BPJS", nil},
+	}
+
+	for _, tt := range tests {
+		ui, action := runTUIKeys(t, tt.keys)
+		if action != tt.action || ui.views[ui.active].Name != tt.view {
+			t.Errorf("%s: aksi %q tab %s, want %q %s", tt.name, action, ui.views[ui.active].Name, tt.action, tt.view)
+			continue
+		}
+		if tt.symbols != nil && fmt.Sprint(tuiSymbols(ui)) != fmt.Sprint(tt.symbols) {
+			t.Errorf("%s: baris %v, want %v", tt.name, tuiSymbols(ui), tt.symbols)
+		}
+	}
+}
+
+func TestSMAAndEMA(t *testing.T) {
+	got := sma([]float64{1, 2, 3, 4, 5}, 3)
+	if !math.IsNaN(got[0]) || !math.IsNaN(got[1]) || got[2] != 2 || got[3] != 3 || got[4] != 4 {
+		t.Errorf("sma = %v, want [NaN NaN 2 3 4]", got)
+	}
+
+	if got := ema([]float64{1, 2, 3}, 3); got[0] != 1 || got[1] != 1.5 || got[2] != 2.25 {
+		t.Errorf("ema = %v, want [1 1.5 2.25]", got)
+	}
+}
+
+func TestRSISeriesWilder(t *testing.T) {
+	closes := []float64{44.34, 44.09, 44.15, 43.61, 44.33, 44.83, 45.10, 45.42, 45.84, 46.08, 45.89, 46.03, 45.61, 46.28,
+		46.28, 46.00, 46.03, 46.41, 46.22, 45.64, 46.21, 46.25, 45.71, 46.45, 45.78, 45.35, 44.03, 44.18, 44.22, 44.57,
+		43.42, 42.66, 43.13}
+	want := []float64{70.53, 66.32, 66.55, 69.41, 66.36, 57.97, 62.93, 63.26, 56.06, 62.38, 54.71, 50.42, 39.99,
+		41.46, 41.87, 45.46, 37.30, 33.08, 37.77}
+
+	got := rsiSeries(closes, 14)
+	for i := 0; i < 14; i++ {
+		if !math.IsNaN(got[i]) {
+			t.Errorf("rsi[%d] = %.2f sebelum 14 periode, want NaN", i, got[i])
+		}
+	}
+	for i, w := range want {
+		if math.Abs(got[14+i]-w) > 0.1 {
+			t.Errorf("rsi[%d] = %.2f, want %.2f", 14+i, got[14+i], w)
+		}
+	}
+
+	if got := rsiSeries([]float64{1, 2, 3, 4}, 3); got[3] != 100 {
+		t.Errorf("rsi tanpa penurunan = %.2f, want 100", got[3])
+	}
+}
+
+func TestMACDSeries(t *testing.T) {
+	flat := make([]float64, 60)
+	ramp := make([]float64, 400)
+	for i := range flat {
+		flat[i] = 1000
+	}
+	for i := range ramp {
+		ramp[i] = float64(i)
+	}
+
+	line, signal, hist := macdSeries(flat)
+	if line[59] != 0 || signal[59] != 0 || hist[59] != 0 {
+		t.Errorf("macd harga datar = %v %v %v, want 0", line[59], signal[59], hist[59])
+	}
+
+	line, signal, hist = macdSeries(ramp)
+	last := len(ramp) - 1
+	if math.Abs(line[last]-7) > 1e-6 || math.Abs(signal[last]-7) > 1e-6 || math.Abs(hist[last]) > 1e-6 {
+		t.Errorf("macd tren linear = %.6f %.6f %.6f, want 7 7 0 (selisih lag EMA 12 dan 26)", line[last], signal[last], hist[last])
+	}
+}
+
+func TestDailyReport(t *testing.T) {
+	var queries []string
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/scan/foreign/buy":
+			w.Write([]byte(`{"results":[{"symbol":"BMRI","name":"Bank <Mandiri>","price":6200,"net_foreign_value":9.3e9,"foreign_percent":41,"score":72,"signal":"STRONG BUY"}]}`))
+		case "/scan/foreign/sell":
+			w.Write([]byte(`{"results":[{"symbol":"TLKM","name":"Telkom Indonesia","price":3870,"net_foreign_value":-4.45e10,"foreign_percent":36,"strength":5,"signal":"STRONG SELL"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer foreign.Close()
+
+	emitens := fixedEmitens()
+	rep := newDailyReport(context.Background(), IndexData{Change1D: 0.5}, emitens, analyzeSectors(emitens, IndexData{}), foreign.URL)
+	rep.GeneratedAt = time.Date(2024, 6, 3, 16, 0, 0, 0, jakartaLocation())
+	rep.Meta = ScanMeta{Seed: 42, AsOf: "2024-06-03"}
+	if rep.ForeignNote != "" || len(rep.ForeignBuy) != 1 || len(rep.ForeignSell) != 1 {
+		t.Fatalf("data asing: note %q buy %d sell %d", rep.ForeignNote, len(rep.ForeignBuy), len(rep.ForeignSell))
+	}
+	if want := "/scan/foreign/buy?top=" + strconv.Itoa(reportRows); queries[0] != want {
+		t.Errorf("query %q, want %q", queries[0], want)
+	}
+
+	dir := t.TempDir()
+	day, err := writeReport(dir, "report", rep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if day != filepath.Join(dir, "2024-06-03") {
+		t.Errorf("folder laporan %s", day)
+	}
+	html, _ := os.ReadFile(filepath.Join(day, "report.html"))
+	md, _ := os.ReadFile(filepath.Join(day, "report.md"))
+
+	for _, want := range []string{"BBCA", "Bank &lt;Mandiri&gt;", "Seed 42", "STRONG SELL", `<td class="num">*****</td>`} {
+		if !bytes.Contains(html, []byte(want)) {
+			t.Errorf("HTML tidak memuat %q", want)
+		}
+	}
+	if bytes.Contains(html, []byte("<Mandiri>")) {
+		t.Error("nama saham tidak di-escape di HTML")
+	}
+	for _, want := range []string{"# Laporan Harian Scanner IDX", "| BSJP | 2 |", "| **TLKM** | Telkom Indonesia | Rp3870 |", "| ***** | STRONG SELL |", "| 72 | STRONG BUY |", "replay -seed 42 -as-of 2024-06-03"} {
+		if !bytes.Contains(md, []byte(want)) {
+			t.Errorf("Markdown tidak memuat %q", want)
+		}
+	}
+
+	foreign.Close()
+	rep = newDailyReport(context.Background(), IndexData{}, emitens, nil, foreign.URL)
+	if !strings.HasPrefix(rep.ForeignNote, "Data net foreign gagal diambil") {
+		t.Errorf("API asing mati: note %q", rep.ForeignNote)
+	}
+	if rep = newDailyReport(context.Background(), IndexData{}, emitens, nil, ""); rep.ForeignNote == "" {
+		t.Error("tanpa -foreign-api seharusnya ada catatan")
+	}
+}
+
+func TestOptimizeFolds(t *testing.T) {
+	m := buildMarket(syntheticSeries(11, 150+btWarmup))
+	n := len(m.Dates)
+	idx := make(map[string]int, n)
+	for i, d := range m.Dates {
+		idx[d.Format("2006-01-02")] = i
+	}
+
+	tests := []struct {
+		train, test int
+	}{
+		{40, 20},
+		{60, 30},
+		{50, 17},
+		{n - 10, 10},
+	}
+	for _, tt := range tests {
+		rep, err := optimize(rand.New(rand.NewSource(1)), m, defaultStrategyParams(), "bsjp", "expectancy", 3, tt.train, tt.test)
+		if err != nil {
+			t.Fatalf("train %d test %d: %v", tt.train, tt.test, err)
+		}
+		if want := (n - tt.train) / tt.test; len(rep.Folds) != want {
+			t.Fatalf("train %d test %d: %d fold, want %d", tt.train, tt.test, len(rep.Folds), want)
+		}
+		for k, f := range rep.Folds {
+			start := k * tt.test
+			got := []int{idx[f.TrainFrom], idx[f.TrainTo], idx[f.TestFrom], idx[f.TestTo]}
+			want := []int{start, start + tt.train - 1, start + tt.train, start + tt.train + tt.test - 1}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Errorf("train %d test %d fold %d: batas %v, want %v", tt.train, tt.test, k, got, want)
+					break
+				}
+			}
+			if k > 0 && idx[f.TestFrom] != idx[rep.Folds[k-1].TestTo]+1 {
+				t.Errorf("train %d test %d fold %d: jendela test tidak bersambung", tt.train, tt.test, k)
+			}
+		}
+		if last := rep.Folds[len(rep.Folds)-1]; idx[last.TestTo]+tt.test <= n-1 {
+			t.Errorf("train %d test %d: fold terakhir berhenti di %s, masih muat satu fold lagi", tt.train, tt.test, last.TestTo)
+		}
+	}
+
+	if _, err := optimize(rand.New(rand.NewSource(1)), m, defaultStrategyParams(), "bsjp", "expectancy", 3, n-5, 10); err == nil {
+		t.Error("train+test melebihi data seharusnya error")
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	values := make([]float64, 101)
+	for i := range values {
+		values[i] = float64(100 - i)
+	}
+	got := percentiles(values)
+	want := mcPercentiles{Mean: 50, P5: 5, P25: 25, P50: 50, P75: 75, P95: 95}
+	if math.Abs(got.Mean-want.Mean) > 1e-9 || got.P5 != want.P5 || got.P25 != want.P25 || got.P50 != want.P50 || got.P75 != want.P75 || got.P95 != want.P95 {
+		t.Errorf("percentiles = %+v, want %+v", got, want)
+	}
+	if values[0] != 100 {
+		t.Error("percentiles mengubah urutan slice input")
+	}
+	if got := percentiles(nil); got != (mcPercentiles{}) {
+		t.Errorf("percentiles(nil) = %+v, want nol", got)
+	}
+}
+
+func TestMonteCarloSeed(t *testing.T) {
+	var trades []btTrade
+	for _, r := range []float64{4, -2, 3, -5, 1, 2, -1, 6, -3, 2} {
+		trades = append(trades, btTrade{Return: r})
+	}
+
+	run := func(seed int64, mode string, slippage float64) monteCarloReport {
+		return monteCarlo(rand.New(rand.NewSource(seed)), trades, 500, mode, slippage, 0.5)
+	}
+
+	a, b := run(42, "bootstrap", 0.1), run(42, "bootstrap", 0.1)
+	if a.FinalReturn != b.FinalReturn || a.MaxDrawdown != b.MaxDrawdown || a.LosingStreak != b.LosingStreak || a.ProbLoss != b.ProbLoss {
+		t.Errorf("seed sama menghasilkan persentil berbeda: %+v vs %+v", a.FinalReturn, b.FinalReturn)
+	}
+	if c := run(43, "bootstrap", 0.1); c.FinalReturn == a.FinalReturn {
+		t.Error("seed berbeda menghasilkan persentil identik")
+	}
+
+	for _, p := range []mcPercentiles{a.FinalReturn, a.MaxDrawdown, a.LosingStreak} {
+		if !(p.P5 <= p.P25 && p.P25 <= p.P50 && p.P50 <= p.P75 && p.P75 <= p.P95) {
+			t.Errorf("persentil tidak berurutan: %+v", p)
+		}
+	}
+
+	shuffled := run(7, "shuffle", 0)
+	if math.Abs(shuffled.FinalReturn.P5-shuffled.Original.TotalReturn) > 1e-9 || math.Abs(shuffled.FinalReturn.P95-shuffled.Original.TotalReturn) > 1e-9 {
+		t.Errorf("shuffle tanpa slippage harus selalu berakhir di %.4f%%, dapat P5 %.4f P95 %.4f",
+			shuffled.Original.TotalReturn, shuffled.FinalReturn.P5, shuffled.FinalReturn.P95)
+	}
+	if slipped := run(7, "shuffle", 0.5); slipped.FinalReturn.P95 >= shuffled.Original.TotalReturn {
+		t.Errorf("slippage harus selalu merugikan: P95 %.4f, asli %.4f", slipped.FinalReturn.P95, shuffled.Original.TotalReturn)
+	}
+}
+
+func TestForeignBacktest(t *testing.T) {
+	m := buildMarket(syntheticSeries(3, 120+btWarmup))
+	res := runBacktest(m, defaultStrategyParams(), "foreign", 0, len(m.Dates))
+	if len(res.Trades) == 0 {
+		t.Fatal("backtest foreign tidak menghasilkan trade")
+	}
+	for _, tr := range res.Trades {
+		if tr.Strategy != "FOREIGN" || tr.Score < btForeignMin {
+			t.Fatalf("trade %s %s score %.1f di bawah batas %d", tr.Strategy, tr.Symbol, tr.Score, btForeignMin)
+		}
+	}
+
+	for d := range m.Days {
+		for i := range m.Days[d] {
+			m.Days[d][i].Emiten.NetForeign = 0
+		}
+	}
+	if n := len(runBacktest(m, defaultStrategyParams(), "foreign", 0, len(m.Dates)).Trades); n != 0 {
+		t.Errorf("tanpa data net_foreign ada %d trade, want 0", n)
+	}
+}
+
+func TestPriceLimits(t *testing.T) {
+	tests := []struct {
+		prevClose    float64
+		lower, upper float64
+	}{
+		{60, 50, 81},
+		{150, 98, 202},
+		{200, 130, 270},
+		{1000, 750, 1250},
+		{5000, 3750, 6250},
+		{8000, 6400, 9600},
+		{10025, 8025, 12025},
+	}
+	for _, tt := range tests {
+		lower, upper := priceLimits(tt.prevClose)
+		if lower != tt.lower || upper != tt.upper {
+			t.Errorf("priceLimits(%.0f) = %.0f-%.0f, want %.0f-%.0f", tt.prevClose, lower, upper, tt.lower, tt.upper)
+		}
+	}
+}
+
+func onTick(p float64) bool {
+	return p >= 50 && math.Mod(p, tickSize(p)) == 0
+}
+
+func TestSimulatorInvariants(t *testing.T) {
+	for _, seed := range []int64{1, 7, 42} {
+		_, series := newMarketSimulator(seed).simulate(time.Now(), 250)
+		for _, s := range series {
+			for i := 1; i < len(s.Bars); i++ {
+				b, prev := s.Bars[i], s.Bars[i-1]
+				lower, upper := priceLimits(prev.Close)
+				for _, p := range []float64{b.Open, b.High, b.Low, b.Close} {
+					if !onTick(p) {
+						t.Fatalf("seed %d %s %s: harga %.2f tidak sesuai fraksi %.0f", seed, s.Symbol, b.Date.Format("2006-01-02"), p, tickSize(p))
+					}
+					if p < lower || p > upper {
+						t.Fatalf("seed %d %s %s: harga %.0f di luar ARB/ARA %.0f-%.0f (prev %.0f)", seed, s.Symbol, b.Date.Format("2006-01-02"), p, lower, upper, prev.Close)
+					}
+				}
+				if b.Low > math.Min(b.Open, b.Close) || b.High < math.Max(b.Open, b.Close) {
+					t.Fatalf("seed %d %s %s: OHLC tidak konsisten %+v", seed, s.Symbol, b.Date.Format("2006-01-02"), b)
+				}
+				if b.Volume < 0 || b.Volume%sharesPerLot != 0 {
+					t.Fatalf("seed %d %s %s: volume %d bukan kelipatan lot", seed, s.Symbol, b.Date.Format("2006-01-02"), b.Volume)
+				}
+				if !b.Date.After(prev.Date) {
+					t.Fatalf("seed %d %s: tanggal tidak naik di %s", seed, s.Symbol, b.Date.Format("2006-01-02"))
+				}
+			}
+		}
+	}
+}
+
+func newTestProvider(t *testing.T, fake *fakeEODServer) (*httpProvider, *httptest.Server) {
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	p, err := newHTTPProvider(srv.URL, 1000, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.backoff = time.Millisecond
+	return p, srv
+}
+
+func fakeRequests(f *fakeEODServer) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+func TestHTTPProviderRetry(t *testing.T) {
+	_, series := newMarketSimulator(1).simulate(time.Now(), historyDays+btWarmup)
+	fake := newFakeEODServer(series[:1], 1)
+	fake.failNext = 2
+	p, _ := newTestProvider(t, fake)
+
+	s, err := p.Load(context.Background(), series[0].Symbol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Bars) != len(series[0].Bars) || s.Sector != series[0].Sector {
+		t.Errorf("dapat %d bar sektor %q, want %d bar sektor %q", len(s.Bars), s.Sector, len(series[0].Bars), series[0].Sector)
+	}
+	if n := fakeRequests(fake); n != 3 {
+		t.Errorf("request = %d, want 3 (2 gagal + 1 berhasil)", n)
+	}
+
+	fake.failNext = p.retries + 1
+	p.now = func() time.Time { return time.Now().AddDate(0, 0, 1) }
+	_, err = p.Load(context.Background(), series[0].Symbol)
+	var stale *staleDataError
+	if !errors.As(err, &stale) {
+		t.Errorf("err = %v, want staleDataError setelah semua percobaan gagal", err)
+	}
+}
+
+func TestHTTPProviderCache(t *testing.T) {
+	_, series := newMarketSimulator(1).simulate(time.Now(), historyDays+btWarmup)
+	fake := newFakeEODServer(series[:1], 1)
+	p, _ := newTestProvider(t, fake)
+	sym := series[0].Symbol
+
+	if _, err := p.Load(context.Background(), sym); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Load(context.Background(), sym); err != nil {
+		t.Fatal(err)
+	}
+	if n := fakeRequests(fake); n != 1 {
+		t.Errorf("request = %d, want 1 (hari yang sama dilayani cache)", n)
+	}
+
+	p.now = func() time.Time { return time.Now().AddDate(0, 0, 1) }
+	if _, err := p.Load(context.Background(), sym); err != nil {
+		t.Fatal(err)
+	}
+	if n := fakeRequests(fake); n != 2 {
+		t.Errorf("request = %d, want 2", n)
+	}
+	if c := p.readCache(sym); c == nil || c.ETag == "" || !c.FetchedAt.After(time.Now()) {
+		t.Errorf("cache tidak diperbarui setelah 304: %+v", c)
+	}
+}
+
+func TestHTTPProviderStaleFallback(t *testing.T) {
+	_, series := newMarketSimulator(1).simulate(time.Now(), historyDays+btWarmup)
+	p, srv := newTestProvider(t, newFakeEODServer(series, 1))
+
+	fresh, err := runScanPipeline(context.Background(), p, pipelineConfig{Workers: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fresh.Stale) > 0 || len(fresh.Failed) > 0 {
+		t.Fatalf("stale = %v, failed = %v", fresh.Stale, fresh.Failed)
+	}
+
+	srv.Close()
+	p.now = func() time.Time { return time.Now().AddDate(0, 0, 1) }
+	res, err := runScanPipeline(context.Background(), p, pipelineConfig{Workers: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Stale) != len(series) || len(res.Failed) > 0 {
+		t.Errorf("stale = %d, failed = %v, want %d saham basi", len(res.Stale), res.Failed, len(series))
+	}
+	if len(res.BSJP) != len(fresh.BSJP) || len(res.BPJS) != len(fresh.BPJS) {
+		t.Errorf("hasil dari cache berbeda: BSJP %d/%d, BPJS %d/%d", len(res.BSJP), len(fresh.BSJP), len(res.BPJS), len(fresh.BPJS))
+	}
+	if staleNote(res.Stale) == "" {
+		t.Error("staleNote kosong padahal ada data basi")
+	}
+}
+
+func TestHTTPProviderNoCache(t *testing.T) {
+	fake := newFakeEODServer(nil, 1)
+	fake.failNext = 100
+	p, _ := newTestProvider(t, fake)
+
+	_, err := p.Load(context.Background(), "BBCA")
+	var stale *staleDataError
+	if err == nil || errors.As(err, &stale) {
+		t.Errorf("err = %v, want error tanpa cache", err)
+	}
+}
+
+func TestEODCacheFresh(t *testing.T) {
+	loc := jakartaLocation()
+	at := func(day, hour, min int) time.Time { return time.Date(2024, 6, day, hour, min, 0, 0, loc) }
+
+	tests := []struct {
+		name      string
+		fetched   time.Time
+		now       time.Time
+		wantFresh bool
+	}{
+		{"pagi, masih pagi", at(3, 8, 0), at(3, 11, 0), true},
+		{"pagi, sebelum close", at(3, 8, 0), at(3, 16, 14), true},
+		{"pagi, setelah close", at(3, 8, 0), at(3, 16, 15), false},
+		{"pagi, malam hari", at(3, 8, 0), at(3, 22, 0), false},
+		{"setelah close, malam hari", at(3, 16, 30), at(3, 22, 0), true},
+		{"kemarin malam, pagi ini", at(2, 20, 0), at(3, 7, 0), false},
+		{"UTC tengah malam sama dengan 07:00 WIB", time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC), at(3, 12, 0), true},
+	}
+	for _, tt := range tests {
+		c := &eodCache{FetchedAt: tt.fetched}
+		if got := c.fresh(tt.now); got != tt.wantFresh {
+			t.Errorf("%s: fresh = %v, want %v", tt.name, got, tt.wantFresh)
+		}
+	}
+}
+
+func TestHTTPProviderRevalidatesAfterClose(t *testing.T) {
+	_, series := newMarketSimulator(1).simulate(time.Now(), historyDays+btWarmup)
+	fake := newFakeEODServer(series[:1], 1)
+	p, _ := newTestProvider(t, fake)
+	sym := series[0].Symbol
+
+	loc := jakartaLocation()
+	for i, tt := range []struct {
+		now  time.Time
+		want int
+	}{
+		{time.Date(2024, 6, 3, 9, 0, 0, 0, loc), 1},
+		{time.Date(2024, 6, 3, 15, 0, 0, 0, loc), 1},
+		{time.Date(2024, 6, 3, 17, 0, 0, 0, loc), 2},
+		{time.Date(2024, 6, 3, 21, 0, 0, 0, loc), 2},
+	} {
+		p.now = func() time.Time { return tt.now }
+		if _, err := p.Load(context.Background(), sym); err != nil {
+			t.Fatal(err)
+		}
+		if n := fakeRequests(fake); n != tt.want {
+			t.Errorf("load #%d pukul %s: request = %d, want %d", i+1, tt.now.Format("15:04"), n, tt.want)
+		}
+	}
+	if c := p.readCache(sym); c == nil || c.ETag == "" || !c.FetchedAt.Equal(time.Date(2024, 6, 3, 17, 0, 0, 0, loc)) {
+		t.Errorf("cache tidak diperbarui setelah revalidasi: %+v", c)
+	}
+}
+
+func TestHTTPProviderRejectsBadSymbol(t *testing.T) {
+	fake := newFakeEODServer(nil, 1)
+	p, _ := newTestProvider(t, fake)
+
+	for _, sym := range []string{"../../etc/passwd", "..", "bbca", "BB/CA", "", "ABCDEFGHIJKLM"} {
+		if _, err := p.Load(context.Background(), sym); err == nil {
+			t.Errorf("Load(%q) seharusnya ditolak", sym)
+		}
+	}
+	if n := fakeRequests(fake); n != 0 {
+		t.Errorf("request = %d, want 0 untuk kode tidak valid", n)
+	}
+	if entries, _ := os.ReadDir(p.cacheDir); len(entries) != 0 {
+		t.Errorf("cache berisi %d file, want kosong", len(entries))
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(50, 1)
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if err := b.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("6 request pada 50/s selesai dalam %v, want >= 100ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := newTokenBucket(0.001, 1).wait(ctx); err != nil {
+		t.Errorf("token awal harus langsung tersedia: %v", err)
+	}
+	slow := newTokenBucket(0.001, 1)
+	slow.wait(context.Background())
+	if err := slow.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func flatSeries(symbol string, n int, price float64, volume int64) btSeries {
+	s := btSeries{Symbol: symbol, Name: symbol, Sector: "Lainnya"}
+	for _, d := range tradingDaysBack(time.Date(2024, 6, 28, 0, 0, 0, 0, time.Local), n) {
+		s.Bars = append(s.Bars, Bar{Date: d, Open: price, High: price, Low: price, Close: price, Volume: volume})
+	}
+	return s
+}
+
+func TestCorporateActionAdjust(t *testing.T) {
+	exDate := flatSeries("BBCA", 40, 5000, 1000).Bars[30].Date.Format("2006-01-02")
+
+	tests := []struct {
+		name      string
+		action    CorporateAction
+		raw       func(b *Bar)
+		wantPrice float64
+		wantVol   int64
+	}{
+		{"split 1:5", CorporateAction{Type: "split", Ratio: 5}, func(b *Bar) { b.Close, b.Volume = b.Close/5, b.Volume*5 }, 1000, 5000},
+		{"reverse 10:1", CorporateAction{Type: "reverse_split", Ratio: 10}, func(b *Bar) { b.Close, b.Volume = b.Close*10, b.Volume/10 }, 50000, 100},
+		{"rights 4:1 @4000", CorporateAction{Type: "rights", Ratio: 0.25, Price: 4000}, nil, 4800, 1042},
+		{"rights di atas harga", CorporateAction{Type: "rights", Ratio: 0.25, Price: 6000}, nil, 5000, 1000},
+		{"dividen 200", CorporateAction{Type: "dividend", Amount: 200}, nil, 4800, 1000},
+	}
+
+	for _, tt := range tests {
+		tt.action.Symbol, tt.action.ExDate = "BBCA", exDate
+		reg := &corpActionRegistry{bySymbol: map[string][]CorporateAction{"BBCA": {tt.action}}}
+
+		s := flatSeries("BBCA", 40, 5000, 1000)
+		for i := 30; i < len(s.Bars) && tt.raw != nil; i++ {
+			tt.raw(&s.Bars[i])
+		}
+		got, err := reg.adjust(s)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+
+		b := got.Bars[0]
+		if math.Abs(b.Close-tt.wantPrice) > 0.01 || b.Volume != tt.wantVol {
+			t.Errorf("%s: bar pertama close %.2f volume %d, want %.2f dan %d", tt.name, b.Close, b.Volume, tt.wantPrice, tt.wantVol)
+		}
+		if got.Bars[35] != s.Bars[35] {
+			t.Errorf("%s: bar setelah ex-date ikut berubah: %+v", tt.name, got.Bars[35])
+		}
+		if s.Bars[0].Close != 5000 {
+			t.Errorf("%s: series asli ikut berubah", tt.name)
+		}
+	}
+}
+
+func TestLoadHistoryCSVAdjustsCorporateActions(t *testing.T) {
+	defer func(reg *corpActionRegistry) { corpActions = reg }(corpActions)
+
+	s := flatSeries("BBRI", btWarmup+40, 5000, 1000)
+	var b strings.Builder
+	b.WriteString("date,symbol,open,high,low,close,volume\n")
+	for i, bar := range s.Bars {
+		price, vol := bar.Close, bar.Volume
+		if i >= 50 {
+			price, vol = price/5, vol*5
+		}
+		fmt.Fprintf(&b, "%s,BBRI,%.0f,%.0f,%.0f,%.0f,%d\n", bar.Date.Format("2006-01-02"), price, price, price, price, vol)
+	}
+	path := filepath.Join(t.TempDir(), "history.csv")
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	corpActions = &corpActionRegistry{bySymbol: map[string][]CorporateAction{
+		"BBRI": {{Symbol: "BBRI", Type: "split", ExDate: s.Bars[50].Date.Format("2006-01-02"), Ratio: 5}},
+	}}
+	series, err := loadHistoryCSV(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := series[0].Bars[0]; got.Close != 1000 || got.Volume != 5000 {
+		t.Errorf("bar pertama close %.0f volume %d, want 1000 dan 5000", got.Close, got.Volume)
+	}
+
+	m, err := loadBacktestMarket(path, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for d, day := range m.Days {
+		if e := day[0].Emiten; math.Abs(e.Change) > 0.01 || math.Abs(e.Change20D) > 0.01 {
+			t.Errorf("hari %s: change %.2f%% / 20H %.2f%%, split masih terbaca sebagai penurunan", m.Dates[d].Format("2006-01-02"), e.Change, e.Change20D)
+		}
+	}
+}
+
+func TestCorporateActionSplitHidesFakeDrop(t *testing.T) {
+	_, series := newMarketSimulator(5).simulate(time.Now(), historyDays+btWarmup)
+	s := series[0]
+	ex := len(s.Bars) - 3
+	for i := ex; i < len(s.Bars); i++ {
+		b := &s.Bars[i]
+		b.Open, b.High, b.Low, b.Close, b.Volume = b.Open/5, b.High/5, b.Low/5, b.Close/5, b.Volume*5
+	}
+
+	raw, err := emitenFromSeries(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := &corpActionRegistry{bySymbol: map[string][]CorporateAction{
+		s.Symbol: {{Symbol: s.Symbol, Type: "split", ExDate: s.Bars[ex].Date.Format("2006-01-02"), Ratio: 5}},
+	}}
+	adjusted, err := reg.adjust(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := emitenFromSeries(adjusted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if raw.Change5D > -70 {
+		t.Fatalf("data mentah seharusnya turun ~80%%, change5d = %.1f", raw.Change5D)
+	}
+	if e.Change5D < -30 || e.RSI <= raw.RSI {
+		t.Errorf("setelah penyesuaian change5d = %.1f, rsi = %.1f (mentah %.1f)", e.Change5D, e.RSI, raw.RSI)
+	}
+}
+
+func TestLoadCorporateActions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		ok   bool
+	}{
+		{"valid", `[{"symbol":"bbca","type":"split","ex_date":"2024-06-10","ratio":5},{"symbol":"BBCA","type":"dividend","ex_date":"2024-03-01","amount":170}]`, true},
+		{"tanggal salah", `[{"symbol":"BBCA","type":"split","ex_date":"10-06-2024","ratio":5}]`, false},
+		{"split tanpa ratio", `[{"symbol":"BBCA","type":"split","ex_date":"2024-06-10"}]`, false},
+		{"rights tanpa harga", `[{"symbol":"BBCA","type":"rights","ex_date":"2024-06-10","ratio":0.2}]`, false},
+		{"tipe tidak dikenal", `[{"symbol":"BBCA","type":"bonus","ex_date":"2024-06-10","ratio":1}]`, false},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "actions.json")
+		if err := os.WriteFile(path, []byte(tt.body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		reg, err := loadCorporateActions(path)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v", tt.name, err)
+			continue
+		}
+		if tt.ok {
+			list := reg.bySymbol["BBCA"]
+			if len(list) != 2 || list[0].ExDate != "2024-03-01" {
+				t.Errorf("%s: aksi tidak terurut per ex_date: %+v", tt.name, list)
+			}
+		}
+	}
+}
+
+func TestGoldenExDividend(t *testing.T) {
+	defer func(reg *corpActionRegistry, meta ScanMeta) { corpActions, lastScan = reg, meta }(corpActions, lastScan)
+
+	lastScan = ScanMeta{AsOf: "2024-06-14"}
+	corpActions = &corpActionRegistry{bySymbol: map[string][]CorporateAction{
+		"BBCA": {{Symbol: "BBCA", Type: "dividend", ExDate: "2024-06-17", Amount: 170}},
+		"ANTM": {{Symbol: "ANTM", Type: "dividend", ExDate: "2024-06-18", Amount: 50}},
+	}}
+
+	checkGolden(t, "bsjp_exdiv", captureStdout(t, func() { printBSJP(scanBSJP(fixedEmitens())) }))
+}
+
+func TestExDividendTUIColumn(t *testing.T) {
+	defer func(reg *corpActionRegistry, meta ScanMeta) { corpActions, lastScan = reg, meta }(corpActions, lastScan)
+
+	lastScan = ScanMeta{AsOf: "2024-06-14"}
+	corpActions = &corpActionRegistry{bySymbol: map[string][]CorporateAction{
+		"BBCA": {{Symbol: "BBCA", Type: "dividend", ExDate: "2024-06-17", Amount: 170}},
+	}}
+
+	emitens := fixedEmitens()
+	views := emitenViews(emitens, scanBSJP(emitens), scanBPJS(emitens), chartStyle{})
+	for _, v := range views {
+		last := v.Columns[len(v.Columns)-1]
+		if (last.Title == "XD") != (v.Name == "BSJP") {
+			t.Errorf("view %s: kolom terakhir %q", v.Name, last.Title)
+			continue
+		}
+		if v.Name != "BSJP" {
+			continue
+		}
+		for i := 0; i < v.Len; i++ {
+			want := ""
+			if v.Symbol(i) == "BBCA" {
+				want = "XD"
+			}
+			if got := last.Text(i); got != want {
+				t.Errorf("BSJP %s: kolom XD %q, want %q", v.Symbol(i), got, want)
+			}
+			detail := strings.Join(v.Detail(i), "\n")
+			if strings.Contains(detail, "XD Rp170") != (want != "") {
+				t.Errorf("BSJP %s: detail XD tidak sesuai:\n%s", v.Symbol(i), detail)
+			}
+		}
+	}
+}
+
+func TestNextTradingDayHolidays(t *testing.T) {
+	defer func(reg *corpActionRegistry, meta ScanMeta, holidays map[string]bool) {
+		corpActions, lastScan, marketHolidays = reg, meta, holidays
+	}(corpActions, lastScan, marketHolidays)
+
+	days, err := parseHolidays([]string{"2024-06-17", "2024-12-25", "2024-12-26"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseHolidays([]string{"17-06-2024"}); err == nil {
+		t.Error("tanggal libur tidak valid diterima")
+	}
+
+	tests := []struct {
+		from, want string
+		holidays   map[string]bool
+	}{
+		{"2024-06-14", "2024-06-17", nil},
+		{"2024-06-14", "2024-06-18", days},
+		{"2024-06-13", "2024-06-14", days},
+		{"2024-12-24", "2024-12-27", days},
+	}
+	for _, tt := range tests {
+		marketHolidays = tt.holidays
+		from, _ := time.Parse("2006-01-02", tt.from)
+		if got := nextTradingDay(from).Format("2006-01-02"); got != tt.want {
+			t.Errorf("nextTradingDay(%s) libur %d = %s, want %s", tt.from, len(tt.holidays), got, tt.want)
+		}
+	}
+
+	marketHolidays = days
+	lastScan = ScanMeta{AsOf: "2024-06-14"}
+	corpActions = &corpActionRegistry{bySymbol: map[string][]CorporateAction{
+		"BBCA": {{Symbol: "BBCA", Type: "dividend", ExDate: "2024-06-17", Amount: 170}},
+		"ANTM": {{Symbol: "ANTM", Type: "dividend", ExDate: "2024-06-18", Amount: 50}},
+	}}
+	if note := exDividendNote("BBCA", 9850); note != "" {
+		t.Errorf("BBCA ex di hari libur tetap ditandai %q", note)
+	}
+	if note := exDividendNote("ANTM", 1525); !strings.HasPrefix(note, "XD Rp50") {
+		t.Errorf("ANTM ex setelah libur = %q, want XD Rp50", note)
+	}
+}
+
+func TestDividendCumDate(t *testing.T) {
+	tests := []struct {
+		action CorporateAction
+		want   string
+	}{
+		{CorporateAction{ExDate: "2024-06-18"}, "2024-06-17"},
+		{CorporateAction{ExDate: "2024-06-17"}, "2024-06-14"},
+		{CorporateAction{ExDate: "2024-06-18", CumDate: "2024-06-14"}, "2024-06-14"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.action.cumDate(); got != tt.want {
+			t.Errorf("cumDate(%+v) = %s, want %s", tt.action, got, tt.want)
+		}
+	}
+}
+
+func TestDividendRuns(t *testing.T) {
+	s := flatSeries("TLKM", 40, 3000, 1000)
+	for i := 20; i <= 25; i++ {
+		s.Bars[i].Close = 3300
+	}
+	cum := s.Bars[25].Date.Format("2006-01-02")
+	reg := &corpActionRegistry{bySymbol: map[string][]CorporateAction{"TLKM": {
+		{Symbol: "TLKM", Type: "dividend", ExDate: s.Bars[26].Date.Format("2006-01-02"), Amount: 100},
+		{Symbol: "TLKM", Type: "dividend", ExDate: s.Bars[5].Date.Format("2006-01-02"), Amount: 100},
+		{Symbol: "TLKM", Type: "dividend", ExDate: "2030-01-02", Amount: 100},
+		{Symbol: "TLKM", Type: "split", ExDate: s.Bars[30].Date.Format("2006-01-02"), Ratio: 2},
+	}}}
+
+	runs := reg.dividendRuns(s)
+	if len(runs) != 1 {
+		t.Fatalf("runs = %+v, want hanya dividen dengan %d bar sebelum cum %s", runs, preCumDays, cum)
+	}
+	if math.Abs(runs[0].PreCum-10) > 1e-9 {
+		t.Errorf("pre-cum = %.2f%%, want 10%%", runs[0].PreCum)
+	}
+}
+
+func fixedDividends() *corpActionRegistry {
+	return &corpActionRegistry{bySymbol: map[string][]CorporateAction{
+		"BBCA": {{Symbol: "BBCA", Type: "dividend", ExDate: "2024-06-20", Amount: 170, PayDate: "2024-07-05"}},
+		"TLKM": {
+			{Symbol: "TLKM", Type: "dividend", ExDate: "2024-06-04", Amount: 150},
+			{Symbol: "TLKM", Type: "dividend", ExDate: "2024-07-04", Amount: 180, AnnounceDate: "2024-05-30"},
+		},
+		"ANTM": {{Symbol: "ANTM", Type: "dividend", ExDate: "2024-06-17", CumDate: "2024-06-14", Amount: 60}},
+		"UNVR": {{Symbol: "UNVR", Type: "dividend", ExDate: "2024-08-01", Amount: 80}},
+		"GOTO": {{Symbol: "GOTO", Type: "split", ExDate: "2024-06-20", Ratio: 2}},
+	}}
+}
+
+func TestScanDividends(t *testing.T) {
+	emitens := fixedEmitens()
+	for i := range emitens {
+		if emitens[i].Symbol == "TLKM" {
+			emitens[i].DividendRuns = []DividendRun{{PreCum: 4}, {PreCum: -1}, {PreCum: 3}}
+		}
+	}
+
+	picks := scanDividends(emitens, fixedDividends(), time.Date(2024, 6, 14, 15, 0, 0, 0, time.Local))
+	var got []string
+	for _, p := range picks {
+		got = append(got, fmt.Sprintf("%s:%d", p.Symbol, p.DaysLeft))
+	}
+	if want := "[ANTM:0 BBCA:5 TLKM:19]"; fmt.Sprint(got) != want {
+		t.Fatalf("picks = %v, want %s", got, want)
+	}
+
+	if y := picks[1].Yield; math.Abs(y-170.0/9850*100) > 1e-9 {
+		t.Errorf("yield BBCA = %.3f", y)
+	}
+	if p := picks[2]; p.Runs != 3 || p.RunsUp != 2 || math.Abs(p.AvgRun-2) > 1e-9 {
+		t.Errorf("run TLKM = %d/%d rata-rata %.2f, want 2/3 rata-rata 2", p.RunsUp, p.Runs, p.AvgRun)
+	}
+	if scanDividends(emitens, nil, time.Now()) != nil {
+		t.Error("tanpa registry seharusnya tidak ada hasil")
+	}
+}
+
+func TestGoldenDividendCalendar(t *testing.T) {
+	defer func(reg *corpActionRegistry) { corpActions = reg }(corpActions)
+
+	corpActions = fixedDividends()
+	emitens := fixedEmitens()
+	emitens[1].DividendRuns = []DividendRun{{PreCum: 4}, {PreCum: -1}}
+
+	checkGolden(t, "dividends", captureStdout(t, func() {
+		printDividendCalendar(scanDividends(emitens, corpActions, time.Date(2024, 6, 14, 0, 0, 0, 0, time.Local)))
+		printDividendCalendar(nil)
+	}))
+}
+
+func quarters(incomes ...float64) []FinancialQuarter {
+	var qs []FinancialQuarter
+	for i, ni := range incomes {
+		qs = append(qs, FinancialQuarter{
+			Period:      fmt.Sprintf("%dQ%d", 2022+i/4, i%4+1),
+			NetIncome:   ni,
+			Equity:      400,
+			Liabilities: 200,
+			Shares:      10,
+		})
+	}
+	return qs
+}
+
+func TestComputeFundamentals(t *testing.T) {
+	tests := []struct {
+		name      string
+		quarters  []FinancialQuarter
+		price     float64
+		wantEPS   float64
+		wantPER   float64
+		wantROE   float64
+		wantGrow  float64
+		hasGrowth bool
+		loss      bool
+	}{
+		{"4 kuartal", quarters(10, 10, 10, 10), 80, 4, 20, 10, 0, false, false},
+		{"2 kuartal disetahunkan", quarters(5, 15), 100, 4, 25, 10, 0, false, false},
+		{"5 kuartal yoy", quarters(10, 10, 10, 10, 15), 100, 4.5, 100 / 4.5, 11.25, 50, true, false},
+		{"8 kuartal ttm", quarters(5, 5, 5, 5, 10, 10, 10, 10), 100, 4, 25, 10, 100, true, false},
+		{"rugi", quarters(-10, -10, 5, -5), 100, -2, 0, -5, 0, false, true},
+	}
+
+	for _, tt := range tests {
+		f := computeFundamentals(tt.quarters, tt.price)
+		if math.Abs(f.EPS-tt.wantEPS) > 1e-9 || math.Abs(f.PER-tt.wantPER) > 1e-9 || math.Abs(f.ROE-tt.wantROE) > 1e-9 {
+			t.Errorf("%s: EPS %.2f PER %.2f ROE %.2f, want %.2f %.2f %.2f", tt.name, f.EPS, f.PER, f.ROE, tt.wantEPS, tt.wantPER, tt.wantROE)
+		}
+		if f.HasGrowth != tt.hasGrowth || math.Abs(f.EPSGrowth-tt.wantGrow) > 1e-9 || f.Loss != tt.loss {
+			t.Errorf("%s: growth %.2f (%v) loss %v, want %.2f (%v) %v", tt.name, f.EPSGrowth, f.HasGrowth, f.Loss, tt.wantGrow, tt.hasGrowth, tt.loss)
+		}
+		if f.PBV != tt.price/40 || f.DER != 0.5 || f.MarketCap != tt.price*10 {
+			t.Errorf("%s: PBV %.2f DER %.2f kapitalisasi %.0f", tt.name, f.PBV, f.DER, f.MarketCap)
+		}
+	}
+
+	neg := quarters(1, 1, 1, 1)
+	neg[3].Equity = -50
+	if f := computeFundamentals(neg, 100); !f.NegativeEquity || f.PBV != 0 || f.DER != 0 {
+		t.Errorf("ekuitas negatif: %+v", f)
+	}
+	if computeFundamentals(nil, 100) != nil {
+		t.Error("tanpa data kuartal seharusnya nil")
+	}
+}
+
+func TestFundamentalRules(t *testing.T) {
+	good := &Fundamentals{PER: 10, PBV: 1.5, ROE: 18, DER: 0.8, EPSGrowth: 12, HasGrowth: true, MarketCap: 5e12}
+	loss := &Fundamentals{Loss: true, PBV: 3, ROE: -4, DER: 2.5, MarketCap: 8e11}
+	broke := &Fundamentals{Loss: true, NegativeEquity: true, MarketCap: 2e11}
+
+	rules := FundamentalRules{
+		Filter:      FundamentalCriteria{ProfitOnly: true, MaxDER: 2},
+		Bonus:       FundamentalCriteria{MaxPER: 15, MinROE: 15, MinEPSGrowth: 10, MinMarketCap: 1e13},
+		BonusPoints: 2.5,
+	}
+	for _, tt := range []struct {
+		name   string
+		f      *Fundamentals
+		pass   bool
+		points float64
+	}{
+		{"sehat", good, true, 7.5},
+		{"rugi", loss, false, 0},
+		{"ekuitas negatif", broke, false, 0},
+		{"tanpa data", nil, true, 0},
+	} {
+		if got := rules.passes(tt.f); got != tt.pass {
+			t.Errorf("%s: passes = %v, want %v", tt.name, got, tt.pass)
+		}
+		c, ok := rules.component(tt.f)
+		if !ok || c.Points != tt.points || c.Factor != "fundamental" {
+			t.Errorf("%s: component = %+v, want %.1f poin", tt.name, c, tt.points)
+		}
+	}
+
+	rules.RequireData = true
+	if rules.passes(nil) {
+		t.Error("require_data: saham tanpa data seharusnya tidak lolos")
+	}
+	if _, ok := (FundamentalRules{}).component(good); ok {
+		t.Error("tanpa bonus_points seharusnya tidak ada komponen score")
+	}
+	if !(FundamentalRules{}).passes(broke) {
+		t.Error("tanpa filter semua saham seharusnya lolos")
+	}
+}
+
+func TestLoadFinancials(t *testing.T) {
+	header := "symbol,period,revenue,net_income,equity,liabilities,shares\n"
+	tests := []struct {
+		name string
+		body string
+		ok   bool
+	}{
+		{"valid", header + "bbca,2024Q2,27e12,13.2e12,250e12,1150e12,123.3e9\nBBCA,2024q1,26e12,12.9e12,245e12,1130e12,123.3e9\n", true},
+		{"kolom kurang", "symbol,period,net_income\nBBCA,2024Q1,1\n", false},
+		{"periode salah", header + "BBCA,2024-03,1,1,1,1,1\n", false},
+		{"angka salah", header + "BBCA,2024Q1,1,x,1,1,1\n", false},
+		{"saham nol", header + "BBCA,2024Q1,1,1,1,1,0\n", false},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "fin.csv")
+		if err := os.WriteFile(path, []byte(tt.body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		store, err := loadFinancials(path)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v", tt.name, err)
+			continue
+		}
+		if tt.ok {
+			qs := store.bySymbol["BBCA"]
+			if len(qs) != 2 || qs[0].Period != "2024Q1" {
+				t.Errorf("%s: kuartal tidak terurut: %+v", tt.name, qs)
+			}
+		}
+	}
+}
+
+func TestScanFundamentalFilterAndBonus(t *testing.T) {
+	defer func(rules FundamentalRules) { fundamentalRules = rules }(fundamentalRules)
+
+	emitens := fixedEmitens()
+	emitens[0].Fundamental = &Fundamentals{Loss: true, NegativeEquity: true}
+	emitens[2].Fundamental = &Fundamentals{PER: 8, ROE: 20, DER: 0.5}
+	fundamentalRules = FundamentalRules{
+		Filter:      FundamentalCriteria{ProfitOnly: true},
+		Bonus:       FundamentalCriteria{MaxPER: 15, MinROE: 15},
+		BonusPoints: 4,
+	}
+	before := emitens[2].ScoreBSJP
+	scoreEmitens(emitens, nil)
+
+	for _, r := range scanBSJP(emitens) {
+		if r.Symbol == "BBCA" {
+			t.Error("BBCA rugi dengan ekuitas negatif seharusnya tersaring dari BSJP")
+		}
+	}
+	if got := emitens[2].ScoreBSJP; got != math.Min(100, before+8) {
+		t.Errorf("score ANTM = %.0f, want %.0f + 8 bonus fundamental", got, before)
+	}
+}
+
+func TestSeedReplay(t *testing.T) {
+	if got := newSeedSource(0, true).next(); got != 0 {
+		t.Errorf("seed 0 eksplisit diganti menjadi %d", got)
+	}
+	a, b := newSeedSource(0, true), newSeedSource(0, true)
+	a.next()
+	b.next()
+	if x, y := a.next(), b.next(); x != y {
+		t.Errorf("scan kedua dari seed 0 berbeda: %d vs %d", x, y)
+	}
+
+	scan := func(seed int64) pipelineResult {
+		meta := newScanMeta(seed, time.Date(2024, 6, 3, 0, 0, 0, 0, time.Local))
+		res, err := loadMarket(context.Background(), meta, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	for _, seed := range []int64{0, 42} {
+		first, again := scan(seed), scan(seed)
+		if len(first.BSJP)+len(first.BPJS) == 0 {
+			t.Fatalf("seed %d: scan tidak menghasilkan sinyal", seed)
+		}
+		if diffs := append(compareResults("BSJP", first.BSJP, again.BSJP), compareResults("BPJS", first.BPJS, again.BPJS)...); len(diffs) > 0 {
+			t.Errorf("seed %d: replay berbeda: %v", seed, diffs)
+		}
+	}
+	if fmt.Sprint(scan(0).BSJP) == fmt.Sprint(scan(42).BSJP) {
+		t.Error("seed 0 dan 42 menghasilkan scan yang sama")
+	}
+
+	defer func(p StrategyParams) { strategyParams = p }(strategyParams)
+	bad := newScanMeta(42, time.Date(2024, 6, 3, 0, 0, 0, 0, time.Local))
+	bad.Params.BPJS.RSIMin, bad.Params.BPJS.RSIMax = 70, 55
+	for name, meta := range map[string]ScanMeta{"batas terbalik": bad, "tanpa params": {Seed: 42, AsOf: "2024-06-03"}} {
+		data, err := json.Marshal(replayFile{Meta: &meta})
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(t.TempDir(), "scan.json")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		before := strategyParams
+		if err := runReplayCommand([]string{path}); err == nil {
+			t.Errorf("%s: replay dengan params tidak valid tidak ditolak", name)
+		}
+		if strategyParams != before {
+			t.Errorf("%s: params tidak valid tetap dipakai: %+v", name, strategyParams)
+		}
+	}
+}
+
+func sectorFixture() ([]Emiten, IndexData) {
+	return []Emiten{
+		{Symbol: "BBCA", Sector: "Banking", Change: 2, Change5D: 4, Change20D: 3, NetForeign: 4e9},
+		{Symbol: "BMRI", Sector: "Banking", Change: 1, Change5D: 3, Change20D: 3, NetForeign: 2e9},
+		{Symbol: "BBNI", Sector: "Banking", Change: 0, Change5D: 2, Change20D: 3, NetForeign: -1e9},
+		{Symbol: "ANTM", Sector: "Mining", Change: -2, Change5D: -3, Change20D: 0, NetForeign: -3e9},
+		{Symbol: "INCO", Sector: "Mining", Change: -3, Change5D: -5, Change20D: 2, NetForeign: 2e9},
+		{Symbol: "TLKM", Sector: "Telco", Change: 0.5, Change5D: 1, Change20D: 2},
+	}, IndexData{Change1D: 0.5, Change5D: 1, Change20D: 2}
+}
+
+func TestAnalyzeSectors(t *testing.T) {
+	emitens, ihsg := sectorFixture()
+	sectors := analyzeSectors(emitens, ihsg)
+
+	tests := []struct {
+		sector          string
+		count, adv, dec int
+		breadth         float64
+		rs1, rs5, rs20  float64
+		rotation        float64
+		status          string
+		bonus           float64
+	}{
+		{"Telco", 1, 1, 0, 100, 0, 0, 0, 2, "NETRAL", 0},
+		{"Banking", 3, 2, 0, 200.0 / 3, 0.5, 2, 1, 0.25 + 0.6 - 0.1 + 2.0/3, "ROTASI MASUK", 10},
+		{"Mining", 2, 0, 2, 0, -3, -5, -1, -1.5 - 1.5 + 0.1 - 2, "ROTASI KELUAR", -6},
+	}
+
+	if len(sectors) != len(tests) {
+		t.Fatalf("jumlah sektor = %d, want %d", len(sectors), len(tests))
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	for i, tt := range tests {
+		s := sectors[i]
+		if s.Sector != tt.sector {
+			t.Errorf("rank %d = %s, want %s", i+1, s.Sector, tt.sector)
+			continue
+		}
+		if s.Count != tt.count || s.Advancers != tt.adv || s.Decliners != tt.dec || !near(s.Breadth, tt.breadth) {
+			t.Errorf("%s: jml %d adv/dec %d/%d breadth %.2f, want %d %d/%d %.2f",
+				tt.sector, s.Count, s.Advancers, s.Decliners, s.Breadth, tt.count, tt.adv, tt.dec, tt.breadth)
+		}
+		if !near(s.RS1D, tt.rs1) || !near(s.RS5D, tt.rs5) || !near(s.RS20D, tt.rs20) {
+			t.Errorf("%s: RS = %.2f/%.2f/%.2f, want %.2f/%.2f/%.2f", tt.sector, s.RS1D, s.RS5D, s.RS20D, tt.rs1, tt.rs5, tt.rs20)
+		}
+		if !near(s.Rotation, tt.rotation) || s.Status != tt.status {
+			t.Errorf("%s: rotasi %.3f %s, want %.3f %s", tt.sector, s.Rotation, s.Status, tt.rotation, tt.status)
+		}
+		if got := sectorBonus(s); got != tt.bonus {
+			t.Errorf("%s: bonus = %.0f, want %.0f", tt.sector, got, tt.bonus)
+		}
+	}
+
+	scoreEmitens(emitens, sectors)
+	for _, e := range emitens {
+		if want := sectorBonuses(sectors)[e.Sector]; e.SectorBonus != want {
+			t.Errorf("%s: bonus sektor = %.0f, want %.0f", e.Symbol, e.SectorBonus, want)
+		}
+	}
+}
+
+func TestGoldenSectorRotation(t *testing.T) {
+	emitens, ihsg := sectorFixture()
+	checkGolden(t, "rotation", captureStdout(t, func() { printSectorRotation(analyzeSectors(emitens, ihsg), ihsg) }))
+}
+
+type flakySource struct {
+	*seriesSource
+}
+
+func (s flakySource) Load(ctx context.Context, symbol string) (btSeries, error) {
+	switch symbol {
+	case "BBCA":
+		return btSeries{}, errors.New("file rusak")
+	case "TLKM":
+		panic("parser gagal")
+	case "ASII":
+		series, _ := s.seriesSource.Load(ctx, symbol)
+		series.Bars = series.Bars[:10]
+		return series, nil
+	}
+	return s.seriesSource.Load(ctx, symbol)
+}
+
+func TestPipelineIsolatesSymbolErrors(t *testing.T) {
+	_, series := newMarketSimulator(1).simulate(time.Now(), historyDays+btWarmup)
+	src := flakySource{newSeriesSource(series)}
+
+	var last scanProgress
+	res, err := runScanPipeline(context.Background(), src, pipelineConfig{Workers: 4, Progress: func(p scanProgress) { last = p }})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed := make(map[string]bool)
+	for _, f := range res.Failed {
+		failed[f.Symbol] = true
+	}
+	for _, sym := range []string{"BBCA", "TLKM", "ASII"} {
+		if !failed[sym] {
+			t.Errorf("%s seharusnya gagal, failed = %v", sym, res.Failed)
+		}
+	}
+	if len(res.Emitens) != len(series)-3 {
+		t.Errorf("dapat %d emiten, want %d", len(res.Emitens), len(series)-3)
+	}
+	if last.Stage != "ranking" {
+		t.Errorf("progress terakhir %+v, want tahap ranking", last)
+	}
+}
+
+func TestPipelineMatchesSequentialScore(t *testing.T) {
+	ihsg, series := newMarketSimulator(3).simulate(time.Now(), historyDays+btWarmup)
+
+	res, err := runScanPipeline(context.Background(), newSeriesSource(series), pipelineConfig{Workers: 8, IHSG: &ihsg})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	emitens := make([]Emiten, len(series))
+	for i, s := range series {
+		if emitens[i], err = emitenFromSeries(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	scoreEmitens(emitens, analyzeSectors(emitens, ihsg))
+
+	for i, e := range emitens {
+		got := res.Emitens[i]
+		if got.Symbol != e.Symbol || got.ScoreBSJP != e.ScoreBSJP || got.ScoreBPJS != e.ScoreBPJS {
+			t.Fatalf("%s: paralel %v/%v, berurutan %v/%v", e.Symbol, got.ScoreBSJP, got.ScoreBPJS, e.ScoreBSJP, e.ScoreBPJS)
+		}
+	}
+}
+
+func TestPipelineCancel(t *testing.T) {
+	_, series := newMarketSimulator(1).simulate(time.Now(), historyDays+btWarmup)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := runScanPipeline(ctx, newSeriesSource(series), pipelineConfig{Workers: 2}); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func benchUniverse(symbols, days int) []btSeries {
+	rng := rand.New(rand.NewSource(1))
+	dates := tradingDaysBack(time.Now(), days)
+	series := make([]btSeries, symbols)
+
+	for n := range series {
+		info := emitenList[n%len(emitenList)]
+		s := btSeries{Symbol: fmt.Sprintf("S%03d", n), Name: info.name, Sector: info.sector, Bars: make([]Bar, days)}
+		price := float64(100 + rng.Intn(10000))
+		for i, date := range dates {
+			open := roundTick(price * (1 + rng.NormFloat64()*0.005))
+			price = roundTick(price * math.Exp(rng.NormFloat64()*0.02))
+			s.Bars[i] = Bar{
+				Date:   date,
+				Open:   open,
+				High:   math.Max(open, price) * (1 + rng.Float64()*0.01),
+				Low:    math.Min(open, price) * (1 - rng.Float64()*0.01),
+				Close:  price,
+				Volume: int64(1000000 + rng.Intn(50000000)),
+			}
+		}
+		series[n] = s
+	}
+	return series
+}
+
+func benchWorkers() []int {
+	workers := []int{1, 4}
+	if n := runtime.NumCPU(); n > 4 {
+		workers = append(workers, n)
+	}
+	return workers
+}
+
+func BenchmarkScanPipeline(b *testing.B) {
+	src := newSeriesSource(benchUniverse(900, 5*250))
+
+	for _, workers := range benchWorkers() {
+		b.Run(fmt.Sprintf("memori/workers=%d", workers), func(b *testing.B) {
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				if _, err := runScanPipeline(context.Background(), src, pipelineConfig{Workers: workers}); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(900*b.N)/time.Since(start).Seconds(), "saham/s")
+		})
+	}
+}
+
+func BenchmarkScanPipelineCSV(b *testing.B) {
+	dir := b.TempDir()
+	for _, s := range benchUniverse(900, 5*250) {
+		f, err := os.Create(filepath.Join(dir, s.Symbol+".csv"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		w := csv.NewWriter(f)
+		w.Write([]string{"date", "open", "high", "low", "close", "volume"})
+		for _, bar := range s.Bars {
+			w.Write([]string{
+				bar.Date.Format("2006-01-02"),
+				strconv.FormatFloat(bar.Open, 'f', 2, 64),
+				strconv.FormatFloat(bar.High, 'f', 2, 64),
+				strconv.FormatFloat(bar.Low, 'f', 2, 64),
+				strconv.FormatFloat(bar.Close, 'f', 2, 64),
+				strconv.FormatInt(bar.Volume, 10),
+			})
+		}
+		w.Flush()
+		f.Close()
+	}
+	src := csvDirSource{dir: dir}
+
+	for _, workers := range benchWorkers() {
+		b.Run(fmt.Sprintf("csv/workers=%d", workers), func(b *testing.B) {
+			start := time.Now()
+			for i := 0; i < b.N; i++ {
+				res, err := runScanPipeline(context.Background(), src, pipelineConfig{Workers: workers})
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(res.Failed) > 0 {
+					b.Fatal(res.Failed[0])
+				}
+			}
+			b.ReportMetric(float64(900*b.N)/time.Since(start).Seconds(), "saham/s")
+		})
+	}
+}
@@ -1,105 +1,359 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"log"
 	"math"
 	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
 type StockData struct {
-	Symbol          string
-	Name            string
-	ClosePrice      float64
-	ChangePercent   float64
-	Volume          int64
-	ForeignBuy      int64
-	ForeignSell     int64
-	NetForeignBuy   int64
-	NetForeignValue float64
-	ForeignPercent  float64
-	Accumulation    int
-	Score           float64
+	Symbol          string         `json:"symbol"`
+	Name            string         `json:"name"`
+	Sector          string         `json:"sector"`
+	ClosePrice      float64        `json:"close_price"`
+	ChangePercent   float64        `json:"change_percent"`
+	Volume          int64          `json:"volume"`
+	ForeignBuy      int64          `json:"foreign_buy"`
+	ForeignSell     int64          `json:"foreign_sell"`
+	NetForeignBuy   int64          `json:"net_foreign_buy"`
+	NetForeignValue float64        `json:"net_foreign_value"`
+	ForeignPercent  float64        `json:"foreign_percent"`
+	Regular         MarketFlow     `json:"regular"`
+	Negotiated      MarketFlow     `json:"negotiated"`
+	Cash            MarketFlow     `json:"cash"`
+	NGShare         float64        `json:"ng_share"`
+	NGDominant      bool           `json:"ng_dominant"`
+	Accumulation    int            `json:"accumulation"`
+	Change5D        float64        `json:"change_5d"`
+	Change20D       float64        `json:"change_20d"`
+	FlowHistory     []FlowDay      `json:"flow_history"`
+	Flow            FlowStats      `json:"flow"`
+	Ownership       OwnershipTrend `json:"ownership"`
+	SectorBonus     float64        `json:"sector_bonus"`
+	Score           float64        `json:"score"`
+	Fundamental     *Fundamentals  `json:"fundamental,omitempty"`
+}
+
+type FlowDay struct {
+	Date            time.Time `json:"date"`
+	NetForeignValue float64   `json:"net_foreign_value"`
+	ForeignPercent  float64   `json:"foreign_percent"`
+}
+
+type FlowStats struct {
+	NetValueMean   float64 `json:"net_value_mean"`
+	NetValueStd    float64 `json:"net_value_std"`
+	NetValueZ      float64 `json:"net_value_z"`
+	NetValuePctl   float64 `json:"net_value_pctl"`
+	ForeignPctMean float64 `json:"foreign_pct_mean"`
+	ForeignPctStd  float64 `json:"foreign_pct_std"`
+	ForeignPctZ    float64 `json:"foreign_pct_z"`
+}
+
+type UnusualFlow struct {
+	Symbol          string    `json:"symbol"`
+	Name            string    `json:"name"`
+	Sector          string    `json:"sector"`
+	Date            time.Time `json:"date"`
+	NetForeignValue float64   `json:"net_foreign_value"`
+	NetValueMean    float64   `json:"net_value_mean"`
+	NetValueZ       float64   `json:"net_value_z"`
+	NetValuePctl    float64   `json:"net_value_pctl"`
+	ForeignPercent  float64   `json:"foreign_percent"`
+	ForeignPctZ     float64   `json:"foreign_pct_z"`
+}
+
+type OwnershipRecord struct {
+	Symbol  string
+	Month   time.Time
+	Local   map[string]int64
+	Foreign map[string]int64
+}
+
+type OwnershipTrend struct {
+	Available   bool      `json:"available"`
+	Month       time.Time `json:"month"`
+	ForeignPct  float64   `json:"foreign_pct"`
+	ChangeMoM   float64   `json:"change_mo_m"`
+	Trend       string    `json:"trend"`
+	TopForeign  string    `json:"top_foreign"`
+	MonthlyFlow float64   `json:"monthly_flow"`
+	Divergence  bool      `json:"divergence"`
+}
+
+type MarketFlow struct {
+	ForeignBuy  int64 `json:"foreign_buy"`
+	ForeignSell int64 `json:"foreign_sell"`
+}
+
+type IndexData struct {
+	Change1D  float64 `json:"change_1d"`
+	Change5D  float64 `json:"change_5d"`
+	Change20D float64 `json:"change_20d"`
+}
+
+type SectorFlow struct {
+	Sector          string  `json:"sector"`
+	Count           int     `json:"count"`
+	AvgChange       float64 `json:"avg_change"`
+	Advancers       int     `json:"advancers"`
+	Decliners       int     `json:"decliners"`
+	Breadth         float64 `json:"breadth"`
+	NetForeignValue float64 `json:"net_foreign_value"`
+	RS1D            float64 `json:"rs_1d"`
+	RS5D            float64 `json:"rs_5d"`
+	RS20D           float64 `json:"rs_20d"`
+	Rotation        float64 `json:"rotation"`
+	Status          string  `json:"status"`
+}
+
+type BrokerActivity struct {
+	Symbol  string
+	Broker  string
+	BuyLot  int64
+	BuyAvg  float64
+	SellLot int64
+	SellAvg float64
+}
+
+type BandarResult struct {
+	Symbol        string   `json:"symbol"`
+	Name          string   `json:"name"`
+	Sector        string   `json:"sector"`
+	Price         float64  `json:"price"`
+	TotalBuyLot   int64    `json:"total_buy_lot"`
+	TopBuyers     []string `json:"top_buyers"`
+	Concentration float64  `json:"concentration"`
+	NetTop3       int64    `json:"net_top_3"`
+	NetTop5       int64    `json:"net_top_5"`
+	NetForeign    int64    `json:"net_foreign"`
+	NetDomestic   int64    `json:"net_domestic"`
+	NetRetail     int64    `json:"net_retail"`
+	AccumPrice    float64  `json:"accum_price"`
+	PriceVsAccum  float64  `json:"price_vs_accum"`
+	Score         float64  `json:"score"`
+	Signal        string   `json:"signal"`
 }
 
 type ScanResult struct {
-	Symbol          string
-	Name            string
-	Price           float64
-	Change          float64
-	NetForeignBuy   int64
-	NetForeignValue float64
-	ForeignPercent  float64
-	Accumulation    int
-	Strength        int
-	Signal          string
+	Symbol          string  `json:"symbol"`
+	Name            string  `json:"name"`
+	Sector          string  `json:"sector"`
+	Price           float64 `json:"price"`
+	Change          float64 `json:"change"`
+	NetForeignBuy   int64   `json:"net_foreign_buy"`
+	NetForeignValue float64 `json:"net_foreign_value"`
+	ForeignPercent  float64 `json:"foreign_percent"`
+	Accumulation    int     `json:"accumulation"`
+	NGNet           int64   `json:"ng_net"`
+	NGDominant      bool    `json:"ng_dominant"`
+	Score           float64 `json:"score,omitempty"`
+	Strength        int     `json:"strength"`
+	Signal          string  `json:"signal"`
+}
+
+type ScoreComponent struct {
+	Factor string  `json:"factor"`
+	Value  float64 `json:"value"`
+	Points float64 `json:"points"`
+	Max    float64 `json:"max"`
 }
 
 var stockList = []struct {
 	symbol string
 	name   string
+	sector string
 }{
-	{"BBCA", "Bank Central Asia"},
-	{"BBRI", "Bank Rakyat Indonesia"},
-	{"BMRI", "Bank Mandiri"},
-	{"TLKM", "Telkom Indonesia"},
-	{"ASII", "Astra International"},
-	{"UNVR", "Unilever Indonesia"},
-	{"ICBP", "Indofood CBP"},
-	{"GOTO", "GoTo Gojek Tokopedia"},
-	{"BUKA", "Bukalapak"},
-	{"ARTO", "Bank Jago"},
-	{"EMTK", "Elang Mahkota"},
-	{"MDKA", "Merdeka Copper Gold"},
-	{"ANTM", "Aneka Tambang"},
-	{"INCO", "Vale Indonesia"},
-	{"PTBA", "Bukit Asam"},
-	{"ADRO", "Adaro Energy"},
-	{"ITMG", "Indo Tambangraya"},
-	{"PGAS", "Perusahaan Gas Negara"},
-	{"JSMR", "Jasa Marga"},
-	{"CPIN", "Charoen Pokphand"},
-	{"JPFA", "Japfa Comfeed"},
-	{"ACES", "Ace Hardware"},
-	{"ERAA", "Erajaya Swasembada"},
-	{"MAPA", "MAP Aktif Adiperkasa"},
-	{"SIDO", "Sido Muncul"},
-	{"KLBF", "Kalbe Farma"},
-	{"INDF", "Indofood Sukses"},
-	{"GGRM", "Gudang Garam"},
-	{"HMSP", "HM Sampoerna"},
-	{"EXCL", "XL Axiata"},
-	{"BRIS", "Bank Syariah Indonesia"},
-	{"AMMN", "Amman Mineral"},
-	{"BRPT", "Barito Pacific"},
-	{"TPIA", "Chandra Asri"},
-	{"SMGR", "Semen Indonesia"},
-}
-
-func generateStockData() []StockData {
+	{"BBCA", "Bank Central Asia", "Banking"},
+	{"BBRI", "Bank Rakyat Indonesia", "Banking"},
+	{"BMRI", "Bank Mandiri", "Banking"},
+	{"TLKM", "Telkom Indonesia", "Telecom"},
+	{"ASII", "Astra International", "Automotive"},
+	{"UNVR", "Unilever Indonesia", "Consumer"},
+	{"ICBP", "Indofood CBP", "Consumer"},
+	{"GOTO", "GoTo Gojek Tokopedia", "Technology"},
+	{"BUKA", "Bukalapak", "Technology"},
+	{"ARTO", "Bank Jago", "Banking"},
+	{"EMTK", "Elang Mahkota", "Technology"},
+	{"MDKA", "Merdeka Copper Gold", "Mining"},
+	{"ANTM", "Aneka Tambang", "Mining"},
+	{"INCO", "Vale Indonesia", "Mining"},
+	{"PTBA", "Bukit Asam", "Mining"},
+	{"ADRO", "Adaro Energy", "Mining"},
+	{"ITMG", "Indo Tambangraya", "Mining"},
+	{"PGAS", "Perusahaan Gas Negara", "Energy"},
+	{"JSMR", "Jasa Marga", "Infrastructure"},
+	{"CPIN", "Charoen Pokphand", "Poultry"},
+	{"JPFA", "Japfa Comfeed", "Poultry"},
+	{"ACES", "Ace Hardware", "Retail"},
+	{"ERAA", "Erajaya Swasembada", "Retail"},
+	{"MAPA", "MAP Aktif Adiperkasa", "Retail"},
+	{"SIDO", "Sido Muncul", "Healthcare"},
+	{"KLBF", "Kalbe Farma", "Healthcare"},
+	{"INDF", "Indofood Sukses", "Consumer"},
+	{"GGRM", "Gudang Garam", "Tobacco"},
+	{"HMSP", "HM Sampoerna", "Tobacco"},
+	{"EXCL", "XL Axiata", "Telecom"},
+	{"BRIS", "Bank Syariah Indonesia", "Banking"},
+	{"AMMN", "Amman Mineral", "Mining"},
+	{"BRPT", "Barito Pacific", "Chemical"},
+	{"TPIA", "Chandra Asri", "Chemical"},
+	{"SMGR", "Semen Indonesia", "Cement"},
+}
+
+var useAllMarkets bool
+
+var watchedSymbols = map[string]bool{}
+
+const (
+	flowHistoryDays = 60
+	flowWindow      = 20
+	unusualZ        = 2.0
+	unusualLookback = 5
+)
+
+var brokerCategory = map[string]string{
+	"AK": "FOREIGN",
+	"BK": "FOREIGN",
+	"ZP": "FOREIGN",
+	"KZ": "FOREIGN",
+	"RX": "FOREIGN",
+	"CS": "FOREIGN",
+	"ML": "FOREIGN",
+	"YU": "FOREIGN",
+	"CC": "DOMESTIC",
+	"NI": "DOMESTIC",
+	"DX": "DOMESTIC",
+	"LG": "DOMESTIC",
+	"OD": "DOMESTIC",
+	"KI": "DOMESTIC",
+	"SQ": "DOMESTIC",
+	"HP": "DOMESTIC",
+	"YP": "RETAIL",
+	"PD": "RETAIL",
+	"XC": "RETAIL",
+	"XL": "RETAIL",
+	"CP": "RETAIL",
+	"EP": "RETAIL",
+	"GR": "RETAIL",
+}
+
+var investorTypes = []string{"IS", "CP", "PF", "IB", "ID", "MF", "SC", "FD", "OT"}
+
+var investorTypeName = map[string]string{
+	"IS": "Asuransi",
+	"CP": "Korporasi",
+	"PF": "Dana Pensiun",
+	"IB": "Lembaga Keuangan",
+	"ID": "Individu",
+	"MF": "Reksa Dana",
+	"SC": "Sekuritas",
+	"FD": "Yayasan",
+	"OT": "Lainnya",
+}
+
+func classifyBroker(code string) string {
+	if cat, ok := brokerCategory[strings.ToUpper(code)]; ok {
+		return cat
+	}
+	return "DOMESTIC"
+}
+
+func generateIHSG(rng *rand.Rand) IndexData {
+	return IndexData{
+		Change1D:  -1.5 + rng.Float64()*3,
+		Change5D:  -4 + rng.Float64()*8,
+		Change20D: -8 + rng.Float64()*16,
+	}
+}
+
+func generateStockData(rng *rand.Rand, ihsg IndexData, asOf time.Time) []StockData {
 	var stocks []StockData
 
+	sectorTrend := make(map[string]float64)
+
 	for _, s := range stockList {
-		price := 500 + rand.Float64()*49500
-		change := -5 + rand.Float64()*10
-		volume := int64(1000000 + rand.Intn(99000000))
+		trend, ok := sectorTrend[s.sector]
+		if !ok {
+			trend = -1 + rng.Float64()*2
+			sectorTrend[s.sector] = trend
+		}
+
+		price := 500 + rng.Float64()*49500
+		change := -5 + rng.Float64()*10
+		regVolume := int64(1000000 + rng.Intn(99000000))
+
+		regular := MarketFlow{
+			ForeignBuy:  int64(float64(regVolume) * (0.1 + rng.Float64()*0.35 + trend*0.05)),
+			ForeignSell: int64(float64(regVolume) * (0.1 + rng.Float64()*0.35 - trend*0.05)),
+		}
+
+		var ngVolume int64
+		var negotiated MarketFlow
+		if rng.Float64() < 0.2 {
+			ngVolume = int64(float64(regVolume) * (0.1 + rng.Float64()*0.5))
+			if rng.Float64() < 0.5 {
+				negotiated.ForeignBuy = ngVolume
+				negotiated.ForeignSell = int64(float64(ngVolume) * rng.Float64() * 0.2)
+			} else {
+				negotiated.ForeignSell = ngVolume
+				negotiated.ForeignBuy = int64(float64(ngVolume) * rng.Float64() * 0.2)
+			}
+		}
+
+		tnVolume := int64(float64(regVolume) * rng.Float64() * 0.01)
+		cash := MarketFlow{
+			ForeignBuy:  int64(float64(tnVolume) * rng.Float64() * 0.5),
+			ForeignSell: int64(float64(tnVolume) * rng.Float64() * 0.5),
+		}
 
-		foreignBuy := int64(float64(volume) * (0.1 + rand.Float64()*0.4))
-		foreignSell := int64(float64(volume) * (0.1 + rand.Float64()*0.4))
+		volume := regVolume + ngVolume + tnVolume
+		foreignBuy := regular.ForeignBuy + negotiated.ForeignBuy + cash.ForeignBuy
+		foreignSell := regular.ForeignSell + negotiated.ForeignSell + cash.ForeignSell
 		netFB := foreignBuy - foreignSell
 		netFBValue := float64(netFB) * price
 
-		foreignPct := float64(foreignBuy+foreignSell) / float64(volume) * 100
+		ngShare, ngDominant := negotiatedShare(regular, negotiated, cash)
 
-		accum := rand.Intn(10) - 3
+		scoringNet := float64(netFlow(regular)) * price
+		foreignPct := float64(regular.ForeignBuy+regular.ForeignSell) / float64(regVolume) * 100
+		if useAllMarkets {
+			scoringNet = netFBValue
+			foreignPct = float64(foreignBuy+foreignSell) / float64(volume) * 100
+		}
+		history := generateFlowHistory(rng, asOf, float64(regVolume)*price, trend, FlowDay{
+			NetForeignValue: scoringNet,
+			ForeignPercent:  foreignPct,
+		})
+
+		accum := rng.Intn(10) - 3
 
-		score := calculateScore(netFB, netFBValue, foreignPct, accum, change)
+		change5D := ihsg.Change5D + trend*3 + change + (-2 + rng.Float64()*4)
+		change20D := ihsg.Change20D + trend*6 + change5D*0.5 + (-4 + rng.Float64()*8)
 
 		stocks = append(stocks, StockData{
 			Symbol:          s.symbol,
 			Name:            s.name,
+			Sector:          s.sector,
 			ClosePrice:      price,
 			ChangePercent:   change,
 			Volume:          volume,
@@ -108,382 +362,3081 @@ func generateStockData() []StockData {
 			NetForeignBuy:   netFB,
 			NetForeignValue: netFBValue,
 			ForeignPercent:  foreignPct,
+			Regular:         regular,
+			Negotiated:      negotiated,
+			Cash:            cash,
+			NGShare:         ngShare,
+			NGDominant:      ngDominant,
 			Accumulation:    accum,
-			Score:           score,
+			Change5D:        change5D,
+			Change20D:       change20D,
+			FlowHistory:     history,
+			Flow:            computeFlowStats(history, len(history)-1, flowWindow),
 		})
 	}
 
+	scoreStocks(stocks, analyzeSectorFlow(stocks, ihsg))
+
 	return stocks
 }
 
-func calculateScore(netFB int64, netFBValue, foreignPct float64, accum int, change float64) float64 {
-	score := 0.0
+func generateFlowHistory(rng *rand.Rand, asOf time.Time, turnover, trend float64, today FlowDay) []FlowDay {
+	history := make([]FlowDay, flowHistoryDays)
 
-	if netFB > 0 {
-		score += 20
-		if netFB > 5000000 {
-			score += 15
-		} else if netFB > 1000000 {
-			score += 10
+	std := turnover * 0.14
+	date := asOf
+	for i := flowHistoryDays - 1; i >= 0; i-- {
+		for date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+			date = date.AddDate(0, 0, -1)
+		}
+
+		day := FlowDay{
+			Date:            date,
+			NetForeignValue: (rng.NormFloat64() + trend*0.3) * std,
+			ForeignPercent:  math.Max(0, 55+rng.NormFloat64()*10),
+		}
+		if i == flowHistoryDays-1 {
+			day.NetForeignValue = today.NetForeignValue
+			day.ForeignPercent = today.ForeignPercent
 		}
+		history[i] = day
+
+		date = date.AddDate(0, 0, -1)
 	}
 
-	if netFBValue > 50000000000 {
-		score += 20
-	} else if netFBValue > 10000000000 {
-		score += 15
-	} else if netFBValue > 1000000000 {
-		score += 10
+	return history
+}
+
+func computeFlowStats(history []FlowDay, idx, window int) FlowStats {
+	start := idx - window
+	if start < 0 {
+		start = 0
 	}
+	past := history[start:idx]
 
-	if foreignPct > 40 {
-		score += 15
-	} else if foreignPct > 25 {
-		score += 10
+	var stats FlowStats
+	if len(past) < 2 {
+		return stats
 	}
 
-	if accum > 3 {
-		score += 15
-	} else if accum > 0 {
-		score += 8
+	values := make([]float64, len(past))
+	pcts := make([]float64, len(past))
+	for i, d := range past {
+		values[i] = d.NetForeignValue
+		pcts[i] = d.ForeignPercent
 	}
 
-	if change > 0 && change < 3 {
-		score += 10
+	today := history[idx]
+	stats.NetValueMean, stats.NetValueStd = meanStd(values)
+	stats.ForeignPctMean, stats.ForeignPctStd = meanStd(pcts)
+	stats.NetValueZ = zScore(today.NetForeignValue, stats.NetValueMean, stats.NetValueStd)
+	stats.ForeignPctZ = zScore(today.ForeignPercent, stats.ForeignPctMean, stats.ForeignPctStd)
+
+	below := 0
+	for _, v := range values {
+		if v < today.NetForeignValue {
+			below++
+		}
 	}
+	stats.NetValuePctl = float64(below) / float64(len(values)) * 100
 
-	return math.Min(100, score)
+	return stats
 }
 
-func scanNetForeignBuy(stocks []StockData) []ScanResult {
-	var results []ScanResult
+func meanStd(values []float64) (float64, float64) {
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
 
-	for _, stock := range stocks {
-		if stock.NetForeignBuy > 0 && stock.Score >= 40 {
-			strength := int(stock.Score / 20)
-			if strength < 1 {
-				strength = 1
-			}
-			if strength > 5 {
-				strength = 5
-			}
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(values) - 1)
 
-			signal := "ACCUMULATE"
-			if stock.Score >= 70 {
-				signal = "STRONG BUY"
-			} else if stock.Score >= 55 {
-				signal = "BUY"
-			}
+	return mean, math.Sqrt(variance)
+}
 
-			results = append(results, ScanResult{
-				Symbol:          stock.Symbol,
-				Name:            stock.Name,
-				Price:           stock.ClosePrice,
-				Change:          stock.ChangePercent,
-				NetForeignBuy:   stock.NetForeignBuy,
-				NetForeignValue: stock.NetForeignValue,
-				ForeignPercent:  stock.ForeignPercent,
-				Accumulation:    stock.Accumulation,
-				Strength:        strength,
-				Signal:          signal,
-			})
-		}
+func zScore(value, mean, std float64) float64 {
+	if std <= 1e-9*math.Max(1, math.Abs(mean)) {
+		return 0
 	}
+	return (value - mean) / std
+}
 
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].NetForeignValue > results[j].NetForeignValue
-	})
+func netFlow(f MarketFlow) int64 {
+	return f.ForeignBuy - f.ForeignSell
+}
 
-	return results
+func negotiatedShare(regular, negotiated, cash MarketFlow) (float64, bool) {
+	rg := math.Abs(float64(netFlow(regular)))
+	ng := math.Abs(float64(netFlow(negotiated)))
+	tn := math.Abs(float64(netFlow(cash)))
+
+	if rg+ng+tn == 0 {
+		return 0, false
+	}
+
+	share := ng / (rg + ng + tn) * 100
+	return share, share > 50
 }
 
-func scanNetForeignSell(stocks []StockData) []ScanResult {
-	var results []ScanResult
+func scoringFlow(stock StockData) (int64, float64) {
+	if useAllMarkets {
+		return stock.NetForeignBuy, stock.NetForeignValue
+	}
+
+	net := netFlow(stock.Regular)
+	return net, float64(net) * stock.ClosePrice
+}
+
+func scoreStocks(stocks []StockData, sectors []SectorFlow) {
+	bonus := make(map[string]float64)
+	for _, s := range sectors {
+		bonus[s.Sector] = sectorBonus(s)
+	}
+
+	for i := range stocks {
+		s := &stocks[i]
+		s.SectorBonus = bonus[s.Sector]
+		netFB, _ := scoringFlow(*s)
+		s.Score = calculateScore(netFB, s.Flow, s.Accumulation, s.ChangePercent, s.SectorBonus)
+		if c, ok := fundamentalRules.component(s.Fundamental); ok {
+			s.Score = math.Max(0, math.Min(100, s.Score+c.Points))
+		}
+	}
+}
+
+func analyzeSectorFlow(stocks []StockData, ihsg IndexData) []SectorFlow {
+	flows := make(map[string]*SectorFlow)
+	var order []string
 
 	for _, stock := range stocks {
-		if stock.NetForeignBuy < -500000 {
-			strength := 1
-			netSell := -stock.NetForeignBuy
-			if netSell > 10000000 {
-				strength = 5
-			} else if netSell > 5000000 {
-				strength = 4
-			} else if netSell > 2000000 {
-				strength = 3
-			} else if netSell > 1000000 {
-				strength = 2
-			}
+		f, ok := flows[stock.Sector]
+		if !ok {
+			f = &SectorFlow{Sector: stock.Sector}
+			flows[stock.Sector] = f
+			order = append(order, stock.Sector)
+		}
 
-			signal := "DISTRIBUTE"
-			if strength >= 4 {
-				signal = "STRONG SELL"
-			} else if strength >= 3 {
-				signal = "SELL"
-			}
+		f.Count++
+		f.AvgChange += stock.ChangePercent
+		f.RS5D += stock.Change5D
+		f.RS20D += stock.Change20D
+		_, netFBValue := scoringFlow(stock)
+		f.NetForeignValue += netFBValue
+
+		if stock.ChangePercent > 0 {
+			f.Advancers++
+		} else if stock.ChangePercent < 0 {
+			f.Decliners++
+		}
+	}
 
-			results = append(results, ScanResult{
-				Symbol:          stock.Symbol,
-				Name:            stock.Name,
-				Price:           stock.ClosePrice,
-				Change:          stock.ChangePercent,
-				NetForeignBuy:   stock.NetForeignBuy,
-				NetForeignValue: stock.NetForeignValue,
-				ForeignPercent:  stock.ForeignPercent,
-				Accumulation:    stock.Accumulation,
-				Strength:        strength,
-				Signal:          signal,
-			})
+	var results []SectorFlow
+	for _, name := range order {
+		f := flows[name]
+		n := float64(f.Count)
+
+		f.AvgChange /= n
+		f.RS1D = f.AvgChange - ihsg.Change1D
+		f.RS5D = f.RS5D/n - ihsg.Change5D
+		f.RS20D = f.RS20D/n - ihsg.Change20D
+		f.Breadth = float64(f.Advancers) / n * 100
+
+		f.Rotation = f.RS1D*0.5 + f.RS5D*0.3 - f.RS20D*0.1 + (f.Breadth-50)/25
+		if f.NetForeignValue > 0 {
+			f.Rotation += 0.5
+		} else if f.NetForeignValue < 0 {
+			f.Rotation -= 0.5
+		}
+
+		f.Status = "NETRAL"
+		if f.Rotation >= 1 && f.RS5D > 0 {
+			f.Status = "ROTASI MASUK"
+		} else if f.Rotation <= -1 && f.RS5D < 0 {
+			f.Status = "ROTASI KELUAR"
 		}
+
+		results = append(results, *f)
 	}
 
 	sort.Slice(results, func(i, j int) bool {
-		return results[i].NetForeignValue < results[j].NetForeignValue
+		return results[i].Rotation > results[j].Rotation
 	})
 
 	return results
 }
 
-func formatMoney(val float64) string {
-	absVal := math.Abs(val)
-	sign := ""
-	if val < 0 {
-		sign = "-"
-	}
-
-	if absVal >= 1000000000000 {
-		return fmt.Sprintf("%sRp %.1fT", sign, absVal/1000000000000)
-	} else if absVal >= 1000000000 {
-		return fmt.Sprintf("%sRp %.1fB", sign, absVal/1000000000)
-	} else if absVal >= 1000000 {
-		return fmt.Sprintf("%sRp %.1fM", sign, absVal/1000000)
-	}
-	return fmt.Sprintf("%sRp %.0f", sign, absVal)
-}
+func sectorBonus(f SectorFlow) float64 {
+	bonus := 0.0
 
-func formatVolume(vol int64) string {
-	absVol := vol
-	sign := ""
-	if vol < 0 {
-		absVol = -vol
-		sign = "-"
+	if f.Status == "ROTASI MASUK" {
+		bonus += 6
+	} else if f.Status == "ROTASI KELUAR" {
+		bonus -= 6
 	}
 
-	if absVol >= 1000000 {
-		return fmt.Sprintf("%s%.1fM", sign, float64(absVol)/1000000)
-	} else if absVol >= 1000 {
-		return fmt.Sprintf("%s%.1fK", sign, float64(absVol)/1000)
+	if f.NetForeignValue > 10000000000 {
+		bonus += 4
+	} else if f.NetForeignValue > 0 {
+		bonus += 2
 	}
-	return fmt.Sprintf("%s%d", sign, absVol)
-}
-
-func getStars(n int) string {
-	return strings.Repeat("*", n) + strings.Repeat(" ", 5-n)
-}
 
-func printHeader() {
-	fmt.Print("\033[2J\033[H")
-	fmt.Println(strings.Repeat("=", 95))
-	fmt.Println("                      NET FOREIGN BUY SCANNER")
-	fmt.Println("                    Indonesia Stock Exchange (IDX)")
-	fmt.Println(strings.Repeat("=", 95))
-	fmt.Printf(" Waktu: %s\n", time.Now().Format("02 Jan 2006 15:04:05"))
-	fmt.Println(strings.Repeat("-", 95))
-	fmt.Println()
+	return bonus
 }
 
-func printNetForeignBuy(results []ScanResult) {
-	fmt.Println("\033[1;32m                        NET FOREIGN BUY (Akumulasi Asing)\033[0m")
-	fmt.Println(" Saham yang sedang diakumulasi oleh investor asing")
-	fmt.Println(strings.Repeat("-", 95))
+func loadBrokerSummary(path string) ([]BrokerActivity, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
 
-	if len(results) == 0 {
-		fmt.Println(" Tidak ada saham dengan net foreign buy signifikan.")
-		fmt.Println()
-		return
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("file %s kosong", path)
 	}
 
-	fmt.Printf(" %-7s %-20s %-10s %-7s %-10s %-12s %-6s %-4s %-6s %-10s\n",
-		"KODE", "NAMA", "HARGA", "CHG%", "NET FB", "VALUE", "F%", "ACC", "RATE", "SIGNAL")
-	fmt.Println(strings.Repeat("-", 95))
+	col := make(map[string]int)
+	for i, h := range rows[0] {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, name := range []string{"symbol", "broker", "buy_lot", "buy_avg", "sell_lot", "sell_avg"} {
+		if _, ok := col[name]; !ok {
+			return nil, fmt.Errorf("kolom %q tidak ditemukan di %s", name, path)
+		}
+	}
 
-	count := 0
-	for _, r := range results {
-		if count >= 12 {
-			break
+	var activity []BrokerActivity
+	index := make(map[string]int)
+	for n, row := range rows[1:] {
+		if len(row) < len(rows[0]) {
+			return nil, fmt.Errorf("baris %d: jumlah kolom tidak sesuai", n+2)
 		}
 
-		chgColor := "\033[32m"
-		if r.Change < 0 {
-			chgColor = "\033[31m"
+		symbol := strings.ToUpper(strings.TrimSpace(row[col["symbol"]]))
+		broker := strings.ToUpper(strings.TrimSpace(row[col["broker"]]))
+		if symbol == "" || broker == "" {
+			return nil, fmt.Errorf("baris %d: symbol dan broker wajib diisi", n+2)
 		}
 
-		name := r.Name
-		if len(name) > 18 {
-			name = name[:18]
+		buyLot, err1 := strconv.ParseInt(strings.TrimSpace(row[col["buy_lot"]]), 10, 64)
+		buyAvg, err2 := strconv.ParseFloat(strings.TrimSpace(row[col["buy_avg"]]), 64)
+		sellLot, err3 := strconv.ParseInt(strings.TrimSpace(row[col["sell_lot"]]), 10, 64)
+		sellAvg, err4 := strconv.ParseFloat(strings.TrimSpace(row[col["sell_avg"]]), 64)
+		for _, err := range []error{err1, err2, err3, err4} {
+			if err != nil {
+				return nil, fmt.Errorf("baris %d: %v", n+2, err)
+			}
+		}
+		if buyLot < 0 || sellLot < 0 || !(buyAvg >= 0) || !(sellAvg >= 0) {
+			return nil, fmt.Errorf("baris %d: lot dan harga rata-rata tidak boleh negatif", n+2)
+		}
+		if buyLot == 0 && sellLot == 0 {
+			continue
 		}
 
-		fmt.Printf(" %-7s %-20s Rp%-8.0f %s%-6.1f%%\033[0m %-10s %-12s %-5.0f%% %-4d %-6s %-10s\n",
-			r.Symbol,
-			name,
-			r.Price,
-			chgColor,
-			r.Change,
-			formatVolume(r.NetForeignBuy),
-			formatMoney(r.NetForeignValue),
-			r.ForeignPercent,
-			r.Accumulation,
-			getStars(r.Strength),
-			r.Signal)
-		count++
+		key := symbol + "/" + broker
+		if i, ok := index[key]; ok {
+			a := &activity[i]
+			a.BuyAvg = weightedAvg(a.BuyAvg, a.BuyLot, buyAvg, buyLot)
+			a.SellAvg = weightedAvg(a.SellAvg, a.SellLot, sellAvg, sellLot)
+			a.BuyLot += buyLot
+			a.SellLot += sellLot
+			continue
+		}
+		index[key] = len(activity)
+		activity = append(activity, BrokerActivity{
+			Symbol:  symbol,
+			Broker:  broker,
+			BuyLot:  buyLot,
+			BuyAvg:  buyAvg,
+			SellLot: sellLot,
+			SellAvg: sellAvg,
+		})
 	}
-	fmt.Println()
+
+	return activity, nil
 }
 
-func printNetForeignSell(results []ScanResult) {
-	fmt.Println("\033[1;31m                       NET FOREIGN SELL (Distribusi Asing)\033[0m")
-	fmt.Println(" Saham yang sedang dijual oleh investor asing")
-	fmt.Println(strings.Repeat("-", 95))
+func weightedAvg(avgA float64, lotA int64, avgB float64, lotB int64) float64 {
+	if lotA+lotB == 0 {
+		return 0
+	}
+	return (avgA*float64(lotA) + avgB*float64(lotB)) / float64(lotA+lotB)
+}
 
-	if len(results) == 0 {
-		fmt.Println(" Tidak ada saham dengan net foreign sell signifikan.")
-		fmt.Println()
-		return
+func generateBrokerSummary(rng *rand.Rand, stocks []StockData) []BrokerActivity {
+	var brokers []string
+	for code := range brokerCategory {
+		brokers = append(brokers, code)
 	}
+	sort.Strings(brokers)
 
-	fmt.Printf(" %-7s %-20s %-10s %-7s %-10s %-12s %-6s %-4s %-6s %-10s\n",
-		"KODE", "NAMA", "HARGA", "CHG%", "NET FS", "VALUE", "F%", "ACC", "RATE", "SIGNAL")
+	var activity []BrokerActivity
+
+	for _, stock := range stocks {
+		totalLot := stock.Volume / 100
+		bias := float64(stock.NetForeignBuy) / float64(stock.Volume)
+
+		for _, code := range brokers {
+			share := rng.Float64() * 0.08
+			buyRatio := 0.5 + (rng.Float64()-0.5)*0.4
+			if classifyBroker(code) == "FOREIGN" {
+				buyRatio += bias * 2
+			} else if classifyBroker(code) == "RETAIL" {
+				buyRatio -= bias
+			}
+			buyRatio = math.Max(0.05, math.Min(0.95, buyRatio))
+
+			lot := int64(float64(totalLot) * share)
+			buyLot := int64(float64(lot) * buyRatio)
+
+			activity = append(activity, BrokerActivity{
+				Symbol:  stock.Symbol,
+				Broker:  code,
+				BuyLot:  buyLot,
+				BuyAvg:  stock.ClosePrice * (0.97 + rng.Float64()*0.05),
+				SellLot: lot - buyLot,
+				SellAvg: stock.ClosePrice * (0.98 + rng.Float64()*0.05),
+			})
+		}
+	}
+
+	return activity
+}
+
+func loadOwnership(pattern string) ([]OwnershipRecord, error) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("tidak ada file yang cocok dengan %s", pattern)
+	}
+	sort.Strings(files)
+
+	var records []OwnershipRecord
+	for _, path := range files {
+		loaded, err := loadOwnershipFile(path)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, loaded...)
+	}
+
+	return records, nil
+}
+
+func loadOwnershipFile(path string) ([]OwnershipRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("file %s kosong", path)
+	}
+
+	header := rows[0]
+	monthCol, symbolCol := -1, -1
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		header[i] = h
+		if h == "month" {
+			monthCol = i
+		} else if h == "symbol" {
+			symbolCol = i
+		}
+	}
+	if monthCol < 0 || symbolCol < 0 {
+		return nil, fmt.Errorf("kolom month/symbol tidak ditemukan di %s", path)
+	}
+
+	var records []OwnershipRecord
+	for n, row := range rows[1:] {
+		if len(row) < len(header) {
+			return nil, fmt.Errorf("%s baris %d: jumlah kolom tidak sesuai", path, n+2)
+		}
+
+		month, err := time.Parse("2006-01", strings.TrimSpace(row[monthCol]))
+		if err != nil {
+			return nil, fmt.Errorf("%s baris %d: %v", path, n+2, err)
+		}
+
+		rec := OwnershipRecord{
+			Symbol:  strings.ToUpper(strings.TrimSpace(row[symbolCol])),
+			Month:   month,
+			Local:   make(map[string]int64),
+			Foreign: make(map[string]int64),
+		}
+
+		for i, h := range header {
+			var target map[string]int64
+			if strings.HasPrefix(h, "local_") {
+				target = rec.Local
+			} else if strings.HasPrefix(h, "foreign_") {
+				target = rec.Foreign
+			} else {
+				continue
+			}
+
+			shares, err := strconv.ParseInt(strings.TrimSpace(row[i]), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s baris %d: %v", path, n+2, err)
+			}
+			if shares < 0 {
+				return nil, fmt.Errorf("%s baris %d: jumlah saham %s tidak boleh negatif", path, n+2, h)
+			}
+			target[strings.ToUpper(h[strings.Index(h, "_")+1:])] = shares
+		}
+
+		records = append(records, rec)
+	}
+
+	return records, nil
+}
+
+func generateOwnership(rng *rand.Rand, asOf time.Time, stocks []StockData) []OwnershipRecord {
+	var records []OwnershipRecord
+
+	lastMonth := time.Date(asOf.Year(), asOf.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+
+	for _, stock := range stocks {
+		listed := int64(1000000000 + rng.Intn(9000000000))
+		foreignPct := 15 + rng.Float64()*55
+
+		monthlyFlow := 0.0
+		for _, d := range stock.FlowHistory[len(stock.FlowHistory)-flowWindow:] {
+			monthlyFlow += d.NetForeignValue
+		}
+		direction := 1.0
+		if monthlyFlow < 0 {
+			direction = -1
+		}
+		if rng.Float64() < 0.2 {
+			direction = -direction
+		}
+
+		for m := 2; m >= 0; m-- {
+			pct := foreignPct - direction*float64(m)*(0.2+rng.Float64()*0.8)
+			foreign := int64(float64(listed) * pct / 100)
+
+			rec := OwnershipRecord{
+				Symbol:  stock.Symbol,
+				Month:   lastMonth.AddDate(0, -m, 0),
+				Local:   splitByInvestorType(rng, listed-foreign),
+				Foreign: splitByInvestorType(rng, foreign),
+			}
+			records = append(records, rec)
+		}
+	}
+
+	return records
+}
+
+func splitByInvestorType(rng *rand.Rand, total int64) map[string]int64 {
+	weights := make([]float64, len(investorTypes))
+	sum := 0.0
+	for i := range weights {
+		weights[i] = rng.Float64()
+		sum += weights[i]
+	}
+
+	split := make(map[string]int64)
+	remaining := total
+	for i, t := range investorTypes {
+		if i == len(investorTypes)-1 {
+			split[t] = remaining
+			break
+		}
+		part := int64(float64(total) * weights[i] / sum)
+		split[t] = part
+		remaining -= part
+	}
+
+	return split
+}
+
+func buildOwnershipTrends(records []OwnershipRecord) map[string]OwnershipTrend {
+	bySymbol := make(map[string][]OwnershipRecord)
+	for _, r := range records {
+		bySymbol[r.Symbol] = append(bySymbol[r.Symbol], r)
+	}
+
+	trends := make(map[string]OwnershipTrend)
+	for symbol, list := range bySymbol {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].Month.Before(list[j].Month)
+		})
+		for i := len(list) - 1; i > 0; i-- {
+			if list[i].Month.Equal(list[i-1].Month) {
+				list = append(list[:i-1], list[i:]...)
+			}
+		}
+
+		last := list[len(list)-1]
+		t := OwnershipTrend{
+			Available:  true,
+			Month:      last.Month,
+			ForeignPct: foreignOwnershipPct(last),
+			Trend:      "STABIL",
+		}
+
+		if len(list) > 1 {
+			t.ChangeMoM = t.ForeignPct - foreignOwnershipPct(list[len(list)-2])
+			if t.ChangeMoM >= 0.1-1e-9 {
+				t.Trend = "NAIK"
+			} else if t.ChangeMoM <= -0.1+1e-9 {
+				t.Trend = "TURUN"
+			}
+		}
+
+		var topShares int64
+		for _, it := range investorTypes {
+			if last.Foreign[it] > topShares {
+				topShares = last.Foreign[it]
+				t.TopForeign = it
+			}
+		}
+
+		trends[symbol] = t
+	}
+
+	return trends
+}
+
+func foreignOwnershipPct(r OwnershipRecord) float64 {
+	var local, foreign int64
+	for _, v := range r.Local {
+		local += v
+	}
+	for _, v := range r.Foreign {
+		foreign += v
+	}
+
+	if local+foreign == 0 {
+		return 0
+	}
+	return float64(foreign) / float64(local+foreign) * 100
+}
+
+func attachOwnership(stocks []StockData, trends map[string]OwnershipTrend) {
+	for i := range stocks {
+		t, ok := trends[stocks[i].Symbol]
+		if !ok {
+			continue
+		}
+
+		history := stocks[i].FlowHistory
+		start := len(history) - flowWindow
+		if start < 0 {
+			start = 0
+		}
+		for _, d := range history[start:] {
+			t.MonthlyFlow += d.NetForeignValue
+		}
+
+		t.Divergence = (t.MonthlyFlow > 0 && t.Trend == "TURUN") || (t.MonthlyFlow < 0 && t.Trend == "NAIK")
+		stocks[i].Ownership = t
+	}
+}
+
+func analyzeBandar(stocks []StockData, activity []BrokerActivity) []BandarResult {
+	bySymbol := make(map[string][]BrokerActivity)
+	for _, a := range activity {
+		bySymbol[a.Symbol] = append(bySymbol[a.Symbol], a)
+	}
+
+	var results []BandarResult
+
+	for _, stock := range stocks {
+		list := bySymbol[stock.Symbol]
+		if len(list) == 0 {
+			continue
+		}
+
+		r := BandarResult{
+			Symbol: stock.Symbol,
+			Name:   stock.Name,
+			Sector: stock.Sector,
+			Price:  stock.ClosePrice,
+		}
+
+		for _, a := range list {
+			r.TotalBuyLot += a.BuyLot
+			net := a.BuyLot - a.SellLot
+			switch classifyBroker(a.Broker) {
+			case "FOREIGN":
+				r.NetForeign += net
+			case "RETAIL":
+				r.NetRetail += net
+			default:
+				r.NetDomestic += net
+			}
+		}
+
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].BuyLot-list[i].SellLot > list[j].BuyLot-list[j].SellLot
+		})
+
+		var topBuyLot int64
+		var accumValue float64
+		var accumLot int64
+		for i, a := range list {
+			if i >= 5 {
+				break
+			}
+			if i < 3 {
+				topBuyLot += a.BuyLot
+				r.TopBuyers = append(r.TopBuyers, a.Broker)
+			}
+			if a.BuyLot > a.SellLot {
+				accumValue += float64(a.BuyLot) * a.BuyAvg
+				accumLot += a.BuyLot
+			}
+		}
+
+		for i := 0; i < 5 && i < len(list); i++ {
+			buyNet := list[i].BuyLot - list[i].SellLot
+			sellNet := list[len(list)-1-i].BuyLot - list[len(list)-1-i].SellLot
+			net := int64(0)
+			if buyNet > 0 {
+				net += buyNet
+			}
+			if sellNet < 0 {
+				net += sellNet
+			}
+			if i < 3 {
+				r.NetTop3 += net
+			}
+			r.NetTop5 += net
+		}
+
+		if r.TotalBuyLot > 0 {
+			r.Concentration = float64(topBuyLot) / float64(r.TotalBuyLot) * 100
+		}
+		if accumLot > 0 {
+			r.AccumPrice = accumValue / float64(accumLot)
+			r.PriceVsAccum = (r.Price - r.AccumPrice) / r.AccumPrice * 100
+		}
+
+		r.Score = calculateBandarScore(r)
+
+		r.Signal = "NEUTRAL"
+		if r.NetTop5 > 0 && r.Score >= 70 {
+			r.Signal = "BIG ACCUM"
+		} else if r.NetTop5 > 0 && r.Score >= 60 {
+			r.Signal = "ACCUM"
+		} else if r.NetTop5 < 0 {
+			r.Signal = "DISTRIBUTE"
+		}
+
+		results = append(results, r)
+	}
+
+	return results
+}
+
+func calculateBandarScore(r BandarResult) float64 {
+	score := 0.0
+
+	if r.NetTop5 > 0 {
+		score += 20
+		if r.NetTop3 > 0 {
+			score += 10
+		}
+	}
+
+	if r.Concentration > 50 {
+		score += 25
+	} else if r.Concentration > 35 {
+		score += 15
+	} else if r.Concentration > 25 {
+		score += 8
+	}
+
+	if r.AccumPrice > 0 {
+		if r.PriceVsAccum <= 0 {
+			score += 20
+		} else if r.PriceVsAccum < 2 {
+			score += 12
+		} else if r.PriceVsAccum < 5 {
+			score += 5
+		}
+	}
+
+	if r.NetForeign > 0 {
+		score += 15
+	}
+
+	if r.NetRetail < 0 {
+		score += 10
+	}
+
+	return math.Min(100, score)
+}
+
+func calculateScore(netFB int64, flow FlowStats, accum int, change, sectorBonus float64) float64 {
+	score := 0.0
+	for _, c := range scoreComponents(netFB, flow, accum, change, sectorBonus) {
+		score += c.Points
+	}
+	return math.Max(0, math.Min(100, score))
+}
+
+func scoreComponents(netFB int64, flow FlowStats, accum int, change, sectorBonus float64) []ScoreComponent {
+	var netPts, zPts, pctlPts, foreignPts, accumPts, changePts float64
+
+	if netFB > 0 {
+		netPts = 20
+		if flow.NetValueZ > 2 {
+			zPts = 15
+		} else if flow.NetValueZ > 1 {
+			zPts = 10
+		}
+	}
+
+	if flow.NetValuePctl >= 95 {
+		pctlPts = 20
+	} else if flow.NetValuePctl >= 80 {
+		pctlPts = 15
+	} else if flow.NetValuePctl >= 60 {
+		pctlPts = 10
+	}
+
+	if flow.ForeignPctZ > 1.5 {
+		foreignPts = 15
+	} else if flow.ForeignPctZ > 0.5 {
+		foreignPts = 10
+	}
+
+	if accum > 3 {
+		accumPts = 15
+	} else if accum > 0 {
+		accumPts = 8
+	}
+
+	if change > 0 && change < 3 {
+		changePts = 10
+	}
+
+	return []ScoreComponent{
+		{Factor: "net_foreign", Value: float64(netFB), Points: netPts, Max: 20},
+		{Factor: "net_value_z", Value: flow.NetValueZ, Points: zPts, Max: 15},
+		{Factor: "net_value_pctl", Value: flow.NetValuePctl, Points: pctlPts, Max: 20},
+		{Factor: "foreign_pct_z", Value: flow.ForeignPctZ, Points: foreignPts, Max: 15},
+		{Factor: "accumulation", Value: float64(accum), Points: accumPts, Max: 15},
+		{Factor: "change", Value: change, Points: changePts, Max: 10},
+		{Factor: "sector_bonus", Value: sectorBonus, Points: sectorBonus, Max: 10},
+	}
+}
+
+func scoreBreakdown(stock StockData) []ScoreComponent {
+	netFB, _ := scoringFlow(stock)
+	components := scoreComponents(netFB, stock.Flow, stock.Accumulation, stock.ChangePercent, stock.SectorBonus)
+	if c, ok := fundamentalRules.component(stock.Fundamental); ok {
+		components = append(components, c)
+	}
+	return components
+}
+
+type FinancialQuarter struct {
+	Period      string  `json:"period"`
+	Revenue     float64 `json:"revenue"`
+	NetIncome   float64 `json:"net_income"`
+	Equity      float64 `json:"equity"`
+	Liabilities float64 `json:"liabilities"`
+	Shares      float64 `json:"shares"`
+}
+
+type Fundamentals struct {
+	Period         string  `json:"period"`
+	EPS            float64 `json:"eps"`
+	BVPS           float64 `json:"bvps"`
+	PER            float64 `json:"per"`
+	PBV            float64 `json:"pbv"`
+	ROE            float64 `json:"roe"`
+	DER            float64 `json:"der"`
+	EPSGrowth      float64 `json:"eps_growth"`
+	HasGrowth      bool    `json:"has_growth"`
+	MarketCap      float64 `json:"market_cap"`
+	Loss           bool    `json:"loss"`
+	NegativeEquity bool    `json:"negative_equity"`
+}
+
+type FundamentalCriteria struct {
+	MaxPER       float64 `json:"max_per,omitempty"`
+	MaxPBV       float64 `json:"max_pbv,omitempty"`
+	MinROE       float64 `json:"min_roe,omitempty"`
+	MaxDER       float64 `json:"max_der,omitempty"`
+	MinEPSGrowth float64 `json:"min_eps_growth,omitempty"`
+	MinMarketCap float64 `json:"min_market_cap,omitempty"`
+	ProfitOnly   bool    `json:"profit_only,omitempty"`
+}
+
+type FundamentalRules struct {
+	Filter      FundamentalCriteria `json:"filter"`
+	Bonus       FundamentalCriteria `json:"bonus"`
+	BonusPoints float64             `json:"bonus_points,omitempty"`
+	RequireData bool                `json:"require_data,omitempty"`
+}
+
+type fundamentalStore struct {
+	path     string
+	bySymbol map[string][]FinancialQuarter
+}
+
+var fundamentalRules FundamentalRules
+
+func loadFinancials(path string) (*fundamentalStore, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("file %s kosong", path)
+	}
+
+	col := make(map[string]int)
+	for i, h := range rows[0] {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	numeric := []string{"revenue", "net_income", "equity", "liabilities", "shares"}
+	for _, name := range append([]string{"symbol", "period"}, numeric...) {
+		if _, ok := col[name]; !ok {
+			return nil, fmt.Errorf("kolom %q tidak ditemukan di %s", name, path)
+		}
+	}
+
+	store := &fundamentalStore{path: path, bySymbol: make(map[string][]FinancialQuarter)}
+	for n, row := range rows[1:] {
+		if len(row) < len(rows[0]) {
+			return nil, fmt.Errorf("baris %d: jumlah kolom tidak sesuai", n+2)
+		}
+
+		values := make(map[string]float64)
+		for _, name := range numeric {
+			v, err := strconv.ParseFloat(strings.TrimSpace(row[col[name]]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("baris %d: %s: %v", n+2, name, err)
+			}
+			values[name] = v
+		}
+		period := strings.ToUpper(strings.TrimSpace(row[col["period"]]))
+		if len(period) != 6 || period[4] != 'Q' || period[5] < '1' || period[5] > '4' {
+			return nil, fmt.Errorf("baris %d: periode %q harus berformat YYYYQn, contoh 2024Q1", n+2, period)
+		}
+		if values["shares"] <= 0 {
+			return nil, fmt.Errorf("baris %d: jumlah saham harus lebih dari 0", n+2)
+		}
+
+		symbol := strings.ToUpper(strings.TrimSpace(row[col["symbol"]]))
+		store.bySymbol[symbol] = append(store.bySymbol[symbol], FinancialQuarter{
+			Period:      period,
+			Revenue:     values["revenue"],
+			NetIncome:   values["net_income"],
+			Equity:      values["equity"],
+			Liabilities: values["liabilities"],
+			Shares:      values["shares"],
+		})
+	}
+	for _, list := range store.bySymbol {
+		sort.Slice(list, func(i, j int) bool { return list[i].Period < list[j].Period })
+	}
+
+	return store, nil
+}
+
+func loadFundamentalRules(path string) (FundamentalRules, error) {
+	var rules FundamentalRules
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return rules, err
+	}
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return rules, fmt.Errorf("%s: %v", path, err)
+	}
+	if rules.BonusPoints < 0 {
+		return rules, fmt.Errorf("%s: bonus_points tidak boleh negatif", path)
+	}
+
+	return rules, nil
+}
+
+func trailingIncome(quarters []FinancialQuarter) float64 {
+	if len(quarters) > 4 {
+		quarters = quarters[len(quarters)-4:]
+	}
+	sum := 0.0
+	for _, q := range quarters {
+		sum += q.NetIncome
+	}
+	return sum * 4 / float64(len(quarters))
+}
+
+func computeFundamentals(quarters []FinancialQuarter, price float64) *Fundamentals {
+	if len(quarters) == 0 || price <= 0 {
+		return nil
+	}
+	last := quarters[len(quarters)-1]
+	income := trailingIncome(quarters)
+
+	f := &Fundamentals{
+		Period:    last.Period,
+		EPS:       income / last.Shares,
+		BVPS:      last.Equity / last.Shares,
+		MarketCap: price * last.Shares,
+		Loss:      income <= 0,
+	}
+	if !f.Loss {
+		f.PER = price / f.EPS
+	}
+	if last.Equity > 0 {
+		f.PBV = price / f.BVPS
+		f.ROE = income / last.Equity * 100
+		f.DER = last.Liabilities / last.Equity
+	} else {
+		f.NegativeEquity = true
+	}
+
+	var now, prev float64
+	switch n := len(quarters); {
+	case n >= 8:
+		now, prev = trailingIncome(quarters)/last.Shares, trailingIncome(quarters[:n-4])/quarters[n-5].Shares
+	case n >= 5:
+		now, prev = last.NetIncome/last.Shares, quarters[n-5].NetIncome/quarters[n-5].Shares
+	}
+	if prev != 0 {
+		f.EPSGrowth = (now - prev) / math.Abs(prev) * 100
+		f.HasGrowth = true
+	}
+
+	return f
+}
+
+func (s *fundamentalStore) metrics(symbol string, price float64) *Fundamentals {
+	if s == nil {
+		return nil
+	}
+	return computeFundamentals(s.bySymbol[symbol], price)
+}
+
+func (c FundamentalCriteria) evaluate(f *Fundamentals) (met, active int) {
+	check := func(enabled, ok bool) {
+		if enabled {
+			active++
+			if ok {
+				met++
+			}
+		}
+	}
+
+	check(c.MaxPER > 0, !f.Loss && f.PER <= c.MaxPER)
+	check(c.MaxPBV > 0, !f.NegativeEquity && f.PBV <= c.MaxPBV)
+	check(c.MinROE != 0, !f.NegativeEquity && f.ROE >= c.MinROE)
+	check(c.MaxDER > 0, !f.NegativeEquity && f.DER <= c.MaxDER)
+	check(c.MinEPSGrowth != 0 && f.HasGrowth, f.EPSGrowth >= c.MinEPSGrowth)
+	check(c.MinMarketCap > 0, f.MarketCap >= c.MinMarketCap)
+	check(c.ProfitOnly, !f.Loss)
+	return met, active
+}
+
+func (r FundamentalRules) passes(f *Fundamentals) bool {
+	if f == nil {
+		return !r.RequireData
+	}
+	met, active := r.Filter.evaluate(f)
+	return met == active
+}
+
+func (r FundamentalRules) component(f *Fundamentals) (ScoreComponent, bool) {
+	if r.BonusPoints == 0 || r.Bonus == (FundamentalCriteria{}) {
+		return ScoreComponent{}, false
+	}
+	c := ScoreComponent{Factor: "fundamental"}
+	if f == nil {
+		return c, true
+	}
+	met, active := r.Bonus.evaluate(f)
+	c.Value = float64(met)
+	c.Points = float64(met) * r.BonusPoints
+	c.Max = float64(active) * r.BonusPoints
+	return c, true
+}
+
+func fundamentalLines(f *Fundamentals) []string {
+	if f == nil {
+		return []string{"Fundamental: tidak ada data laporan keuangan"}
+	}
+
+	per := fmt.Sprintf("%.1fx", f.PER)
+	if f.Loss {
+		per = "\033[31mrugi\033[0m"
+	}
+	pbv, roe, der := fmt.Sprintf("%.2fx", f.PBV), fmt.Sprintf("%.1f%%", f.ROE), fmt.Sprintf("%.2fx", f.DER)
+	if f.NegativeEquity {
+		pbv, roe, der = "\033[31mekuitas negatif\033[0m", "-", "-"
+	}
+	growth := "-"
+	if f.HasGrowth {
+		growth = fmt.Sprintf("%s%+.1f%%\033[0m", changeColor(f.EPSGrowth), f.EPSGrowth)
+	}
+
+	status := ""
+	if fundamentalRules.Filter != (FundamentalCriteria{}) {
+		status = "  \033[32mlolos filter\033[0m"
+		if !fundamentalRules.passes(f) {
+			status = "  \033[31mtidak lolos filter\033[0m"
+		}
+	}
+	return []string{
+		fmt.Sprintf("Fundamental %s  PER %s  PBV %s  ROE %s  DER %s%s", f.Period, per, pbv, roe, der, status),
+		fmt.Sprintf("EPS Rp%.1f  BVPS Rp%.0f  Pertumbuhan EPS %s  Kapitalisasi %s", f.EPS, f.BVPS, growth, formatMoney(f.MarketCap)),
+	}
+}
+
+func scanNetForeignBuy(stocks []StockData) []ScanResult {
+	var results []ScanResult
+
+	for _, stock := range stocks {
+		netFB, netFBValue := scoringFlow(stock)
+		if netFB > 0 && stock.Score >= 40 && fundamentalRules.passes(stock.Fundamental) {
+			strength := int(stock.Score / 20)
+			if strength < 1 {
+				strength = 1
+			}
+			if strength > 5 {
+				strength = 5
+			}
+
+			signal := "ACCUMULATE"
+			if stock.Score >= 70 {
+				signal = "STRONG BUY"
+			} else if stock.Score >= 55 {
+				signal = "BUY"
+			}
+
+			results = append(results, ScanResult{
+				Symbol:          stock.Symbol,
+				Name:            stock.Name,
+				Sector:          stock.Sector,
+				Price:           stock.ClosePrice,
+				Change:          stock.ChangePercent,
+				NetForeignBuy:   netFB,
+				NetForeignValue: netFBValue,
+				ForeignPercent:  stock.ForeignPercent,
+				Accumulation:    stock.Accumulation,
+				NGNet:           netFlow(stock.Negotiated),
+				NGDominant:      stock.NGDominant,
+				Score:           stock.Score,
+				Strength:        strength,
+				Signal:          signal,
+			})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].NetForeignValue > results[j].NetForeignValue
+	})
+
+	return results
+}
+
+func scanBandarAccumulation(stocks []StockData, activity []BrokerActivity) []BandarResult {
+	var results []BandarResult
+
+	passed := make(map[string]bool)
+	for _, s := range stocks {
+		passed[s.Symbol] = fundamentalRules.passes(s.Fundamental)
+	}
+	for _, r := range analyzeBandar(stocks, activity) {
+		if r.NetTop5 > 0 && r.Score >= 60 && passed[r.Symbol] {
+			results = append(results, r)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	return results
+}
+
+func scanUnusualForeign(stocks []StockData) []UnusualFlow {
+	var results []UnusualFlow
+
+	for _, stock := range stocks {
+		if !fundamentalRules.passes(stock.Fundamental) {
+			continue
+		}
+		for idx := len(stock.FlowHistory) - unusualLookback; idx < len(stock.FlowHistory); idx++ {
+			if idx < flowWindow {
+				continue
+			}
+
+			stats := computeFlowStats(stock.FlowHistory, idx, flowWindow)
+			if math.Abs(stats.NetValueZ) <= unusualZ {
+				continue
+			}
+
+			day := stock.FlowHistory[idx]
+			results = append(results, UnusualFlow{
+				Symbol:          stock.Symbol,
+				Name:            stock.Name,
+				Sector:          stock.Sector,
+				Date:            day.Date,
+				NetForeignValue: day.NetForeignValue,
+				NetValueMean:    stats.NetValueMean,
+				NetValueZ:       stats.NetValueZ,
+				NetValuePctl:    stats.NetValuePctl,
+				ForeignPercent:  day.ForeignPercent,
+				ForeignPctZ:     stats.ForeignPctZ,
+			})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return math.Abs(results[i].NetValueZ) > math.Abs(results[j].NetValueZ)
+	})
+
+	return results
+}
+
+func scanNetForeignSell(stocks []StockData) []ScanResult {
+	var results []ScanResult
+
+	for _, stock := range stocks {
+		netFB, netFBValue := scoringFlow(stock)
+		if netFB < -500000 && fundamentalRules.passes(stock.Fundamental) {
+			strength := 1
+			netSell := -netFB
+			if netSell > 10000000 {
+				strength = 5
+			} else if netSell > 5000000 {
+				strength = 4
+			} else if netSell > 2000000 {
+				strength = 3
+			} else if netSell > 1000000 {
+				strength = 2
+			}
+
+			signal := "DISTRIBUTE"
+			if strength >= 4 {
+				signal = "STRONG SELL"
+			} else if strength >= 3 {
+				signal = "SELL"
+			}
+
+			results = append(results, ScanResult{
+				Symbol:          stock.Symbol,
+				Name:            stock.Name,
+				Sector:          stock.Sector,
+				Price:           stock.ClosePrice,
+				Change:          stock.ChangePercent,
+				NetForeignBuy:   netFB,
+				NetForeignValue: netFBValue,
+				ForeignPercent:  stock.ForeignPercent,
+				Accumulation:    stock.Accumulation,
+				NGNet:           netFlow(stock.Negotiated),
+				NGDominant:      stock.NGDominant,
+				Strength:        strength,
+				Signal:          signal,
+			})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].NetForeignValue < results[j].NetForeignValue
+	})
+
+	return results
+}
+
+func formatMoney(val float64) string {
+	absVal := math.Abs(val)
+	sign := ""
+	if val < 0 {
+		sign = "-"
+	}
+
+	if absVal >= 1000000000000 {
+		return fmt.Sprintf("%sRp %.1fT", sign, absVal/1000000000000)
+	} else if absVal >= 1000000000 {
+		return fmt.Sprintf("%sRp %.1fB", sign, absVal/1000000000)
+	} else if absVal >= 1000000 {
+		return fmt.Sprintf("%sRp %.1fM", sign, absVal/1000000)
+	}
+	return fmt.Sprintf("%sRp %.0f", sign, absVal)
+}
+
+func formatVolume(vol int64) string {
+	absVol := vol
+	sign := ""
+	if vol < 0 {
+		absVol = -vol
+		sign = "-"
+	}
+
+	if absVol >= 1000000 {
+		return fmt.Sprintf("%s%.1fM", sign, float64(absVol)/1000000)
+	} else if absVol >= 1000 {
+		return fmt.Sprintf("%s%.1fK", sign, float64(absVol)/1000)
+	}
+	return fmt.Sprintf("%s%d", sign, absVol)
+}
+
+func getStars(n int) string {
+	return strings.Repeat("*", n) + strings.Repeat(" ", 5-n)
+}
+
+func printHeader() {
+	fmt.Print("\033[2J\033[H")
+	fmt.Println(strings.Repeat("=", 95))
+	fmt.Println("                      NET FOREIGN BUY SCANNER")
+	fmt.Println("                    Indonesia Stock Exchange (IDX)")
+	fmt.Println(strings.Repeat("=", 95))
+	fmt.Printf(" Waktu: %s", time.Now().Format("02 Jan 2006 15:04:05"))
+	if lastMeta != nil {
+		fmt.Printf("   Seed: %d (ulang dengan -seed %d)", lastMeta.Seed, lastMeta.Seed)
+	}
+	fmt.Println()
+	fmt.Println(strings.Repeat("-", 95))
+	fmt.Println()
+}
+
+func flowBasis() string {
+	if useAllMarkets {
+		return " Basis flow: semua pasar (RG+NG+TN)"
+	}
+	return " Basis flow: pasar reguler (RG), NG = flow pasar negosiasi dominan"
+}
+
+func ngMarker(r ScanResult) string {
+	if r.NGDominant {
+		return "\033[1;33mNG\033[0m"
+	}
+	return ""
+}
+
+func printNetForeignBuy(results []ScanResult) {
+	fmt.Println("\033[1;32m                        NET FOREIGN BUY (Akumulasi Asing)\033[0m")
+	fmt.Println(" Saham yang sedang diakumulasi oleh investor asing")
+	fmt.Println(flowBasis())
+	fmt.Println(strings.Repeat("-", 95))
+
+	if len(results) == 0 {
+		fmt.Println(" Tidak ada saham dengan net foreign buy signifikan.")
+		fmt.Println()
+		return
+	}
+
+	fmt.Printf(" %-7s %-20s %-10s %-7s %-10s %-12s %-6s %-4s %-6s %-10s %s\n",
+		"KODE", "NAMA", "HARGA", "CHG%", "NET FB", "VALUE", "F%", "ACC", "RATE", "SIGNAL", "MKT")
+	fmt.Println(strings.Repeat("-", 95))
+
+	count := 0
+	for _, r := range results {
+		if count >= 12 {
+			break
+		}
+
+		chgColor := "\033[32m"
+		if r.Change < 0 {
+			chgColor = "\033[31m"
+		}
+
+		name := r.Name
+		if len(name) > 18 {
+			name = name[:18]
+		}
+
+		fmt.Printf("%s%-7s %-20s Rp%-8.0f %s%-6.1f%%\033[0m %-10s %-12s %-5.0f%% %-4d %-6s %-10s %s\n",
+			watchMark(r.Symbol),
+			r.Symbol,
+			name,
+			r.Price,
+			chgColor,
+			r.Change,
+			formatVolume(r.NetForeignBuy),
+			formatMoney(r.NetForeignValue),
+			r.ForeignPercent,
+			r.Accumulation,
+			getStars(r.Strength),
+			r.Signal,
+			ngMarker(r))
+		count++
+	}
+	fmt.Println()
+}
+
+func printNetForeignSell(results []ScanResult) {
+	fmt.Println("\033[1;31m                       NET FOREIGN SELL (Distribusi Asing)\033[0m")
+	fmt.Println(" Saham yang sedang dijual oleh investor asing")
+	fmt.Println(flowBasis())
+	fmt.Println(strings.Repeat("-", 95))
+
+	if len(results) == 0 {
+		fmt.Println(" Tidak ada saham dengan net foreign sell signifikan.")
+		fmt.Println()
+		return
+	}
+
+	fmt.Printf(" %-7s %-20s %-10s %-7s %-10s %-12s %-6s %-4s %-6s %-10s %s\n",
+		"KODE", "NAMA", "HARGA", "CHG%", "NET FS", "VALUE", "F%", "ACC", "RATE", "SIGNAL", "MKT")
+	fmt.Println(strings.Repeat("-", 95))
+
+	count := 0
+	for _, r := range results {
+		if count >= 8 {
+			break
+		}
+
+		chgColor := "\033[32m"
+		if r.Change < 0 {
+			chgColor = "\033[31m"
+		}
+
+		name := r.Name
+		if len(name) > 18 {
+			name = name[:18]
+		}
+
+		fmt.Printf("%s%-7s %-20s Rp%-8.0f %s%-6.1f%%\033[0m %-10s %-12s %-5.0f%% %-4d %-6s %-10s %s\n",
+			watchMark(r.Symbol),
+			r.Symbol,
+			name,
+			r.Price,
+			chgColor,
+			r.Change,
+			formatVolume(r.NetForeignBuy),
+			formatMoney(r.NetForeignValue),
+			r.ForeignPercent,
+			r.Accumulation,
+			getStars(r.Strength),
+			r.Signal,
+			ngMarker(r))
+		count++
+	}
+	fmt.Println()
+}
+
+func printBandarAccumulation(results []BandarResult, source string) {
+	printHeader()
+	fmt.Println("\033[1;35m                     BANDAR AKUMULASI (Broker Summary)\033[0m")
+	fmt.Printf(" Sumber data: %s\n", source)
+	fmt.Println(strings.Repeat("-", 95))
+
+	if len(results) == 0 {
+		fmt.Println(" Tidak ada saham dengan akumulasi broker signifikan.")
+		fmt.Println()
+		return
+	}
+
+	fmt.Printf(" %-7s %-10s %-10s %-6s %-9s %-9s %-9s %-9s %-10s %-6s %-10s\n",
+		"KODE", "TOP BUYER", "HARGA", "KONS%", "NET TOP3", "NET TOP5", "ASING", "RITEL", "AVG AKUM", "VS%", "SIGNAL")
+	fmt.Println(strings.Repeat("-", 95))
+
+	for _, r := range results {
+		vsColor := "\033[32m"
+		if r.PriceVsAccum > 2 {
+			vsColor = "\033[31m"
+		}
+
+		fmt.Printf(" %-7s %-10s Rp%-8.0f %-6.0f %-9s %-9s %-9s %-9s Rp%-8.0f %s%-+6.1f\033[0m %-10s\n",
+			r.Symbol,
+			strings.Join(r.TopBuyers, ","),
+			r.Price,
+			r.Concentration,
+			formatVolume(r.NetTop3),
+			formatVolume(r.NetTop5),
+			formatVolume(r.NetForeign),
+			formatVolume(r.NetRetail),
+			r.AccumPrice,
+			vsColor,
+			r.PriceVsAccum,
+			r.Signal)
+	}
+
+	fmt.Println()
+	fmt.Println(" KONS% = porsi beli 3 broker teratas dari total beli (lot).")
+	fmt.Println(" AVG AKUM = harga rata-rata beli 5 broker net buy teratas; VS% = harga sekarang terhadap AVG AKUM.")
+	fmt.Println()
+}
+
+func printUnusualForeign(results []UnusualFlow) {
+	printHeader()
+	fmt.Println("\033[1;33m                    AKTIVITAS ASING TIDAK BIASA (|z| > 2)\033[0m")
+	fmt.Printf(" Net foreign value dibanding rata-rata %d hari sebelumnya, %d hari terakhir\n", flowWindow, unusualLookback)
+	fmt.Println(strings.Repeat("-", 95))
+
+	if len(results) == 0 {
+		fmt.Println(" Tidak ada aktivitas asing yang tidak biasa.")
+		fmt.Println()
+		return
+	}
+
+	fmt.Printf(" %-7s %-18s %-10s %-13s %-13s %-7s %-6s %-6s %-7s %-6s\n",
+		"KODE", "NAMA", "TANGGAL", "NET VALUE", "RATA-RATA", "Z", "PCTL", "F%", "F% Z", "ARAH")
+	fmt.Println(strings.Repeat("-", 95))
+
+	for _, r := range results {
+		direction := "\033[32mBELI\033[0m"
+		if r.NetValueZ < 0 {
+			direction = "\033[31mJUAL\033[0m"
+		}
+
+		name := r.Name
+		if len(name) > 16 {
+			name = name[:16]
+		}
+
+		fmt.Printf(" %-7s %-18s %-10s %-13s %-13s %-+7.1f %-6.0f %-6.0f %-+7.1f %s\n",
+			r.Symbol,
+			name,
+			r.Date.Format("02 Jan"),
+			formatMoney(r.NetForeignValue),
+			formatMoney(r.NetValueMean),
+			r.NetValueZ,
+			r.NetValuePctl,
+			r.ForeignPercent,
+			r.ForeignPctZ,
+			direction)
+	}
+
+	fmt.Println()
+	fmt.Println(" Z = (nilai hari itu - rata-rata) / standar deviasi, sehingga saham kecil dan besar sebanding.")
+	fmt.Println()
+}
+
+func printAllStocks(stocks []StockData) {
+	printHeader()
+	fmt.Println("                           DATA SEMUA SAHAM")
+	fmt.Println(strings.Repeat("-", 95))
+
+	fmt.Printf(" %-7s %-18s %-10s %-7s %-10s %-10s %-10s %-12s %-6s %-5s %-7s %-6s %s\n",
+		"KODE", "NAMA", "HARGA", "CHG%", "FB", "FS", "NET FB", "NET VALUE", "SCORE", "NG%", "ASING%", "MoM", "DIV")
+	fmt.Println(strings.Repeat("-", 95))
+
+	for _, s := range stocks {
+		chgColor := "\033[32m"
+		if s.ChangePercent < 0 {
+			chgColor = "\033[31m"
+		}
+
+		name := s.Name
+		if len(name) > 16 {
+			name = name[:16]
+		}
+
+		ngColor := "\033[0m"
+		if s.NGDominant {
+			ngColor = "\033[1;33m"
+		}
+
+		ownPct := "-"
+		ownMoM := "-"
+		if s.Ownership.Available {
+			ownPct = fmt.Sprintf("%.1f", s.Ownership.ForeignPct)
+			ownMoM = fmt.Sprintf("%+.2f", s.Ownership.ChangeMoM)
+		}
+		divergence := ""
+		if s.Ownership.Divergence {
+			divergence = "\033[1;33m!\033[0m"
+		}
+
+		fmt.Printf("%s%-7s %-18s Rp%-8.0f %s%-6.1f%%\033[0m %-10s %-10s %-10s %-12s %-6.0f %s%-5.0f\033[0m %-7s %-6s %s\n",
+			watchMark(s.Symbol),
+			s.Symbol,
+			name,
+			s.ClosePrice,
+			chgColor,
+			s.ChangePercent,
+			formatVolume(s.ForeignBuy),
+			formatVolume(s.ForeignSell),
+			formatVolume(s.NetForeignBuy),
+			formatMoney(s.NetForeignValue),
+			s.Score,
+			ngColor,
+			s.NGShare,
+			ownPct,
+			ownMoM,
+			divergence)
+	}
+	fmt.Println()
+
+	var diverging []StockData
+	var month time.Time
+	for _, s := range stocks {
+		if s.Ownership.Available {
+			month = s.Ownership.Month
+		}
+		if s.Ownership.Divergence {
+			diverging = append(diverging, s)
+		}
+	}
+
+	if !month.IsZero() {
+		fmt.Printf(" ASING%% = kepemilikan asing KSEI %s, MoM = perubahan dari bulan sebelumnya (poin %%)\n", month.Format("Jan 2006"))
+	}
+	if len(diverging) > 0 {
+		fmt.Println(" \033[1;33mDivergensi flow harian vs kepemilikan bulanan:\033[0m")
+		for _, s := range diverging {
+			fmt.Printf("  %-6s net asing %d hari %s, kepemilikan asing %s %+.2f poin (terbesar: %s)\n",
+				s.Symbol,
+				flowWindow,
+				formatMoney(s.Ownership.MonthlyFlow),
+				s.Ownership.Trend,
+				s.Ownership.ChangeMoM,
+				investorTypeName[s.Ownership.TopForeign])
+		}
+		fmt.Println()
+	}
+}
+
+func printSectorFlow(sectors []SectorFlow, ihsg IndexData) {
+	fmt.Println("                      ROTASI SEKTOR & ALIRAN ASING")
+	fmt.Println(strings.Repeat("-", 95))
+	fmt.Printf(" IHSG: 1H %+.1f%%   5H %+.1f%%   20H %+.1f%%\n", ihsg.Change1D, ihsg.Change5D, ihsg.Change20D)
+	fmt.Println(strings.Repeat("-", 95))
+
+	fmt.Printf(" %-4s %-15s %-4s %-7s %-8s %-8s %-13s %-6s %-6s %-7s %-14s\n",
+		"RANK", "SEKTOR", "JML", "AVG%", "ADV/DEC", "BREADTH", "NET ASING", "RS 1H", "RS 5H", "RS 20H", "STATUS")
+	fmt.Println(strings.Repeat("-", 95))
+
+	for i, f := range sectors {
+		statusColor := "\033[0m"
+		if f.Status == "ROTASI MASUK" {
+			statusColor = "\033[32m"
+		} else if f.Status == "ROTASI KELUAR" {
+			statusColor = "\033[31m"
+		}
+
+		sector := f.Sector
+		if len(sector) > 14 {
+			sector = sector[:14]
+		}
+
+		fmt.Printf(" %-4d %-15s %-4d %-+7.1f %-8s %-8s %-13s %-+6.1f %-+6.1f %-+7.1f %s%-14s\033[0m\n",
+			i+1,
+			sector,
+			f.Count,
+			f.AvgChange,
+			fmt.Sprintf("%d/%d", f.Advancers, f.Decliners),
+			fmt.Sprintf("%.0f%%", f.Breadth),
+			formatMoney(f.NetForeignValue),
+			f.RS1D,
+			f.RS5D,
+			f.RS20D,
+			statusColor,
+			f.Status)
+	}
+
+	fmt.Println()
+	fmt.Println(" RS = return rata-rata sektor dikurangi return IHSG pada periode yang sama.")
+	fmt.Println(" Sektor ROTASI MASUK mendapat bonus score, ROTASI KELUAR mendapat penalti.")
+	fmt.Println()
+}
+
+func printGuide() {
+	printHeader()
+	fmt.Println("                         PANDUAN NET FOREIGN BUY")
 	fmt.Println(strings.Repeat("-", 95))
+	fmt.Println()
+	fmt.Println(" APA ITU NET FOREIGN BUY?")
+	fmt.Println(" Net Foreign Buy adalah selisih antara pembelian dan penjualan")
+	fmt.Println(" oleh investor asing. Jika positif, berarti asing lebih banyak")
+	fmt.Println(" membeli (akumulasi). Jika negatif, berarti asing lebih banyak")
+	fmt.Println(" menjual (distribusi).")
+	fmt.Println()
+	fmt.Println(" MENGAPA PENTING?")
+	fmt.Println(" - Investor asing memiliki riset dan analisa mendalam")
+	fmt.Println(" - Akumulasi asing sering mendahului kenaikan harga")
+	fmt.Println(" - Distribusi asing bisa menjadi sinyal peringatan")
+	fmt.Println()
+	fmt.Println(" KRITERIA SCREENING:")
+	fmt.Println(" - Net FB > 0 dengan z-score tinggi dibanding 20 hari terakhir")
+	fmt.Println(" - Net foreign value di persentil atas (>= 60) historisnya")
+	fmt.Println(" - Partisipasi asing di atas rata-rata saham tersebut")
+	fmt.Println(" - Akumulasi berhari-hari (Accumulation Days > 3)")
+	fmt.Println(" - Harga masih dalam tren naik moderat")
+	fmt.Println()
+	fmt.Println(" PASAR REGULER vs NEGOSIASI:")
+	fmt.Println(" - Score dihitung dari flow pasar reguler (RG) secara default")
+	fmt.Println(" - Crossing besar di pasar negosiasi (NG) bisa memalsukan sinyal")
+	fmt.Println(" - Marker NG = lebih dari 50% net foreign berasal dari pasar negosiasi")
+	fmt.Println(" - Jalankan dengan -allmarket untuk memakai flow RG+NG+TN")
+	fmt.Println()
+	fmt.Println(" SIGNAL:")
+	fmt.Println(" STRONG BUY  = Score >= 70, akumulasi sangat kuat")
+	fmt.Println(" BUY         = Score >= 55, akumulasi cukup kuat")
+	fmt.Println(" ACCUMULATE  = Score >= 40, mulai ada akumulasi")
+	fmt.Println()
+	fmt.Println(" PERINGATAN:")
+	fmt.Println(" - Selalu kombinasikan dengan analisa teknikal")
+	fmt.Println(" - Net foreign buy bukan jaminan harga naik")
+	fmt.Println(" - Perhatikan juga kondisi market secara keseluruhan")
+	fmt.Println()
+}
+
+func printMenu() {
+	fmt.Println()
+	fmt.Println(" MENU:")
+	fmt.Println(" [1] Scan Ulang")
+	fmt.Println(" [2] Lihat Semua Saham")
+	fmt.Println(" [3] Panduan")
+	fmt.Println(" [4] Rotasi Sektor")
+	fmt.Println(" [5] Bandar Akumulasi")
+	fmt.Println(" [6] Aktivitas Asing Tidak Biasa")
+	fmt.Println(" [7] Keluar")
+	fmt.Println()
+	fmt.Print(" Pilihan: ")
+}
+
+type dataSources struct {
+	brokers      []BrokerActivity
+	brokerSource string
+	ownership    map[string]OwnershipTrend
+	fundamentals *fundamentalStore
+	watchlists   map[string]map[string][]string
+	profile      string
+	seeds        *seedSource
+	meta         ScanMeta
+}
+
+type ScanMeta struct {
+	Seed             int64             `json:"seed"`
+	AsOf             string            `json:"as_of"`
+	AllMarket        bool              `json:"all_market,omitempty"`
+	Broker           string            `json:"broker,omitempty"`
+	KSEI             string            `json:"ksei,omitempty"`
+	Fundamentals     string            `json:"fundamentals,omitempty"`
+	FundamentalRules *FundamentalRules `json:"fundamental_rules,omitempty"`
+	Profile          string            `json:"profile,omitempty"`
+	Watchlist        string            `json:"watchlist,omitempty"`
+	Symbols          []string          `json:"symbols,omitempty"`
+	Scan             string            `json:"scan,omitempty"`
+	Filter           *scanFilter       `json:"filter,omitempty"`
+}
+
+type scanFilter struct {
+	MinScore    float64 `json:"min_score,omitempty"`
+	MinStrength int     `json:"min_strength,omitempty"`
+	Sector      string  `json:"sector,omitempty"`
+	Top         int     `json:"top"`
+}
+
+func (m ScanMeta) date() time.Time {
+	d, err := time.ParseInLocation("2006-01-02", m.AsOf, time.Local)
+	if err != nil {
+		return time.Now()
+	}
+	return d
+}
+
+func (m ScanMeta) scope() []string {
+	if m.Watchlist != "" && m.Symbols == nil {
+		return []string{}
+	}
+	return m.Symbols
+}
+
+type seedSource struct {
+	mu    sync.Mutex
+	first int64
+	fixed bool
+	rng   *rand.Rand
+}
+
+var lastMeta *ScanMeta
+
+func newSeedSource(seed int64, fixed bool) *seedSource {
+	src := &seedSource{first: seed, fixed: fixed}
+	if !fixed {
+		seed = time.Now().UnixNano()
+	}
+	src.rng = rand.New(rand.NewSource(seed))
+	return src
+}
+
+func flagPassed(fs *flag.FlagSet, name string) bool {
+	passed := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
+
+func (s *seedSource) next() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fixed {
+		s.fixed = false
+		return s.first
+	}
+	return s.rng.Int63()
+}
+
+type scanSnapshot struct {
+	IHSG         IndexData
+	Stocks       []StockData
+	Sectors      []SectorFlow
+	Brokers      []BrokerActivity
+	BrokerSource string
+	GeneratedAt  time.Time
+	Meta         ScanMeta
+}
+
+func loadDataSources(brokerFile, kseiFiles, fundamentalFile string) (dataSources, []string) {
+	src := dataSources{brokerSource: "simulasi"}
+	var warnings []string
+
+	if brokerFile != "" {
+		loaded, err := loadBrokerSummary(brokerFile)
+		if err != nil {
+			src.brokerSource = fmt.Sprintf("simulasi (gagal membaca %s: %v)", brokerFile, err)
+			warnings = append(warnings, fmt.Sprintf("Gagal membaca broker summary: %v", err))
+		} else {
+			src.brokers = loaded
+			src.brokerSource = brokerFile
+			src.meta.Broker = brokerFile
+		}
+	}
+
+	if kseiFiles != "" {
+		records, err := loadOwnership(kseiFiles)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Gagal membaca data KSEI: %v", err))
+		} else {
+			src.ownership = buildOwnershipTrends(records)
+			src.meta.KSEI = kseiFiles
+		}
+	}
+
+	if fundamentalFile != "" {
+		store, err := loadFinancials(fundamentalFile)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Gagal membaca data fundamental: %v", err))
+		} else {
+			src.fundamentals = store
+			src.meta.Fundamentals = fundamentalFile
+		}
+	}
+
+	return src, warnings
+}
+
+func buildSnapshot(src dataSources) scanSnapshot {
+	seed := src.seeds.next()
+	asOf := src.meta.date()
+	rng := rand.New(rand.NewSource(seed))
+	ihsg := generateIHSG(rng)
+	stocks := generateStockData(rng, ihsg, asOf)
+
+	if src.ownership != nil {
+		attachOwnership(stocks, src.ownership)
+	} else {
+		attachOwnership(stocks, buildOwnershipTrends(generateOwnership(rng, asOf, stocks)))
+	}
+
+	brokers := src.brokers
+	if brokers == nil {
+		brokers = generateBrokerSummary(rng, stocks)
+	}
+
+	sectors := analyzeSectorFlow(stocks, ihsg)
+	if src.fundamentals != nil {
+		for i := range stocks {
+			stocks[i].Fundamental = src.fundamentals.metrics(stocks[i].Symbol, stocks[i].ClosePrice)
+		}
+		scoreStocks(stocks, sectors)
+	}
+
+	snap := scanSnapshot{
+		IHSG:         ihsg,
+		Stocks:       stocks,
+		Sectors:      sectors,
+		Brokers:      brokers,
+		BrokerSource: src.brokerSource,
+		GeneratedAt:  time.Now(),
+		Meta:         src.meta,
+	}
+	snap.Meta.Seed, snap.Meta.AsOf, snap.Meta.AllMarket = seed, asOf.Format("2006-01-02"), useAllMarkets
+	if fundamentalRules != (FundamentalRules{}) {
+		rules := fundamentalRules
+		snap.Meta.FundamentalRules = &rules
+	}
+	return snap
+}
+
+type apiServer struct {
+	mu   sync.RWMutex
+	src  dataSources
+	snap scanSnapshot
+}
+
+type apiResponse struct {
+	GeneratedAt time.Time   `json:"generated_at"`
+	Date        string      `json:"date"`
+	Seed        int64       `json:"seed"`
+	Meta        ScanMeta    `json:"meta"`
+	Count       int         `json:"count"`
+	Source      string      `json:"source,omitempty"`
+	Results     interface{} `json:"results"`
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (s *apiServer) refresh() {
+	snap := buildSnapshot(s.src)
+
+	s.mu.Lock()
+	s.snap = snap
+	s.mu.Unlock()
+}
+
+func (s *apiServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/scan/foreign/buy", s.handleScan("buy", scanNetForeignBuy, true))
+	mux.HandleFunc("/scan/foreign/sell", s.handleScan("sell", scanNetForeignSell, false))
+	mux.HandleFunc("/scan/foreign/bandar", s.handleBandar)
+	mux.HandleFunc("/scan/foreign/unusual", s.handleUnusual)
+	mux.HandleFunc("/foreign/stock/", s.handleStock)
+	mux.HandleFunc("/foreign/sectors", s.handleSectors)
+	mux.HandleFunc("/sectors", s.handleSectors)
+
+	return logRequests(mux)
+}
+
+func (s *apiServer) snapshot(w http.ResponseWriter, r *http.Request) (scanSnapshot, bool) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "hanya mendukung GET")
+		return scanSnapshot{}, false
+	}
+
+	s.mu.RLock()
+	snap := s.snap
+	s.mu.RUnlock()
+
+	if date := r.URL.Query().Get("date"); date != "" {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			writeError(w, http.StatusBadRequest, "format date harus YYYY-MM-DD")
+			return scanSnapshot{}, false
+		}
+		if latest := snap.GeneratedAt.Format("2006-01-02"); date != latest {
+			writeError(w, http.StatusNotFound, "data untuk tanggal "+date+" tidak tersedia, server hanya menyimpan scan terakhir ("+latest+"); ulang scan lama dengan perintah replay")
+			return scanSnapshot{}, false
+		}
+	}
+
+	return snap, true
+}
+
+func newAPIResponse(meta ScanMeta, snap scanSnapshot, count int, results interface{}) apiResponse {
+	return apiResponse{
+		GeneratedAt: snap.GeneratedAt,
+		Date:        snap.GeneratedAt.Format("2006-01-02"),
+		Seed:        meta.Seed,
+		Meta:        meta,
+		Count:       count,
+		Results:     results,
+	}
+}
+
+func (s *apiServer) scope(snap scanSnapshot, q url.Values) ([]StockData, ScanMeta, error) {
+	meta := snap.Meta
+	name := q.Get("watchlist")
+	if name == "" {
+		return snap.Stocks, meta, nil
+	}
+
+	profile := q.Get("profile")
+	if profile == "" {
+		profile = s.src.profile
+	}
+
+	list, ok := s.src.watchlists[profile][name]
+	if !ok {
+		return nil, meta, fmt.Errorf("watchlist %q tidak ada di profil %q", name, profile)
+	}
+	meta.Profile, meta.Watchlist, meta.Symbols = profile, name, list
+	return scopeStocks(snap.Stocks, list), meta, nil
+}
+
+func parseListParams(q url.Values) (float64, string, int, error) {
+	minScore := 0.0
+	if v := q.Get("min_score"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, "", 0, fmt.Errorf("min_score harus angka")
+		}
+		minScore = f
+	}
+
+	top := -1
+	if v := q.Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, "", 0, fmt.Errorf("top harus bilangan bulat tidak negatif")
+		}
+		top = n
+	}
+
+	return minScore, q.Get("sector"), top, nil
+}
+
+func parseMinStrength(q url.Values) (int, error) {
+	v := q.Get("min_strength")
+	if v == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > 5 {
+		return 0, fmt.Errorf("min_strength harus bilangan bulat 1-5")
+	}
+	return n, nil
+}
+
+func limitCount(n, top int) int {
+	if top >= 0 && top < n {
+		return top
+	}
+	return n
+}
+
+func filterResults(results []ScanResult, f scanFilter) []ScanResult {
+	kept := []ScanResult{}
+	for _, res := range results {
+		if res.Score < f.MinScore || res.Strength < f.MinStrength || (f.Sector != "" && !strings.EqualFold(f.Sector, res.Sector)) {
+			continue
+		}
+		kept = append(kept, res)
+	}
+	return kept[:limitCount(len(kept), f.Top)]
+}
+
+func (s *apiServer) handleScan(name string, scan func([]StockData) []ScanResult, scored bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		snap, ok := s.snapshot(w, r)
+		if !ok {
+			return
+		}
+
+		minScore, sector, top, err := parseListParams(r.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if !scored && r.URL.Query().Get("min_score") != "" {
+			writeError(w, http.StatusBadRequest, "min_score tidak berlaku untuk scan ini, gunakan min_strength")
+			return
+		}
+		minStrength, err := parseMinStrength(r.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		stocks, meta, err := s.scope(snap, r.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		filter := scanFilter{MinScore: minScore, MinStrength: minStrength, Sector: sector, Top: top}
+		results := filterResults(scan(stocks), filter)
+		meta.Scan, meta.Filter = name, &filter
+
+		writeJSON(w, http.StatusOK, newAPIResponse(meta, snap, len(results), results))
+	}
+}
+
+func (s *apiServer) handleBandar(w http.ResponseWriter, r *http.Request) {
+	snap, ok := s.snapshot(w, r)
+	if !ok {
+		return
+	}
+
+	minScore, sector, top, err := parseListParams(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stocks, meta, err := s.scope(snap, r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	results := []BandarResult{}
+	for _, res := range scanBandarAccumulation(stocks, snap.Brokers) {
+		if res.Score < minScore || (sector != "" && !strings.EqualFold(sector, res.Sector)) {
+			continue
+		}
+		results = append(results, res)
+	}
+	results = results[:limitCount(len(results), top)]
+
+	meta.Scan = "bandar"
+	resp := newAPIResponse(meta, snap, len(results), results)
+	resp.Source = snap.BrokerSource
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *apiServer) handleUnusual(w http.ResponseWriter, r *http.Request) {
+	snap, ok := s.snapshot(w, r)
+	if !ok {
+		return
+	}
+
+	_, sector, top, err := parseListParams(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stocks, meta, err := s.scope(snap, r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	results := []UnusualFlow{}
+	for _, res := range scanUnusualForeign(stocks) {
+		if sector != "" && !strings.EqualFold(sector, res.Sector) {
+			continue
+		}
+		results = append(results, res)
+	}
+	results = results[:limitCount(len(results), top)]
+
+	meta.Scan = "unusual"
+	writeJSON(w, http.StatusOK, newAPIResponse(meta, snap, len(results), results))
+}
+
+func (s *apiServer) handleStock(w http.ResponseWriter, r *http.Request) {
+	snap, ok := s.snapshot(w, r)
+	if !ok {
+		return
+	}
+
+	symbol := strings.ToUpper(strings.Trim(strings.TrimPrefix(r.URL.Path, "/foreign/stock/"), "/"))
+	for _, stock := range snap.Stocks {
+		if stock.Symbol == symbol {
+			writeJSON(w, http.StatusOK, newAPIResponse(snap.Meta, snap, 1, map[string]interface{}{
+				"stock":     stock,
+				"breakdown": scoreBreakdown(stock),
+			}))
+			return
+		}
+	}
+
+	writeError(w, http.StatusNotFound, "saham "+symbol+" tidak ditemukan")
+}
+
+func (s *apiServer) handleSectors(w http.ResponseWriter, r *http.Request) {
+	snap, ok := s.snapshot(w, r)
+	if !ok {
+		return
+	}
+
+	_, sector, top, err := parseListParams(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	results := []SectorFlow{}
+	for _, f := range snap.Sectors {
+		if sector != "" && !strings.EqualFold(sector, f.Sector) {
+			continue
+		}
+		results = append(results, f)
+	}
+	results = results[:limitCount(len(results), top)]
+
+	writeJSON(w, http.StatusOK, newAPIResponse(snap.Meta, snap, len(results), map[string]interface{}{
+		"ihsg":    snap.IHSG,
+		"sectors": results,
+	}))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		log.Printf("%s %s %d %s", r.Method, r.URL.RequestURI(), rec.status, time.Since(start).Round(time.Microsecond))
+	})
+}
+
+func runServer(addr string, src dataSources, refresh time.Duration) error {
+	api := &apiServer{src: src}
+	api.refresh()
+
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: api.routes(),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if refresh > 0 {
+		go func() {
+			ticker := time.NewTicker(refresh)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					api.refresh()
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("server berjalan di %s", addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("menghentikan server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+func defaultProfile() string {
+	if p := os.Getenv("IDX_PROFILE"); p != "" {
+		return p
+	}
+	return "default"
+}
+
+func loadWatchlists(path string) (map[string]map[string][]string, error) {
+	var store struct {
+		Profiles map[string]map[string][]string `json:"profiles"`
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store); err != nil {
+		return nil, fmt.Errorf("watchlist %s rusak: %v", path, err)
+	}
+	for _, lists := range store.Profiles {
+		for name, list := range lists {
+			if list == nil {
+				lists[name] = []string{}
+			}
+		}
+	}
+
+	return store.Profiles, nil
+}
+
+func scopeStocks(stocks []StockData, symbols []string) []StockData {
+	if symbols == nil {
+		return stocks
+	}
+
+	keep := make(map[string]bool)
+	for _, sym := range symbols {
+		keep[sym] = true
+	}
+
+	scoped := []StockData{}
+	for _, s := range stocks {
+		if keep[s.Symbol] {
+			scoped = append(scoped, s)
+		}
+	}
+	return scoped
+}
+
+func watchMark(symbol string) string {
+	if watchedSymbols[symbol] {
+		return "\033[1;36m*\033[0m"
+	}
+	return " "
+}
+
+type tuiColumn struct {
+	Title string
+	Width int
+	Text  func(i int) string
+	Num   func(i int) float64
+	Color func(i int) string
+}
+
+type tuiView struct {
+	Name    string
+	Len     int
+	Columns []tuiColumn
+	Symbol  func(i int) string
+	Sector  func(i int) string
+	Detail  func(i int) []string
+	Chart   func(i int) []string
+}
+
+type tuiState struct {
+	sortCol int
+	desc    bool
+	cursor  int
+	offset  int
+}
+
+type tui struct {
+	title     string
+	width     int
+	views     []tuiView
+	states    []tuiState
+	active    int
+	search    string
+	searching bool
+	sectors   []string
+	sector    int
+	detail    bool
+	rows      []int
+	notes     []string
+	in        *bufio.Reader
+	restore   string
+}
+
+const (
+	keyUp = iota + 256
+	keyDown
+	keyLeft
+	keyRight
+	keyPgUp
+	keyPgDn
+	keyHome
+	keyEnd
+)
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+var stdin = bufio.NewReader(os.Stdin)
+
+func readLine() string {
+	line, _ := stdin.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+func rawModeAvailable() bool {
+	_, err := stty("-g")
+	return err == nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+func terminalHeight() int {
+	if out, err := stty("size"); err == nil {
+		var rows, cols int
+		if _, err := fmt.Sscan(out, &rows, &cols); err == nil && rows > 10 {
+			return rows
+		}
+	}
+	if n, err := strconv.Atoi(os.Getenv("LINES")); err == nil && n > 10 {
+		return n
+	}
+	return 24
+}
+
+func newTUI(title string, width int, views []tuiView, in *bufio.Reader) *tui {
+	t := &tui{title: title, width: width, views: views, in: in}
+
+	seen := make(map[string]bool)
+	for _, v := range views {
+		t.states = append(t.states, tuiState{sortCol: -1})
+		for i := 0; i < v.Len; i++ {
+			if sec := v.Sector(i); !seen[sec] {
+				seen[sec] = true
+				t.sectors = append(t.sectors, sec)
+			}
+		}
+	}
+	sort.Strings(t.sectors)
+
+	return t
+}
 
-	count := 0
-	for _, r := range results {
-		if count >= 8 {
-			break
+func (t *tui) enterRaw() {
+	if saved, err := stty("-g"); err == nil {
+		t.restore = saved
+		stty("-icanon", "-echo", "min", "1")
+	}
+	fmt.Print("\033[?25l")
+}
+
+func (t *tui) leaveRaw() {
+	if t.restore != "" {
+		stty(t.restore)
+		t.restore = ""
+	}
+	fmt.Print("\033[?25h")
+}
+
+func (t *tui) readKey() (int, error) {
+	b, err := t.in.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != 27 || t.in.Buffered() == 0 {
+		return int(b), nil
+	}
+
+	next, _ := t.in.ReadByte()
+	if next != '[' && next != 'O' {
+		return 27, nil
+	}
+	code, _ := t.in.ReadByte()
+	switch code {
+	case 'A':
+		return keyUp, nil
+	case 'B':
+		return keyDown, nil
+	case 'C':
+		return keyRight, nil
+	case 'D':
+		return keyLeft, nil
+	case 'H':
+		return keyHome, nil
+	case 'F':
+		return keyEnd, nil
+	case '5', '6':
+		t.in.ReadByte()
+		if code == '5' {
+			return keyPgUp, nil
 		}
+		return keyPgDn, nil
+	}
+	return 27, nil
+}
 
-		chgColor := "\033[32m"
-		if r.Change < 0 {
-			chgColor = "\033[31m"
+func (t *tui) filter() {
+	v := t.views[t.active]
+	st := &t.states[t.active]
+	query := strings.ToUpper(t.search)
+
+	t.rows = t.rows[:0]
+	for i := 0; i < v.Len; i++ {
+		if query != "" && !strings.Contains(v.Symbol(i), query) {
+			continue
+		}
+		if t.sector > 0 && v.Sector(i) != t.sectors[t.sector-1] {
+			continue
 		}
+		t.rows = append(t.rows, i)
+	}
 
-		name := r.Name
-		if len(name) > 18 {
-			name = name[:18]
+	if st.sortCol >= 0 {
+		col := v.Columns[st.sortCol]
+		sort.SliceStable(t.rows, func(a, b int) bool {
+			x, y := t.rows[a], t.rows[b]
+			if st.desc {
+				x, y = y, x
+			}
+			if col.Num != nil {
+				return col.Num(x) < col.Num(y)
+			}
+			return col.Text(x) < col.Text(y)
+		})
+	}
+
+	if st.cursor >= len(t.rows) {
+		st.cursor = len(t.rows) - 1
+	}
+	if st.cursor < 0 {
+		st.cursor = 0
+	}
+}
+
+func padCell(s string, width int) string {
+	r := []rune(s)
+	if len(r) > width-1 {
+		r = r[:width-1]
+	}
+	return string(r) + strings.Repeat(" ", width-len(r))
+}
+
+func (t *tui) render(height int) {
+	v := t.views[t.active]
+	st := &t.states[t.active]
+	var b strings.Builder
+
+	b.WriteString("\033[H\033[2J")
+	b.WriteString(strings.Repeat("=", t.width) + "\n")
+	b.WriteString(" \033[1m" + t.title + "\033[0m  ")
+	for i, view := range t.views {
+		if i == t.active {
+			b.WriteString("\033[7m " + view.Name + " \033[0m ")
+		} else {
+			b.WriteString(" " + view.Name + "  ")
 		}
+	}
+	b.WriteString("\n")
 
-		fmt.Printf(" %-7s %-20s Rp%-8.0f %s%-6.1f%%\033[0m %-10s %-12s %-5.0f%% %-4d %-6s %-10s\n",
-			r.Symbol,
-			name,
-			r.Price,
-			chgColor,
-			r.Change,
-			formatVolume(r.NetForeignBuy),
-			formatMoney(r.NetForeignValue),
-			r.ForeignPercent,
-			r.Accumulation,
-			getStars(r.Strength),
-			r.Signal)
-		count++
+	sortLabel := "-"
+	if st.sortCol >= 0 {
+		dir := "naik"
+		if st.desc {
+			dir = "turun"
+		}
+		sortLabel = v.Columns[st.sortCol].Title + " " + dir
 	}
-	fmt.Println()
+	sectorLabel := "Semua"
+	if t.sector > 0 {
+		sectorLabel = t.sectors[t.sector-1]
+	}
+	cursorMark := ""
+	if t.searching {
+		cursorMark = "_"
+	}
+	fmt.Fprintf(&b, " Urut: %-16s Sektor: %-14s Cari: %-10s %d/%d baris\n",
+		sortLabel, sectorLabel, t.search+cursorMark, len(t.rows), v.Len)
+	b.WriteString(strings.Repeat("-", t.width) + "\n")
+
+	b.WriteString(" ")
+	for i, col := range v.Columns {
+		title := col.Title
+		if i == st.sortCol {
+			if st.desc {
+				title += "v"
+			} else {
+				title += "^"
+			}
+		}
+		b.WriteString(padCell(title, col.Width))
+	}
+	b.WriteString("\n")
+
+	var detail []string
+	if t.detail && len(t.rows) > 0 {
+		detail = v.Detail(t.rows[st.cursor])
+	}
+
+	visible := height - 7 - len(t.notes)
+	if len(detail) > 0 {
+		visible -= len(detail) + 1
+	}
+	if visible < 3 {
+		visible = 3
+	}
+	if st.cursor < st.offset {
+		st.offset = st.cursor
+	}
+	if st.cursor >= st.offset+visible {
+		st.offset = st.cursor - visible + 1
+	}
+
+	for n := st.offset; n < st.offset+visible; n++ {
+		if n >= len(t.rows) {
+			b.WriteString("\n")
+			continue
+		}
+		i := t.rows[n]
+		selected := n == st.cursor
+
+		if selected {
+			b.WriteString("\033[7m>")
+		} else {
+			b.WriteString(watchMark(v.Symbol(i)))
+		}
+		for _, col := range v.Columns {
+			cell := padCell(col.Text(i), col.Width)
+			if col.Color != nil && !selected {
+				cell = col.Color(i) + cell + "\033[0m"
+			}
+			b.WriteString(cell)
+		}
+		if selected {
+			b.WriteString("\033[0m")
+		}
+		b.WriteString("\n")
+	}
+
+	if len(detail) > 0 {
+		b.WriteString(strings.Repeat("-", t.width) + "\n")
+		for _, line := range detail {
+			b.WriteString(" " + line + "\n")
+		}
+	}
+
+	for _, note := range t.notes {
+		b.WriteString(" " + note + "\n")
+	}
+	b.WriteString(strings.Repeat("-", t.width) + "\n")
+	if t.searching {
+		b.WriteString(" Ketik kode saham, [Enter] selesai, [Esc] hapus")
+	} else {
+		help := " Tab tabel  j/k gerak  </> urut  s balik  / cari  f sektor  d detail  c grafik  r scan  m menu  q keluar"
+		if t.views[t.active].Chart == nil {
+			help = strings.Replace(help, "  c grafik", "", 1)
+		}
+		b.WriteString(help)
+	}
+
+	fmt.Print(b.String())
 }
 
-func printAllStocks(stocks []StockData) {
-	printHeader()
-	fmt.Println("                           DATA SEMUA SAHAM")
-	fmt.Println(strings.Repeat("-", 95))
+func (t *tui) run() (string, error) {
+	t.enterRaw()
+	defer t.leaveRaw()
 
-	fmt.Printf(" %-7s %-18s %-10s %-7s %-10s %-10s %-12s %-6s\n",
-		"KODE", "NAMA", "HARGA", "CHG%", "FB", "FS", "NET VALUE", "SCORE")
-	fmt.Println(strings.Repeat("-", 95))
+	for {
+		t.filter()
+		height := terminalHeight()
+		t.render(height)
 
-	for _, s := range stocks {
-		chgColor := "\033[32m"
-		if s.ChangePercent < 0 {
-			chgColor = "\033[31m"
+		key, err := t.readKey()
+		if err != nil {
+			return "q", err
 		}
 
-		name := s.Name
-		if len(name) > 16 {
-			name = name[:16]
+		st := &t.states[t.active]
+		page := height - 10
+
+		if t.searching {
+			switch {
+			case key == '\n' || key == '\r':
+				t.searching = false
+			case key == 27:
+				t.search = ""
+				t.searching = false
+			case key == 127 || key == 8:
+				if len(t.search) > 0 {
+					t.search = t.search[:len(t.search)-1]
+				}
+			case key >= '0' && key <= '9' || key >= 'a' && key <= 'z' || key >= 'A' && key <= 'Z':
+				t.search += strings.ToUpper(string(rune(key)))
+				st.cursor = 0
+			}
+			continue
 		}
 
-		fmt.Printf(" %-7s %-18s Rp%-8.0f %s%-6.1f%%\033[0m %-10s %-10s %-12s %-6.0f\n",
-			s.Symbol,
-			name,
-			s.ClosePrice,
-			chgColor,
-			s.ChangePercent,
-			formatVolume(s.ForeignBuy),
-			formatVolume(s.ForeignSell),
-			formatMoney(s.NetForeignValue),
-			s.Score)
+		switch key {
+		case 'q', 'Q':
+			return "q", nil
+		case 'r', 'R':
+			return "r", nil
+		case 'm', 'M':
+			return "m", nil
+		case '\t':
+			t.active = (t.active + 1) % len(t.views)
+		case keyDown, 'j':
+			st.cursor++
+		case keyUp, 'k':
+			st.cursor--
+		case keyPgDn, ' ':
+			st.cursor += page
+		case keyPgUp, 'b':
+			st.cursor -= page
+		case keyHome, 'g':
+			st.cursor = 0
+		case keyEnd, 'G':
+			st.cursor = len(t.rows) - 1
+		case keyRight, '>', '.':
+			st.sortCol = (st.sortCol + 1) % len(t.views[t.active].Columns)
+			st.desc = t.views[t.active].Columns[st.sortCol].Num != nil
+		case keyLeft, '<', ',':
+			if st.sortCol <= 0 {
+				st.sortCol = len(t.views[t.active].Columns)
+			}
+			st.sortCol--
+			st.desc = t.views[t.active].Columns[st.sortCol].Num != nil
+		case 's':
+			st.desc = !st.desc
+		case '/':
+			t.searching = true
+		case 'f':
+			t.sector = (t.sector + 1) % (len(t.sectors) + 1)
+			st.cursor = 0
+		case 'd', '\r':
+			t.detail = !t.detail
+		case 'c', 'C':
+			if v := t.views[t.active]; v.Chart != nil && len(t.rows) > 0 {
+				fmt.Print("\033[H\033[2J" + strings.Join(v.Chart(t.rows[st.cursor]), "\n") + "\n\n Tekan tombol apa saja...")
+				if _, err := t.readKey(); err != nil {
+					return "q", err
+				}
+			}
+		case '\n':
+			if t.restore != "" {
+				t.detail = !t.detail
+			}
+		}
 	}
-	fmt.Println()
 }
 
-func printGuide() {
-	printHeader()
-	fmt.Println("                         PANDUAN NET FOREIGN BUY")
-	fmt.Println(strings.Repeat("-", 95))
-	fmt.Println()
-	fmt.Println(" APA ITU NET FOREIGN BUY?")
-	fmt.Println(" Net Foreign Buy adalah selisih antara pembelian dan penjualan")
-	fmt.Println(" oleh investor asing. Jika positif, berarti asing lebih banyak")
-	fmt.Println(" membeli (akumulasi). Jika negatif, berarti asing lebih banyak")
-	fmt.Println(" menjual (distribusi).")
-	fmt.Println()
-	fmt.Println(" MENGAPA PENTING?")
-	fmt.Println(" - Investor asing memiliki riset dan analisa mendalam")
-	fmt.Println(" - Akumulasi asing sering mendahului kenaikan harga")
-	fmt.Println(" - Distribusi asing bisa menjadi sinyal peringatan")
-	fmt.Println()
-	fmt.Println(" KRITERIA SCREENING:")
-	fmt.Println(" - Net FB > 0 dengan nilai signifikan (> 1 Miliar)")
-	fmt.Println(" - Foreign Percentage > 25% dari total volume")
-	fmt.Println(" - Akumulasi berhari-hari (Accumulation Days > 3)")
-	fmt.Println(" - Harga masih dalam tren naik moderat")
-	fmt.Println()
-	fmt.Println(" SIGNAL:")
-	fmt.Println(" STRONG BUY  = Score >= 70, akumulasi sangat kuat")
-	fmt.Println(" BUY         = Score >= 55, akumulasi cukup kuat")
-	fmt.Println(" ACCUMULATE  = Score >= 40, mulai ada akumulasi")
-	fmt.Println()
-	fmt.Println(" PERINGATAN:")
-	fmt.Println(" - Selalu kombinasikan dengan analisa teknikal")
-	fmt.Println(" - Net foreign buy bukan jaminan harga naik")
-	fmt.Println(" - Perhatikan juga kondisi market secara keseluruhan")
-	fmt.Println()
+func changeColor(v float64) string {
+	if v < 0 {
+		return "\033[31m"
+	}
+	return "\033[32m"
 }
 
-func printMenu() {
+func breakdownLine(components []ScoreComponent) string {
+	var parts []string
+	for _, c := range components {
+		parts = append(parts, fmt.Sprintf("%s %.0f/%.0f", c.Factor, c.Points, c.Max))
+	}
+	return strings.Join(parts, "  ")
+}
+
+func stockDetail(s StockData) []string {
+	lines := []string{
+		fmt.Sprintf("\033[1m%s\033[0m %s (%s)  Harga Rp%.0f  Chg %+.2f%%  Vol %s  Asing %.1f%%",
+			s.Symbol, s.Name, s.Sector, s.ClosePrice, s.ChangePercent, formatVolume(s.Volume), s.ForeignPercent),
+		fmt.Sprintf("Net RG %s  NG %s  TN %s lot  NG share %.0f%%  Akumulasi %d hari  Kepemilikan asing %.2f%% (MoM %+.2f)",
+			formatVolume(netFlow(s.Regular)), formatVolume(netFlow(s.Negotiated)), formatVolume(netFlow(s.Cash)),
+			s.NGShare, s.Accumulation, s.Ownership.ForeignPct, s.Ownership.ChangeMoM),
+		fmt.Sprintf("Z net %.2f  Persentil %.0f  Z asing%% %.2f  (rata-rata %d hari %s)",
+			s.Flow.NetValueZ, s.Flow.NetValuePctl, s.Flow.ForeignPctZ, flowWindow, formatMoney(s.Flow.NetValueMean)),
+		fmt.Sprintf("Score %.0f: %s", s.Score, breakdownLine(scoreBreakdown(s))),
+	}
+	if s.Fundamental != nil {
+		lines = append(lines, fundamentalLines(s.Fundamental)...)
+	}
+	return lines
+}
+
+type chartStyle struct {
+	unicode bool
+	color   bool
+}
+
+func detectChartStyle(forceASCII bool) chartStyle {
+	if forceASCII {
+		return chartStyle{}
+	}
+
+	locale := strings.ToUpper(os.Getenv("LC_ALL") + os.Getenv("LC_CTYPE") + os.Getenv("LANG"))
+	return chartStyle{
+		unicode: strings.Contains(locale, "UTF-8") || strings.Contains(locale, "UTF8") || os.Getenv("WT_SESSION") != "",
+		color:   os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb",
+	}
+}
+
+func sparkline(values []float64, st chartStyle) string {
+	ramp := []rune("_.-~=^*#")
+	if st.unicode {
+		ramp = []rune("▁▂▃▄▅▆▇█")
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+
+	out := make([]rune, len(values))
+	for i, v := range values {
+		idx := 0
+		if hi > lo {
+			idx = int((v - lo) / (hi - lo) * float64(len(ramp)-1))
+		}
+		out[i] = ramp[idx]
+	}
+	return string(out)
+}
+
+func flowSpark(s StockData, st chartStyle) string {
+	history := s.FlowHistory
+	if len(history) > 12 {
+		history = history[len(history)-12:]
+	}
+	values := make([]float64, len(history))
+	for i, d := range history {
+		values[i] = d.NetForeignValue
+	}
+	return sparkline(values, st)
+}
+
+func resultView(name string, results []ScanResult, bySymbol map[string]StockData, scored bool, st chartStyle) tuiView {
+	rank := tuiColumn{Title: "SCORE", Width: 6, Text: func(i int) string { return fmt.Sprintf("%.0f", results[i].Score) },
+		Num: func(i int) float64 { return results[i].Score }}
+	if !scored {
+		rank = tuiColumn{Title: "RATE", Width: 6, Text: func(i int) string { return getStars(results[i].Strength) },
+			Num: func(i int) float64 { return float64(results[i].Strength) }}
+	}
+
+	return tuiView{
+		Name: name,
+		Len:  len(results),
+		Columns: []tuiColumn{
+			{Title: "KODE", Width: 7, Text: func(i int) string { return results[i].Symbol }},
+			{Title: "NAMA", Width: 20, Text: func(i int) string { return results[i].Name }},
+			{Title: "HARGA", Width: 9, Text: func(i int) string { return fmt.Sprintf("Rp%.0f", results[i].Price) },
+				Num: func(i int) float64 { return results[i].Price }},
+			{Title: "CHG%", Width: 7, Text: func(i int) string { return fmt.Sprintf("%.1f%%", results[i].Change) },
+				Num: func(i int) float64 { return results[i].Change }, Color: func(i int) string { return changeColor(results[i].Change) }},
+			{Title: "NET FB", Width: 9, Text: func(i int) string { return formatVolume(results[i].NetForeignBuy) },
+				Num: func(i int) float64 { return float64(results[i].NetForeignBuy) }},
+			{Title: "VALUE", Width: 13, Text: func(i int) string { return formatMoney(results[i].NetForeignValue) },
+				Num: func(i int) float64 { return results[i].NetForeignValue }},
+			{Title: "F%", Width: 6, Text: func(i int) string { return fmt.Sprintf("%.0f%%", results[i].ForeignPercent) },
+				Num: func(i int) float64 { return results[i].ForeignPercent }},
+			{Title: "ACC", Width: 5, Text: func(i int) string { return strconv.Itoa(results[i].Accumulation) },
+				Num: func(i int) float64 { return float64(results[i].Accumulation) }},
+			rank,
+			{Title: "SIGNAL", Width: 12, Text: func(i int) string { return results[i].Signal }},
+			{Title: "FLOW", Width: 14, Text: func(i int) string { return flowSpark(bySymbol[results[i].Symbol], st) }},
+		},
+		Symbol: func(i int) string { return results[i].Symbol },
+		Sector: func(i int) string { return results[i].Sector },
+		Detail: func(i int) []string { return stockDetail(bySymbol[results[i].Symbol]) },
+	}
+}
+
+func stockViews(stocks []StockData, buyResults, sellResults []ScanResult, st chartStyle) []tuiView {
+	bySymbol := make(map[string]StockData)
+	for _, s := range stocks {
+		bySymbol[s.Symbol] = s
+	}
+
+	all := tuiView{
+		Name: "SEMUA",
+		Len:  len(stocks),
+		Columns: []tuiColumn{
+			{Title: "KODE", Width: 7, Text: func(i int) string { return stocks[i].Symbol }},
+			{Title: "NAMA", Width: 20, Text: func(i int) string { return stocks[i].Name }},
+			{Title: "HARGA", Width: 9, Text: func(i int) string { return fmt.Sprintf("Rp%.0f", stocks[i].ClosePrice) },
+				Num: func(i int) float64 { return stocks[i].ClosePrice }},
+			{Title: "CHG%", Width: 7, Text: func(i int) string { return fmt.Sprintf("%.1f%%", stocks[i].ChangePercent) },
+				Num: func(i int) float64 { return stocks[i].ChangePercent }, Color: func(i int) string { return changeColor(stocks[i].ChangePercent) }},
+			{Title: "NET FB", Width: 9, Text: func(i int) string { return formatVolume(stocks[i].NetForeignBuy) },
+				Num: func(i int) float64 { return float64(stocks[i].NetForeignBuy) }},
+			{Title: "VALUE", Width: 13, Text: func(i int) string { return formatMoney(stocks[i].NetForeignValue) },
+				Num: func(i int) float64 { return stocks[i].NetForeignValue }},
+			{Title: "Z", Width: 6, Text: func(i int) string { return fmt.Sprintf("%.1f", stocks[i].Flow.NetValueZ) },
+				Num: func(i int) float64 { return stocks[i].Flow.NetValueZ }},
+			{Title: "NG%", Width: 5, Text: func(i int) string { return fmt.Sprintf("%.0f", stocks[i].NGShare) },
+				Num: func(i int) float64 { return stocks[i].NGShare }},
+			{Title: "ASING%", Width: 7, Text: func(i int) string { return fmt.Sprintf("%.1f", stocks[i].Ownership.ForeignPct) },
+				Num: func(i int) float64 { return stocks[i].Ownership.ForeignPct }},
+			{Title: "SCORE", Width: 6, Text: func(i int) string { return fmt.Sprintf("%.0f", stocks[i].Score) },
+				Num: func(i int) float64 { return stocks[i].Score }},
+			{Title: "FLOW", Width: 14, Text: func(i int) string { return flowSpark(stocks[i], st) }},
+		},
+		Symbol: func(i int) string { return stocks[i].Symbol },
+		Sector: func(i int) string { return stocks[i].Sector },
+		Detail: func(i int) []string { return stockDetail(stocks[i]) },
+	}
+
+	return []tuiView{
+		resultView("BUY", buyResults, bySymbol, true, st),
+		resultView("SELL", sellResults, bySymbol, false, st),
+		all,
+	}
+}
+
+type replayFile struct {
+	Meta    *ScanMeta       `json:"meta"`
+	Results json.RawMessage `json:"results"`
+}
+
+func compareResults(scan string, want, got []ScanResult) []string {
+	var diffs []string
+	for i := 0; i < len(want) || i < len(got); i++ {
+		switch {
+		case i >= len(got):
+			diffs = append(diffs, fmt.Sprintf("%s #%d: %s tidak muncul lagi", scan, i+1, want[i].Symbol))
+		case i >= len(want):
+			diffs = append(diffs, fmt.Sprintf("%s #%d: %s tambahan", scan, i+1, got[i].Symbol))
+		case want[i] != got[i]:
+			diffs = append(diffs, fmt.Sprintf("%s #%d: %s score %.0f kekuatan %d %s, replay %s score %.0f kekuatan %d %s", scan, i+1,
+				want[i].Symbol, want[i].Score, want[i].Strength, want[i].Signal, got[i].Symbol, got[i].Score, got[i].Strength, got[i].Signal))
+		}
+	}
+	return diffs
+}
+
+func replaySnapshot(meta ScanMeta) (scanSnapshot, error) {
+	if _, err := time.Parse("2006-01-02", meta.AsOf); err != nil {
+		return scanSnapshot{}, fmt.Errorf("tanggal %q tidak valid, format YYYY-MM-DD", meta.AsOf)
+	}
+	useAllMarkets = meta.AllMarket
+	fundamentalRules = FundamentalRules{}
+	if meta.FundamentalRules != nil {
+		fundamentalRules = *meta.FundamentalRules
+	}
+
+	src, warnings := loadDataSources(meta.Broker, meta.KSEI, meta.Fundamentals)
+	if len(warnings) > 0 {
+		return scanSnapshot{}, errors.New(strings.Join(warnings, "; "))
+	}
+	src.meta = meta
+	src.seeds = newSeedSource(meta.Seed, true)
+	return buildSnapshot(src), nil
+}
+
+func runReplayCommand(base ScanMeta, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	seed := fs.Int64("seed", 0, "seed scan yang diulang (lihat header scan atau field meta di respons API)")
+	asOf := fs.String("as-of", time.Now().Format("2006-01-02"), "tanggal scan yang diulang (YYYY-MM-DD)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	meta := base
+	meta.Seed, meta.AsOf = *seed, *asOf
+	var saved *replayFile
+	if fs.NArg() > 0 {
+		data, err := os.ReadFile(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		saved = &replayFile{}
+		if err := json.Unmarshal(data, saved); err != nil {
+			return fmt.Errorf("%s: %v", fs.Arg(0), err)
+		}
+		if saved.Meta == nil {
+			return fmt.Errorf("%s tidak berisi metadata scan", fs.Arg(0))
+		}
+		meta = *saved.Meta
+	} else if !flagPassed(fs, "seed") {
+		return errors.New("isi -seed atau berikan file JSON respons API yang berisi meta")
+	}
+
+	snap, err := replaySnapshot(meta)
+	if err != nil {
+		return err
+	}
+	stocks := scopeStocks(snap.Stocks, meta.scope())
+	buy, sell := scanNetForeignBuy(stocks), scanNetForeignSell(stocks)
+
+	fmt.Printf("\033[1;33m REPLAY SCAN\033[0m  seed %d, %d saham", meta.Seed, len(stocks))
+	if meta.AsOf != "" {
+		fmt.Printf(", scan asli %s", meta.AsOf)
+	}
+	if meta.Watchlist != "" {
+		fmt.Printf(", watchlist %s", meta.Watchlist)
+	}
+	if meta.Broker != "" {
+		fmt.Printf(", broker %s", meta.Broker)
+	}
+	if meta.KSEI != "" {
+		fmt.Printf(", KSEI %s", meta.KSEI)
+	}
+	if meta.Fundamentals != "" {
+		fmt.Printf(", fundamental %s", meta.Fundamentals)
+	}
 	fmt.Println()
-	fmt.Println(" MENU:")
-	fmt.Println(" [1] Scan Ulang")
-	fmt.Println(" [2] Lihat Semua Saham")
-	fmt.Println(" [3] Panduan")
-	fmt.Println(" [4] Keluar")
 	fmt.Println()
-	fmt.Print(" Pilihan: ")
+	printNetForeignBuy(buy)
+	printNetForeignSell(sell)
+
+	if saved == nil {
+		return nil
+	}
+	var got []ScanResult
+	switch meta.Scan {
+	case "buy":
+		got = buy
+	case "sell":
+		got = sell
+	default:
+		fmt.Println(" File bukan hasil scan buy/sell, perbandingan dilewati.")
+		return nil
+	}
+	var want []ScanResult
+	if err := json.Unmarshal(saved.Results, &want); err != nil {
+		return fmt.Errorf("%s: %v", fs.Arg(0), err)
+	}
+	if meta.Filter != nil {
+		got = filterResults(got, *meta.Filter)
+	}
+
+	diffs := compareResults(strings.ToUpper(meta.Scan), want, got)
+	if len(diffs) == 0 {
+		fmt.Printf(" \033[32mHasil identik dengan %s.\033[0m\n", fs.Arg(0))
+		return nil
+	}
+	fmt.Printf(" \033[31mHasil berbeda dengan %s:\033[0m\n", fs.Arg(0))
+	for _, d := range diffs {
+		fmt.Printf("  - %s\n", d)
+	}
+	return fmt.Errorf("%d perbedaan", len(diffs))
 }
 
 func main() {
-	rand.Seed(time.Now().UnixNano())
+	brokerFile := flag.String("broker", "", "file CSV broker summary harian (symbol,broker,buy_lot,buy_avg,sell_lot,sell_avg)")
+	kseiFiles := flag.String("ksei", "", "pola file CSV komposisi kepemilikan KSEI bulanan, contoh data/ksei_*.csv")
+	serveAddr := flag.String("serve", "", "jalankan REST API di alamat ini (contoh :8081) alih-alih menu interaktif")
+	refresh := flag.Duration("refresh", 5*time.Minute, "interval scan ulang data pada mode server (0 = tidak pernah)")
+	flag.BoolVar(&useAllMarkets, "allmarket", false, "hitung score dari flow semua pasar (RG+NG+TN), default hanya pasar reguler")
+	watchlistFile := flag.String("watchlists", "watchlists.json", "file watchlist (dikelola lewat emiten_scanner watchlist)")
+	profile := flag.String("profile", defaultProfile(), "profil watchlist (default env IDX_PROFILE atau \"default\")")
+	watchlist := flag.String("watchlist", "", "batasi scan hanya ke saham di watchlist ini")
+	classic := flag.Bool("classic", false, "pakai tampilan lama (cetak tabel + menu angka) alih-alih TUI")
+	ascii := flag.Bool("ascii", false, "gambar sparkline dengan ASCII polos tanpa Unicode")
+	seed := flag.Int64("seed", 0, "seed data simulasi untuk scan pertama; scan berikutnya diturunkan dari seed ini (tanpa flag ini = acak)")
+	fundamentalFile := flag.String("fundamentals", "", "file CSV ringkasan laporan keuangan kuartalan (symbol,period,revenue,net_income,equity,liabilities,shares)")
+	fundamentalRuleFile := flag.String("fundamental-rules", "", "file JSON filter dan bonus score fundamental (PER, PBV, ROE, DER, pertumbuhan EPS, kapitalisasi)")
+	flag.Parse()
+
+	if *fundamentalRuleFile != "" {
+		rules, err := loadFundamentalRules(*fundamentalRuleFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fundamentalRules = rules
+	}
 
-	for {
-		stocks := generateStockData()
+	src, warnings := loadDataSources(*brokerFile, *kseiFiles, *fundamentalFile)
+	src.seeds = newSeedSource(*seed, flagPassed(flag.CommandLine, "seed"))
 
-		printHeader()
+	watchlists, err := loadWatchlists(*watchlistFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	src.watchlists = watchlists
+	src.profile = *profile
+	for _, list := range watchlists[*profile] {
+		for _, sym := range list {
+			watchedSymbols[sym] = true
+		}
+	}
 
-		buyResults := scanNetForeignBuy(stocks)
-		sellResults := scanNetForeignSell(stocks)
+	var scope []string
+	if *watchlist != "" {
+		list, ok := watchlists[*profile][*watchlist]
+		if !ok {
+			log.Fatalf("watchlist %q tidak ada di profil %q", *watchlist, *profile)
+		}
+		scope = list
+	}
+
+	if flag.Arg(0) == "replay" {
+		meta := src.meta
+		meta.AllMarket = useAllMarkets
+		if fundamentalRules != (FundamentalRules{}) {
+			rules := fundamentalRules
+			meta.FundamentalRules = &rules
+		}
+		if *watchlist != "" {
+			meta.Profile, meta.Watchlist, meta.Symbols = *profile, *watchlist, scope
+		}
+		if err := runReplayCommand(meta, flag.Args()[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *serveAddr != "" {
+		for _, w := range warnings {
+			log.Println(w)
+		}
+		if err := runServer(*serveAddr, src, *refresh); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(warnings) > 0 {
+		for _, w := range warnings {
+			fmt.Printf(" %s\n", w)
+		}
+		fmt.Println(" Memakai data simulasi. Tekan Enter...")
+		readLine()
+	}
 
-		printNetForeignBuy(buyResults)
-		printNetForeignSell(sellResults)
+	useTUI := !*classic && isTerminal(os.Stdin) && isTerminal(os.Stdout) && rawModeAvailable()
+
+	for {
+		snap := buildSnapshot(src)
+		meta := snap.Meta
+		if *watchlist != "" {
+			meta.Profile, meta.Watchlist, meta.Symbols = *profile, *watchlist, scope
+		}
+		lastMeta = &meta
+		stocks := scopeStocks(snap.Stocks, scope)
 
-		printMenu()
+		buyResults := scanNetForeignBuy(stocks)
+		sellResults := scanNetForeignSell(stocks)
 
 		var choice string
-		fmt.Scanln(&choice)
+		if useTUI {
+			action, _ := newTUI(fmt.Sprintf("NET FOREIGN SCANNER  seed %d", snap.Meta.Seed), 110, stockViews(stocks, buyResults, sellResults, detectChartStyle(*ascii)), stdin).run()
+			switch action {
+			case "r":
+				choice = "1"
+			case "q":
+				choice = "7"
+			default:
+				printHeader()
+				printMenu()
+				choice = readLine()
+			}
+		} else {
+			printHeader()
+			printNetForeignBuy(buyResults)
+			printNetForeignSell(sellResults)
+			printMenu()
+			choice = readLine()
+		}
 
 		switch choice {
 		case "1":
@@ -491,12 +3444,25 @@ func main() {
 		case "2":
 			printAllStocks(stocks)
 			fmt.Println(" Tekan Enter...")
-			fmt.Scanln()
+			readLine()
 		case "3":
 			printGuide()
 			fmt.Println(" Tekan Enter...")
-			fmt.Scanln()
-		case "4", "q", "Q":
+			readLine()
+		case "4":
+			printHeader()
+			printSectorFlow(snap.Sectors, snap.IHSG)
+			fmt.Println(" Tekan Enter...")
+			readLine()
+		case "5":
+			printBandarAccumulation(scanBandarAccumulation(stocks, snap.Brokers), snap.BrokerSource)
+			fmt.Println(" Tekan Enter...")
+			readLine()
+		case "6":
+			printUnusualForeign(scanUnusualForeign(stocks))
+			fmt.Println(" Tekan Enter...")
+			readLine()
+		case "7", "q", "Q":
 			fmt.Println()
 			fmt.Println(" Terima kasih!")
 			fmt.Println(" Selamat berinvestasi.")
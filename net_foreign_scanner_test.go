@@ -0,0 +1,1198 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+var update = flag.Bool("update", false, "tulis ulang file golden di testdata")
+
+func captureStdout(t *testing.T, fn func()) []byte {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- data
+	}()
+
+	defer func() { os.Stdout = stdout }()
+	fn()
+	w.Close()
+	return <-done
+}
+
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", "foreign", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (jalankan dengan -update untuk membuat file golden)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output %s berbeda dari %s\n--- got ---\n%s\n--- want ---\n%s", name, path, got, want)
+	}
+}
+
+func TestCalculateScoreThresholds(t *testing.T) {
+	type input struct {
+		netFB         int64
+		flow          FlowStats
+		accum         int
+		change, bonus float64
+	}
+
+	tests := []struct {
+		name string
+		in   input
+		want float64
+	}{
+		{"netral", input{}, 0},
+		{"net foreign positif", input{netFB: 1}, 20},
+		{"z tanpa net positif diabaikan", input{netFB: -1, flow: FlowStats{NetValueZ: 3}}, 0},
+		{"z tepat 1", input{netFB: 1, flow: FlowStats{NetValueZ: 1}}, 20},
+		{"z di atas 1", input{netFB: 1, flow: FlowStats{NetValueZ: 1.1}}, 30},
+		{"z tepat 2", input{netFB: 1, flow: FlowStats{NetValueZ: 2}}, 30},
+		{"z di atas 2", input{netFB: 1, flow: FlowStats{NetValueZ: 2.1}}, 35},
+		{"persentil di bawah 60", input{flow: FlowStats{NetValuePctl: 59.9}}, 0},
+		{"persentil tepat 60", input{flow: FlowStats{NetValuePctl: 60}}, 10},
+		{"persentil di bawah 80", input{flow: FlowStats{NetValuePctl: 79.9}}, 10},
+		{"persentil tepat 80", input{flow: FlowStats{NetValuePctl: 80}}, 15},
+		{"persentil di bawah 95", input{flow: FlowStats{NetValuePctl: 94.9}}, 15},
+		{"persentil tepat 95", input{flow: FlowStats{NetValuePctl: 95}}, 20},
+		{"foreign% z tepat 0.5", input{flow: FlowStats{ForeignPctZ: 0.5}}, 0},
+		{"foreign% z di atas 0.5", input{flow: FlowStats{ForeignPctZ: 0.6}}, 10},
+		{"foreign% z tepat 1.5", input{flow: FlowStats{ForeignPctZ: 1.5}}, 10},
+		{"foreign% z di atas 1.5", input{flow: FlowStats{ForeignPctZ: 1.6}}, 15},
+		{"akumulasi nol", input{accum: 0}, 0},
+		{"akumulasi 1", input{accum: 1}, 8},
+		{"akumulasi 3", input{accum: 3}, 8},
+		{"akumulasi 4", input{accum: 4}, 15},
+		{"change nol", input{change: 0}, 0},
+		{"change positif kecil", input{change: 0.1}, 10},
+		{"change di bawah 3", input{change: 2.9}, 10},
+		{"change tepat 3", input{change: 3}, 0},
+		{"bonus sektor", input{bonus: 6}, 6},
+		{"bonus negatif dipotong ke 0", input{bonus: -10}, 0},
+		{"semua maksimal dipotong ke 100", input{netFB: 1, flow: FlowStats{NetValueZ: 3, NetValuePctl: 99, ForeignPctZ: 2}, accum: 5, change: 1, bonus: 10}, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := calculateScore(tt.in.netFB, tt.in.flow, tt.in.accum, tt.in.change, tt.in.bonus)
+			if got != tt.want {
+				t.Errorf("calculateScore = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func sellStock(symbol string, netFB int64) StockData {
+	regular := MarketFlow{ForeignBuy: 20000000, ForeignSell: 20000000 - netFB}
+	return StockData{
+		Symbol:          symbol,
+		Name:            symbol,
+		Sector:          "Banking",
+		ClosePrice:      1000,
+		Regular:         regular,
+		NetForeignBuy:   netFB,
+		NetForeignValue: float64(netFB) * 1000,
+	}
+}
+
+func TestScanNetForeignSellTiers(t *testing.T) {
+	tests := []struct {
+		netFB    int64
+		strength int
+		signal   string
+	}{
+		{-500000, 0, ""},
+		{-500001, 1, "DISTRIBUTE"},
+		{-1000000, 1, "DISTRIBUTE"},
+		{-1000001, 2, "DISTRIBUTE"},
+		{-2000000, 2, "DISTRIBUTE"},
+		{-2000001, 3, "SELL"},
+		{-5000000, 3, "SELL"},
+		{-5000001, 4, "STRONG SELL"},
+		{-10000000, 4, "STRONG SELL"},
+		{-10000001, 5, "STRONG SELL"},
+	}
+
+	for _, tt := range tests {
+		results := scanNetForeignSell([]StockData{sellStock("TEST", tt.netFB)})
+		if tt.strength == 0 {
+			if len(results) != 0 {
+				t.Errorf("net %d: seharusnya tidak lolos, dapat %+v", tt.netFB, results[0])
+			}
+			continue
+		}
+		if len(results) != 1 {
+			t.Fatalf("net %d: dapat %d hasil, want 1", tt.netFB, len(results))
+		}
+		r := results[0]
+		if r.Strength != tt.strength || r.Signal != tt.signal || r.Score != 0 {
+			t.Errorf("net %d: strength %d signal %q score %v, want %d %q tanpa score",
+				tt.netFB, r.Strength, r.Signal, r.Score, tt.strength, tt.signal)
+		}
+	}
+}
+
+func TestScoreRangeProperty(t *testing.T) {
+	cfg := &quick.Config{MaxCount: 2000, Rand: rand.New(rand.NewSource(1))}
+
+	score := func(netFB int64, z, pctl, fz float64, accum int, change, bonus float64) bool {
+		s := calculateScore(netFB, FlowStats{NetValueZ: z, NetValuePctl: pctl, ForeignPctZ: fz}, accum, change, bonus)
+		return s >= 0 && s <= 100
+	}
+	if err := quick.Check(score, cfg); err != nil {
+		t.Errorf("score di luar 0-100: %v", err)
+	}
+}
+
+func TestScanResultsProperty(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+
+	for round := 0; round < 20; round++ {
+		stocks := generateStockData(rng, generateIHSG(rng), time.Now())
+
+		buy := scanNetForeignBuy(stocks)
+		if !sort.SliceIsSorted(buy, func(i, j int) bool { return buy[i].NetForeignValue > buy[j].NetForeignValue }) {
+			t.Fatalf("putaran %d: hasil buy tidak urut menurun", round)
+		}
+		for _, r := range buy {
+			if r.NetForeignBuy <= 0 || r.Score < 40 || r.Score > 100 {
+				t.Fatalf("putaran %d: buy %s net %d score %v tidak memenuhi kriteria", round, r.Symbol, r.NetForeignBuy, r.Score)
+			}
+		}
+
+		sell := scanNetForeignSell(stocks)
+		if !sort.SliceIsSorted(sell, func(i, j int) bool { return sell[i].NetForeignValue < sell[j].NetForeignValue }) {
+			t.Fatalf("putaran %d: hasil sell tidak urut dari jual terbesar", round)
+		}
+		sold := 0
+		for _, s := range stocks {
+			if net, _ := scoringFlow(s); net < -500000 {
+				sold++
+			}
+		}
+		if len(sell) != sold {
+			t.Fatalf("putaran %d: %d hasil sell, want %d saham dengan net < -500000", round, len(sell), sold)
+		}
+		for _, r := range sell {
+			if r.NetForeignBuy >= -500000 {
+				t.Fatalf("putaran %d: sell %s net %d tidak di bawah -500000", round, r.Symbol, r.NetForeignBuy)
+			}
+		}
+	}
+}
+
+func TestFormatVolume(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{-999, "-999"},
+		{1000, "1.0K"},
+		{-1500, "-1.5K"},
+		{999900, "999.9K"},
+		{1000000, "1.0M"},
+		{-25400000, "-25.4M"},
+		{1000000000, "1000.0M"},
+	}
+
+	for _, tt := range tests {
+		if got := formatVolume(tt.in); got != tt.want {
+			t.Errorf("formatVolume(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatMoney(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want string
+	}{
+		{0, "Rp 0"},
+		{999999, "Rp 999999"},
+		{-999999, "-Rp 999999"},
+		{1000000, "Rp 1.0M"},
+		{-1000000, "-Rp 1.0M"},
+		{999900000, "Rp 999.9M"},
+		{1000000000, "Rp 1.0B"},
+		{-2500000000, "-Rp 2.5B"},
+		{999900000000, "Rp 999.9B"},
+		{1000000000000, "Rp 1.0T"},
+		{-1500000000000, "-Rp 1.5T"},
+	}
+
+	for _, tt := range tests {
+		if got := formatMoney(tt.in); got != tt.want {
+			t.Errorf("formatMoney(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func fixedStocks() []StockData {
+	stocks := []StockData{
+		{Symbol: "BBCA", Name: "Bank Central Asia", Sector: "Banking", ClosePrice: 9850, ChangePercent: 1.2, ForeignPercent: 48.5, Accumulation: 5,
+			Regular: MarketFlow{ForeignBuy: 18000000, ForeignSell: 9000000}, Flow: FlowStats{NetValueZ: 2.4, NetValuePctl: 97, ForeignPctZ: 1.8}},
+		{Symbol: "BMRI", Name: "Bank Mandiri", Sector: "Banking", ClosePrice: 6200, ChangePercent: 0.8, ForeignPercent: 41.2, Accumulation: 2,
+			Regular: MarketFlow{ForeignBuy: 12000000, ForeignSell: 10500000}, Flow: FlowStats{NetValueZ: 1.2, NetValuePctl: 82, ForeignPctZ: 0.7},
+			Negotiated: MarketFlow{ForeignBuy: 4000000}, NGDominant: true},
+		{Symbol: "TLKM", Name: "Telkom Indonesia", Sector: "Telco", ClosePrice: 3870, ChangePercent: -1.5, ForeignPercent: 35.9, Accumulation: -2,
+			Regular: MarketFlow{ForeignBuy: 6000000, ForeignSell: 17500000}, Flow: FlowStats{NetValueZ: -2.1, NetValuePctl: 3}},
+		{Symbol: "ANTM", Name: "Aneka Tambang", Sector: "Mining", ClosePrice: 1525, ChangePercent: -3.1, ForeignPercent: 22.4, Accumulation: -3,
+			Regular: MarketFlow{ForeignBuy: 4000000, ForeignSell: 7200000}, Flow: FlowStats{NetValueZ: -1.1, NetValuePctl: 12}},
+		{Symbol: "UNVR", Name: "Unilever Indonesia", Sector: "Consumer", ClosePrice: 2650, ChangePercent: 0.1, ForeignPercent: 18.3,
+			Regular: MarketFlow{ForeignBuy: 3000000, ForeignSell: 3300000}, Flow: FlowStats{NetValuePctl: 45}},
+	}
+	for i := range stocks {
+		s := &stocks[i]
+		s.NetForeignBuy = netFlow(s.Regular) + netFlow(s.Negotiated)
+		s.NetForeignValue = float64(s.NetForeignBuy) * s.ClosePrice
+	}
+	scoreStocks(stocks, nil)
+	return stocks
+}
+
+func TestGoldenTables(t *testing.T) {
+	stocks := fixedStocks()
+
+	checkGolden(t, "buy", captureStdout(t, func() { printNetForeignBuy(scanNetForeignBuy(stocks)) }))
+	checkGolden(t, "sell", captureStdout(t, func() { printNetForeignSell(scanNetForeignSell(stocks)) }))
+	checkGolden(t, "empty", captureStdout(t, func() {
+		printNetForeignBuy(nil)
+		printNetForeignSell(nil)
+	}))
+}
+
+func TestFundamentalFilterAndBonus(t *testing.T) {
+	defer func(rules FundamentalRules) { fundamentalRules = rules }(fundamentalRules)
+
+	store := &fundamentalStore{bySymbol: map[string][]FinancialQuarter{
+		"BBCA": {{Period: "2024Q2", NetIncome: 13e12, Equity: 250e12, Liabilities: 1150e12, Shares: 123e9}},
+		"BMRI": {{Period: "2024Q2", NetIncome: -1e12, Equity: 200e12, Liabilities: 1500e12, Shares: 93e9}},
+		"TLKM": {{Period: "2024Q2", NetIncome: -2e12, Equity: 150e12, Liabilities: 130e12, Shares: 99e9}},
+	}}
+	stocks := fixedStocks()
+	base := make(map[string]float64)
+	for i := range stocks {
+		base[stocks[i].Symbol] = stocks[i].Score
+		stocks[i].Fundamental = store.metrics(stocks[i].Symbol, stocks[i].ClosePrice)
+	}
+
+	fundamentalRules = FundamentalRules{
+		Filter:      FundamentalCriteria{ProfitOnly: true},
+		Bonus:       FundamentalCriteria{MinROE: 15},
+		BonusPoints: 5,
+	}
+	scoreStocks(stocks, nil)
+
+	var symbols []string
+	for _, r := range scanNetForeignBuy(stocks) {
+		symbols = append(symbols, r.Symbol)
+	}
+	if len(symbols) != 1 || symbols[0] != "BBCA" {
+		t.Errorf("buy = %v, want hanya BBCA (BMRI rugi tersaring)", symbols)
+	}
+	if got, want := stocks[0].Score, base["BBCA"]+5; got != want && got != 100 {
+		t.Errorf("score BBCA = %.0f, want %.0f", got, want)
+	}
+	symbols = nil
+	for _, r := range scanNetForeignSell(stocks) {
+		symbols = append(symbols, r.Symbol)
+	}
+	if len(symbols) != 1 || symbols[0] != "ANTM" {
+		t.Errorf("sell = %v, want hanya ANTM (TLKM rugi tersaring)", symbols)
+	}
+}
+
+func TestAPIHandlers(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	s := &apiServer{
+		src: dataSources{
+			profile:    "default",
+			watchlists: map[string]map[string][]string{"default": {"bank": {"BMRI", "TLKM"}, "kosong": {}}},
+		},
+		snap: scanSnapshot{
+			Stocks:      fixedStocks(),
+			GeneratedAt: time.Date(2024, 6, 3, 16, 0, 0, 0, time.UTC),
+			Meta:        ScanMeta{Seed: 42},
+		},
+	}
+	h := s.routes()
+
+	tests := []struct {
+		name    string
+		target  string
+		status  int
+		symbols []string
+	}{
+		{"buy", "/scan/foreign/buy", 200, []string{"BBCA", "BMRI"}},
+		{"buy min_score", "/scan/foreign/buy?min_score=80", 200, []string{"BBCA"}},
+		{"buy min_score bukan angka", "/scan/foreign/buy?min_score=x", 400, nil},
+		{"sell", "/scan/foreign/sell", 200, []string{"TLKM", "ANTM"}},
+		{"sell min_strength", "/scan/foreign/sell?min_strength=4", 200, []string{"TLKM"}},
+		{"sell min_strength di luar 1-5", "/scan/foreign/sell?min_strength=6", 400, nil},
+		{"sell min_score ditolak", "/scan/foreign/sell?min_score=40", 400, nil},
+		{"sector", "/scan/foreign/sell?sector=mining", 200, []string{"ANTM"}},
+		{"top", "/scan/foreign/buy?top=1", 200, []string{"BBCA"}},
+		{"top nol", "/scan/foreign/buy?top=0", 200, []string{}},
+		{"top negatif", "/scan/foreign/buy?top=-1", 400, nil},
+		{"date sama", "/scan/foreign/buy?date=2024-06-03", 200, []string{"BBCA", "BMRI"}},
+		{"date lain", "/scan/foreign/buy?date=2024-06-04", 404, nil},
+		{"date salah format", "/scan/foreign/buy?date=2024/06/03", 400, nil},
+		{"watchlist", "/scan/foreign/buy?watchlist=bank", 200, []string{"BMRI"}},
+		{"watchlist kosong", "/scan/foreign/sell?watchlist=kosong", 200, []string{}},
+		{"watchlist tidak ada", "/scan/foreign/buy?watchlist=tambang", 404, nil},
+		{"stock", "/foreign/stock/bbca", 200, nil},
+		{"stock tidak ada", "/foreign/stock/XXXX", 404, nil},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", tt.target, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, rec.Code, tt.status, rec.Body)
+			continue
+		}
+		if tt.symbols == nil {
+			continue
+		}
+
+		var resp struct {
+			Date    string
+			Seed    int64
+			Count   int
+			Results []ScanResult
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var got []string
+		for _, r := range resp.Results {
+			got = append(got, r.Symbol)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.symbols) || resp.Count != len(tt.symbols) {
+			t.Errorf("%s: hasil %v (count %d), want %v", tt.name, got, resp.Count, tt.symbols)
+		}
+		if resp.Date != "2024-06-03" || resp.Seed != 42 {
+			t.Errorf("%s: date %q seed %d, want 2024-06-03 seed 42", tt.name, resp.Date, resp.Seed)
+		}
+	}
+}
+
+func TestReplayMatchesAPI(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	defer func(all bool) { useAllMarkets = all }(useAllMarkets)
+
+	if got := newSeedSource(0, true).next(); got != 0 {
+		t.Errorf("seed 0 eksplisit diganti menjadi %d", got)
+	}
+
+	useAllMarkets = true
+	s := &apiServer{src: dataSources{
+		profile:    "default",
+		watchlists: map[string]map[string][]string{"default": {"bank": {"BBCA", "BMRI", "BBRI", "BBNI"}}},
+		seeds:      newSeedSource(0, true),
+	}}
+	s.refresh()
+	h := s.routes()
+
+	for _, target := range []string{
+		"/scan/foreign/buy?min_score=40&top=5",
+		"/scan/foreign/sell?min_strength=3",
+		"/scan/foreign/buy?watchlist=bank",
+	} {
+		useAllMarkets = true
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		useAllMarkets = false
+		var saved replayFile
+		if err := json.Unmarshal(rec.Body.Bytes(), &saved); err != nil || saved.Meta == nil {
+			t.Fatalf("%s: respons tanpa meta: %v %s", target, err, rec.Body)
+		}
+		if saved.Meta.Seed != 0 || !saved.Meta.AllMarket || saved.Meta.Filter == nil {
+			t.Errorf("%s: meta tidak lengkap: %+v", target, *saved.Meta)
+		}
+
+		var want []ScanResult
+		if err := json.Unmarshal(saved.Results, &want); err != nil {
+			t.Fatal(err)
+		}
+		snap, err := replaySnapshot(*saved.Meta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stocks := scopeStocks(snap.Stocks, saved.Meta.scope())
+		got := scanNetForeignBuy(stocks)
+		if saved.Meta.Scan == "sell" {
+			got = scanNetForeignSell(stocks)
+		}
+		if diffs := compareResults(saved.Meta.Scan, want, filterResults(got, *saved.Meta.Filter)); len(diffs) > 0 || len(want) == 0 {
+			t.Errorf("%s: replay berbeda (%d hasil asli): %v", target, len(want), diffs)
+		}
+	}
+}
+
+func TestLoadBrokerSummary(t *testing.T) {
+	header := "symbol,broker,buy_lot,buy_avg,sell_lot,sell_avg\n"
+	tests := []struct {
+		name string
+		body string
+		want []BrokerActivity
+		err  bool
+	}{
+		{"normal", header + "bbca, ak ,500,9800,100,9850\nTLKM,YP,0,0,300,3870\n",
+			[]BrokerActivity{{"BBCA", "AK", 500, 9800, 100, 9850}, {"TLKM", "YP", 0, 0, 300, 3870}}, false},
+		{"urutan kolom bebas", "Broker,Symbol,Sell_Lot,Sell_Avg,Buy_Lot,Buy_Avg\nCC,BBCA,10,9900,20,9800\n",
+			[]BrokerActivity{{"BBCA", "CC", 20, 9800, 10, 9900}}, false},
+		{"broker ganda digabung", header + "BBCA,AK,100,9800,0,0\nBBCA,ZP,50,9900,0,0\nbbca,AK,300,9900,200,9950\n",
+			[]BrokerActivity{{"BBCA", "AK", 400, 9875, 200, 9950}, {"BBCA", "ZP", 50, 9900, 0, 0}}, false},
+		{"lot nol dilewati", header + "BBCA,AK,0,0,0,0\nBBCA,CC,10,9800,0,0\n",
+			[]BrokerActivity{{"BBCA", "CC", 10, 9800, 0, 0}}, false},
+		{"lot bukan angka", header + "BBCA,AK,banyak,9800,0,0\n", nil, true},
+		{"harga bukan angka", header + "BBCA,AK,10,x,0,0\n", nil, true},
+		{"lot negatif", header + "BBCA,AK,-10,9800,0,0\n", nil, true},
+		{"harga negatif", header + "BBCA,AK,10,-9800,0,0\n", nil, true},
+		{"broker kosong", header + "BBCA,,10,9800,0,0\n", nil, true},
+		{"kolom kurang", header + "BBCA,AK,10,9800,0\n", nil, true},
+		{"kolom hilang", "symbol,broker,buy_lot,buy_avg,sell_lot\nBBCA,AK,10,9800,0\n", nil, true},
+		{"tanpa data", header, nil, true},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "broker.csv")
+		if err := os.WriteFile(path, []byte(tt.body), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := loadBrokerSummary(path)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: err %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func bandarFixture() ([]StockData, []BrokerActivity) {
+	stocks := []StockData{
+		{Symbol: "BBCA", Name: "Bank Central Asia", Sector: "Banking", ClosePrice: 9850},
+		{Symbol: "TLKM", Name: "Telkom Indonesia", Sector: "Telco", ClosePrice: 3870},
+		{Symbol: "UNVR", Name: "Unilever Indonesia", Sector: "Consumer", ClosePrice: 2650},
+	}
+	activity := []BrokerActivity{
+		{"BBCA", "AK", 5000, 9800, 1000, 9900},
+		{"BBCA", "CC", 3000, 9700, 500, 9800},
+		{"BBCA", "ZP", 2000, 10000, 1000, 9950},
+		{"BBCA", "NI", 1000, 9750, 800, 9800},
+		{"BBCA", "YP", 500, 9900, 3500, 9850},
+		{"BBCA", "XL", 500, 9900, 3700, 9850},
+		{"BBCA", "KI", 0, 0, 500, 9850},
+		{"TLKM", "YP", 1000, 3880, 0, 0},
+		{"TLKM", "AK", 0, 0, 3000, 3870},
+	}
+	return stocks, activity
+}
+
+func TestAnalyzeBandar(t *testing.T) {
+	stocks, activity := bandarFixture()
+	results := analyzeBandar(stocks, activity)
+	if len(results) != 2 {
+		t.Fatalf("%d hasil, want 2 (UNVR tanpa broker summary dilewati)", len(results))
+	}
+
+	bbca := results[0]
+	accum := (5000*9800.0 + 3000*9700.0 + 2000*10000.0 + 1000*9750.0) / 11000
+	want := BandarResult{
+		Symbol: "BBCA", Name: "Bank Central Asia", Sector: "Banking", Price: 9850,
+		TotalBuyLot:   12000,
+		TopBuyers:     []string{"AK", "CC", "ZP"},
+		Concentration: 10000.0 / 12000 * 100,
+		NetTop3:       (4000 - 3200) + (2500 - 3000) + (1000 - 500),
+		NetTop5:       (4000 - 3200) + (2500 - 3000) + (1000 - 500) + 200,
+		NetForeign:    5000,
+		NetDomestic:   2200,
+		NetRetail:     -6200,
+		AccumPrice:    accum,
+		PriceVsAccum:  (9850 - accum) / accum * 100,
+		Score:         20 + 10 + 25 + 12 + 15 + 10,
+		Signal:        "BIG ACCUM",
+	}
+	if math.Abs(bbca.Concentration-want.Concentration) > 1e-9 || math.Abs(bbca.AccumPrice-want.AccumPrice) > 1e-9 ||
+		math.Abs(bbca.PriceVsAccum-want.PriceVsAccum) > 1e-9 {
+		t.Errorf("BBCA: kons %.4f accum %.4f vs %.4f, want %.4f %.4f %.4f",
+			bbca.Concentration, bbca.AccumPrice, bbca.PriceVsAccum, want.Concentration, want.AccumPrice, want.PriceVsAccum)
+	}
+	bbca.Concentration, bbca.AccumPrice, bbca.PriceVsAccum = want.Concentration, want.AccumPrice, want.PriceVsAccum
+	if fmt.Sprint(bbca) != fmt.Sprint(want) {
+		t.Errorf("BBCA:\n got %+v\nwant %+v", bbca, want)
+	}
+
+	tlkm := results[1]
+	if tlkm.NetTop3 != -2000 || tlkm.NetTop5 != -2000 || tlkm.Concentration != 100 || tlkm.AccumPrice != 3880 || tlkm.Signal != "DISTRIBUTE" {
+		t.Errorf("TLKM: %+v", tlkm)
+	}
+
+	scanned := scanBandarAccumulation(stocks, activity)
+	if len(scanned) != 1 || scanned[0].Symbol != "BBCA" {
+		t.Errorf("scan bandar = %v, want hanya BBCA", scanned)
+	}
+}
+
+func flowHistory(values ...float64) []FlowDay {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	history := make([]FlowDay, len(values))
+	for i, v := range values {
+		history[i] = FlowDay{Date: start.AddDate(0, 0, i), NetForeignValue: v, ForeignPercent: 40 + float64(i)}
+	}
+	return history
+}
+
+func TestComputeFlowStats(t *testing.T) {
+	history := flowHistory(1e9, 2e9, 3e9, 4e9, 5e9, 6e9)
+
+	stats := computeFlowStats(history, 5, 4)
+	std := math.Sqrt((1.5*1.5 + 0.5*0.5 + 0.5*0.5 + 1.5*1.5) / 3)
+	if math.Abs(stats.NetValueMean-3.5e9) > 1e-3 || math.Abs(stats.NetValueStd-std*1e9) > 1e-3 {
+		t.Errorf("rolling mean/std = %.0f / %.0f, want 3.5e9 / %.0f", stats.NetValueMean, stats.NetValueStd, std*1e9)
+	}
+	if math.Abs(stats.NetValueZ-2.5/std) > 1e-9 || math.Abs(stats.ForeignPctZ-2.5/std) > 1e-9 {
+		t.Errorf("z = %.6f / %.6f, want %.6f", stats.NetValueZ, stats.ForeignPctZ, 2.5/std)
+	}
+
+	tests := []struct {
+		name  string
+		today float64
+		pctl  float64
+	}{
+		{"tertinggi", 9e9, 100},
+		{"terendah", -1e9, 0},
+		{"sama dengan terendah", 2e9, 0},
+		{"di tengah", 3.5e9, 50},
+	}
+	for _, tt := range tests {
+		history[5].NetForeignValue = tt.today
+		if got := computeFlowStats(history, 5, 4).NetValuePctl; got != tt.pctl {
+			t.Errorf("persentil %s = %.0f, want %.0f", tt.name, got, tt.pctl)
+		}
+	}
+
+	if got := computeFlowStats(history, 1, 4); got != (FlowStats{}) {
+		t.Errorf("riwayat satu hari = %+v, want kosong", got)
+	}
+
+	flat := flowHistory(0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1, 0.1)
+	for _, today := range []float64{0.1, 0.1000001, 0} {
+		flat[20].NetForeignValue = today
+		stats := computeFlowStats(flat, 20, flowWindow)
+		for _, v := range []float64{stats.NetValueMean, stats.NetValueStd, stats.NetValueZ, stats.NetValuePctl} {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				t.Fatalf("variansi nol menghasilkan %+v", stats)
+			}
+		}
+		if stats.NetValueZ != 0 {
+			t.Errorf("variansi nol, hari ini %v: z = %v, want 0", today, stats.NetValueZ)
+		}
+	}
+}
+
+func TestScoringFlow(t *testing.T) {
+	defer func(all bool) { useAllMarkets = all }(useAllMarkets)
+
+	s := StockData{ClosePrice: 1000, NetForeignBuy: 5000, NetForeignValue: 5e6,
+		Regular: MarketFlow{ForeignBuy: 3000, ForeignSell: 2000}, Negotiated: MarketFlow{ForeignBuy: 4000}}
+
+	useAllMarkets = false
+	if net, value := scoringFlow(s); net != 1000 || value != 1e6 {
+		t.Errorf("pasar reguler = %d / %.0f, want 1000 / 1000000", net, value)
+	}
+	useAllMarkets = true
+	if net, value := scoringFlow(s); net != 5000 || value != 5e6 {
+		t.Errorf("semua pasar = %d / %.0f, want 5000 / 5000000", net, value)
+	}
+}
+
+func TestScanUnusualForeign(t *testing.T) {
+	values := make([]float64, flowWindow+unusualLookback)
+	for i := range values {
+		values[i] = 1e9
+		if i%2 == 1 {
+			values[i] = -1e9
+		}
+	}
+	last := len(values) - 1
+	std := computeFlowStats(flowHistory(values...), last, flowWindow).NetValueStd
+
+	stock := func(symbol string, today float64) StockData {
+		v := append([]float64(nil), values...)
+		v[last] = today
+		return StockData{Symbol: symbol, FlowHistory: flowHistory(v...)}
+	}
+	flat := make([]float64, len(values))
+	for i := range flat {
+		flat[i] = 5e8
+	}
+
+	stocks := []StockData{
+		stock("PAS2", 2*std),
+		stock("JUAL", -2.5*std),
+		stock("BELI", 3*std),
+		{Symbol: "DATR", FlowHistory: flowHistory(flat...)},
+		{Symbol: "BARU", FlowHistory: flowHistory(values[:flowWindow]...)},
+	}
+	stocks[3].FlowHistory[last].NetForeignValue = 5e8 + 1
+
+	var got []string
+	for _, r := range scanUnusualForeign(stocks) {
+		got = append(got, fmt.Sprintf("%s %.1f", r.Symbol, r.NetValueZ))
+		if !r.Date.Equal(stocks[0].FlowHistory[last].Date) {
+			t.Errorf("%s ditandai di %s, want hari terakhir", r.Symbol, r.Date.Format("2006-01-02"))
+		}
+	}
+	if want := "[BELI 3.0 JUAL -2.5]"; fmt.Sprint(got) != want {
+		t.Errorf("unusual = %v, want %s (|z| tepat 2 tidak ikut)", got, want)
+	}
+}
+
+func TestLoadOwnership(t *testing.T) {
+	const header = "month,symbol,local_id,local_mf,foreign_is,foreign_mf\n"
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "dua bulan dari dua file",
+			files: map[string]string{
+				"ksei_2024-02.csv": header + "2024-02,bbca,600,200,150,50\n",
+				"ksei_2024-01.csv": header + "2024-01, BBCA ,700,100,100,100\n2024-01,TLKM,900,0,100,0\n",
+			},
+			want: "[BBCA 2024-01 L800 F200 TLKM 2024-01 L900 F100 BBCA 2024-02 L800 F200]",
+		},
+		{
+			name:    "format bulan salah",
+			files:   map[string]string{"ksei.csv": header + "01/2024,BBCA,1,1,1,1\n"},
+			wantErr: true,
+		},
+		{
+			name:    "jumlah saham bukan angka",
+			files:   map[string]string{"ksei.csv": header + "2024-01,BBCA,1,x,1,1\n"},
+			wantErr: true,
+		},
+		{
+			name:    "jumlah saham negatif",
+			files:   map[string]string{"ksei.csv": header + "2024-01,BBCA,1,1,-5,1\n"},
+			wantErr: true,
+		},
+		{
+			name:    "kolom kurang",
+			files:   map[string]string{"ksei.csv": "month,local_id,foreign_is\n2024-01,1,1\n"},
+			wantErr: true,
+		},
+		{
+			name:    "hanya header",
+			files:   map[string]string{"ksei.csv": header},
+			wantErr: true,
+		},
+		{
+			name:    "tidak ada file",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+		for name, body := range tt.files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		records, err := loadOwnership(filepath.Join(dir, "ksei*.csv"))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: error = nil, want error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: error = %v", tt.name, err)
+			continue
+		}
+
+		var got []string
+		for _, r := range records {
+			var local, foreign int64
+			for _, v := range r.Local {
+				local += v
+			}
+			for _, v := range r.Foreign {
+				foreign += v
+			}
+			got = append(got, fmt.Sprintf("%s %s L%d F%d", r.Symbol, r.Month.Format("2006-01"), local, foreign))
+		}
+		if fmt.Sprint(got) != tt.want {
+			t.Errorf("%s: records = %v, want %s", tt.name, got, tt.want)
+		}
+		if records[0].Foreign["IS"] != 100 || records[0].Local["MF"] != 100 {
+			t.Errorf("%s: tipe investor = %v/%v, want IS 100 dan MF 100", tt.name, records[0].Foreign, records[0].Local)
+		}
+	}
+}
+
+func TestBuildOwnershipTrends(t *testing.T) {
+	record := func(symbol, month string, local int64, foreign map[string]int64) OwnershipRecord {
+		m, err := time.Parse("2006-01", month)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return OwnershipRecord{Symbol: symbol, Month: m, Local: map[string]int64{"ID": local}, Foreign: foreign}
+	}
+
+	trends := buildOwnershipTrends([]OwnershipRecord{
+		record("NAIK", "2024-03", 6990, map[string]int64{"IS": 2010, "MF": 1000}),
+		record("NAIK", "2024-01", 9000, map[string]int64{"IS": 1000}),
+		record("NAIK", "2024-02", 7000, map[string]int64{"IS": 2000, "MF": 1000}),
+		record("TURUN", "2024-01", 7000, map[string]int64{"IS": 3000}),
+		record("TURUN", "2024-02", 7010, map[string]int64{"IS": 990, "PF": 2000}),
+		record("STABIL", "2024-01", 7000, map[string]int64{"IS": 3000}),
+		record("STABIL", "2024-02", 7009, map[string]int64{"IS": 2991}),
+		record("SATU", "2024-05", 500, map[string]int64{"MF": 500}),
+		record("KOSONG", "2024-05", 0, nil),
+		record("ULANG", "2024-01", 7000, map[string]int64{"IS": 3000}),
+		record("ULANG", "2024-02", 1000, map[string]int64{"IS": 9000}),
+		record("ULANG", "2024-02", 7000, map[string]int64{"IS": 3000}),
+	})
+
+	tests := []struct {
+		symbol     string
+		month      string
+		foreignPct float64
+		change     float64
+		trend      string
+		top        string
+	}{
+		{"NAIK", "2024-03", 30.1, 0.1, "NAIK", "IS"},
+		{"TURUN", "2024-02", 29.9, -0.1, "TURUN", "PF"},
+		{"STABIL", "2024-02", 29.91, -0.09, "STABIL", "IS"},
+		{"SATU", "2024-05", 50, 0, "STABIL", "MF"},
+		{"KOSONG", "2024-05", 0, 0, "STABIL", ""},
+		{"ULANG", "2024-02", 30, 0, "STABIL", "IS"},
+	}
+
+	if len(trends) != len(tests) {
+		t.Errorf("jumlah tren = %d, want %d", len(trends), len(tests))
+	}
+	for _, tt := range tests {
+		got, ok := trends[tt.symbol]
+		if !ok || !got.Available {
+			t.Errorf("%s: tren tidak tersedia", tt.symbol)
+			continue
+		}
+		if got.Month.Format("2006-01") != tt.month {
+			t.Errorf("%s: bulan = %s, want %s", tt.symbol, got.Month.Format("2006-01"), tt.month)
+		}
+		if math.Abs(got.ForeignPct-tt.foreignPct) > 1e-9 || math.Abs(got.ChangeMoM-tt.change) > 1e-9 {
+			t.Errorf("%s: asing = %.4f%% (MoM %.4f), want %.4f%% (MoM %.4f)", tt.symbol, got.ForeignPct, got.ChangeMoM, tt.foreignPct, tt.change)
+		}
+		if got.Trend != tt.trend || got.TopForeign != tt.top {
+			t.Errorf("%s: tren = %s top %q, want %s top %q", tt.symbol, got.Trend, got.TopForeign, tt.trend, tt.top)
+		}
+	}
+}
+
+func TestAttachOwnership(t *testing.T) {
+	history := func(today float64) []FlowDay {
+		values := make([]float64, flowWindow+5)
+		for i := range values {
+			values[i] = -1e12
+			if i >= 5 {
+				values[i] = 1e9
+			}
+		}
+		values[len(values)-1] = today
+		return flowHistory(values...)
+	}
+	trend := func(name string) OwnershipTrend {
+		return OwnershipTrend{Available: true, Trend: name}
+	}
+
+	stocks := []StockData{
+		{Symbol: "BELI", FlowHistory: history(1e9)},
+		{Symbol: "JUAL", FlowHistory: history(-1e11)},
+		{Symbol: "SEARAH", FlowHistory: history(1e9)},
+		{Symbol: "DATAR", FlowHistory: history(-19e9)},
+		{Symbol: "PENDEK", FlowHistory: flowHistory(-1e9, -2e9)},
+		{Symbol: "TANPA", FlowHistory: history(1e9)},
+	}
+	attachOwnership(stocks, map[string]OwnershipTrend{
+		"BELI":   trend("TURUN"),
+		"JUAL":   trend("NAIK"),
+		"SEARAH": trend("NAIK"),
+		"DATAR":  trend("TURUN"),
+		"PENDEK": trend("TURUN"),
+	})
+
+	tests := []struct {
+		flow       float64
+		divergence bool
+		available  bool
+	}{
+		{20e9, true, true},
+		{-81e9, true, true},
+		{20e9, false, true},
+		{0, false, true},
+		{-3e9, false, true},
+		{0, false, false},
+	}
+	for i, tt := range tests {
+		got := stocks[i].Ownership
+		if got.Available != tt.available || got.MonthlyFlow != tt.flow || got.Divergence != tt.divergence {
+			t.Errorf("%s: tersedia %v flow %.0f divergen %v, want %v %.0f %v",
+				stocks[i].Symbol, got.Available, got.MonthlyFlow, got.Divergence, tt.available, tt.flow, tt.divergence)
+		}
+	}
+}
+
+func TestNegotiatedShare(t *testing.T) {
+	tests := []struct {
+		name              string
+		regular, ng, cash MarketFlow
+		share             float64
+		dominant          bool
+	}{
+		{"tanpa flow", MarketFlow{}, MarketFlow{}, MarketFlow{}, 0, false},
+		{"hanya reguler", MarketFlow{ForeignBuy: 500}, MarketFlow{}, MarketFlow{}, 0, false},
+		{"crossing NG", MarketFlow{ForeignBuy: 100}, MarketFlow{ForeignBuy: 900}, MarketFlow{}, 90, true},
+		{"tepat separuh", MarketFlow{ForeignBuy: 400}, MarketFlow{ForeignSell: 500}, MarketFlow{ForeignBuy: 100}, 50, false},
+		{"arah berlawanan dihitung absolut", MarketFlow{ForeignSell: 300}, MarketFlow{ForeignBuy: 600, ForeignSell: 100}, MarketFlow{ForeignSell: 100}, 500.0 / 900 * 100, true},
+		{"NG net nol", MarketFlow{ForeignBuy: 10}, MarketFlow{ForeignBuy: 1000, ForeignSell: 1000}, MarketFlow{}, 0, false},
+	}
+
+	for _, tt := range tests {
+		share, dominant := negotiatedShare(tt.regular, tt.ng, tt.cash)
+		if math.Abs(share-tt.share) > 1e-9 || dominant != tt.dominant {
+			t.Errorf("%s: share = %.2f dominan %v, want %.2f %v", tt.name, share, dominant, tt.share, tt.dominant)
+		}
+	}
+}
+
+func TestScanNetForeignBuyNegotiated(t *testing.T) {
+	defer func(all bool) { useAllMarkets = all }(useAllMarkets)
+
+	stock := func(symbol string, regular, negotiated MarketFlow) StockData {
+		s := StockData{
+			Symbol:        symbol,
+			ClosePrice:    1000,
+			ChangePercent: 1.5,
+			Accumulation:  5,
+			Regular:       regular,
+			Negotiated:    negotiated,
+			NetForeignBuy: netFlow(regular) + netFlow(negotiated),
+		}
+		s.NetForeignValue = float64(s.NetForeignBuy) * s.ClosePrice
+		s.NGShare, s.NGDominant = negotiatedShare(regular, negotiated, MarketFlow{})
+
+		values := make([]float64, flowWindow+5)
+		for i := range values {
+			values[i] = 1e9
+			if i%2 == 1 {
+				values[i] = -1e9
+			}
+		}
+		_, values[len(values)-1] = scoringFlow(s)
+		s.FlowHistory = flowHistory(values...)
+		s.Flow = computeFlowStats(s.FlowHistory, len(values)-1, flowWindow)
+		return s
+	}
+
+	tests := []struct {
+		allMarket bool
+		want      string
+	}{
+		{false, "[RGLR 3000 STRONG BUY false]"},
+		{true, "[CROS 49800 STRONG BUY true RGLR 3000 STRONG BUY false]"},
+	}
+	for _, tt := range tests {
+		useAllMarkets = tt.allMarket
+		stocks := []StockData{
+			stock("CROS", MarketFlow{ForeignBuy: 1000000, ForeignSell: 1200000}, MarketFlow{ForeignBuy: 50000000}),
+			stock("RGLR", MarketFlow{ForeignBuy: 8000000, ForeignSell: 5000000}, MarketFlow{}),
+		}
+		scoreStocks(stocks, nil)
+
+		var got []string
+		for _, r := range scanNetForeignBuy(stocks) {
+			got = append(got, fmt.Sprintf("%s %d %s %v", r.Symbol, r.NetForeignBuy/1000, r.Signal, r.NGDominant))
+		}
+		if fmt.Sprint(got) != tt.want {
+			t.Errorf("allmarket=%v: buy = %v, want %s", tt.allMarket, got, tt.want)
+		}
+		if !stocks[0].NGDominant || stocks[1].NGDominant {
+			t.Errorf("allmarket=%v: marker NG = %v/%v, want true/false", tt.allMarket, stocks[0].NGDominant, stocks[1].NGDominant)
+		}
+	}
+}
+
+func sectorFlowFixture() ([]StockData, IndexData) {
+	stocks := []StockData{
+		{Symbol: "BBCA", Sector: "Banking", ClosePrice: 1000, ChangePercent: 2, Change5D: 4, Change20D: 3,
+			Regular: MarketFlow{ForeignBuy: 9000000, ForeignSell: 1000000}, Negotiated: MarketFlow{ForeignBuy: 100000000}},
+		{Symbol: "BMRI", Sector: "Banking", ClosePrice: 1000, ChangePercent: 1, Change5D: 3, Change20D: 3,
+			Regular: MarketFlow{ForeignBuy: 5000000, ForeignSell: 1000000}},
+		{Symbol: "BBNI", Sector: "Banking", ClosePrice: 1000, Change5D: 2, Change20D: 3},
+		{Symbol: "ANTM", Sector: "Mining", ClosePrice: 1000, ChangePercent: -2, Change5D: -3,
+			Regular: MarketFlow{ForeignBuy: 1000000, ForeignSell: 3000000}},
+		{Symbol: "INCO", Sector: "Mining", ClosePrice: 1000, ChangePercent: -3, Change5D: -5, Change20D: 2},
+		{Symbol: "TLKM", Sector: "Telco", ClosePrice: 1000, ChangePercent: 0.5, Change5D: 1, Change20D: 2,
+			Regular: MarketFlow{ForeignBuy: 1000000}},
+	}
+	for i := range stocks {
+		s := &stocks[i]
+		s.NetForeignBuy = netFlow(s.Regular) + netFlow(s.Negotiated)
+		s.NetForeignValue = float64(s.NetForeignBuy) * s.ClosePrice
+	}
+	return stocks, IndexData{Change1D: 0.5, Change5D: 1, Change20D: 2}
+}
+
+func TestAnalyzeSectorFlow(t *testing.T) {
+	defer func(all bool) { useAllMarkets = all }(useAllMarkets)
+	useAllMarkets = false
+
+	stocks, ihsg := sectorFlowFixture()
+	sectors := analyzeSectorFlow(stocks, ihsg)
+
+	tests := []struct {
+		sector          string
+		count, adv, dec int
+		breadth         float64
+		rs1, rs5, rs20  float64
+		net             float64
+		rotation        float64
+		status          string
+		bonus           float64
+	}{
+		{"Telco", 1, 1, 0, 100, 0, 0, 0, 1e9, 2.5, "NETRAL", 2},
+		{"Banking", 3, 2, 0, 200.0 / 3, 0.5, 2, 1, 12e9, 0.25 + 0.6 - 0.1 + 2.0/3 + 0.5, "ROTASI MASUK", 10},
+		{"Mining", 2, 0, 2, 0, -3, -5, -1, -2e9, -1.5 - 1.5 + 0.1 - 2 - 0.5, "ROTASI KELUAR", -6},
+	}
+
+	if len(sectors) != len(tests) {
+		t.Fatalf("jumlah sektor = %d, want %d", len(sectors), len(tests))
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	for i, tt := range tests {
+		f := sectors[i]
+		if f.Sector != tt.sector {
+			t.Errorf("rank %d = %s, want %s", i+1, f.Sector, tt.sector)
+			continue
+		}
+		if f.Count != tt.count || f.Advancers != tt.adv || f.Decliners != tt.dec || !near(f.Breadth, tt.breadth) {
+			t.Errorf("%s: jml %d adv/dec %d/%d breadth %.2f, want %d %d/%d %.2f",
+				tt.sector, f.Count, f.Advancers, f.Decliners, f.Breadth, tt.count, tt.adv, tt.dec, tt.breadth)
+		}
+		if !near(f.RS1D, tt.rs1) || !near(f.RS5D, tt.rs5) || !near(f.RS20D, tt.rs20) {
+			t.Errorf("%s: RS = %.2f/%.2f/%.2f, want %.2f/%.2f/%.2f", tt.sector, f.RS1D, f.RS5D, f.RS20D, tt.rs1, tt.rs5, tt.rs20)
+		}
+		if f.NetForeignValue != tt.net || !near(f.Rotation, tt.rotation) || f.Status != tt.status {
+			t.Errorf("%s: net %.0f rotasi %.3f %s, want %.0f %.3f %s",
+				tt.sector, f.NetForeignValue, f.Rotation, f.Status, tt.net, tt.rotation, tt.status)
+		}
+		if got := sectorBonus(f); got != tt.bonus {
+			t.Errorf("%s: bonus = %.0f, want %.0f", tt.sector, got, tt.bonus)
+		}
+	}
+
+	scoreStocks(stocks, sectors)
+	for _, s := range stocks {
+		if want := map[string]float64{"Banking": 10, "Mining": -6, "Telco": 2}[s.Sector]; s.SectorBonus != want {
+			t.Errorf("%s: bonus sektor = %.0f, want %.0f", s.Symbol, s.SectorBonus, want)
+		}
+	}
+
+	useAllMarkets = true
+	for _, f := range analyzeSectorFlow(stocks, ihsg) {
+		if f.Sector == "Banking" && f.NetForeignValue != 112e9 {
+			t.Errorf("allmarket: net Banking = %.0f, want 112000000000 (termasuk NG)", f.NetForeignValue)
+		}
+	}
+}
+
+func TestGoldenSectorFlow(t *testing.T) {
+	defer func(all bool) { useAllMarkets = all }(useAllMarkets)
+	useAllMarkets = false
+
+	stocks, ihsg := sectorFlowFixture()
+	checkGolden(t, "rotation", captureStdout(t, func() { printSectorFlow(analyzeSectorFlow(stocks, ihsg), ihsg) }))
+}
+
+func TestReplayAsOf(t *testing.T) {
+	defer func(all bool) { useAllMarkets = all }(useAllMarkets)
+	defer func(rules FundamentalRules) { fundamentalRules = rules }(fundamentalRules)
+
+	meta := ScanMeta{Seed: 42, AsOf: "2024-06-03"}
+	snap, err := replaySnapshot(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := replaySnapshot(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history := snap.Stocks[0].FlowHistory
+	if got := history[len(history)-1].Date.Format("2006-01-02"); got != "2024-06-03" || snap.Meta.AsOf != "2024-06-03" {
+		t.Errorf("hari terakhir flow = %s (meta %s), want 2024-06-03", got, snap.Meta.AsOf)
+	}
+	if got := snap.Stocks[0].Ownership.Month.Format("2006-01"); got != "2024-05" {
+		t.Errorf("bulan KSEI simulasi = %s, want 2024-05", got)
+	}
+	if fmt.Sprint(scanUnusualForeign(snap.Stocks)) != fmt.Sprint(scanUnusualForeign(again.Stocks)) {
+		t.Error("replay dengan tanggal yang sama menghasilkan aktivitas tidak biasa yang berbeda")
+	}
+
+	for _, asOf := range []string{"", "03-06-2024"} {
+		if _, err := replaySnapshot(ScanMeta{Seed: 42, AsOf: asOf}); err == nil {
+			t.Errorf("tanggal %q diterima, want error", asOf)
+		}
+	}
+}
+
+func runTUIKeys(t *testing.T, keys string) (*tui, string) {
+	t.Helper()
+
+	stocks := fixedStocks()
+	ui := newTUI("TES", 112, stockViews(stocks, scanNetForeignBuy(stocks), scanNetForeignSell(stocks), chartStyle{}), bufio.NewReader(strings.NewReader(keys)))
+	var action string
+	captureStdout(t, func() { action, _ = ui.run() })
+	return ui, action
+}
+
+func tuiSymbols(ui *tui) []string {
+	var out []string
+	for _, i := range ui.rows {
+		out = append(out, ui.views[ui.active].Symbol(i))
+	}
+	return out
+}
+
+func TestTUIKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    string
+		action  string
+		view    string
+		symbols []string
+	}{
+		{"awal", "q", "q", "BUY", []string{"BBCA", "BMRI"}},
+		{"pindah tab", "\tq", "q", "SELL", []string{"TLKM", "ANTM"}},
+		{"semua saham", "\t\tq", "q", "SEMUA", []string{"BBCA", "BMRI", "TLKM", "ANTM", "UNVR"}},
+		{"urut kode", "\t\t>q", "q", "SEMUA", []string{"ANTM", "BBCA", "BMRI", "TLKM", "UNVR"}},
+		{"urut kode terbalik", "\t\t>sq", "q", "SEMUA", []string{"UNVR", "TLKM", "BMRI", "BBCA", "ANTM"}},
+		{"cari", "\t\t/bm\rq", "q", "SEMUA", []string{"BMRI"}},
+		{"cari lalu batal", "\t\t/bm\x1bq", "q", "SEMUA", []string{"BBCA", "BMRI", "TLKM", "ANTM", "UNVR"}},
+		{"filter sektor", "\t\tfq", "q", "SEMUA", []string{"BBCA", "BMRI"}},
+		{"scan ulang", "r", "r", "BUY", []string{"BBCA", "BMRI"}},
+		{"menu", "\tm", "m", "SELL", nil},
+		{"input habis", "\t", "q", "SELL", nil},
+	}
+
+	for _, tt := range tests {
+		ui, action := runTUIKeys(t, tt.keys)
+		if action != tt.action || ui.views[ui.active].Name != tt.view {
+			t.Errorf("%s: aksi %q tab %s, want %q %s", tt.name, action, ui.views[ui.active].Name, tt.action, tt.view)
+			continue
+		}
+		if tt.symbols != nil && fmt.Sprint(tuiSymbols(ui)) != fmt.Sprint(tt.symbols) {
+			t.Errorf("%s: baris %v, want %v", tt.name, tuiSymbols(ui), tt.symbols)
+		}
+	}
+
+	help := captureStdout(t, func() { newTUI("TES", 112, stockViews(fixedStocks(), nil, nil, chartStyle{}), nil).render(30) })
+	if bytes.Contains(help, []byte("c grafik")) {
+		t.Error("bantuan menampilkan tombol grafik padahal tabel tidak punya grafik")
+	}
+}
+
+func TestTUISharedWithEmiten(t *testing.T) {
+	names := []string{"tuiColumn", "tuiView", "tuiState", "tui", "isTerminal", "readLine", "rawModeAvailable", "stty",
+		"terminalHeight", "newTUI", "enterRaw", "leaveRaw", "readKey", "filter", "padCell", "render", "run", "changeColor", "breakdownLine"}
+
+	decls := func(path string) map[string]string {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make(map[string]string)
+		for _, d := range file.Decls {
+			var name string
+			switch d := d.(type) {
+			case *ast.FuncDecl:
+				name = d.Name.Name
+			case *ast.GenDecl:
+				if s, ok := d.Specs[0].(*ast.TypeSpec); ok && d.Tok == token.TYPE {
+					name = s.Name.Name
+				}
+			}
+			var b bytes.Buffer
+			if err := printer.Fprint(&b, fset, d); err != nil {
+				t.Fatal(err)
+			}
+			out[name] = b.String()
+		}
+		return out
+	}
+
+	foreign, emiten := decls("net_foreign_scanner.go"), decls("emiten_scanner.go")
+	for _, name := range names {
+		if foreign[name] == "" || foreign[name] != emiten[name] {
+			t.Errorf("%s berbeda antara net_foreign_scanner.go dan emiten_scanner.go, salin versi yang sama ke kedua file", name)
+		}
+	}
+}